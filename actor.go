@@ -0,0 +1,675 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrActorsClosed = errors.New("rita: actors closed")
+)
+
+// Entity is a model of state for a single entity. It evolves from the
+// entity's own events and decides which events to emit given a command.
+type Entity interface {
+	Evolver
+	Decider
+}
+
+type actorsOpts struct {
+	prefix      string
+	partitions  int
+	retries     int
+	idle        time.Duration
+	concurrency int
+	trust       bool
+}
+
+type actorsOptFn func(o *actorsOpts) error
+
+func (f actorsOptFn) actorsOpt(o *actorsOpts) error {
+	return f(o)
+}
+
+// ActorsOption is an option for the entity actors runtime.
+type ActorsOption interface {
+	actorsOpt(o *actorsOpts) error
+}
+
+// ActorPartitions sets the number of partitions entity subjects are
+// deterministically mapped to. Each partition is owned by a single instance,
+// see Listen. Default is 1.
+func ActorPartitions(n int) ActorsOption {
+	return actorsOptFn(func(o *actorsOpts) error {
+		if n < 1 {
+			return fmt.Errorf("actors: partitions must be positive")
+		}
+		o.partitions = n
+		return nil
+	})
+}
+
+// ActorSubjectPrefix sets the prefix of the subjects commands are sent on.
+// Default is "rita.actors.{store}".
+func ActorSubjectPrefix(prefix string) ActorsOption {
+	return actorsOptFn(func(o *actorsOpts) error {
+		o.prefix = prefix
+		return nil
+	})
+}
+
+// ActorRetries sets the number of times a command is decided again after a
+// sequence conflict caused by a writer outside of the actor. Default is 3.
+func ActorRetries(n int) ActorsOption {
+	return actorsOptFn(func(o *actorsOpts) error {
+		o.retries = n
+		return nil
+	})
+}
+
+// ActorIdleTimeout evicts the cached state of an entity that has not received
+// a command for the duration. Default is to never evict.
+func ActorIdleTimeout(d time.Duration) ActorsOption {
+	return actorsOptFn(func(o *actorsOpts) error {
+		o.idle = d
+		return nil
+	})
+}
+
+// ActorConcurrency sets the number of workers executing commands received by
+// Listen. Commands for the same entity subject are always handled by the same
+// worker, so they are never reordered. Default is 1. If n is zero, the number
+// of CPUs is used.
+func ActorConcurrency(n int) ActorsOption {
+	return actorsOptFn(func(o *actorsOpts) error {
+		if n < 0 {
+			return fmt.Errorf("actors: concurrency must not be negative")
+		}
+		if n == 0 {
+			n = runtime.NumCPU()
+		}
+		o.concurrency = n
+		return nil
+	})
+}
+
+// ActorsTrustIdentity propagates the actor and claims stamped in the metadata
+// of received commands to the events they decide and to the authorizer. Only
+// enable it if every client able to publish on the actor subjects is trusted
+// to assert an identity. The claims of the listener context always take
+// precedence. Default is to ignore the identity of commands.
+func ActorsTrustIdentity() ActorsOption {
+	return actorsOptFn(func(o *actorsOpts) error {
+		o.trust = true
+		return nil
+	})
+}
+
+// actor is the in-memory owner of a single entity.
+type actor struct {
+	mu     sync.Mutex
+	entity Entity
+	seq    uint64
+	seen   uint64
+	loaded bool
+
+	// refs is the number of commands holding the actor and used is when
+	// the last one released it. Both are guarded by the lock of Actors.
+	refs int
+	used time.Time
+}
+
+// Actors is a runtime of single-writer entity actors. Commands for an entity
+// subject are routed to one in-memory owner which serializes decisions and
+// keeps the evolved state cached between commands. Only events appended since
+// the last command are loaded before deciding, and the expected sequence is
+// always the one the actor has observed, so conflicts only occur if another
+// writer appends to the subject.
+type Actors struct {
+	es   *EventStore
+	init func() Entity
+	opts actorsOpts
+
+	id string
+
+	mu        sync.Mutex
+	actors    map[string]*actor
+	listeners []*listener
+	lastSweep time.Time
+}
+
+// actor returns the actor for the subject, creating it if needed. The actor
+// must be released once the command is handled.
+func (a *Actors) actor(subject string) *actor {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.es.rt.clock.Now()
+
+	// Actors held by a command are never evicted, otherwise a command
+	// waiting on the lock of an evicted actor would run concurrently with
+	// one of the actor which replaced it.
+	if a.opts.idle > 0 && now.Sub(a.lastSweep) > a.opts.idle {
+		for s, ac := range a.actors {
+			if ac.refs == 0 && now.Sub(ac.used) > a.opts.idle {
+				delete(a.actors, s)
+			}
+		}
+		a.lastSweep = now
+	}
+
+	ac, ok := a.actors[subject]
+	if !ok {
+		ac = &actor{}
+		a.actors[subject] = ac
+	}
+	ac.refs++
+
+	return ac
+}
+
+// release releases the actor held by a command.
+func (a *Actors) release(ac *actor) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ac.refs--
+	ac.used = a.es.rt.clock.Now()
+}
+
+// hydrate evolves the actor's entity with any events it has not seen yet.
+func (a *Actors) hydrate(ctx context.Context, subject string, ac *actor) error {
+	var opts []LoadOption
+	if ac.loaded {
+		opts = append(opts, AfterSequence(ac.seen))
+	} else {
+		ac.entity = a.init()
+		ac.seq = 0
+		ac.seen = 0
+	}
+
+	// Redacted events are not counted toward the expected sequence, but
+	// must not be applied again on the next command.
+	seq, seen, err := a.es.evolve(ctx, subject, ac.entity, opts...)
+	if err != nil {
+		ac.loaded = false
+		return err
+	}
+
+	if seq > 0 {
+		ac.seq = seq
+	}
+	if seen > ac.seen {
+		ac.seen = seen
+	}
+	ac.loaded = true
+
+	return nil
+}
+
+// Execute routes the command to the in-process actor for the entity subject.
+// The decided events are appended and returned along with the latest sequence
+// of the entity. If the entity rejects the command, a *Rejection error is
+// returned.
+func (a *Actors) Execute(ctx context.Context, subject string, cmd *Command) ([]*Event, uint64, error) {
+	ac := a.actor(subject)
+	defer a.release(ac)
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	for i := 0; ; i++ {
+		if err := a.hydrate(ctx, subject, ac); err != nil {
+			return nil, 0, err
+		}
+
+		events, seq, err := a.es.decide(ctx, subject, ac.entity, ac.seq, cmd)
+		if err != nil {
+			if errors.Is(err, ErrSequenceConflict) && i < a.opts.retries {
+				// The entity is fully re-hydrated, since the conflict may
+				// be caused by the last event having been redacted.
+				ac.loaded = false
+				continue
+			}
+			return nil, ac.seq, err
+		}
+
+		// Apply the appended events to the cached state. If this fails, the
+		// state is dropped and re-hydrated on the next command.
+		for _, e := range events {
+			if err := ac.entity.Evolve(e); err != nil {
+				ac.loaded = false
+				break
+			}
+		}
+		ac.seq = seq
+		ac.seen = seq
+
+		return events, seq, nil
+	}
+}
+
+// Partition returns the partition the entity subject deterministically maps to.
+func (a *Actors) Partition(subject string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(subject))
+	return int(h.Sum32() % uint32(a.opts.partitions))
+}
+
+type actorJob struct {
+	subject string
+	msg     *nats.Msg
+}
+
+// listener is the subscriptions and workers started by a call to Listen.
+type listener struct {
+	ctx context.Context
+
+	// prefixes are the command subject prefixes of the partitions, which
+	// are also the keys of the leases of claimed partitions.
+	prefixes map[int]string
+
+	// kv holds the leases of claimed partitions, or is nil if the
+	// partitions were assigned.
+	kv   nats.KeyValue
+	revs map[int]uint64
+
+	stop chan struct{}
+	done chan struct{}
+
+	mu       sync.Mutex
+	closed   bool
+	subs     map[int]*nats.Subscription
+	draining []*nats.Subscription
+
+	// workers receive the commands if the concurrency is greater than one.
+	// Dispatching holds a read lock, so the workers are only stopped once
+	// no command is being dispatched.
+	workers []chan actorJob
+	wg      sync.WaitGroup
+	wmu     sync.RWMutex
+	stopped bool
+}
+
+// dispatch hands off the command message to a worker. Messages for the
+// same entity subject are always sent to the same worker so their order is
+// preserved. A command received once the workers are stopped is replied to
+// with ErrActorsClosed.
+func (a *Actors) dispatch(l *listener, subject string, msg *nats.Msg) {
+	l.wmu.RLock()
+	defer l.wmu.RUnlock()
+
+	if l.stopped {
+		a.respond(msg, nil, 0, ErrActorsClosed)
+		return
+	}
+
+	if len(l.workers) == 0 {
+		a.handle(l.ctx, subject, msg)
+		return
+	}
+
+	// Use a different hash than the partition so entities within a
+	// partition are spread across workers.
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(subject))
+	l.workers[h.Sum64()%uint64(len(l.workers))] <- actorJob{subject, msg}
+}
+
+// startWorkers starts the workers of the listener, if the concurrency is
+// greater than one. The workers run until the listener is closed, so every
+// command received is replied to.
+func (a *Actors) startWorkers(l *listener) {
+	if a.opts.concurrency <= 1 {
+		return
+	}
+
+	l.workers = make([]chan actorJob, a.opts.concurrency)
+	for i := range l.workers {
+		ch := make(chan actorJob, 64)
+		l.workers[i] = ch
+
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			for j := range ch {
+				a.handle(l.ctx, j.subject, j.msg)
+			}
+		}()
+	}
+}
+
+// Subject returns the command subject for the entity subject.
+func (a *Actors) Subject(subject string) string {
+	return fmt.Sprintf("%s.%d.%s", a.opts.prefix, a.Partition(subject), subject)
+}
+
+// Send sends a command to the owner of the entity subject and returns the
+// appended events and the latest sequence of the entity after the command
+// was executed. If the entity rejects the command, a *Rejection error is
+// returned.
+func (a *Actors) Send(ctx context.Context, subject string, cmd *Command) ([]*Event, uint64, error) {
+	msg, err := a.es.rt.packCommand(ctx, a.Subject(subject), cmd)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rep, err := a.es.rt.nc.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return a.es.rt.unpackCommandReply(rep)
+}
+
+// Listen subscribes to the command subjects of the given partitions, or
+// claims partitions if none are specified. Each partition is a separate
+// queue group, so assigning partitions exclusively across instances results
+// in a single owner per entity.
+//
+// Claimed partitions are leased in a KV bucket, so each is owned by one
+// instance at a time. An instance claims every partition not owned by
+// another and renews its leases in the background. The partitions of a
+// failed instance are claimed by another within roughly the lease TTL. If
+// a lease is lost, the partition is no longer subscribed to. To spread
+// partitions across instances, assign them explicitly instead.
+//
+// The subscriptions are drained and the leases released when the context is
+// done or Close is called. See ActorConcurrency for executing commands
+// concurrently.
+func (a *Actors) Listen(ctx context.Context, partitions ...int) error {
+	for _, p := range partitions {
+		if p < 0 || p >= a.opts.partitions {
+			return fmt.Errorf("actors: invalid partition %d", p)
+		}
+	}
+
+	l := &listener{
+		ctx:      ctx,
+		prefixes: make(map[int]string),
+		subs:     make(map[int]*nats.Subscription),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	claim := len(partitions) == 0
+	if claim {
+		for p := 0; p < a.opts.partitions; p++ {
+			partitions = append(partitions, p)
+		}
+	}
+	for _, p := range partitions {
+		l.prefixes[p] = fmt.Sprintf("%s.%d", a.opts.prefix, p)
+	}
+
+	var interval time.Duration
+	if claim {
+		kv, err := a.es.rt.leases(defaultLeaseTTL)
+		if err != nil {
+			return err
+		}
+		interval, err = leaseInterval(kv)
+		if err != nil {
+			return err
+		}
+		l.kv = kv
+		l.revs = make(map[int]uint64)
+	}
+
+	a.startWorkers(l)
+
+	var err error
+	if claim {
+		err = a.claim(l)
+	} else {
+		l.mu.Lock()
+		for _, p := range partitions {
+			if err = a.subscribe(l, p); err != nil {
+				break
+			}
+		}
+		l.mu.Unlock()
+	}
+	if err != nil {
+		close(l.done)
+		_ = a.closeListener(l)
+		return err
+	}
+
+	a.mu.Lock()
+	a.listeners = append(a.listeners, l)
+	a.mu.Unlock()
+
+	go func() {
+		defer close(l.done)
+		if !claim {
+			return
+		}
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-t.C:
+			}
+			_ = a.claim(l)
+		}
+	}()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = a.closeListener(l)
+		case <-l.stop:
+		}
+	}()
+
+	return nil
+}
+
+// subscribe subscribes to the command subjects of the partition. The lock
+// of the listener must be held.
+func (a *Actors) subscribe(l *listener, p int) error {
+	prefix := l.prefixes[p]
+	sub, err := a.es.rt.nc.QueueSubscribe(prefix+".>", prefix, func(msg *nats.Msg) {
+		a.dispatch(l, strings.TrimPrefix(msg.Subject, prefix+"."), msg)
+	})
+	if err != nil {
+		return err
+	}
+	l.subs[p] = sub
+	return nil
+}
+
+// claim renews the leases of the partitions owned by the listener and
+// claims the partitions not owned by another instance. Partitions whose
+// lease was lost are unsubscribed.
+func (a *Actors) claim(l *listener) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+
+	for p, key := range l.prefixes {
+		if rev, ok := l.revs[p]; ok {
+			rev, err := l.kv.Update(key, []byte(a.id), rev)
+			if err == nil {
+				l.revs[p] = rev
+				continue
+			}
+
+			// The lease was lost, so another instance may own the
+			// partition.
+			delete(l.revs, p)
+			if sub := l.subs[p]; sub != nil {
+				_ = sub.Drain()
+				l.draining = append(l.draining, sub)
+				delete(l.subs, p)
+			}
+			continue
+		}
+
+		rev, err := l.kv.Create(key, []byte(a.id))
+		if err != nil {
+			continue
+		}
+		if err := a.subscribe(l, p); err != nil {
+			_ = l.kv.Delete(key, nats.LastRevision(rev))
+			return err
+		}
+		l.revs[p] = rev
+	}
+
+	return nil
+}
+
+// closeListener drains the subscriptions of the listener, waits for the
+// commands received to be handled, and releases the leases.
+func (a *Actors) closeListener(l *listener) error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.stop)
+	l.mu.Unlock()
+
+	<-l.done
+
+	l.mu.Lock()
+	subs := l.draining
+	for _, sub := range l.subs {
+		subs = append(subs, sub)
+	}
+	l.subs = nil
+	l.draining = nil
+	l.mu.Unlock()
+
+	var err error
+	for _, sub := range subs {
+		if derr := sub.Drain(); derr != nil && err == nil && !errors.Is(derr, nats.ErrBadSubscription) {
+			err = derr
+		}
+	}
+
+	// Drain is asynchronous, so wait for the pending messages to be
+	// dispatched before the workers are stopped.
+	deadline := time.Now().Add(a.es.rt.nc.Opts.DrainTimeout)
+	for _, sub := range subs {
+		for sub.IsValid() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	l.wmu.Lock()
+	l.stopped = true
+	for _, ch := range l.workers {
+		close(ch)
+	}
+	l.wmu.Unlock()
+	l.wg.Wait()
+
+	for p, rev := range l.revs {
+		_ = l.kv.Delete(l.prefixes[p], nats.LastRevision(rev))
+	}
+
+	return err
+}
+
+// Run listens on all partitions until the context is done, so actors can be
+// run as a Component. See Listen.
+func (a *Actors) Run(ctx context.Context) error {
+	if err := a.Listen(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return a.Close()
+}
+
+func (a *Actors) handle(ctx context.Context, subject string, msg *nats.Msg) {
+	var (
+		events []*Event
+		seq    uint64
+	)
+
+	cmd, err := a.es.rt.UnpackCommand(msg)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		if a.opts.trust {
+			ctx = identityContext(ctx, cmd.Meta)
+		}
+		events, seq, err = a.Execute(ctx, subject, cmd)
+	}
+
+	a.respond(msg, events, seq, err)
+}
+
+// respond replies to the command message with the result.
+func (a *Actors) respond(msg *nats.Msg, events []*Event, seq uint64, err error) {
+	data, perr := a.es.rt.packCommandReply(events, seq, err)
+	if perr != nil {
+		data, _ = a.es.rt.packCommandReply(nil, seq, perr)
+	}
+
+	_ = msg.Respond(data)
+}
+
+// Close drains the subscriptions created by Listen, waits for the commands
+// received to be handled, and releases the leases of claimed partitions.
+func (a *Actors) Close() error {
+	a.mu.Lock()
+	ls := a.listeners
+	a.listeners = nil
+	a.mu.Unlock()
+
+	var err error
+	for _, l := range ls {
+		if cerr := a.closeListener(l); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Actors returns an entity actors runtime for the event store. The init
+// function must return a new zero-state entity.
+func (s *EventStore) Actors(init func() Entity, opts ...ActorsOption) (*Actors, error) {
+	o := actorsOpts{
+		prefix:      fmt.Sprintf("rita.actors.%s", s.name),
+		partitions:  1,
+		retries:     3,
+		concurrency: 1,
+	}
+
+	for _, opt := range opts {
+		if err := opt.actorsOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	o.prefix = s.rt.subject(o.prefix)
+
+	return &Actors{
+		es:     s,
+		init:   init,
+		opts:   o,
+		id:     s.rt.id.New(),
+		actors: make(map[string]*actor),
+	}, nil
+}
@@ -0,0 +1,375 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+type PlaceOrder struct {
+	ID string
+}
+
+type ShipOrder struct {
+	ID string
+}
+
+var errOrderNotPlaced = errors.New("order not placed")
+
+type Order struct {
+	Placed  bool
+	Shipped bool
+}
+
+func (o *Order) Evolve(event *Event) error {
+	switch event.Data.(type) {
+	case *OrderPlaced:
+		o.Placed = true
+	case *OrderShipped:
+		o.Shipped = true
+	}
+	return nil
+}
+
+func (o *Order) Decide(command *Command) ([]*Event, error) {
+	switch c := command.Data.(type) {
+	case *PlaceOrder:
+		if o.Placed {
+			return nil, nil
+		}
+		return []*Event{{Data: &OrderPlaced{ID: c.ID}}}, nil
+	case *ShipOrder:
+		if !o.Placed {
+			return nil, errOrderNotPlaced
+		}
+		if o.Shipped {
+			return nil, nil
+		}
+		return []*Event{{Data: &OrderShipped{ID: c.ID}}}, nil
+	}
+	return nil, errors.New("unknown command")
+}
+
+func newOrderTypes(t *testing.T) *types.Registry {
+	tr, err := types.NewRegistry(map[string]*types.Type{
+		"order-placed": {
+			Init: func() any { return &OrderPlaced{} },
+		},
+		"order-shipped": {
+			Init: func() any { return &OrderShipped{} },
+		},
+		"place-order": {
+			Init: func() any { return &PlaceOrder{} },
+		},
+		"ship-order": {
+			Init: func() any { return &ShipOrder{} },
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestActors(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	err = es.Create(&EventStoreConfig{
+		Storage: nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	actors, err := es.Actors(func() Entity { return &Order{} }, ActorPartitions(4))
+	is.NoErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Decide in-process.
+	events, seq, err := actors.Execute(ctx, "orders.1", &Command{Data: &PlaceOrder{ID: "1"}})
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(seq, uint64(1))
+
+	// Idempotent decision based on the cached state.
+	events, seq, err = actors.Execute(ctx, "orders.1", &Command{Data: &PlaceOrder{ID: "1"}})
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+	is.Equal(seq, uint64(1))
+
+	// Append out-of-band, the actor catches up before deciding.
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	events, seq, err = actors.Execute(ctx, "orders.1", &Command{Data: &ShipOrder{ID: "1"}})
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+	is.Equal(seq, uint64(3))
+
+	// Route over NATS.
+	err = actors.Listen(ctx)
+	is.NoErr(err)
+
+	p := actors.Partition("orders.3")
+	is.True(p >= 0 && p < 4)
+	is.Equal(actors.Partition("orders.3"), p)
+
+	events, seq, err = actors.Send(ctx, "orders.3", &Command{Data: &PlaceOrder{ID: "3"}})
+	is.NoErr(err)
+	is.Equal(seq, uint64(4))
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Sequence, uint64(4))
+	is.Equal(events[0].Subject, "orders.3")
+	is.Equal(*events[0].Data.(*OrderPlaced), OrderPlaced{ID: "3"})
+
+	_, _, err = actors.Send(ctx, "orders.4", &Command{Data: &ShipOrder{ID: "4"}})
+	var rej *Rejection
+	is.True(errors.As(err, &rej))
+	is.Equal(rej.Reason, errOrderNotPlaced.Error())
+}
+
+func TestActorsConcurrency(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	err = es.Create(&EventStoreConfig{
+		Storage: nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	actors, err := es.Actors(func() Entity { return &Order{} }, ActorConcurrency(4))
+	is.NoErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = actors.Listen(ctx)
+	is.NoErr(err)
+
+	// Commands for the same entity are published without waiting so
+	// ordering relies on the dispatcher.
+	var reps []*nats.Msg
+	for i := 0; i < 10; i++ {
+		subject := fmt.Sprintf("orders.%d", i)
+
+		inbox := nats.NewInbox()
+		sub, err := nc.SubscribeSync(inbox)
+		is.NoErr(err)
+
+		for _, data := range []any{&PlaceOrder{ID: subject}, &ShipOrder{ID: subject}} {
+			msg, err := r.PackCommand(actors.Subject(subject), &Command{Data: data})
+			is.NoErr(err)
+			msg.Reply = inbox
+			is.NoErr(nc.PublishMsg(msg))
+		}
+
+		for j := 0; j < 2; j++ {
+			rep, err := sub.NextMsg(time.Second)
+			is.NoErr(err)
+			reps = append(reps, rep)
+		}
+	}
+
+	for _, rep := range reps {
+		_, _, err := r.unpackCommandReply(rep)
+		is.NoErr(err)
+	}
+}
+
+func TestActorsOwnership(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	// Use a short TTL so a released partition is claimed quickly.
+	kv, err := r.leases(time.Second)
+	is.NoErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a1, err := es.Actors(func() Entity { return &Order{} }, ActorPartitions(2))
+	is.NoErr(err)
+	is.NoErr(a1.Listen(ctx))
+
+	a2, err := es.Actors(func() Entity { return &Order{} }, ActorPartitions(2))
+	is.NoErr(err)
+	is.NoErr(a2.Listen(ctx))
+	defer a2.Close()
+
+	owners := func() map[string]string {
+		m := make(map[string]string)
+		for p := 0; p < 2; p++ {
+			e, err := kv.Get(fmt.Sprintf("%s.%d", a1.opts.prefix, p))
+			if err == nil {
+				m[fmt.Sprint(p)] = string(e.Value())
+			}
+		}
+		return m
+	}
+
+	// The first instance owns every partition.
+	is.Equal(owners(), map[string]string{"0": a1.id, "1": a1.id})
+
+	_, _, err = a2.Send(ctx, "orders.1", &Command{Data: &PlaceOrder{ID: "1"}})
+	is.NoErr(err)
+
+	a1.mu.Lock()
+	_, ok := a1.actors["orders.1"]
+	a1.mu.Unlock()
+	is.True(ok)
+
+	// The partitions are claimed by the second once released.
+	is.NoErr(a1.Close())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if o := owners(); o["0"] == a2.id && o["1"] == a2.id {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	is.Equal(owners(), map[string]string{"0": a2.id, "1": a2.id})
+
+	_, _, err = a2.Send(ctx, "orders.1", &Command{Data: &ShipOrder{ID: "1"}})
+	is.NoErr(err)
+}
+
+func TestActorsClose(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	actors, err := es.Actors(func() Entity { return &Order{} }, ActorConcurrency(4))
+	is.NoErr(err)
+	is.NoErr(actors.Listen(context.Background()))
+	l := actors.listeners[0]
+
+	inbox := nats.NewInbox()
+	sub, err := nc.SubscribeSync(inbox)
+	is.NoErr(err)
+
+	for i := 0; i < 20; i++ {
+		subject := fmt.Sprintf("orders.%d", i)
+		msg, err := r.PackCommand(actors.Subject(subject), &Command{Data: &PlaceOrder{ID: subject}})
+		is.NoErr(err)
+		msg.Reply = inbox
+		is.NoErr(nc.PublishMsg(msg))
+	}
+
+	// Commands received before closing are handled, even though the
+	// context is not done.
+	is.NoErr(actors.Close())
+
+	for i := 0; i < 20; i++ {
+		rep, err := sub.NextMsg(time.Second)
+		is.NoErr(err)
+		_, _, err = r.unpackCommandReply(rep)
+		is.NoErr(err)
+	}
+
+	// The workers are stopped.
+	is.Equal(len(actors.listeners), 0)
+	for _, ch := range l.workers {
+		_, ok := <-ch
+		is.True(!ok)
+	}
+}
+
+func TestActorsIdleTimeout(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	clk := &testClock{t: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)}
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), Clock(clk))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	actors, err := es.Actors(func() Entity { return &Order{} }, ActorIdleTimeout(time.Minute))
+	is.NoErr(err)
+
+	cached := func(subject string) bool {
+		actors.mu.Lock()
+		defer actors.mu.Unlock()
+		_, ok := actors.actors[subject]
+		return ok
+	}
+
+	// An actor held by a command is not evicted while idle.
+	held := actors.actor("orders.1")
+	clk.Add(2 * time.Minute)
+	actors.release(actors.actor("orders.2"))
+	is.True(cached("orders.1"))
+
+	// Once released, it is evicted after the idle timeout.
+	actors.release(held)
+	clk.Add(2 * time.Minute)
+	actors.release(actors.actor("orders.3"))
+	is.True(!cached("orders.1"))
+	is.True(!cached("orders.2"))
+
+	// The state of an evicted entity is hydrated on the next command.
+	ctx := context.Background()
+	_, _, err = actors.Execute(ctx, "orders.1", &Command{Data: &PlaceOrder{ID: "1"}})
+	is.NoErr(err)
+	clk.Add(2 * time.Minute)
+	_, _, err = actors.Execute(ctx, "orders.1", &Command{Data: &ShipOrder{ID: "1"}})
+	is.NoErr(err)
+}
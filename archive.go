@@ -0,0 +1,363 @@
+package rita
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	defaultArchiveWindow   = 24 * time.Hour
+	defaultArchiveInterval = time.Minute
+	defaultArchiveGrace    = 5 * time.Second
+)
+
+// errArchiveWindowOpen stops reading at the first event of a window which
+// is not yet complete.
+var errArchiveWindowOpen = errors.New("rita: archive window open")
+
+// ArchiveSink stores archived segments, such as the NATS Object Store or an
+// S3-compatible bucket. Segment names are deterministic, so a segment may be
+// put again after a failure and must replace the previous one.
+type ArchiveSink interface {
+	// Exists returns true if the segment with the name is stored.
+	Exists(ctx context.Context, name string) (bool, error)
+
+	// Put stores the segment with the name.
+	Put(ctx context.Context, name string, data []byte) error
+}
+
+type objectStoreSink struct {
+	obs nats.ObjectStore
+}
+
+func (s *objectStoreSink) Exists(ctx context.Context, name string) (bool, error) {
+	info, err := s.obs.GetInfo(name)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.Deleted, nil
+}
+
+func (s *objectStoreSink) Put(ctx context.Context, name string, data []byte) error {
+	_, err := s.obs.PutBytes(name, data)
+	return err
+}
+
+// ObjectStoreSink returns a sink storing segments as objects in the NATS
+// Object Store.
+func ObjectStoreSink(obs nats.ObjectStore) ArchiveSink {
+	return &objectStoreSink{obs: obs}
+}
+
+type archiverOpts struct {
+	subject  string
+	window   time.Duration
+	interval time.Duration
+	grace    time.Duration
+}
+
+type archiverOptFn func(o *archiverOpts) error
+
+func (f archiverOptFn) archiverOpt(o *archiverOpts) error {
+	return f(o)
+}
+
+// ArchiverOption is an option for an archiver.
+type ArchiverOption interface {
+	archiverOpt(o *archiverOpts) error
+}
+
+// ArchiveSubject sets the subject filter of the events archived. Default is
+// all subjects of the store.
+func ArchiveSubject(subject string) ArchiverOption {
+	return archiverOptFn(func(o *archiverOpts) error {
+		o.subject = subject
+		return nil
+	})
+}
+
+// ArchiveWindow sets the duration of the time windows events are segmented
+// by, aligned to UTC, e.g. 24 hours for daily segments. Default is 24 hours.
+func ArchiveWindow(d time.Duration) ArchiverOption {
+	return archiverOptFn(func(o *archiverOpts) error {
+		if d < time.Second {
+			return fmt.Errorf("archive: window must be at least a second")
+		}
+		o.window = d
+		return nil
+	})
+}
+
+// ArchiveInterval sets the interval Run checks for completed windows.
+// Default is 1 minute.
+func ArchiveInterval(d time.Duration) ArchiverOption {
+	return archiverOptFn(func(o *archiverOpts) error {
+		if d <= 0 {
+			return fmt.Errorf("archive: interval must be positive")
+		}
+		o.interval = d
+		return nil
+	})
+}
+
+// ArchiveGrace sets the time after the end of a window before it is
+// considered complete, allowing for clock skew between servers. Default is
+// 5 seconds.
+func ArchiveGrace(d time.Duration) ArchiverOption {
+	return archiverOptFn(func(o *archiverOpts) error {
+		if d < 0 {
+			return fmt.Errorf("archive: grace must not be negative")
+		}
+		o.grace = d
+		return nil
+	})
+}
+
+// archivedEvent is an event encoded as a line of a segment.
+type archivedEvent struct {
+	ID           string            `json:"id"`
+	Type         string            `json:"type"`
+	Subject      string            `json:"subject"`
+	Sequence     uint64            `json:"seq"`
+	Time         time.Time         `json:"time"`
+	RecordedTime time.Time         `json:"recorded_time"`
+	Meta         map[string]string `json:"meta,omitempty"`
+	Data         any               `json:"data,omitempty"`
+	Redacted     bool              `json:"redacted,omitempty"`
+}
+
+// archiveCheckpoint is the position of an archiver.
+type archiveCheckpoint struct {
+	Sequence uint64    `json:"seq"`
+	Window   time.Time `json:"window"`
+}
+
+// ArchiveSegment describes a segment written by an archiver.
+type ArchiveSegment struct {
+	// Name of the segment in the sink.
+	Name string
+
+	// Start of the window of the segment.
+	Start time.Time
+
+	// Count of events in the segment.
+	Count int
+
+	// Sequence of the last event in the segment.
+	Sequence uint64
+}
+
+// Archiver writes the events of completed time windows as segments of
+// newline-delimited JSON into a sink for analytics pipelines. Windows are
+// determined by the time events were recorded by the store, so a window is
+// complete once its end has passed. The position of the archiver is
+// checkpointed in a KV bucket named "{stream}_archives" after each segment,
+// and segments are named by the archiver and window, so a segment written
+// before a failure is not written again.
+type Archiver struct {
+	es   *EventStore
+	name string
+	sink ArchiveSink
+	kv   nats.KeyValue
+	opts archiverOpts
+
+	mu sync.Mutex
+}
+
+// Archiver returns an archiver with the name writing to the sink.
+func (s *EventStore) Archiver(name string, sink ArchiveSink, opts ...ArchiverOption) (*Archiver, error) {
+	o := archiverOpts{
+		subject:  s.filterSubject(),
+		window:   defaultArchiveWindow,
+		interval: defaultArchiveInterval,
+		grace:    defaultArchiveGrace,
+	}
+
+	for _, opt := range opts {
+		if err := opt.archiverOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if sink == nil {
+		return nil, errors.New("rita: archive: sink required")
+	}
+	if name == "" || strings.ContainsAny(name, ".*>/ ") {
+		return nil, fmt.Errorf("rita: archive: invalid name %q", name)
+	}
+
+	kv, err := s.derivedKV("archives")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archiver{
+		es:   s,
+		name: name,
+		sink: sink,
+		kv:   kv,
+		opts: o,
+	}, nil
+}
+
+// segmentName returns the name of the segment of the window.
+func (a *Archiver) segmentName(start time.Time) string {
+	return fmt.Sprintf("%s/%s/%s.ndjson", a.es.name, a.name, start.UTC().Format("20060102T150405Z"))
+}
+
+func (a *Archiver) checkpoint() (*archiveCheckpoint, uint64, error) {
+	var cp archiveCheckpoint
+
+	entry, err := a.kv.Get(a.name)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return &cp, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := json.Unmarshal(entry.Value(), &cp); err != nil {
+		return nil, 0, err
+	}
+	return &cp, entry.Revision(), nil
+}
+
+// Archive writes the segments of the windows completed since the last
+// checkpoint and returns them. Run calls it periodically.
+func (a *Archiver) Archive(ctx context.Context) ([]*ArchiveSegment, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.es.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	cp, rev, err := a.checkpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.es.rt.clock.Now()
+
+	var (
+		segments []*ArchiveSegment
+		seg      *ArchiveSegment
+		buf      bytes.Buffer
+	)
+
+	flush := func() error {
+		if seg == nil {
+			return nil
+		}
+
+		ok, err := a.sink.Exists(ctx, seg.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err := a.sink.Put(ctx, seg.Name, buf.Bytes()); err != nil {
+				return err
+			}
+		}
+
+		b, _ := json.Marshal(&archiveCheckpoint{
+			Sequence: seg.Sequence,
+			Window:   seg.Start,
+		})
+		if rev == 0 {
+			rev, err = a.kv.Create(a.name, b)
+		} else {
+			rev, err = a.kv.Update(a.name, b, rev)
+		}
+		if err != nil {
+			return err
+		}
+
+		segments = append(segments, seg)
+		seg = nil
+		buf.Reset()
+		return nil
+	}
+
+	o := loadOpts{afterSeq: &cp.Sequence}
+
+	_, err = a.es.read(ctx, a.opts.subject, &o, func(e *Event) error {
+		start := e.RecordedTime.UTC().Truncate(a.opts.window)
+
+		if seg == nil || !start.Equal(seg.Start) {
+			if err := flush(); err != nil {
+				return err
+			}
+			if now.Before(start.Add(a.opts.window + a.opts.grace)) {
+				return errArchiveWindowOpen
+			}
+			seg = &ArchiveSegment{
+				Name:  a.segmentName(start),
+				Start: start,
+			}
+		}
+
+		b, err := json.Marshal(&archivedEvent{
+			ID:           e.ID,
+			Type:         e.Type,
+			Subject:      e.Subject,
+			Sequence:     e.Sequence,
+			Time:         e.Time,
+			RecordedTime: e.RecordedTime,
+			Meta:         e.Meta,
+			Data:         e.Data,
+			Redacted:     e.Redacted,
+		})
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+
+		seg.Count++
+		seg.Sequence = e.Sequence
+		return nil
+	})
+	if err != nil && !errors.Is(err, errArchiveWindowOpen) {
+		return segments, err
+	}
+
+	// Segments are only started for complete windows.
+	if err := flush(); err != nil {
+		return segments, err
+	}
+
+	return segments, nil
+}
+
+// Run archives completed windows at the interval until the context is done.
+func (a *Archiver) Run(ctx context.Context) error {
+	t := time.NewTicker(a.opts.interval)
+	defer t.Stop()
+
+	for {
+		if _, err := a.Archive(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
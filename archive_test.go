@@ -0,0 +1,88 @@
+package rita
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestArchiver(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	clk := &testClock{t: time.Now()}
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), Clock(clk))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	js, _ := nc.JetStream()
+	obs, err := js.CreateObjectStore(&nats.ObjectStoreConfig{
+		Bucket:  "archive",
+		Storage: nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	_, err = es.Archiver("daily", nil)
+	is.Err(err, nil)
+	_, err = es.Archiver("daily.v1", ObjectStoreSink(obs))
+	is.Err(err, nil)
+
+	a, err := es.Archiver("daily", ObjectStoreSink(obs), ArchiveWindow(time.Hour))
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	// The current window is not complete.
+	segs, err := a.Archive(ctx)
+	is.NoErr(err)
+	is.Equal(len(segs), 0)
+
+	clk.Add(2 * time.Hour)
+
+	segs, err = a.Archive(ctx)
+	is.NoErr(err)
+	is.Equal(len(segs), 1)
+	is.Equal(segs[0].Count, 3)
+	is.Equal(segs[0].Sequence, uint64(3))
+
+	b, err := obs.GetBytes(segs[0].Name)
+	is.NoErr(err)
+
+	var lines []*archivedEvent
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		var e archivedEvent
+		is.NoErr(json.Unmarshal(sc.Bytes(), &e))
+		lines = append(lines, &e)
+	}
+	is.Equal(len(lines), 3)
+	is.Equal(lines[2].Subject, "orders.2")
+	is.Equal(lines[2].Data, map[string]any{"ID": "2"})
+
+	// Checkpointed, so nothing is archived again.
+	segs, err = a.Archive(ctx)
+	is.NoErr(err)
+	is.Equal(len(segs), 0)
+}
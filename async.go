@@ -0,0 +1,40 @@
+package rita
+
+import (
+	"context"
+	"errors"
+)
+
+// AppendCallback is called with the result of an asynchronous append.
+type AppendCallback func(seq uint64, err error)
+
+// AppendAsync appends the events like Append, but returns immediately and
+// calls the callback with the resulting sequence or error once the append
+// completes. Asynchronous appends of the store are performed in the order
+// they are called, so the events of consecutive calls for a subject are
+// appended in order, and the callbacks are called from a separate goroutine.
+// The context applies to the append, so it must not be canceled before the
+// callback is called.
+func (s *EventStore) AppendAsync(ctx context.Context, subject string, events []*Event, cb AppendCallback, opts ...AppendOption) error {
+	if cb == nil {
+		return errors.New("rita: append callback required")
+	}
+
+	s.asyncMu.Lock()
+	prev := s.asyncTail
+	done := make(chan struct{})
+	s.asyncTail = done
+	s.asyncMu.Unlock()
+
+	go func() {
+		if prev != nil {
+			<-prev
+		}
+		seq, err := s.Append(ctx, subject, events, opts...)
+		// The next append does not wait for the callback.
+		close(done)
+		cb(seq, err)
+	}()
+
+	return nil
+}
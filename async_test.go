@@ -0,0 +1,76 @@
+package rita
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestAppendAsync(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	is.Err(es.AppendAsync(ctx, "orders.1", nil, nil), nil)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seqs []uint64
+		errs []error
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		err := es.AppendAsync(ctx, "orders.1", []*Event{
+			{ID: fmt.Sprint(i), Data: &OrderPlaced{ID: "1"}},
+		}, func(seq uint64, err error) {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			seqs = append(seqs, seq)
+			errs = append(errs, err)
+		}, ExpectSequence(uint64(i)))
+		is.NoErr(err)
+	}
+
+	wg.Wait()
+
+	// Appends are performed in order, so each expected sequence matches.
+	for _, err := range errs {
+		is.NoErr(err)
+	}
+	is.Equal(len(seqs), 10)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 10)
+	for i, e := range events {
+		is.Equal(e.ID, fmt.Sprint(i))
+	}
+
+	// Errors are passed to the callback.
+	wg.Add(1)
+	is.NoErr(es.AppendAsync(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}}, func(seq uint64, err error) {
+		defer wg.Done()
+		errs = append(errs[:0], err)
+	}, ExpectSequence(1)))
+	wg.Wait()
+	is.Err(errs[0], ErrSequenceConflict)
+}
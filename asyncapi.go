@@ -0,0 +1,249 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+const asyncAPIVersion = "2.6.0"
+
+// codecContentTypes maps codec names to the content type of encoded data.
+var codecContentTypes = map[string]string{
+	"binary":   "application/octet-stream",
+	"json":     "application/json",
+	"msgpack":  "application/msgpack",
+	"protobuf": "application/x-protobuf",
+}
+
+type asyncAPIOpts struct {
+	title       string
+	version     string
+	description string
+	serverURL   string
+}
+
+type asyncAPIOptFn func(o *asyncAPIOpts) error
+
+func (f asyncAPIOptFn) asyncAPIOpt(o *asyncAPIOpts) error {
+	return f(o)
+}
+
+// AsyncAPIOption is an option for generating an AsyncAPI document.
+type AsyncAPIOption interface {
+	asyncAPIOpt(o *asyncAPIOpts) error
+}
+
+// AsyncAPIInfo sets the title, version, and description of the document.
+// Default title is the context, or "rita" if not set, and version is "1.0.0".
+func AsyncAPIInfo(title, version, description string) AsyncAPIOption {
+	return asyncAPIOptFn(func(o *asyncAPIOpts) error {
+		if title == "" || version == "" {
+			return errors.New("rita: asyncapi: title and version required")
+		}
+		o.title = title
+		o.version = version
+		o.description = description
+		return nil
+	})
+}
+
+// AsyncAPIServer sets the URL of the NATS server in the document. By
+// default, no servers are included.
+func AsyncAPIServer(url string) AsyncAPIOption {
+	return asyncAPIOptFn(func(o *asyncAPIOpts) error {
+		o.serverURL = url
+		return nil
+	})
+}
+
+// AsyncAPI generates an AsyncAPI 2.6 document in JSON describing the event
+// stores of the context discovered by ListEventStores and the types of the
+// type registry. Each store is a channel of the subjects "{store}.>", mapped
+// into the context, and each registered type is a message whose payload is
+// the JSON Schema generated by the registry, unless an explicit JSON Schema
+// was set for the type. The catalog metadata of the types is included in the
+// messages. Since the registry does not record which store a type is appended
+// to, every channel references all registered types.
+func (r *Rita) AsyncAPI(ctx context.Context, opts ...AsyncAPIOption) ([]byte, error) {
+	o := asyncAPIOpts{
+		title:   r.context,
+		version: "1.0.0",
+	}
+	if o.title == "" {
+		o.title = "rita"
+	}
+
+	for _, opt := range opts {
+		if err := opt.asyncAPIOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	stores, err := r.ListEventStores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]any{
+		"title":   o.title,
+		"version": o.version,
+	}
+	if o.description != "" {
+		info["description"] = o.description
+	}
+
+	doc := map[string]any{
+		"asyncapi": asyncAPIVersion,
+		"info":     info,
+	}
+
+	if o.serverURL != "" {
+		doc["servers"] = map[string]any{
+			"nats": map[string]any{
+				"url":      o.serverURL,
+				"protocol": "nats",
+			},
+		}
+	}
+
+	var refs []any
+	if r.types != nil {
+		messages, schemas, err := r.asyncAPIMessages()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range r.types.Names() {
+			refs = append(refs, map[string]any{
+				"$ref": "#/components/messages/" + t,
+			})
+		}
+
+		doc["defaultContentType"] = codecContentTypes[r.types.Codec().Name()]
+		doc["components"] = map[string]any{
+			"messages": messages,
+			"schemas":  schemas,
+		}
+	}
+
+	channels := make(map[string]any, len(stores))
+	for _, s := range stores {
+		op := map[string]any{
+			"operationId": fmt.Sprintf("%s-events", s.Name),
+			"summary":     fmt.Sprintf("Events appended to the %s event store.", s.Name),
+		}
+		if len(refs) > 0 {
+			op["message"] = map[string]any{
+				"oneOf": refs,
+			}
+		}
+
+		channels[r.subject(s.Name+".>")] = map[string]any{
+			"description": fmt.Sprintf("Event store %s in stream %s.", s.Name, s.Stream),
+			"subscribe":   op,
+		}
+	}
+	doc["channels"] = channels
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// asyncAPIMessages returns the message and schema components of the
+// registered types.
+func (r *Rita) asyncAPIMessages() (map[string]any, map[string]any, error) {
+	generated, err := r.types.Schemas()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	messages := make(map[string]any)
+	schemas := make(map[string]any)
+
+	for _, t := range r.types.Names() {
+		s, err := r.types.Schema(t)
+		if err != nil {
+			return nil, nil, err
+		}
+		if s == nil || s.Format != "jsonschema" {
+			s = generated[t]
+		}
+
+		var schema map[string]any
+		if err := json.Unmarshal(s.Data, &schema); err != nil {
+			return nil, nil, fmt.Errorf("rita: asyncapi: schema of %s: %w", t, err)
+		}
+
+		// References are relative to the schema document, which is embedded
+		// in the components.
+		delete(schema, "$schema")
+		asyncAPIRebase(schema, "#/components/schemas/"+t)
+		schemas[t] = schema
+
+		msg := map[string]any{
+			"name":  t,
+			"title": t,
+			"headers": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					eventTypeHdr:    map[string]any{"type": "string", "const": t},
+					eventCodecHdr:   map[string]any{"type": "string"},
+					eventTimeHdr:    map[string]any{"type": "string", "format": "date-time"},
+					nats.MsgIdHdr:   map[string]any{"type": "string"},
+					eventVersionHdr: map[string]any{"type": "string"},
+				},
+				"required": []string{eventTypeHdr, eventCodecHdr, eventTimeHdr},
+			},
+			"payload": map[string]any{
+				"$ref": "#/components/schemas/" + t,
+			},
+		}
+
+		// The catalog metadata is described on the message as well.
+		c, _ := r.types.Catalog(t)
+		if c != nil {
+			if c.Description != "" {
+				msg["description"] = c.Description
+			}
+			if c.Owner != "" {
+				msg["x-owner"] = c.Owner
+			}
+			if c.PII != "" {
+				msg["x-pii"] = c.PII
+			}
+			if examples, ok := schema["examples"].([]any); ok {
+				var xs []any
+				for _, x := range examples {
+					xs = append(xs, map[string]any{"payload": x})
+				}
+				msg["examples"] = xs
+			}
+		}
+
+		messages[t] = msg
+	}
+
+	return messages, schemas, nil
+}
+
+// asyncAPIRebase rewrites local "$ref" values of the schema to be relative
+// to the base pointer.
+func asyncAPIRebase(v any, base string) {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, c := range x {
+			if ref, ok := c.(string); ok && k == "$ref" && len(ref) > 0 && ref[0] == '#' {
+				x[k] = base + ref[1:]
+				continue
+			}
+			asyncAPIRebase(c, base)
+		}
+	case []any:
+		for _, c := range x {
+			asyncAPIRebase(c, base)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestAsyncAPI(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr, err := types.NewRegistry(map[string]*types.Type{
+		"order-placed": {
+			Init: func() any { return &OrderPlaced{} },
+			Catalog: &types.Catalog{
+				Description: "An order was placed.",
+				Owner:       "checkout",
+				PII:         types.PIINone,
+				Examples:    []any{&OrderPlaced{ID: "1"}},
+			},
+		},
+		"order-shipped": {
+			Init: func() any { return &OrderShipped{} },
+		},
+	})
+	is.NoErr(err)
+
+	r, err := New(nc, TypeRegistry(tr), Context("shop"))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = r.AsyncAPI(ctx, AsyncAPIInfo("", "", ""))
+	is.Err(err, nil)
+
+	b, err := r.AsyncAPI(ctx, AsyncAPIInfo("Shop", "2.0.0", ""), AsyncAPIServer(srv.ClientURL()))
+	is.NoErr(err)
+
+	var doc struct {
+		AsyncAPI string `json:"asyncapi"`
+		Info     struct {
+			Title   string `json:"title"`
+			Version string `json:"version"`
+		} `json:"info"`
+		Servers            map[string]map[string]string `json:"servers"`
+		DefaultContentType string                       `json:"defaultContentType"`
+		Channels           map[string]struct {
+			Subscribe struct {
+				Message struct {
+					OneOf []map[string]string `json:"oneOf"`
+				} `json:"message"`
+			} `json:"subscribe"`
+		} `json:"channels"`
+		Components struct {
+			Messages map[string]struct {
+				Description string            `json:"description"`
+				Owner       string            `json:"x-owner"`
+				PII         string            `json:"x-pii"`
+				Examples    []map[string]any  `json:"examples"`
+				Payload     map[string]string `json:"payload"`
+			} `json:"messages"`
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	is.NoErr(json.Unmarshal(b, &doc))
+
+	is.Equal(doc.AsyncAPI, "2.6.0")
+	is.Equal(doc.Info.Title, "Shop")
+	is.Equal(doc.Info.Version, "2.0.0")
+	is.Equal(doc.Servers["nats"]["url"], srv.ClientURL())
+	is.Equal(doc.DefaultContentType, "application/json")
+
+	is.Equal(len(doc.Channels), 1)
+	ch, ok := doc.Channels["shop.orders.>"]
+	is.True(ok)
+	is.Equal(len(ch.Subscribe.Message.OneOf), 2)
+	is.Equal(ch.Subscribe.Message.OneOf[0]["$ref"], "#/components/messages/order-placed")
+
+	is.Equal(len(doc.Components.Messages), 2)
+
+	m := doc.Components.Messages["order-placed"]
+	is.Equal(m.Payload["$ref"], "#/components/schemas/order-placed")
+	is.Equal(m.Description, "An order was placed.")
+	is.Equal(m.Owner, "checkout")
+	is.Equal(m.PII, "none")
+	is.Equal(len(m.Examples), 1)
+
+	s := doc.Components.Schemas["order-placed"]
+	is.Equal(s["type"], "object")
+	_, ok = s["$schema"]
+	is.True(!ok)
+}
@@ -0,0 +1,166 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bruth/rita/types"
+)
+
+const (
+	auditRecordType = "audit-record"
+
+	AuditOK       = "ok"
+	AuditConflict = "conflict"
+	AuditDenied   = "denied"
+	AuditError    = "error"
+)
+
+type actorKey struct{}
+
+// WithActor returns a context with the actor performing operations, such as
+// a user or service name, which is recorded in the audit log and stamped on
+// the events appended and commands sent with the context. See MetaActor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor of the context, if any.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// AuditRecord is the data of an audit log event recording an operation on
+// an event store.
+type AuditRecord struct {
+	// Actor performing the operation taken from the context.
+	Actor string `json:"actor,omitempty"`
+
+	// Operation performed, e.g. "append".
+	Operation string `json:"operation"`
+
+	// Store the operation was performed on.
+	Store string `json:"store"`
+
+	// Subject the operation was performed on.
+	Subject string `json:"subject"`
+
+	// Types and IDs of the events of the operation.
+	Types []string `json:"types,omitempty"`
+	IDs   []string `json:"ids,omitempty"`
+
+	// Sequence of the last event appended, if the operation succeeded.
+	Sequence uint64 `json:"seq,omitempty"`
+
+	// Outcome of the operation, one of AuditOK, AuditConflict, AuditDenied,
+	// or AuditError.
+	Outcome string `json:"outcome"`
+
+	// Error of the operation if it did not succeed.
+	Error string `json:"error,omitempty"`
+
+	// Reason given for the operation, such as for an erasure.
+	Reason string `json:"reason,omitempty"`
+}
+
+// EventStoreAudit records the operations on the store in the audit log
+// returned by AuditLog. Recording is best effort, a failure to record does
+// not fail the operation. Default is no audit log.
+func EventStoreAudit(audit *EventStore) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if audit == nil || !audit.rt.audit {
+			return fmt.Errorf("rita: audit store must be created with AuditLog")
+		}
+		o.audit = audit
+		return nil
+	})
+}
+
+// AuditLog returns an event store with the name used as an audit log of the
+// operations on stores configured with EventStoreAudit. The store must be
+// created before it is used. Each event is an AuditRecord with the subject
+// "{name}.{subject}" of the subject the operation was performed on, so the
+// records can be loaded with the Load API, e.g. Load(ctx, "audit.orders.1").
+func (r *Rita) AuditLog(name string) (*EventStore, error) {
+	tr, err := types.NewRegistry(map[string]*types.Type{
+		auditRecordType: {
+			Init: func() any { return &AuditRecord{} },
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ar := &Rita{
+		nc:            r.nc,
+		js:            r.js,
+		anc:           r.anc,
+		ajs:           r.ajs,
+		appendTimeout: r.appendTimeout,
+		defaults:      r.defaults,
+		apiTimeout:    r.apiTimeout,
+		context:       r.context,
+		id:            r.id,
+		clock:         r.clock,
+		types:         tr,
+		audit:         true,
+	}
+
+	return ar.EventStore(name)
+}
+
+// auditOutcome returns the outcome of an operation given the error.
+func auditOutcome(err error) string {
+	switch {
+	case err == nil:
+		return AuditOK
+	case errors.Is(err, ErrSequenceConflict):
+		return AuditConflict
+	case errors.Is(err, ErrReadOnly), errors.Is(err, ErrUnauthorized):
+		return AuditDenied
+	default:
+		return AuditError
+	}
+}
+
+// auditAppend records an append in the audit log, if configured.
+func (s *EventStore) auditAppend(ctx context.Context, subject string, events []*Event, seq uint64, err error) {
+	if s.audit == nil {
+		return
+	}
+
+	rec := &AuditRecord{
+		Actor:     ActorFromContext(ctx),
+		Operation: "append",
+		Store:     s.name,
+		Subject:   subject,
+		Sequence:  seq,
+		Outcome:   auditOutcome(err),
+	}
+
+	for _, e := range events {
+		rec.Types = append(rec.Types, e.Type)
+		rec.IDs = append(rec.IDs, e.ID)
+	}
+
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	s.auditRecord(ctx, rec)
+}
+
+// auditRecord appends the record to the audit log of the store, if
+// configured.
+func (s *EventStore) auditRecord(ctx context.Context, rec *AuditRecord) {
+	if s.audit == nil {
+		return
+	}
+
+	asubject := fmt.Sprintf("%s.%s", s.audit.name, rec.Subject)
+
+	// Audit records are never audited.
+	_, _ = s.audit.append(ctx, asubject, []*Event{{Data: rec}})
+}
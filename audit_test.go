@@ -0,0 +1,58 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreAudit(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	audit, err := r.AuditLog("audit")
+	is.NoErr(err)
+	is.NoErr(audit.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	_, err = r.EventStore("orders", EventStoreAudit(nil))
+	is.True(err != nil)
+
+	es, err := r.EventStore("orders", EventStoreAudit(audit))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := WithActor(context.Background(), "billing-service")
+
+	seq, err := es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, ExpectSequence(10))
+	is.Err(err, ErrSequenceConflict)
+
+	events, _, err := audit.Load(ctx, "audit.orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+
+	rec := events[0].Data.(*AuditRecord)
+	is.Equal(rec.Actor, "billing-service")
+	is.Equal(rec.Operation, "append")
+	is.Equal(rec.Store, "orders")
+	is.Equal(rec.Subject, "orders.1")
+	is.Equal(rec.Types, []string{"order-placed"})
+	is.Equal(rec.Sequence, seq)
+	is.Equal(rec.Outcome, AuditOK)
+
+	rec = events[1].Data.(*AuditRecord)
+	is.Equal(rec.Outcome, AuditConflict)
+	is.Equal(rec.Sequence, uint64(0))
+	is.True(rec.Error != "")
+}
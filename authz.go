@@ -0,0 +1,63 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnauthorized = errors.New("rita: unauthorized")
+)
+
+// Operation is an operation on an event store which is authorized.
+type Operation string
+
+const (
+	OpAppend Operation = "append"
+	OpLoad   Operation = "load"
+	OpWatch  Operation = "watch"
+	OpErase  Operation = "erase"
+)
+
+// Authorizer authorizes operations on event stores. This allows services
+// sharing NATS credentials to be limited to their own subjects, e.g. using
+// the actor of the context. The subject may contain wildcards for loads and
+// is the filter subject of the store, subscription, or consumer for watches,
+// which are tails, taps, and the runs of subscriptions, workers, reactors,
+// and projections. Erasures and redactions are authorized as erases of the
+// subject of the events. The events are only passed
+// for appends and have their type resolved. A non-nil error denies the
+// operation.
+type Authorizer interface {
+	Authorize(ctx context.Context, op Operation, subject string, events []*Event) error
+}
+
+// AuthorizerFunc is a function which implements Authorizer.
+type AuthorizerFunc func(ctx context.Context, op Operation, subject string, events []*Event) error
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, op Operation, subject string, events []*Event) error {
+	return f(ctx, op, subject, events)
+}
+
+// Authorization sets an authorizer which is consulted before events are
+// appended, loaded, evolved, watched, or erased. Denied
+// operations return an error wrapping ErrUnauthorized. Default is all
+// operations are allowed.
+func Authorization(a Authorizer) RitaOption {
+	return ritaOption(func(o *Rita) error {
+		o.authz = a
+		return nil
+	})
+}
+
+// authorize consults the authorizer, if any, for the operation.
+func (s *EventStore) authorize(ctx context.Context, op Operation, subject string, events []*Event) error {
+	if s.rt.authz == nil {
+		return nil
+	}
+	if err := s.rt.authz.Authorize(ctx, op, subject, events); err != nil {
+		return fmt.Errorf("%w: %s %s: %s", ErrUnauthorized, op, subject, err)
+	}
+	return nil
+}
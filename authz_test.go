@@ -0,0 +1,100 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestAuthorizer(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	// Each actor may only touch the orders of its entity and may not append
+	// shipped events, watch, or erase.
+	authz := AuthorizerFunc(func(ctx context.Context, op Operation, subject string, events []*Event) error {
+		if op == OpWatch || op == OpErase {
+			return fmt.Errorf("%s not allowed", op)
+		}
+		if subject != "orders."+ActorFromContext(ctx) {
+			return errors.New("not owner")
+		}
+		for _, e := range events {
+			if e.Type == "order-shipped" {
+				return errors.New("cannot ship")
+			}
+		}
+		return nil
+	})
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), Authorization(authz))
+	is.NoErr(err)
+
+	audit, err := r.AuditLog("audit")
+	is.NoErr(err)
+	is.NoErr(audit.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	es, err := r.EventStore("orders", EventStoreAudit(audit))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := WithActor(context.Background(), "1")
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.Err(err, ErrUnauthorized)
+
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.Err(err, ErrUnauthorized)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	_, _, err = es.Load(ctx, "orders.*")
+	is.Err(err, ErrUnauthorized)
+
+	_, err = es.Evolve(ctx, "orders.2", &OrderStats{})
+	is.Err(err, ErrUnauthorized)
+
+	_, err = es.Tail(ctx, "")
+	is.Err(err, ErrUnauthorized)
+
+	// Consumers are authorized as watches when they start.
+	sub, err := es.Subscription("view")
+	is.NoErr(err)
+	defer sub.Delete() //nolint
+
+	is.Err(sub.Run(ctx, func(ctx context.Context, event *Event) error { return nil }), ErrUnauthorized)
+	_, err = sub.Fetch(ctx, 1)
+	is.Err(err, ErrUnauthorized)
+
+	w, err := es.ProjectionWorker("view")
+	is.NoErr(err)
+	is.Err(w.Run(ctx, func(ctx context.Context, event *Event) error { return nil }), ErrUnauthorized)
+
+	rc, err := es.Reactor("shipping", func(ctx context.Context, event *Event) ([]*Dispatch, error) { return nil, nil }, nil)
+	is.NoErr(err)
+	is.Err(rc.Run(ctx), ErrUnauthorized)
+
+	// Erasures and redactions are denied unless erase is allowed.
+	_, err = es.Erase(ctx, "orders.1", "request")
+	is.Err(err, ErrUnauthorized)
+	is.Err(es.Redact(ctx, 1, "request"), ErrUnauthorized)
+
+	// Denied appends are audited.
+	events, _, err = audit.Load(ctx, "audit.orders.2")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Data.(*AuditRecord).Outcome, AuditDenied)
+}
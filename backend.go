@@ -0,0 +1,126 @@
+package rita
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bruth/rita/storage"
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrBackendUnsupported = errors.New("rita: not supported by the storage backend")
+)
+
+// EventStoreBackend stores the events of the store in the backend rather than
+// the stream of the store. This is an optional plug-in point: by default, a
+// store reads and writes its stream directly and no backend is involved.
+// Appends, loads, evolving, and tails are supported with the same envelope
+// and optimistic concurrency semantics. Features which depend on JetStream,
+// such as claim checks, chunking, redaction, parallel loads, and managing the
+// stream, are not supported. Since the backend manages its own storage,
+// Create, Update, Ensure, and Delete return ErrBackendUnsupported.
+func EventStoreBackend(b storage.Backend) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if b == nil {
+			return errors.New("rita: backend required")
+		}
+		o.backend = b
+		return nil
+	})
+}
+
+// placement returns the config of the stream whose storage, replicas, and
+// placement are inherited by the buckets and streams created for the store.
+// A store with a backend has no stream, so the zero config is returned and
+// the server defaults are used.
+func (s *EventStore) placement() (nats.StreamConfig, error) {
+	if s.backend != nil {
+		return nats.StreamConfig{}, nil
+	}
+	info, err := s.rt.js.StreamInfo(s.stream)
+	if err != nil {
+		return nats.StreamConfig{}, err
+	}
+	return info.Config, nil
+}
+
+// backendMsg converts a message of the backend into a NATS message for
+// unpacking.
+func backendMsg(m *storage.Message) *nats.Msg {
+	return &nats.Msg{
+		Subject: m.Subject,
+		Header:  m.Header,
+		Data:    m.Data,
+	}
+}
+
+// backendAppend appends the packed messages of the events to the backend.
+func (s *EventStore) backendAppend(ctx context.Context, subject string, events []*Event, packed []*nats.Msg, expSeq *uint64) (uint64, error) {
+	msgs := make([]*storage.Message, len(packed))
+	for i, msg := range packed {
+		msgs[i] = &storage.Message{
+			Header: msg.Header,
+			Data:   msg.Data,
+		}
+	}
+
+	err := s.backend.Append(ctx, s.subject(subject), msgs, expSeq)
+	if errors.Is(err, storage.ErrSequenceConflict) {
+		return 0, &Error{
+			Op:               "append",
+			Subject:          subject,
+			ExpectedSequence: *expSeq,
+			Err:              ErrSequenceConflict,
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range events {
+		s.sizes.observe(len(msgs[i].Data))
+		e.Subject = subject
+		e.Sequence = msgs[i].Sequence
+	}
+
+	return msgs[len(msgs)-1].Sequence, nil
+}
+
+// backendRead reads the messages of the subject from the backend, returning
+// the sequence of the last message read.
+func (s *EventStore) backendRead(ctx context.Context, subject string, o *loadOpts, fn func(*storage.Message) error) (uint64, error) {
+	var after uint64
+	if o.afterSeq != nil {
+		after = *o.afterSeq
+	}
+
+	var lastSeq uint64
+	err := s.backend.LoadRange(ctx, s.subject(subject), after, o.untilSeq, func(m *storage.Message) error {
+		lastSeq = m.Sequence
+		if after == 0 && m.Time.Before(o.recordedStart) {
+			return nil
+		}
+		return fn(m)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return lastSeq, nil
+}
+
+// backendUnpack unpacks an event read from the backend.
+func (s *EventStore) backendUnpack(m *storage.Message, lenient bool) (*Event, error) {
+	event, err := s.unpackEvent(backendMsg(m), lenient)
+	if err != nil {
+		var de *DecodeError
+		if errors.As(err, &de) {
+			de.Sequence = m.Sequence
+		}
+		return nil, err
+	}
+	event.Sequence = m.Sequence
+	event.RecordedTime = m.Time
+	return event, nil
+}
@@ -0,0 +1,78 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/storage"
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreBackend(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	_, err = r.EventStore("orders", EventStoreBackend(storage.NewMemory()), EventStoreBind())
+	is.True(err != nil)
+
+	es, err := r.EventStore("orders", EventStoreBackend(storage.NewMemory()), EventStoreHashChain())
+	is.NoErr(err)
+
+	is.Err(es.Create(nil), ErrBackendUnsupported)
+	is.Err(es.Delete(), ErrBackendUnsupported)
+
+	ctx := context.Background()
+
+	seq, err := es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+	}, ExpectSequence(0))
+	is.NoErr(err)
+	is.Equal(seq, uint64(1))
+
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}}, ExpectSequence(0))
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, ExpectSequence(0))
+	is.Err(err, ErrSequenceConflict)
+
+	seq, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, ExpectSequence(1))
+	is.NoErr(err)
+	is.Equal(seq, uint64(3))
+
+	events, last, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(last, uint64(3))
+	is.Equal(len(events), 2)
+	is.Equal(events[0].Sequence, uint64(1))
+	is.Equal(events[1].Subject, "orders.1")
+	is.Equal(events[1].prevHash, events[0].Hash)
+	is.True(!events[1].RecordedTime.IsZero())
+	is.Equal(*events[1].Data.(*OrderShipped), OrderShipped{ID: "1"})
+
+	events, _, err = es.Load(ctx, "orders.*", AfterSequence(1), Parallel(2))
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+
+	var stats OrderStats
+	_, err = es.Evolve(ctx, "orders.*", &stats)
+	is.NoErr(err)
+	is.Equal(stats, OrderStats{OrdersPlaced: 2, OrdersShipped: 1})
+
+	tail, err := es.Tail(ctx, "")
+	is.NoErr(err)
+	defer tail.Stop() //nolint
+
+	for i := uint64(1); i <= 3; i++ {
+		e, err := tail.Next(ctx)
+		is.NoErr(err)
+		is.Equal(e.Sequence, i)
+	}
+}
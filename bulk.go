@@ -0,0 +1,242 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+const defaultBulkWindow = 256
+
+var (
+	ErrBulkUnsupported = errors.New("rita: bulk append not supported by the event store")
+)
+
+// EventIterator yields the events of a bulk append. Next returns nil and no
+// error when there are no more events.
+type EventIterator interface {
+	Next(ctx context.Context) (*Event, error)
+}
+
+type sliceIterator struct {
+	events []*Event
+}
+
+func (it *sliceIterator) Next(ctx context.Context) (*Event, error) {
+	if len(it.events) == 0 {
+		return nil, nil
+	}
+	e := it.events[0]
+	it.events = it.events[1:]
+	return e, nil
+}
+
+// IterEvents returns an iterator of the events.
+func IterEvents(events []*Event) EventIterator {
+	return &sliceIterator{events: events}
+}
+
+type bulkOpts struct {
+	window int
+}
+
+type bulkOptFn func(o *bulkOpts) error
+
+func (f bulkOptFn) bulkOpt(o *bulkOpts) error {
+	return f(o)
+}
+
+// BulkOption is an option for a bulk append.
+type BulkOption interface {
+	bulkOpt(o *bulkOpts) error
+}
+
+// BulkWindow sets the max number of messages published and not yet
+// acknowledged. Default is 256.
+func BulkWindow(n int) BulkOption {
+	return bulkOptFn(func(o *bulkOpts) error {
+		if n < 1 {
+			return errors.New("rita: bulk window must be positive")
+		}
+		o.window = n
+		return nil
+	})
+}
+
+// BulkResult is the result of a bulk append.
+type BulkResult struct {
+	// Count of events acknowledged by the stream, including duplicates.
+	Count int
+
+	// Duplicates is the number of events which were already stored, as
+	// determined by the event ID within the duplicate window of the stream.
+	Duplicates int
+
+	// Sequence of the last event stored.
+	Sequence uint64
+}
+
+// bulkPending is a published message which is not yet acknowledged.
+type bulkPending struct {
+	future nats.PubAckFuture
+
+	// last is true if the message is the last chunk of the event.
+	last bool
+
+	// claimed is the object name of the claimed data of the event, if any.
+	claimed string
+}
+
+// BulkAppend appends the events of the iterator to the subject, such as for
+// migrations and backfills. Unlike Append, the events are published
+// asynchronously with a bounded window of unacknowledged messages, so the
+// throughput is not bound by the round trip of each publish. The events are
+// validated and the size limits, authorization, and rate limits of the store
+// apply, but sequences are not checked and the append hooks, index, and
+// event bus are not applied. On error, publishing stops and the result of the
+// events acknowledged so far is returned along with the error. Events
+// published after the failed event may be stored, so retrying with the same
+// event IDs relies on the de-duplication of the stream. Event stores with a
+// storage backend, causal ordering, or a hash chain are not supported.
+func (s *EventStore) BulkAppend(ctx context.Context, subject string, it EventIterator, opts ...BulkOption) (*BulkResult, error) {
+	o := bulkOpts{
+		window: defaultBulkWindow,
+	}
+	for _, opt := range opts {
+		if err := opt.bulkOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	if s.backend != nil || s.causal || s.hashChain {
+		return nil, ErrBulkUnsupported
+	}
+	if err := s.checkStoreSubject(subject); err != nil {
+		return nil, err
+	}
+
+	if err := s.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		res     BulkResult
+		pending []*bulkPending
+	)
+
+	// ack waits for the acknowledgement of the oldest pending message.
+	ack := func() error {
+		p := pending[0]
+		pending = pending[1:]
+
+		select {
+		case a := <-p.future.Ok():
+			if a.Stream != s.stream {
+				return fmt.Errorf("rita: subject %q is bound to stream %q", subject, a.Stream)
+			}
+			if !p.last {
+				return nil
+			}
+			res.Count++
+			if a.Duplicate {
+				s.unclaim(p.claimed)
+				res.Duplicates++
+			} else if a.Sequence > res.Sequence {
+				res.Sequence = a.Sequence
+			}
+			return nil
+
+		case err := <-p.future.Err():
+			s.unclaim(p.claimed)
+			return err
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// reconcile waits for all pending messages, returning the first error.
+	reconcile := func(err error) (*BulkResult, error) {
+		for len(pending) > 0 {
+			if aerr := ack(); err == nil {
+				err = aerr
+			}
+		}
+		return &res, err
+	}
+
+	for i := 0; ; i++ {
+		event, err := it.Next(ctx)
+		if err != nil {
+			return reconcile(err)
+		}
+		if event == nil {
+			break
+		}
+
+		e, err := s.wrapEvent(ctx, event)
+		if err != nil {
+			return reconcile(err)
+		}
+		e.Clock = nil
+		if err := s.setExpires(e, 0); err != nil {
+			return reconcile(err)
+		}
+
+		msg, err := s.packEvent(subject, e)
+		if err != nil {
+			return reconcile(err)
+		}
+
+		size := len(msg.Data)
+		if s.maxEventSize > 0 && size > s.maxEventSize {
+			return reconcile(&SizeError{Index: i, Size: size, Limit: s.maxEventSize, err: ErrEventTooLarge})
+		}
+
+		if err := s.authorize(ctx, OpAppend, subject, []*Event{e}); err != nil {
+			return reconcile(err)
+		}
+
+		if err := s.rateLimit.wait(ctx, subject, 1); err != nil {
+			return reconcile(err)
+		}
+
+		claimed, err := s.claim(msg)
+		if err != nil {
+			return reconcile(err)
+		}
+
+		chunks := s.chunk(msg, e.ID)
+		for j, cmsg := range chunks {
+			for len(pending) >= o.window {
+				if err := ack(); err != nil {
+					return reconcile(err)
+				}
+			}
+
+			f, err := s.rt.ajs.PublishMsgAsync(cmsg)
+			if err != nil {
+				s.unclaim(claimed)
+				return reconcile(err)
+			}
+			last := j == len(chunks)-1
+			p := &bulkPending{
+				future: f,
+				last:   last,
+			}
+			if last {
+				p.claimed = claimed
+			}
+			pending = append(pending, p)
+		}
+
+		s.sizes.observe(size)
+	}
+
+	return reconcile(nil)
+}
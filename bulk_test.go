@@ -0,0 +1,73 @@
+package rita
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestBulkAppend(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	events := func() []*Event {
+		var events []*Event
+		for i := 0; i < 1000; i++ {
+			events = append(events, &Event{
+				ID:   fmt.Sprintf("e%d", i),
+				Data: &OrderPlaced{ID: fmt.Sprint(i)},
+			})
+		}
+		return events
+	}
+
+	_, err = es.BulkAppend(ctx, "orders.1", IterEvents(nil), BulkWindow(0))
+	is.Err(err, nil)
+
+	res, err := es.BulkAppend(ctx, "orders.1", IterEvents(events()), BulkWindow(32))
+	is.NoErr(err)
+	is.Equal(res.Count, 1000)
+	is.Equal(res.Duplicates, 0)
+	is.Equal(res.Sequence, uint64(1000))
+
+	loaded, seq, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(loaded), 1000)
+	is.Equal(seq, uint64(1000))
+	is.Equal(loaded[999].ID, "e999")
+
+	// Retrying relies on de-duplication.
+	res, err = es.BulkAppend(ctx, "orders.1", IterEvents(events()))
+	is.NoErr(err)
+	is.Equal(res.Count, 1000)
+	is.Equal(res.Duplicates, 1000)
+
+	// Invalid events stop the append after the acknowledged events.
+	res, err = es.BulkAppend(ctx, "orders.2", IterEvents([]*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Type: "unknown", Data: []byte("x")},
+	}))
+	is.Err(err, nil)
+	is.Equal(res.Count, 1)
+
+	hc, err := r.EventStore("orders", EventStoreHashChain())
+	is.NoErr(err)
+	_, err = hc.BulkAppend(ctx, "orders.1", IterEvents(events()))
+	is.Err(err, ErrBulkUnsupported)
+}
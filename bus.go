@@ -0,0 +1,108 @@
+package rita
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// EventBus notifies in-process subscribers of events appended by the Rita
+// instance, for example to invalidate caches. Subscribers are called
+// synchronously in the order they subscribed, after the append succeeds and
+// before Append returns, so they should be fast. Notifications are not
+// durable, events appended by other processes are not observed, and a crash
+// after the append may lose notifications. Use a Subscription for reactions
+// which must not be lost.
+type EventBus struct {
+	mu   sync.RWMutex
+	next uint64
+	subs map[uint64]*busSub
+}
+
+type busSub struct {
+	id    uint64
+	types map[string]struct{}
+	fn    func(ctx context.Context, event *Event)
+}
+
+func newEventBus() *EventBus {
+	return &EventBus{
+		subs: make(map[uint64]*busSub),
+	}
+}
+
+// EventBus returns the in-process event bus of the instance.
+func (r *Rita) EventBus() *EventBus {
+	return r.bus
+}
+
+// Subscribe calls the function for each appended event of the types, or of
+// all types if none are given. The returned function unsubscribes.
+func (b *EventBus) Subscribe(fn func(ctx context.Context, event *Event), types ...string) func() {
+	sub := &busSub{
+		fn: fn,
+	}
+	if len(types) > 0 {
+		sub.types = make(map[string]struct{}, len(types))
+		for _, t := range types {
+			sub.types[t] = struct{}{}
+		}
+	}
+
+	b.mu.Lock()
+	b.next++
+	sub.id = b.next
+	b.subs[sub.id] = sub
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subs, sub.id)
+		b.mu.Unlock()
+	}
+}
+
+// SubscribeData calls the function for each appended event whose data is of
+// type T, e.g. SubscribeData(bus, func(ctx context.Context, e *Event, d *OrderPlaced) {...}).
+// The returned function unsubscribes.
+func SubscribeData[T any](b *EventBus, fn func(ctx context.Context, event *Event, data T)) func() {
+	return b.Subscribe(func(ctx context.Context, event *Event) {
+		if data, ok := event.Data.(T); ok {
+			fn(ctx, event, data)
+		}
+	})
+}
+
+// publish notifies the subscribers of the appended events.
+func (b *EventBus) publish(ctx context.Context, events []*Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	subs := make([]*busSub, 0, len(b.subs))
+	for _, s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+
+	if len(subs) == 0 {
+		return
+	}
+
+	// Subscribers are called in the order they subscribed.
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].id < subs[j].id
+	})
+
+	for _, e := range events {
+		for _, s := range subs {
+			if s.types != nil {
+				if _, ok := s.types[e.Type]; !ok {
+					continue
+				}
+			}
+			s.fn(ctx, e)
+		}
+	}
+}
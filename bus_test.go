@@ -0,0 +1,66 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventBus(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	var (
+		all     []string
+		shipped []string
+		placed  []string
+	)
+
+	unsub := r.EventBus().Subscribe(func(ctx context.Context, e *Event) {
+		all = append(all, e.Type)
+	})
+	r.EventBus().Subscribe(func(ctx context.Context, e *Event) {
+		shipped = append(shipped, e.Subject)
+	}, "order-shipped")
+	SubscribeData(r.EventBus(), func(ctx context.Context, e *Event, d *OrderPlaced) {
+		is.Equal(e.Sequence, uint64(1))
+		placed = append(placed, d.ID)
+	})
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	is.Equal(all, []string{"order-placed", "order-shipped"})
+	is.Equal(shipped, []string{"orders.1"})
+	is.Equal(placed, []string{"1"})
+
+	// Failed appends are not published.
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, ExpectSequence(0))
+	is.Err(err, ErrSequenceConflict)
+	is.Equal(len(all), 2)
+
+	unsub()
+
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderShipped{ID: "2"}}})
+	is.NoErr(err)
+	is.Equal(len(all), 2)
+	is.Equal(shipped, []string{"orders.1", "orders.2"})
+}
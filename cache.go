@@ -0,0 +1,235 @@
+package rita
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bruth/rita/storage"
+	"github.com/nats-io/nats.go"
+)
+
+// EventStoreDiskCache caches the messages of subjects in files in the
+// directory, so loads after a process restart read the cached events from
+// disk and only fetch the events appended since from the stream. Only loads
+// of a concrete subject from the start of its history use the cache.
+//
+// The cache of a subject is dropped if an event in it is redacted or if the
+// last cached message is no longer in the stream, e.g. because the store was
+// deleted and recreated by another process. Events after one which failed to
+// decode are not cached, so it is read again once it can be decoded. Other
+// events removed by the limits of the store, e.g. MaxAge, remain cached, so
+// the cache should only be used for stores which retain all events.
+func EventStoreDiskCache(dir string) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if dir == "" {
+			return errors.New("rita: cache directory required")
+		}
+		o.cacheDir = dir
+		return nil
+	})
+}
+
+// diskCache is an append-only file of messages per subject.
+type diskCache struct {
+	dir string
+
+	// mu is held for writing to clear the cache and for reading while the
+	// file of a subject is accessed under the lock of the subject.
+	mu       sync.RWMutex
+	subjects sync.Map
+}
+
+// cachedSubject is the lock and the last cached sequence of a subject.
+type cachedSubject struct {
+	mu sync.Mutex
+
+	// last is the sequence of the last cached message if known.
+	last  uint64
+	known bool
+}
+
+func newDiskCache(dir, stream string) *diskCache {
+	return &diskCache{
+		dir: filepath.Join(dir, stream),
+	}
+}
+
+// lock locks the file of the subject and returns its state.
+func (c *diskCache) lock(subject string) *cachedSubject {
+	c.mu.RLock()
+	v, _ := c.subjects.LoadOrStore(subject, &cachedSubject{})
+	cs := v.(*cachedSubject)
+	cs.mu.Lock()
+	return cs
+}
+
+func (c *diskCache) unlock(cs *cachedSubject) {
+	cs.mu.Unlock()
+	c.mu.RUnlock()
+}
+
+// eligible returns true if the load can use the cache.
+func (c *diskCache) eligible(subject string, o *loadOpts) bool {
+	return c != nil &&
+		!subjectHasWildcard(subject) &&
+		o.afterSeq == nil &&
+		o.untilSeq == 0 &&
+		o.recordedStart.IsZero() &&
+		!o.headersOnly
+}
+
+func (c *diskCache) path(subject string) string {
+	return filepath.Join(c.dir, url.PathEscape(subject)+".ndjson")
+}
+
+// load returns the cached messages of the subject. A corrupt file, e.g. due
+// to a partial write, is dropped.
+func (c *diskCache) load(subject string) ([]*storage.Message, error) {
+	cs := c.lock(subject)
+	defer c.unlock(cs)
+	return c.read(subject, cs)
+}
+
+// read reads the cached messages of the subject while it is locked.
+func (c *diskCache) read(subject string, cs *cachedSubject) ([]*storage.Message, error) {
+	cs.last, cs.known = 0, true
+
+	f, err := os.Open(c.path(subject))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		cs.known = false
+		return nil, err
+	}
+	defer f.Close()
+
+	var msgs []*storage.Message
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64*1024*1024)
+	for sc.Scan() {
+		var m storage.Message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			return nil, os.Remove(c.path(subject))
+		}
+		msgs = append(msgs, &m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, os.Remove(c.path(subject))
+	}
+
+	if len(msgs) > 0 {
+		cs.last = msgs[len(msgs)-1].Sequence
+	}
+	return msgs, nil
+}
+
+// append appends messages to the cache of the subject. Messages up to the
+// last cached sequence are skipped, since a concurrent load of the subject
+// may have cached them already.
+func (c *diskCache) append(subject string, msgs []*storage.Message) error {
+	cs := c.lock(subject)
+	defer c.unlock(cs)
+
+	if !cs.known {
+		if _, err := c.read(subject, cs); err != nil {
+			return err
+		}
+	}
+
+	for len(msgs) > 0 && msgs[0].Sequence <= cs.last {
+		msgs = msgs[1:]
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(c.path(subject), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	// The last sequence is unknown if the write fails part way.
+	cs.known = false
+
+	enc := json.NewEncoder(f)
+	for _, m := range msgs {
+		if err := enc.Encode(m); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	cs.last, cs.known = msgs[len(msgs)-1].Sequence, true
+	return nil
+}
+
+// drop removes the cache of the subject.
+func (c *diskCache) drop(subject string) error {
+	cs := c.lock(subject)
+	defer c.unlock(cs)
+
+	cs.last, cs.known = 0, true
+
+	err := os.Remove(c.path(subject))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// clear removes the cache of all subjects.
+func (c *diskCache) clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.subjects.Range(func(k, _ any) bool {
+		c.subjects.Delete(k)
+		return true
+	})
+	return os.RemoveAll(c.dir)
+}
+
+// cacheCurrent returns true if the cached message is still in the stream,
+// i.e. the message at its sequence has the same subject and event ID. This
+// detects caches of a store deleted and recreated by another process.
+func (s *EventStore) cacheCurrent(ctx context.Context, m *storage.Message) (bool, error) {
+	msg, err := s.rt.js.GetMsg(s.stream, m.Sequence, nats.Context(ctx))
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The last message of a chunked event is the last chunk, which has the
+	// event ID as the chunk ID.
+	id := m.Header.Get(nats.MsgIdHdr)
+	return msg.Subject == m.Subject &&
+		(msg.Header.Get(nats.MsgIdHdr) == id || msg.Header.Get(eventChunkIDHdr) == id), nil
+}
+
+// cacheMsg converts a message read from the stream for caching.
+func cacheMsg(seq uint64, msg *nats.Msg, e *Event) *storage.Message {
+	return &storage.Message{
+		Subject:  msg.Subject,
+		Sequence: seq,
+		Time:     e.RecordedTime,
+		Header:   msg.Header,
+		Data:     msg.Data,
+	}
+}
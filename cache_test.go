@@ -0,0 +1,181 @@
+package rita
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreDiskCache(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	dir := t.TempDir()
+
+	es, err := r.EventStore("orders", EventStoreDiskCache(dir))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	events, last, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(last, uint64(1))
+
+	_, err = os.Stat(filepath.Join(dir, "orders", "orders.1.ndjson"))
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	_, _, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+
+	// The cached event is read from disk even though it was removed from the
+	// stream, since the stream is only read after the cached events.
+	is.NoErr(r.js.DeleteMsg("orders", 1))
+
+	// A new store, e.g. after a restart, uses the same cache.
+	es, err = r.EventStore("orders", EventStoreDiskCache(dir))
+	is.NoErr(err)
+
+	events, last, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(last, uint64(2))
+	is.Equal(events[0].Sequence, uint64(1))
+	is.True(!events[0].RecordedTime.IsZero())
+	is.Equal(*events[1].Data.(*OrderShipped), OrderShipped{ID: "1"})
+
+	// Loads which are not from the start bypass the cache.
+	events, _, err = es.Load(ctx, "orders.1", AfterSequence(0))
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	// A redaction of a cached event drops the cache.
+	is.NoErr(es.Redact(ctx, 2, "erasure request"))
+
+	events, last, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(last, uint64(0))
+	is.Equal(events[0].Redacted, true)
+}
+
+func TestEventStoreDiskCacheConsistency(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	dir := t.TempDir()
+
+	es, err := r.EventStore("orders", EventStoreDiskCache(dir))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	// Concurrent loads of an uncached subject cache the events once.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := es.Load(ctx, "orders.1")
+			is.NoErr(err)
+		}()
+	}
+	wg.Wait()
+
+	cached, err := es.cache.load("orders.1")
+	is.NoErr(err)
+	is.Equal(len(cached), 2)
+
+	// An event of an unknown type is skipped, but neither it nor the events
+	// after it are cached.
+	msg := nats.NewMsg("orders.1")
+	msg.Header.Set(nats.MsgIdHdr, "note")
+	msg.Header.Set(eventTypeHdr, "order-note")
+	msg.Header.Set(eventCodecHdr, "json")
+	msg.Header.Set(eventTimeHdr, time.Now().Format(eventTimeFormat))
+	msg.Data = []byte(`{}`)
+	_, err = r.js.PublishMsg(msg)
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	events, _, err := es.Load(ctx, "orders.1", OnDecodeError(DecodeSkip, nil))
+	is.NoErr(err)
+	is.Equal(len(events), 3)
+
+	// Once the type is registered, the event is loaded.
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-note", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+	r2, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es2, err := r2.EventStore("orders", EventStoreDiskCache(dir))
+	is.NoErr(err)
+
+	events, last, err := es2.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 4)
+	is.Equal(last, uint64(4))
+	is.Equal(events[2].Type, "order-note")
+
+	// The store is recreated by another process, so the cache of this
+	// process no longer matches the stream.
+	is.NoErr(r.js.DeleteStream("orders"))
+	_, err = r.js.AddStream(&nats.StreamConfig{
+		Name:     "orders",
+		Subjects: []string{"orders.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	for _, id := range []string{"2", "3", "4", "5"} {
+		_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: id}}})
+		is.NoErr(err)
+	}
+
+	events, last, err = es2.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 4)
+	is.Equal(last, uint64(4))
+	is.Equal(*events[0].Data.(*OrderPlaced), OrderPlaced{ID: "2"})
+}
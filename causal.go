@@ -0,0 +1,129 @@
+package rita
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	eventClockHdr = "rita-clock"
+)
+
+// EventStoreCausal maintains a vector clock on each event appended to the
+// store. The entry for the store is a logical clock per subject, incremented
+// by each event appended to the subject. Entries for other stores are merged
+// from the Clock set on an appended event, recording which events of other
+// stores, possibly in other contexts, the event causally depends on. Appends
+// must use ExpectSequence so concurrent appends do not observe the same clock,
+// otherwise ErrExpectedSequence is returned. Default is no clock.
+func EventStoreCausal() EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		o.causal = true
+		return nil
+	})
+}
+
+// VectorClock maps the name of a store, namespaced by context, to a logical
+// clock. A missing entry is equivalent to zero.
+type VectorClock map[string]uint64
+
+// Merge returns a new clock with the max of each entry of both clocks.
+func (c VectorClock) Merge(o VectorClock) VectorClock {
+	m := make(VectorClock, len(c))
+	for k, v := range c {
+		m[k] = v
+	}
+	for k, v := range o {
+		if v > m[k] {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// Before returns true if the clock happened before the other clock, i.e.
+// every entry is less than or equal and at least one is less.
+func (c VectorClock) Before(o VectorClock) bool {
+	var less bool
+	for k, v := range c {
+		if v > o[k] {
+			return false
+		}
+		if v < o[k] {
+			less = true
+		}
+	}
+	for k, v := range o {
+		if _, ok := c[k]; !ok && v > 0 {
+			less = true
+		}
+	}
+	return less
+}
+
+// Concurrent returns true if neither clock happened before the other and
+// they are not equal.
+func (c VectorClock) Concurrent(o VectorClock) bool {
+	return !c.Before(o) && !o.Before(c) && !c.Equal(o)
+}
+
+// Equal returns true if both clocks have the same entries.
+func (c VectorClock) Equal(o VectorClock) bool {
+	for k, v := range c {
+		if o[k] != v {
+			return false
+		}
+	}
+	for k, v := range o {
+		if c[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// String encodes the clock as comma-separated name=value pairs sorted by
+// name.
+func (c VectorClock) String() string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, c[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseVectorClock(s string) (VectorClock, error) {
+	c := make(VectorClock)
+	if s == "" {
+		return c, nil
+	}
+
+	for _, p := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			return nil, fmt.Errorf("unpack: invalid clock %q", s)
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unpack: invalid clock %q", s)
+		}
+		c[k] = n
+	}
+	return c, nil
+}
+
+// clockName returns the name of the entry of the store in a vector clock.
+func (s *EventStore) clockName() string {
+	if s.context == "" {
+		return s.name
+	}
+	return fmt.Sprintf("%s_%s", s.context, s.name)
+}
@@ -0,0 +1,106 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestVectorClock(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	a := VectorClock{"orders": 1}
+	b := VectorClock{"orders": 2}
+	c := VectorClock{"orders": 1, "shipping": 1}
+	d := VectorClock{"shipping": 2}
+
+	is.True(a.Before(b))
+	is.True(a.Before(c))
+	is.True(!b.Before(a))
+	is.True(b.Concurrent(c))
+	is.True(a.Concurrent(d))
+	is.True(!a.Concurrent(a))
+	is.Equal(b.Merge(d), VectorClock{"orders": 2, "shipping": 2})
+
+	x, err := parseVectorClock(c.String())
+	is.NoErr(err)
+	is.Equal(x, c)
+
+	_, err = parseVectorClock("orders")
+	is.True(err != nil)
+}
+
+func TestEventStoreCausal(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+
+	billing, err := New(nc, TypeRegistry(tr), Context("billing"))
+	is.NoErr(err)
+
+	shipping, err := New(nc, TypeRegistry(tr), Context("shipping"))
+	is.NoErr(err)
+
+	bes, err := billing.EventStore("orders", EventStoreCausal())
+	is.NoErr(err)
+	is.NoErr(bes.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ses, err := shipping.EventStore("orders", EventStoreCausal())
+	is.NoErr(err)
+	is.NoErr(ses.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	placed := &Event{Data: &OrderPlaced{ID: "1"}}
+	_, err = bes.Append(ctx, "orders.1", []*Event{placed}, ExpectSequence(0))
+	is.NoErr(err)
+	is.Equal(placed.Clock, VectorClock{"billing_orders": 1})
+
+	_, err = bes.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderPlaced{ID: "1"}},
+	}, ExpectSequence(1))
+	is.NoErr(err)
+
+	// Concurrent appends could observe the same clock.
+	_, err = bes.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.Err(err, ErrExpectedSequence)
+
+	// Each subject has its own logical clock.
+	other := &Event{Data: &OrderPlaced{ID: "2"}}
+	_, err = bes.Append(ctx, "orders.2", []*Event{other}, ExpectSequence(0))
+	is.NoErr(err)
+	is.Equal(other.Clock, VectorClock{"billing_orders": 1})
+
+	// The shipped event causally depends on the placed event.
+	_, err = ses.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}, Clock: placed.Clock}}, ExpectSequence(0))
+	is.NoErr(err)
+
+	bevents, _, err := bes.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(bevents), 3)
+	is.Equal(bevents[2].Clock, VectorClock{"billing_orders": 3})
+
+	sevents, _, err := ses.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(sevents[0].Clock, VectorClock{"billing_orders": 1, "shipping_orders": 1})
+
+	is.True(bevents[0].Clock.Before(sevents[0].Clock))
+	is.True(bevents[2].Clock.Concurrent(sevents[0].Clock))
+
+	// Clocks are not maintained for stores which are not causal.
+	es, err := billing.EventStore("orders")
+	is.NoErr(err)
+
+	e := &Event{Data: &OrderPlaced{ID: "3"}, Clock: VectorClock{"x": 1}}
+	_, err = es.Append(ctx, "orders.3", []*Event{e})
+	is.NoErr(err)
+	is.True(e.Clock == nil)
+}
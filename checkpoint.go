@@ -0,0 +1,219 @@
+package rita
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bruth/rita/codec"
+	"github.com/bruth/rita/storage"
+	"github.com/nats-io/nats.go"
+)
+
+// checkpoint is the evolved state of a model persisted in the checkpoint KV
+// bucket.
+type checkpoint struct {
+	Sequence uint64 `json:"seq"`
+	Version  int    `json:"version,omitempty"`
+	Codec    string `json:"codec"`
+	Data     []byte `json:"data"`
+}
+
+// EventStoreCheckpoint enables checkpointing of the models of the registered
+// type evolved by Evolve and Execute. The evolved model and the sequence of
+// the last event are persisted to a KV bucket named "{stream}_checkpoints"
+// once at least the number of events were applied since the last checkpoint,
+// so subsequent evolves only read the events after the checkpoint. The model
+// must be registered in the type registry and be encoded by the codec. A
+// checkpoint of a different version of the type is ignored, so the version
+// must be bumped when the encoded state of the model changes. The model
+// passed to Evolve must be in its initial state since it is restored from the
+// checkpoint. Loads with options filtering events are not checkpointed.
+// Checkpoints of subjects matching an event which is redacted, expired or
+// quarantined are dropped, so the event is no longer applied, and all
+// checkpoints are deleted with the store. See CompactCheckpoints to delete
+// checkpoints which would no longer be restored.
+func EventStoreCheckpoint(modelType string, every uint64) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if modelType == "" {
+			return errors.New("rita: checkpoint model type required")
+		}
+		if every < 1 {
+			return errors.New("rita: checkpoint interval must be positive")
+		}
+		if o.checkpoints == nil {
+			o.checkpoints = make(map[string]uint64)
+		}
+		o.checkpoints[modelType] = every
+		return nil
+	})
+}
+
+// checkpointable returns true if the events read with the options can be
+// checkpointed, i.e. no events are filtered.
+func (o *loadOpts) checkpointable() bool {
+	return o.afterSeq == nil &&
+		o.untilSeq == 0 &&
+		!o.headersOnly &&
+		o.parallel <= 1 &&
+		!o.lenient &&
+		o.decode.policy == DecodeFail &&
+		o.timeStart.IsZero() &&
+		o.timeEnd.IsZero() &&
+		o.recordedStart.IsZero() &&
+		o.recordedEnd.IsZero() &&
+		!o.orderByTime &&
+		o.asAt.IsZero() &&
+		len(o.meta) == 0
+}
+
+// checkpointFor returns the model type name and the checkpoint interval of
+// the model, or zero if the model is not checkpointed.
+func (s *EventStore) checkpointFor(model Evolver) (string, uint64) {
+	if len(s.checkpoints) == 0 || s.rt.types == nil {
+		return "", 0
+	}
+	t, err := s.rt.types.Lookup(model)
+	if err != nil {
+		return "", 0
+	}
+	return t, s.checkpoints[t]
+}
+
+// checkpointBucket returns the name of the KV bucket of checkpoints.
+func (s *EventStore) checkpointBucket() string {
+	return s.derivedName("checkpoints")
+}
+
+// checkpointKey returns the key of the checkpoint of the model type for the
+// subject, which may contain wildcards.
+func checkpointKey(modelType, subject string) string {
+	return fmt.Sprintf("%s.%s", modelType, base64.RawURLEncoding.EncodeToString([]byte(subject)))
+}
+
+// checkpointKV returns the KV bucket of checkpoints, creating it if it does
+// not exist.
+func (s *EventStore) checkpointKV() (nats.KeyValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.checkpointsKV != nil {
+		return s.checkpointsKV, nil
+	}
+
+	kv, err := s.derivedKV("checkpoints")
+	if err != nil {
+		return nil, err
+	}
+
+	s.checkpointsKV = kv
+	return kv, nil
+}
+
+// dropCheckpoints deletes the checkpoints of subjects matching any of the
+// subjects, since they may have applied events which were removed.
+func (s *EventStore) dropCheckpoints(subjects ...string) error {
+	if len(s.checkpoints) == 0 || len(subjects) == 0 {
+		return nil
+	}
+
+	kv, err := s.checkpointKV()
+	if err != nil {
+		return err
+	}
+
+	keys, err := kv.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		// Model types may contain dots, but encoded subjects do not.
+		i := strings.LastIndexByte(key, '.')
+		filter, err := base64.RawURLEncoding.DecodeString(key[i+1:])
+		if err != nil {
+			continue
+		}
+		for _, subject := range subjects {
+			if storage.MatchSubject(string(filter), subject) {
+				if err := kv.Purge(key); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// restoreCheckpoint decodes the checkpoint of the model for the subject into
+// the model and returns the sequence of the checkpoint, or zero if there is
+// no usable checkpoint.
+func (s *EventStore) restoreCheckpoint(modelType, subject string, model Evolver) (uint64, error) {
+	kv, err := s.checkpointKV()
+	if err != nil {
+		return 0, err
+	}
+
+	entry, err := kv.Get(checkpointKey(modelType, subject))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(entry.Value(), &cp); err != nil {
+		return 0, nil
+	}
+
+	version, err := s.rt.types.Version(model)
+	if err != nil || version != cp.Version {
+		return 0, nil
+	}
+
+	c, ok := codec.Codecs[cp.Codec]
+	if !ok {
+		return 0, nil
+	}
+	if err := c.Unmarshal(cp.Data, model); err != nil {
+		return 0, err
+	}
+
+	return cp.Sequence, nil
+}
+
+// saveCheckpoint persists the model evolved up to the sequence.
+func (s *EventStore) saveCheckpoint(modelType, subject string, model Evolver, seq uint64) error {
+	version, err := s.rt.types.Version(model)
+	if err != nil {
+		return err
+	}
+
+	data, codecName, err := s.rt.encodeData(model)
+	if err != nil {
+		return err
+	}
+
+	b, _ := json.Marshal(&checkpoint{
+		Sequence: seq,
+		Version:  version,
+		Codec:    codecName,
+		Data:     data,
+	})
+
+	kv, err := s.checkpointKV()
+	if err != nil {
+		return err
+	}
+
+	_, err = kv.Put(checkpointKey(modelType, subject), b)
+	return err
+}
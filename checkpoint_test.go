@@ -0,0 +1,159 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+type OrderSummary struct {
+	Placed  int
+	Shipped int
+
+	// applied is the number of events applied by this instance.
+	applied int
+}
+
+func (s *OrderSummary) Evolve(e *Event) error {
+	s.applied++
+	switch e.Data.(type) {
+	case *OrderPlaced:
+		s.Placed++
+	case *OrderShipped:
+		s.Shipped++
+	}
+	return nil
+}
+
+func TestEventStoreCheckpoint(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-summary", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	_, err = r.EventStore("orders", EventStoreCheckpoint("order-summary", 0))
+	is.Err(err, nil)
+
+	es, err := r.EventStore("orders", EventStoreCheckpoint("order-summary", 3))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	// Fewer events than the interval are not checkpointed.
+	var m OrderSummary
+	seq, err := es.Evolve(ctx, "orders.1", &m)
+	is.NoErr(err)
+	is.Equal(seq, uint64(2))
+	is.Equal(m.applied, 2)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderShipped{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	m = OrderSummary{}
+	seq, err = es.Evolve(ctx, "orders.1", &m)
+	is.NoErr(err)
+	is.Equal(seq, uint64(4))
+	is.Equal(m.applied, 4)
+
+	// Restored from the checkpoint without reading events.
+	m = OrderSummary{}
+	seq, err = es.Evolve(ctx, "orders.1", &m)
+	is.NoErr(err)
+	is.Equal(seq, uint64(4))
+	is.Equal(m.applied, 0)
+	is.Equal(m.Placed, 1)
+	is.Equal(m.Shipped, 3)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	m = OrderSummary{}
+	seq, err = es.Evolve(ctx, "orders.1", &m)
+	is.NoErr(err)
+	is.Equal(seq, uint64(5))
+	is.Equal(m.applied, 1)
+	is.Equal(m.Shipped, 4)
+
+	// Filtered loads do not use checkpoints.
+	m = OrderSummary{}
+	_, err = es.Evolve(ctx, "orders.1", &m, UntilSequence(2))
+	is.NoErr(err)
+	is.Equal(m.applied, 2)
+}
+
+func TestEventStoreCheckpointRedact(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-summary", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreCheckpoint("order-summary", 2))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	// Checkpoint the subject and a wildcard including it.
+	for _, subject := range []string{"orders.1", "orders.*"} {
+		var m OrderSummary
+		_, err = es.Evolve(ctx, subject, &m)
+		is.NoErr(err)
+
+		m = OrderSummary{}
+		_, err = es.Evolve(ctx, subject, &m)
+		is.NoErr(err)
+		is.Equal(m.applied, 0)
+	}
+
+	is.NoErr(es.Redact(ctx, 2, "test"))
+
+	// The checkpoints applied the redacted event, so they are dropped.
+	for _, subject := range []string{"orders.1", "orders.*"} {
+		var m OrderSummary
+		seq, err := es.Evolve(ctx, subject, &m)
+		is.NoErr(err)
+		is.Equal(seq, uint64(3))
+		is.Equal(m.applied, 3)
+		is.Equal(m.Shipped, 1)
+	}
+}
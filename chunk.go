@@ -0,0 +1,140 @@
+package rita
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	eventChunkHdr   = "rita-chunk"
+	eventChunkIDHdr = "rita-chunk-id"
+)
+
+// EventStoreChunkSize splits event data larger than the size, in bytes,
+// across multiple messages which are reassembled into a single event when
+// read. This is an alternative to EventStoreClaimCheck for events exceeding
+// the max message size of the server which does not require an object store.
+// Since the chunks are appended as separate messages, a failed append may
+// leave an incomplete event which is skipped when read. Default is no
+// chunking.
+func EventStoreChunkSize(n int) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if n < 1 {
+			return fmt.Errorf("rita: chunk size must be positive")
+		}
+		o.chunkSize = n
+		return nil
+	})
+}
+
+// chunk splits the data of the message into multiple messages if it exceeds
+// the chunk size. The first chunk retains the event headers and each chunk
+// is given a unique message ID derived from the event ID. The clock and hash
+// are retained on every chunk so the last message of a subject has them.
+func (s *EventStore) chunk(msg *nats.Msg, id string) []*nats.Msg {
+	if s.chunkSize == 0 || len(msg.Data) <= s.chunkSize {
+		return []*nats.Msg{msg}
+	}
+
+	n := (len(msg.Data) + s.chunkSize - 1) / s.chunkSize
+	msgs := make([]*nats.Msg, n)
+
+	for i := 0; i < n; i++ {
+		end := (i + 1) * s.chunkSize
+		if end > len(msg.Data) {
+			end = len(msg.Data)
+		}
+
+		m := nats.NewMsg(msg.Subject)
+		if i == 0 {
+			m.Header = msg.Header
+		} else {
+			m.Header.Set(nats.MsgIdHdr, fmt.Sprintf("%s#%d", id, i))
+			for _, h := range []string{eventClockHdr, eventHashHdr} {
+				if v := msg.Header.Get(h); v != "" {
+					m.Header.Set(h, v)
+				}
+			}
+		}
+
+		m.Data = msg.Data[i*s.chunkSize : end]
+		m.Header.Set(eventChunkIDHdr, id)
+		m.Header.Set(eventChunkHdr, fmt.Sprintf("%d/%d", i, n))
+		msgs[i] = m
+	}
+
+	return msgs
+}
+
+// assembler buffers chunked messages until all chunks of an event have been
+// received.
+type assembler struct {
+	chunks map[string][]*nats.Msg
+}
+
+func newAssembler() *assembler {
+	return &assembler{
+		chunks: make(map[string][]*nats.Msg),
+	}
+}
+
+// add adds a message and returns the message parts along with the assembled
+// message once all chunks have been received. Messages which are not chunked
+// are returned as is. The assembled message has the headers of the first
+// chunk and the delivery metadata of the last chunk.
+func (a *assembler) add(msg *nats.Msg) ([]*nats.Msg, *nats.Msg, error) {
+	hdr := msg.Header.Get(eventChunkHdr)
+	if hdr == "" {
+		return []*nats.Msg{msg}, msg, nil
+	}
+
+	idx, total, ok := strings.Cut(hdr, "/")
+	i, err1 := strconv.Atoi(idx)
+	n, err2 := strconv.Atoi(total)
+	if !ok || err1 != nil || err2 != nil || n < 1 || i < 0 || i >= n {
+		return nil, nil, fmt.Errorf("rita: invalid chunk header %q", hdr)
+	}
+
+	id := msg.Header.Get(eventChunkIDHdr)
+
+	// Event IDs are deterministic, so a failed append retried with
+	// different data may leave chunks with the same ID but a different
+	// total. The chunks received so far are discarded in that case.
+	parts, ok := a.chunks[id]
+	if !ok || len(parts) != n {
+		parts = make([]*nats.Msg, n)
+		a.chunks[id] = parts
+	}
+
+	// Redelivered chunks replace the previous delivery.
+	parts[i] = msg
+
+	var size int
+	for _, p := range parts {
+		if p == nil {
+			return nil, nil, nil
+		}
+		size += len(p.Data)
+	}
+
+	delete(a.chunks, id)
+
+	data := make([]byte, 0, size)
+	for _, p := range parts {
+		data = append(data, p.Data...)
+	}
+
+	m := *parts[n-1]
+	m.Header = nats.Header{}
+	for k, v := range parts[0].Header {
+		if k != eventChunkHdr && k != eventChunkIDHdr {
+			m.Header[k] = v
+		}
+	}
+	m.Data = data
+
+	return parts, &m, nil
+}
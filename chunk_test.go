@@ -0,0 +1,120 @@
+package rita
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestChunking(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreChunkSize(32))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	large := strings.Repeat("x", 100)
+
+	seq, err := es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: large}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+	is.Equal(seq, uint64(5))
+
+	// Expected sequence applies to the last chunk.
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, ExpectSequence(4))
+	is.Err(err, ErrSequenceConflict)
+
+	events, lseq, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(lseq, uint64(5))
+	is.Equal(len(events), 2)
+	is.Equal(events[0].Data.(*OrderPlaced).ID, large)
+	is.Equal(events[0].Sequence, uint64(4))
+	is.Equal(events[1].Type, "order-shipped")
+
+	tail, err := es.Tail(ctx, "")
+	is.NoErr(err)
+	defer tail.Stop() //nolint
+
+	event, err := tail.Next(ctx)
+	is.NoErr(err)
+	is.Equal(event.Data.(*OrderPlaced).ID, large)
+
+	sub, err := es.Subscription("audit")
+	is.NoErr(err)
+	defer sub.Delete() //nolint
+
+	ds, err := sub.Fetch(ctx, 5)
+	is.NoErr(err)
+	is.Equal(len(ds), 2)
+	is.Equal(ds[0].Data.(*OrderPlaced).ID, large)
+	is.NoErr(ds[0].Ack())
+	is.NoErr(ds[1].Ack())
+	is.NoErr(nc.Flush())
+
+	lag, err := sub.Lag()
+	is.NoErr(err)
+	is.Equal(lag, uint64(0))
+}
+
+func TestChunkAssemblerTotalMismatch(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	chunk := func(i, n int, data string) *nats.Msg {
+		m := nats.NewMsg("orders.1")
+		m.Header.Set(eventChunkIDHdr, "cmd.0")
+		m.Header.Set(eventChunkHdr, fmt.Sprintf("%d/%d", i, n))
+		m.Data = []byte(data)
+		return m
+	}
+
+	asm := newAssembler()
+
+	// Chunks of a failed append with a smaller total.
+	_, msg, err := asm.add(chunk(0, 2, "aa"))
+	is.NoErr(err)
+	is.True(msg == nil)
+
+	// The retried append has a larger total, so the stale chunk is
+	// discarded rather than indexed out of range.
+	for i, data := range []string{"xx", "yy"} {
+		_, msg, err = asm.add(chunk(i, 3, data))
+		is.NoErr(err)
+		is.True(msg == nil)
+	}
+	parts, msg, err := asm.add(chunk(2, 3, "zz"))
+	is.NoErr(err)
+	is.Equal(len(parts), 3)
+	is.Equal(string(msg.Data), "xxyyzz")
+
+	// A smaller total does not mix in stale chunks.
+	_, msg, err = asm.add(chunk(0, 3, "aa"))
+	is.NoErr(err)
+	is.True(msg == nil)
+	_, msg, err = asm.add(chunk(1, 3, "bb"))
+	is.NoErr(err)
+	is.True(msg == nil)
+
+	_, msg, err = asm.add(chunk(0, 2, "cc"))
+	is.NoErr(err)
+	is.True(msg == nil)
+	_, msg, err = asm.add(chunk(1, 2, "dd"))
+	is.NoErr(err)
+	is.Equal(string(msg.Data), "ccdd")
+}
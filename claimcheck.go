@@ -0,0 +1,131 @@
+package rita
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+)
+
+const (
+	eventClaimHdr = "rita-claim"
+)
+
+// EventStoreClaimCheck stores event data larger than the threshold, in bytes,
+// in an object store bucket named "{stream}_payloads" and appends a reference
+// to the object in its place. Reading events transparently dereferences the
+// data. This allows for events exceeding the max message size of the server.
+// The bucket is created on first use with the storage, replicas, and placement
+// of the stream. Default is no claim check.
+func EventStoreClaimCheck(threshold int) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if threshold < 1 {
+			return fmt.Errorf("rita: claim check threshold must be positive")
+		}
+		o.claimThreshold = threshold
+		return nil
+	})
+}
+
+// payloadsBucket returns the name of the object store bucket for claimed
+// event data.
+func (s *EventStore) payloadsBucket() string {
+	return s.derivedName("payloads")
+}
+
+// payloads returns the object store for claimed event data, creating it if
+// it does not exist.
+func (s *EventStore) payloads() (nats.ObjectStore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.obj != nil {
+		return s.obj, nil
+	}
+
+	bucket := s.payloadsBucket()
+
+	obj, err := s.rt.js.ObjectStore(bucket)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		info, ierr := s.rt.js.StreamInfo(s.stream)
+		if ierr != nil {
+			return nil, ierr
+		}
+
+		obj, err = s.rt.js.CreateObjectStore(&nats.ObjectStoreConfig{
+			Bucket:      bucket,
+			Description: s.derivedDescription(),
+			Storage:     info.Config.Storage,
+			Replicas:    info.Config.Replicas,
+			Placement:   info.Config.Placement,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.obj = obj
+	return obj, nil
+}
+
+// claim moves the data of the message to the object store if it exceeds the
+// threshold and returns the object name, or an empty string if the data was
+// not claimed. A unique object name is used rather than the event ID, since
+// a duplicate or reused ID must not overwrite the data of a stored event.
+func (s *EventStore) claim(msg *nats.Msg) (string, error) {
+	if s.claimThreshold == 0 || len(msg.Data) <= s.claimThreshold {
+		return "", nil
+	}
+
+	obj, err := s.payloads()
+	if err != nil {
+		return "", err
+	}
+
+	name := nuid.Next()
+	if _, err := obj.PutBytes(name, msg.Data); err != nil {
+		return "", err
+	}
+
+	msg.Header.Set(eventClaimHdr, name)
+	msg.Data = nil
+
+	return name, nil
+}
+
+// unclaim deletes the object of claimed data which was not stored, because
+// the publish failed or the event was a duplicate. This is best effort.
+func (s *EventStore) unclaim(name string) {
+	if name == "" {
+		return
+	}
+
+	obj, err := s.payloads()
+	if err != nil {
+		return
+	}
+	_ = obj.Delete(name)
+}
+
+// dereference returns a copy of the message with the claimed data, if any.
+func (s *EventStore) dereference(msg *nats.Msg) (*nats.Msg, error) {
+	name := msg.Header.Get(eventClaimHdr)
+	if name == "" {
+		return msg, nil
+	}
+
+	obj, err := s.payloads()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := obj.GetBytes(name)
+	if err != nil {
+		return nil, fmt.Errorf("claim check %q: %w", name, err)
+	}
+
+	cp := *msg
+	cp.Data = data
+	return &cp, nil
+}
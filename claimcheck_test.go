@@ -0,0 +1,77 @@
+package rita
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestClaimCheck(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	_, err = r.EventStore("orders", EventStoreClaimCheck(0))
+	is.True(err != nil)
+
+	es, err := r.EventStore("orders", EventStoreClaimCheck(64))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	large := strings.Repeat("x", 128)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderPlaced{ID: large}},
+	})
+	is.NoErr(err)
+
+	// Only the large event is claimed.
+	msg, err := r.js.GetMsg("orders", 1)
+	is.NoErr(err)
+	is.Equal(msg.Header.Get(eventClaimHdr), "")
+
+	msg, err = r.js.GetMsg("orders", 2)
+	is.NoErr(err)
+	is.True(msg.Header.Get(eventClaimHdr) != "")
+	is.Equal(len(msg.Data), 0)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(events[1].Data.(*OrderPlaced).ID, large)
+
+	// A duplicate event does not overwrite the claimed data of the stored
+	// event and its own claimed data is removed.
+	_, err = es.Append(ctx, "orders.2", []*Event{{ID: "dup", Data: &OrderPlaced{ID: large}}})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{ID: "dup", Data: &OrderPlaced{ID: strings.Repeat("y", 128)}}})
+	is.NoErr(err)
+
+	events, _, err = es.Load(ctx, "orders.2")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Data.(*OrderPlaced).ID, large)
+
+	obj, err := r.js.ObjectStore("orders_payloads")
+	is.NoErr(err)
+	infos, err := obj.List()
+	is.NoErr(err)
+	is.Equal(len(infos), 2)
+
+	is.NoErr(es.Delete())
+
+	_, err = r.js.ObjectStore("orders_payloads")
+	is.Err(err, nats.ErrStreamNotFound)
+}
@@ -0,0 +1,64 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnexpectedResult = errors.New("rita: unexpected result")
+)
+
+// TypedCommand returns a function which sends commands with data of type C
+// to entity subjects using the dispatcher, e.g. Actors, and returns the data
+// of the first decided event of type E. If no event of type E is decided, an
+// error wrapping ErrUnexpectedResult is returned. This allows typed clients
+// to be composed of fields, for example:
+//
+//	type OrdersClient struct {
+//		PlaceOrder func(ctx context.Context, subject string, cmd *PlaceOrder) (*OrderPlaced, error)
+//	}
+//
+//	client := OrdersClient{
+//		PlaceOrder: rita.TypedCommand[*PlaceOrder, *OrderPlaced](actors),
+//	}
+func TypedCommand[C, E any](d Dispatcher) func(ctx context.Context, subject string, cmd C) (E, error) {
+	return func(ctx context.Context, subject string, cmd C) (E, error) {
+		var zero E
+
+		events, _, err := d.Send(ctx, subject, &Command{Data: cmd})
+		if err != nil {
+			return zero, err
+		}
+
+		for _, e := range events {
+			if v, ok := e.Data.(E); ok {
+				return v, nil
+			}
+		}
+
+		return zero, fmt.Errorf("%w: no event of type %T decided", ErrUnexpectedResult, zero)
+	}
+}
+
+// TypedQuery returns a function which sends queries with data of type Q
+// using the query bus and returns the result of type R. If the result is
+// not of type R, an error wrapping ErrUnexpectedResult is returned. A nil
+// result is returned as the zero value of R.
+func TypedQuery[Q, R any](b *QueryBus) func(ctx context.Context, query Q) (R, error) {
+	return func(ctx context.Context, query Q) (R, error) {
+		var zero R
+
+		v, err := b.Ask(ctx, &Query{Data: query})
+		if err != nil || v == nil {
+			return zero, err
+		}
+
+		r, ok := v.(R)
+		if !ok {
+			return zero, fmt.Errorf("%w: result of type %T", ErrUnexpectedResult, v)
+		}
+		return r, nil
+	}
+}
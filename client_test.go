@@ -0,0 +1,79 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestTypedClient(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr, err := types.NewRegistry(map[string]*types.Type{
+		"order-placed": {Init: func() any { return &OrderPlaced{} }},
+		"place-order":  {Init: func() any { return &PlaceOrder{} }},
+		"ship-order":   {Init: func() any { return &ShipOrder{} }},
+		"get-order":    {Init: func() any { return &GetOrder{} }},
+		"order-view":   {Init: func() any { return &OrderView{} }},
+	})
+	is.NoErr(err)
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	actors, err := es.Actors(func() Entity { return &Order{} })
+	is.NoErr(err)
+	is.NoErr(actors.Listen(ctx))
+
+	qb, err := r.QueryBus()
+	is.NoErr(err)
+	is.NoErr(HandleQuery(qb, "get-order", func(ctx context.Context, q *GetOrder) (*OrderView, error) {
+		return &OrderView{ID: q.ID}, nil
+	}))
+	is.NoErr(qb.Listen(ctx))
+
+	client := struct {
+		PlaceOrder func(ctx context.Context, subject string, cmd *PlaceOrder) (*OrderPlaced, error)
+		ShipOrder  func(ctx context.Context, subject string, cmd *ShipOrder) (*OrderPlaced, error)
+		GetOrder   func(ctx context.Context, q *GetOrder) (*OrderView, error)
+		GetWrong   func(ctx context.Context, q *GetOrder) (*OrderPlaced, error)
+	}{
+		PlaceOrder: TypedCommand[*PlaceOrder, *OrderPlaced](actors),
+		ShipOrder:  TypedCommand[*ShipOrder, *OrderPlaced](actors),
+		GetOrder:   TypedQuery[*GetOrder, *OrderView](qb),
+		GetWrong:   TypedQuery[*GetOrder, *OrderPlaced](qb),
+	}
+
+	placed, err := client.PlaceOrder(ctx, "orders.1", &PlaceOrder{ID: "1"})
+	is.NoErr(err)
+	is.Equal(placed, &OrderPlaced{ID: "1"})
+
+	// Placing the order again decides no events.
+	_, err = client.PlaceOrder(ctx, "orders.1", &PlaceOrder{ID: "1"})
+	is.Err(err, ErrUnexpectedResult)
+
+	_, err = client.ShipOrder(ctx, "orders.2", &ShipOrder{ID: "2"})
+	is.True(err != nil)
+
+	view, err := client.GetOrder(ctx, &GetOrder{ID: "1"})
+	is.NoErr(err)
+	is.Equal(view, &OrderView{ID: "1"})
+
+	_, err = client.GetWrong(ctx, &GetOrder{ID: "1"})
+	is.Err(err, ErrUnexpectedResult)
+}
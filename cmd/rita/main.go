@@ -0,0 +1,129 @@
+// Command rita provides tooling for rita-based services.
+//
+// Usage:
+//
+//	rita gen [-o dir] contract.json
+//	rita scan [-marker EventType] [-func Types] [-name rita_types.gen.go] dir...
+//
+// The gen command generates Go structs and type registry wiring from a
+// contract into "{contract}.gen.go". If any type has prior versions, the
+// skeletons of the migrate functions are generated into
+// "{contract}_migrate.go", which is only written if it does not exist, so it
+// can be completed by hand.
+//
+// The scan command generates a function returning the types of the package
+// in each directory which implement the marker method, keyed by the names
+// the method returns, so new types are registered by running go generate,
+// e.g. using "//go:generate rita scan .".
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bruth/rita/internal/gen"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "rita: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+const usage = `usage:
+  rita gen [-o dir] contract.json
+  rita scan [-marker EventType] [-func Types] [-name rita_types.gen.go] dir...`
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "gen":
+		return runGen(args[1:])
+	case "scan":
+		return runScan(args[1:])
+	}
+	return errors.New(usage)
+}
+
+func runScan(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	marker := fs.String("marker", "EventType", "name of the marker method returning the type name")
+	fn := fs.String("func", "Types", "name of the generated function")
+	name := fs.String("name", "rita_types.gen.go", "name of the generated file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: rita scan [-marker EventType] [-func Types] [-name rita_types.gen.go] dir...")
+	}
+
+	for _, dir := range fs.Args() {
+		s, err := gen.Scan(dir, *marker, *fn)
+		if err != nil {
+			return err
+		}
+
+		src, err := gen.GenerateScan(s)
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, *name), src, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runGen(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	out := fs.String("o", ".", "output directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: rita gen [-o dir] contract.json")
+	}
+
+	path := fs.Arg(0)
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	c, err := gen.Parse(b)
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	src, err := gen.Generate(c)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(*out, base+".gen.go"), src, 0o644); err != nil {
+		return err
+	}
+
+	src, err = gen.GenerateMigrations(c)
+	if err != nil || src == nil {
+		return err
+	}
+
+	mpath := filepath.Join(*out, base+"_migrate.go")
+	if _, err := os.Stat(mpath); err == nil {
+		return nil
+	}
+	return os.WriteFile(mpath, src, 0o644)
+}
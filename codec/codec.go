@@ -4,6 +4,7 @@ import "errors"
 
 var (
 	ErrCodecNotRegistered = errors.New("rita: codec not registered")
+	ErrUnknownField       = errors.New("rita: unknown field")
 
 	Default = JSON
 
@@ -20,3 +21,10 @@ type Codec interface {
 	Marshal(interface{}) ([]byte, error)
 	Unmarshal([]byte, interface{}) error
 }
+
+// StrictCodec is implemented by codecs which can reject data containing
+// fields unknown to the value being unmarshaled.
+type StrictCodec interface {
+	Codec
+	UnmarshalStrict([]byte, interface{}) error
+}
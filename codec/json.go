@@ -1,6 +1,11 @@
 package codec
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
 
 var (
 	JSON Codec = &jsonCodec{}
@@ -22,3 +27,16 @@ func (*jsonCodec) Unmarshal(b []byte, v interface{}) error {
 	}
 	return json.Unmarshal(b, v)
 }
+
+func (*jsonCodec) UnmarshalStrict(b []byte, v interface{}) error {
+	if len(b) == 0 {
+		return nil
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	err := d.Decode(v)
+	if err != nil && strings.HasPrefix(err.Error(), "json: unknown field") {
+		return fmt.Errorf("%w: %s", ErrUnknownField, err)
+	}
+	return err
+}
@@ -3,6 +3,8 @@ package codec
 import (
 	"testing"
 	"time"
+
+	"github.com/bruth/rita/testutil"
 )
 
 func BenchmarkJSONMarshal(b *testing.B) {
@@ -67,3 +69,24 @@ func BenchmarkJSONUnmarshal(b *testing.B) {
 		_ = JSON.Unmarshal(y, &v2)
 	}
 }
+
+func TestJSONUnmarshalStrict(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	type T struct {
+		A string
+	}
+
+	b := []byte(`{"A": "foo", "B": 1}`)
+
+	var v T
+	is.NoErr(JSON.Unmarshal(b, &v))
+	is.Equal(v.A, "foo")
+
+	err := JSON.(StrictCodec).UnmarshalStrict(b, &v)
+	is.Err(err, ErrUnknownField)
+
+	err = JSON.(StrictCodec).UnmarshalStrict([]byte(`{"A": "bar"}`), &v)
+	is.NoErr(err)
+	is.Equal(v.A, "bar")
+}
@@ -1,6 +1,10 @@
 package codec
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
+
 	"github.com/vmihailenco/msgpack/v5"
 )
 
@@ -21,3 +25,13 @@ func (*msgpackCodec) Marshal(v interface{}) ([]byte, error) {
 func (*msgpackCodec) Unmarshal(b []byte, v interface{}) error {
 	return msgpack.Unmarshal(b, v)
 }
+
+func (*msgpackCodec) UnmarshalStrict(b []byte, v interface{}) error {
+	d := msgpack.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields(true)
+	err := d.Decode(v)
+	if err != nil && strings.HasPrefix(err.Error(), "msgpack: unknown field") {
+		return fmt.Errorf("%w: %s", ErrUnknownField, err)
+	}
+	return err
+}
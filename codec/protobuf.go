@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 var (
@@ -31,3 +32,47 @@ func (*protoBufCodec) Unmarshal(b []byte, v interface{}) error {
 	}
 	return proto.Unmarshal(b, m)
 }
+
+func (c *protoBufCodec) UnmarshalStrict(b []byte, v interface{}) error {
+	if err := c.Unmarshal(b, v); err != nil {
+		return err
+	}
+	if hasUnknownFields(v.(proto.Message).ProtoReflect()) {
+		return fmt.Errorf("%w: %T", ErrUnknownField, v)
+	}
+	return nil
+}
+
+// hasUnknownFields returns true if the message or any nested message has
+// unknown fields.
+func hasUnknownFields(m protoreflect.Message) bool {
+	if len(m.GetUnknown()) > 0 {
+		return true
+	}
+
+	var found bool
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			if fd.Message() == nil {
+				break
+			}
+			l := v.List()
+			for i := 0; i < l.Len() && !found; i++ {
+				found = hasUnknownFields(l.Get(i).Message())
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				break
+			}
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				found = hasUnknownFields(mv.Message())
+				return !found
+			})
+		case fd.Message() != nil:
+			found = hasUnknownFields(v.Message())
+		}
+		return !found
+	})
+	return found
+}
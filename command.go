@@ -0,0 +1,203 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// wrapCommand validates the command and sets defaults for the ID and time,
+// and stamps the identity of the context in the metadata.
+func (r *Rita) wrapCommand(ctx context.Context, cmd *Command) error {
+	t, err := r.resolveType(cmd.Type, cmd.Data)
+	if err != nil {
+		return fmt.Errorf("command: %w", err)
+	}
+	cmd.Type = t
+
+	if err := validateData(ctx, cmd.Data); err != nil {
+		return err
+	}
+
+	if cmd.ID == "" {
+		cmd.ID = r.id.New()
+	}
+
+	if cmd.Time.IsZero() {
+		cmd.Time = r.clock.Now().Local()
+	}
+
+	cmd.Meta = stampIdentity(ctx, cmd.Meta)
+
+	return nil
+}
+
+// PackCommand packs a command into a NATS message. The command envelope
+// is mapped to headers using the same layout as events, so commands can
+// be sent by any client following the convention. If the ID or time are
+// not set, defaults are set on the command. Commands implementing
+// ValidateContext are validated with a background context.
+func (r *Rita) PackCommand(subject string, cmd *Command) (*nats.Msg, error) {
+	return r.packCommand(context.Background(), subject, cmd)
+}
+
+func (r *Rita) packCommand(ctx context.Context, subject string, cmd *Command) (*nats.Msg, error) {
+	if err := r.wrapCommand(ctx, cmd); err != nil {
+		return nil, err
+	}
+
+	data, codecName, err := r.encodeData(cmd.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+
+	msg.Header.Set(nats.MsgIdHdr, cmd.ID)
+	msg.Header.Set(eventTypeHdr, cmd.Type)
+	msg.Header.Set(eventTimeHdr, cmd.Time.Format(eventTimeFormat))
+	msg.Header.Set(eventCodecHdr, codecName)
+
+	packMeta(msg.Header, cmd.Meta)
+
+	return msg, nil
+}
+
+// UnpackCommand unpacks a Command from a NATS message.
+func (r *Rita) UnpackCommand(msg *nats.Msg) (*Command, error) {
+	cmdType := msg.Header.Get(eventTypeHdr)
+
+	data, err := r.decodeData(msg.Header.Get(eventCodecHdr), cmdType, 0, msg.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	cmdTime, err := time.Parse(eventTimeFormat, msg.Header.Get(eventTimeHdr))
+	if err != nil {
+		return nil, fmt.Errorf("unpack: failed to parse command time: %s", err)
+	}
+
+	return &Command{
+		ID:   msg.Header.Get(nats.MsgIdHdr),
+		Time: cmdTime,
+		Type: cmdType,
+		Data: data,
+		Meta: unpackMeta(msg.Header),
+	}, nil
+}
+
+// replyEvent is the representation of an event in a command reply. The data
+// is encoded with the codec used when the event was appended.
+type replyEvent struct {
+	ID       string            `json:"id"`
+	Type     string            `json:"type"`
+	Time     time.Time         `json:"time"`
+	Codec    string            `json:"codec"`
+	Data     []byte            `json:"data"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Subject  string            `json:"subject"`
+	Sequence uint64            `json:"seq"`
+}
+
+// commandReply is the reply to a command sent over NATS.
+type commandReply struct {
+	Sequence  uint64        `json:"seq"`
+	Events    []*replyEvent `json:"events,omitempty"`
+	Rejection *Rejection    `json:"rejection,omitempty"`
+	Error     string        `json:"error,omitempty"`
+
+	// Conflict is set if the error is a sequence conflict.
+	Conflict *Error `json:"conflict,omitempty"`
+}
+
+// packCommandReply encodes the result of executing a command.
+func (r *Rita) packCommandReply(events []*Event, seq uint64, err error) ([]byte, error) {
+	rep := commandReply{
+		Sequence: seq,
+	}
+
+	var rej *Rejection
+	if errors.As(err, &rej) {
+		rep.Rejection = rej
+	} else if err != nil {
+		rep.Error = err.Error()
+
+		if errors.Is(err, ErrSequenceConflict) {
+			var e *Error
+			if !errors.As(err, &e) {
+				e = &Error{Op: "append"}
+			}
+			rep.Conflict = e
+		}
+	}
+
+	for _, e := range events {
+		data, codecName, err := r.encodeData(e.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		rep.Events = append(rep.Events, &replyEvent{
+			ID:       e.ID,
+			Type:     e.Type,
+			Time:     e.Time,
+			Codec:    codecName,
+			Data:     data,
+			Meta:     e.Meta,
+			Subject:  e.Subject,
+			Sequence: e.Sequence,
+		})
+	}
+
+	return json.Marshal(&rep)
+}
+
+// unpackCommandReply decodes the result of executing a command.
+func (r *Rita) unpackCommandReply(msg *nats.Msg) ([]*Event, uint64, error) {
+	var rep commandReply
+	if err := json.Unmarshal(msg.Data, &rep); err != nil {
+		return nil, 0, fmt.Errorf("unpack: failed to decode command reply: %w", err)
+	}
+
+	if rep.Rejection != nil {
+		return nil, rep.Sequence, rep.Rejection
+	}
+
+	if rep.Conflict != nil {
+		rep.Conflict.Err = ErrSequenceConflict
+		return nil, rep.Sequence, rep.Conflict
+	}
+
+	if rep.Error != "" {
+		// Replies of earlier versions only have the message.
+		if rep.Error == ErrSequenceConflict.Error() {
+			return nil, rep.Sequence, ErrSequenceConflict
+		}
+		return nil, rep.Sequence, errors.New(rep.Error)
+	}
+
+	events := make([]*Event, len(rep.Events))
+	for i, e := range rep.Events {
+		data, err := r.decodeData(e.Codec, e.Type, 0, e.Data)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		events[i] = &Event{
+			ID:       e.ID,
+			Type:     e.Type,
+			Time:     e.Time,
+			Data:     data,
+			Meta:     e.Meta,
+			Subject:  e.Subject,
+			Sequence: e.Sequence,
+		}
+	}
+
+	return events, rep.Sequence, nil
+}
@@ -0,0 +1,58 @@
+package rita
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestPackUnpackCommand(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	clock := testutil.NewClock(time.Second)
+	idgen := testutil.NewIDGen(&staticID{"abc"})
+
+	r := &Rita{
+		id:    idgen,
+		clock: clock,
+		types: newOrderTypes(t),
+	}
+
+	cmd := &Command{
+		Data: &PlaceOrder{ID: "1"},
+		Meta: map[string]string{
+			"actor": "bob",
+		},
+	}
+
+	msg, err := r.PackCommand("orders.1", cmd)
+	is.NoErr(err)
+
+	is.Equal(msg.Header.Get(nats.MsgIdHdr), "abc")
+	is.Equal(msg.Header.Get(eventTypeHdr), "place-order")
+	is.Equal(msg.Header.Get(eventMetaPrefixHdr+"actor"), "bob")
+
+	cmd2, err := r.UnpackCommand(msg)
+	is.NoErr(err)
+
+	is.Equal(cmd2.ID, "abc")
+	is.Equal(cmd2.Type, "place-order")
+	is.True(cmd2.Time.Equal(clock.Last()))
+	is.Equal(cmd2.Data, cmd.Data)
+	is.Equal(cmd2.Meta, cmd.Meta)
+
+	// Type is required without a registry.
+	r.types = nil
+	_, err = r.PackCommand("orders.1", &Command{Data: []byte("x")})
+	is.Err(err, ErrEventTypeRequired)
+}
+
+type staticID struct {
+	id string
+}
+
+func (s *staticID) New() string {
+	return s.id
+}
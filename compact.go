@@ -0,0 +1,180 @@
+package rita
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/bruth/rita/codec"
+	"github.com/nats-io/nats.go"
+)
+
+type compactOpts struct {
+	dryRun bool
+}
+
+type compactOptFn func(o *compactOpts) error
+
+func (f compactOptFn) compactOpt(o *compactOpts) error {
+	return f(o)
+}
+
+// CompactOption is an option for CompactCheckpoints.
+type CompactOption interface {
+	compactOpt(o *compactOpts) error
+}
+
+// CompactDryRun reports the checkpoints which would be deleted without
+// deleting them.
+func CompactDryRun() CompactOption {
+	return compactOptFn(func(o *compactOpts) error {
+		o.dryRun = true
+		return nil
+	})
+}
+
+// CompactionReport is the result of a compaction of checkpoints.
+type CompactionReport struct {
+	// DryRun is true if nothing was deleted.
+	DryRun bool
+
+	// Kept is the number of checkpoints kept.
+	Kept int
+
+	// Stale are the keys of checkpoints which can no longer be restored,
+	// since they are of another version of the model type, of a model type
+	// which is not checkpointed, or cannot be decoded.
+	Stale []string
+
+	// Orphaned are the keys of checkpoints of subjects without events, e.g.
+	// of entities which were removed.
+	Orphaned []string
+}
+
+// CompactCheckpoints deletes the checkpoints which would never be restored,
+// see EventStoreCheckpoint, so they do not accumulate as model types are
+// versioned or entities are removed. Only the latest checkpoint of each model
+// type and subject is kept by the bucket, and the delete markers left by
+// previous deletes are removed. It is typically run periodically by one
+// instance.
+func (s *EventStore) CompactCheckpoints(ctx context.Context, opts ...CompactOption) (*CompactionReport, error) {
+	var o compactOpts
+	for _, opt := range opts {
+		if err := opt.compactOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.readOnly && !o.dryRun {
+		return nil, ErrReadOnly
+	}
+	if s.backend != nil {
+		return nil, ErrBackendUnsupported
+	}
+
+	report := &CompactionReport{DryRun: o.dryRun}
+
+	kv, err := s.rt.js.KeyValue(s.checkpointBucket())
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := kv.Keys(nats.Context(ctx))
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	// Versions of the model types which are checkpointed.
+	versions := make(map[string]int)
+	if s.rt.types != nil {
+		for t := range s.checkpoints {
+			v, err := s.rt.types.Init(t)
+			if err != nil {
+				continue
+			}
+			if versions[t], err = s.rt.types.Version(v); err != nil {
+				delete(versions, t)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		stale, orphaned, err := s.compactable(ctx, kv, key, versions)
+		if err != nil {
+			return report, err
+		}
+
+		switch {
+		case stale:
+			report.Stale = append(report.Stale, key)
+		case orphaned:
+			report.Orphaned = append(report.Orphaned, key)
+		default:
+			report.Kept++
+			continue
+		}
+
+		if o.dryRun {
+			continue
+		}
+		if err := kv.Purge(key); err != nil {
+			return report, err
+		}
+	}
+
+	if o.dryRun {
+		return report, nil
+	}
+	return report, kv.PurgeDeletes(nats.DeleteMarkersOlderThan(-1), nats.Context(ctx))
+}
+
+// compactable returns whether the checkpoint of the key is stale or of a
+// subject without events.
+func (s *EventStore) compactable(ctx context.Context, kv nats.KeyValue, key string, versions map[string]int) (bool, bool, error) {
+	i := strings.LastIndexByte(key, '.')
+	if i < 0 {
+		return true, false, nil
+	}
+
+	version, ok := versions[key[:i]]
+	if !ok {
+		return true, false, nil
+	}
+
+	subject, err := base64.RawURLEncoding.DecodeString(key[i+1:])
+	if err != nil {
+		return true, false, nil
+	}
+
+	entry, err := kv.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(entry.Value(), &cp); err != nil || cp.Version != version {
+		return true, false, nil
+	}
+	if _, ok := codec.Codecs[cp.Codec]; !ok {
+		return true, false, nil
+	}
+
+	last, err := s.lastMsgForSubject(ctx, string(subject))
+	if err != nil {
+		return false, false, err
+	}
+	return false, last.Sequence == 0, nil
+}
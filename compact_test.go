@@ -0,0 +1,98 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreCompactCheckpoints(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-summary", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreCheckpoint("order-summary", 1))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	// Nothing to compact before any checkpoint is saved.
+	report, err := es.CompactCheckpoints(ctx)
+	is.NoErr(err)
+	is.Equal(report.Kept, 0)
+
+	for _, subject := range []string{"orders.1", "orders.2"} {
+		_, err = es.Append(ctx, subject, []*Event{{Data: &OrderPlaced{ID: subject}}})
+		is.NoErr(err)
+	}
+
+	for _, subject := range []string{"orders.1", "orders.2", "orders.*"} {
+		var m OrderSummary
+		_, err = es.Evolve(ctx, subject, &m)
+		is.NoErr(err)
+	}
+
+	// Checkpoints of a previous version and of a model type which is no
+	// longer checkpointed.
+	kv, err := es.checkpointKV()
+	is.NoErr(err)
+
+	b, _ := json.Marshal(&checkpoint{Sequence: 1, Version: 1, Codec: "json", Data: []byte(`{}`)})
+	_, err = kv.Put(checkpointKey("order-summary", "orders.3"), b)
+	is.NoErr(err)
+	_, err = kv.Put(checkpointKey("order-count", "orders.1"), b)
+	is.NoErr(err)
+
+	// The events of an entity are removed.
+	is.NoErr(es.purgeSubject("orders.2"))
+
+	stale := []string{
+		checkpointKey("order-count", "orders.1"),
+		checkpointKey("order-summary", "orders.3"),
+	}
+	orphaned := []string{checkpointKey("order-summary", "orders.2")}
+
+	report, err = es.CompactCheckpoints(ctx, CompactDryRun())
+	is.NoErr(err)
+	is.True(report.DryRun)
+	is.Equal(report.Kept, 2)
+	is.Equal(report.Stale, stale)
+	is.Equal(report.Orphaned, orphaned)
+
+	keys, err := kv.Keys()
+	is.NoErr(err)
+	is.Equal(len(keys), 5)
+
+	report, err = es.CompactCheckpoints(ctx)
+	is.NoErr(err)
+	is.Equal(report.Kept, 2)
+	is.Equal(report.Stale, stale)
+	is.Equal(report.Orphaned, orphaned)
+
+	keys, err = kv.Keys()
+	is.NoErr(err)
+	is.Equal(len(keys), 2)
+
+	// The kept checkpoints are restored.
+	var m OrderSummary
+	_, err = es.Evolve(ctx, "orders.*", &m)
+	is.NoErr(err)
+	is.Equal(m.applied, 0)
+	is.Equal(m.Placed, 2)
+}
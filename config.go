@@ -0,0 +1,454 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrInvalidConfig      = errors.New("rita: invalid event store config")
+	ErrIncompatibleConfig = errors.New("rita: incompatible event store config")
+)
+
+// incompatibleFields are the stream config fields which cannot be changed
+// once a stream is created.
+var incompatibleFields = []string{"Storage", "Retention", "Mirror"}
+
+// EventStoreSource identifies an event store whose events are mirrored or
+// sourced into another event store.
+type EventStoreSource struct {
+	// Name of the source event store. The name is mapped into the context
+	// of the Rita instance unless the store is external.
+	Name string
+
+	// FilterSubject optionally limits the events to the subject.
+	FilterSubject string
+
+	// StartSequence optionally sets the first sequence to copy.
+	StartSequence uint64
+
+	// StartTime optionally sets the time of the first event to copy.
+	StartTime *time.Time
+
+	// External identifies a store in another account or JetStream domain.
+	// In this case, Name is the name of the stream as is.
+	External *nats.ExternalStream
+
+	// raw indicates the name and filter subject are already mapped.
+	raw bool
+}
+
+// EventStoreConfig is the configuration of an event store.
+type EventStoreConfig struct {
+	// Description of the event store.
+	Description string
+
+	// Subjects bound to the store, which must be prefixed with "{name}.",
+	// since the events of a store are read from "{name}.>". Default is
+	// "{name}.>".
+	Subjects []string
+
+	// Storage type of the store. Default is file storage.
+	Storage nats.StorageType
+
+	// Replicas is the number of replicas of the store in a cluster.
+	Replicas int
+
+	// MaxAge is the max age of events retained by the store.
+	MaxAge time.Duration
+
+	// MaxBytes is the max number of bytes retained by the store.
+	MaxBytes int64
+
+	// MaxMsgs is the max number of events retained by the store.
+	MaxMsgs int64
+
+	// Discard is the policy applied once a limit is reached. The default,
+	// nats.DiscardOld, removes the oldest events. nats.DiscardNew rejects
+	// appends instead, which keeps the history intact.
+	Discard nats.DiscardPolicy
+
+	// Duplicates is the window in which an event ID is tracked to detect
+	// duplicate appends. Default is two minutes. It cannot exceed MaxAge.
+	Duplicates time.Duration
+
+	// Placement optionally places the store in a cluster or on servers
+	// with the tags.
+	Placement *nats.Placement
+
+	// Mirror makes the store a read-only replica of another store, for
+	// example to support low-latency loads in another region. Events keep
+	// the subjects of the origin store, so loads use the same subjects.
+	Mirror *EventStoreSource
+
+	// Sources aggregates the events of other stores into this store.
+	Sources []*EventStoreSource
+
+	// Advanced is an optional stream config for settings not covered above.
+	// Fields set above take precedence. Settings which would break the
+	// guarantees of the store are rejected: the name, mirror, and sources,
+	// a retention other than limits, no acks, sealing, and rollups. If
+	// DenyDelete is set, Redact, Expire, and quarantining duplicates fail
+	// with ErrDeletesDenied, and if DenyPurge is set, deleting a store
+	// sharing its stream fails with ErrPurgesDenied. Subjects are mapped
+	// into the context like Subjects.
+	Advanced *nats.StreamConfig
+
+	// Explicit lists the fields above whose zero value is applied rather
+	// than treated as unset: "Storage", "MaxAge", "MaxBytes", "MaxMsgs", and
+	// "Discard". For example, listing "Storage" requires file storage, so
+	// Ensure fails for a memory store, and listing "MaxAge" removes the max
+	// age of the store on Update.
+	Explicit []string
+}
+
+// explicitFields are the fields which may be listed in Explicit.
+var explicitFields = []string{"Storage", "MaxAge", "MaxBytes", "MaxMsgs", "Discard"}
+
+// explicit returns true if the field is listed in Explicit.
+func (c *EventStoreConfig) explicit(field string) bool {
+	return containsString(c.Explicit, field)
+}
+
+func (c *EventStoreConfig) validate(name string, shared bool) error {
+	if c.Duplicates < 0 {
+		return fmt.Errorf("%w: duplicates window must be positive", ErrInvalidConfig)
+	}
+	if c.MaxAge > 0 && c.Duplicates > c.MaxAge {
+		return fmt.Errorf("%w: duplicates window cannot exceed max age", ErrInvalidConfig)
+	}
+
+	for _, f := range c.Explicit {
+		if !containsString(explicitFields, f) {
+			return fmt.Errorf("%w: field %q cannot be explicit", ErrInvalidConfig, f)
+		}
+	}
+
+	for _, subj := range c.Subjects {
+		if err := validateStoreSubject(name, subj); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidConfig, err)
+		}
+	}
+
+	if c.Advanced != nil {
+		if err := c.validateAdvanced(name); err != nil {
+			return fmt.Errorf("%w: advanced: %s", ErrInvalidConfig, err)
+		}
+	}
+
+	if c.Mirror != nil {
+		if len(c.Subjects) > 0 {
+			return fmt.Errorf("%w: mirror cannot have subjects", ErrInvalidConfig)
+		}
+		if len(c.Sources) > 0 {
+			return fmt.Errorf("%w: mirror cannot have sources", ErrInvalidConfig)
+		}
+		if shared {
+			return fmt.Errorf("%w: mirror cannot use a shared stream", ErrInvalidConfig)
+		}
+		if err := c.Mirror.validate(); err != nil {
+			return fmt.Errorf("%w: mirror: %s", ErrInvalidConfig, err)
+		}
+	}
+
+	for _, src := range c.Sources {
+		if err := src.validate(); err != nil {
+			return fmt.Errorf("%w: source: %s", ErrInvalidConfig, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *EventStoreConfig) validateAdvanced(name string) error {
+	a := c.Advanced
+
+	switch {
+	case a.Name != "":
+		return errors.New("name cannot be set")
+	case a.Mirror != nil || len(a.Sources) > 0:
+		return errors.New("use Mirror and Sources instead")
+	case a.Retention != nats.LimitsPolicy:
+		return errors.New("retention must be limits")
+	case a.NoAck:
+		return errors.New("acks cannot be disabled")
+	case a.Sealed:
+		return errors.New("store cannot be sealed")
+	case a.AllowRollup:
+		return errors.New("rollups cannot be allowed")
+	}
+
+	if len(a.Subjects) > 0 {
+		if len(c.Subjects) > 0 {
+			return errors.New("subjects set twice")
+		}
+		if c.Mirror != nil {
+			return errors.New("mirror cannot have subjects")
+		}
+		for _, subj := range a.Subjects {
+			if err := validateStoreSubject(name, subj); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateStoreSubject ensures the configured subject is well-formed and in
+// the store, i.e. prefixed with the name of the store.
+func validateStoreSubject(name, subj string) error {
+	if err := validateSubject(subj, true); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(subj, name+".") {
+		return fmt.Errorf("subject %q must be prefixed with %q", subj, name+".")
+	}
+	return nil
+}
+
+func (s *EventStoreSource) validate() error {
+	if s.Name == "" {
+		return errors.New("name required")
+	}
+	if s.External == nil && !nameRegex.MatchString(s.Name) {
+		return fmt.Errorf("name %q has invalid characters", s.Name)
+	}
+	if s.StartSequence > 0 && s.StartTime != nil {
+		return errors.New("start sequence and start time are mutually exclusive")
+	}
+	if s.FilterSubject != "" {
+		if err := validateSubject(s.FilterSubject, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamSource maps the source onto a stream source.
+func (r *Rita) streamSource(s *EventStoreSource) *nats.StreamSource {
+	ss := &nats.StreamSource{
+		Name:          s.Name,
+		OptStartSeq:   s.StartSequence,
+		OptStartTime:  s.StartTime,
+		FilterSubject: s.FilterSubject,
+		External:      s.External,
+	}
+
+	if s.External == nil && !s.raw {
+		ss.Name = r.resourceName(s.Name)
+		if ss.FilterSubject != "" {
+			ss.FilterSubject = r.subject(ss.FilterSubject)
+		}
+	}
+
+	return ss
+}
+
+// streamConfig maps the event store config onto a stream config. Fields which
+// are not set in the config, i.e. zero and not explicit, are taken from the
+// current stream config, if any, or the advanced config. If the advanced
+// config is set, it replaces the current config apart from the subjects,
+// mirror, and sources, and deletes and purges remain allowed.
+func (s *EventStore) streamConfig(cur *nats.StreamConfig, c *EventStoreConfig) *nats.StreamConfig {
+	sc := &nats.StreamConfig{}
+	if cur != nil {
+		*sc = *cur
+	}
+
+	if c.Advanced != nil {
+		a := *c.Advanced
+		if len(a.Subjects) == 0 {
+			a.Subjects = sc.Subjects
+		} else {
+			a.Subjects = nil
+			for _, subj := range c.Advanced.Subjects {
+				a.Subjects = append(a.Subjects, s.subject(subj))
+			}
+		}
+		a.Mirror = sc.Mirror
+		a.Sources = sc.Sources
+		*sc = a
+	}
+
+	sc.Name = s.stream
+
+	if c.Description != "" {
+		sc.Description = c.Description
+	}
+	if c.Storage != nats.FileStorage || c.explicit("Storage") {
+		sc.Storage = c.Storage
+	}
+	if c.Replicas != 0 {
+		sc.Replicas = c.Replicas
+	}
+	if c.MaxAge != 0 || c.explicit("MaxAge") {
+		sc.MaxAge = c.MaxAge
+	}
+	// The server stores no limit as -1.
+	if c.MaxBytes != 0 {
+		sc.MaxBytes = c.MaxBytes
+	} else if c.explicit("MaxBytes") {
+		sc.MaxBytes = -1
+	}
+	if c.MaxMsgs != 0 {
+		sc.MaxMsgs = c.MaxMsgs
+	} else if c.explicit("MaxMsgs") {
+		sc.MaxMsgs = -1
+	}
+	if c.Discard != nats.DiscardOld || c.explicit("Discard") {
+		sc.Discard = c.Discard
+	}
+	if c.Duplicates != 0 {
+		sc.Duplicates = c.Duplicates
+	}
+	if c.Placement != nil {
+		sc.Placement = c.Placement
+	}
+
+	if len(c.Subjects) > 0 {
+		sc.Subjects = nil
+		for _, subj := range c.Subjects {
+			sc.Subjects = append(sc.Subjects, s.subject(subj))
+		}
+	}
+
+	if c.Mirror != nil {
+		sc.Mirror = s.rt.streamSource(c.Mirror)
+	}
+
+	if len(c.Sources) > 0 {
+		sc.Sources = nil
+		for _, src := range c.Sources {
+			sc.Sources = append(sc.Sources, s.rt.streamSource(src))
+		}
+	}
+
+	return sc
+}
+
+// ConfigDrift is a difference between the desired and actual configuration
+// of the stream of an event store.
+type ConfigDrift struct {
+	// Field is the name of the nats.StreamConfig field.
+	Field string
+
+	Desired any
+	Actual  any
+}
+
+// Diff compares the config with the actual configuration of the store and
+// returns the fields that would change if the config was applied by Update.
+// Fields not set in the config are not compared, so fields whose zero value
+// is desired must be listed in Explicit. No drift is indicated by an
+// empty result.
+func (s *EventStore) Diff(ctx context.Context, config *EventStoreConfig) ([]*ConfigDrift, error) {
+	cur, sc, err := s.desiredConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffStreamConfig(sc, cur), nil
+}
+
+// defaultDuplicates is the duplicate window the server defaults to.
+const defaultDuplicates = 2 * time.Minute
+
+// normalizeStreamConfig returns a copy of the config with unset fields
+// replaced by the values the server stores for them, e.g. no limit as -1, so
+// a config passed to the server compares equal to the config it returns.
+func normalizeStreamConfig(sc *nats.StreamConfig) *nats.StreamConfig {
+	c := *sc
+	for _, v := range []*int64{&c.MaxMsgs, &c.MaxBytes, &c.MaxMsgsPerSubject} {
+		if *v == 0 {
+			*v = -1
+		}
+	}
+	if c.MaxConsumers == 0 {
+		c.MaxConsumers = -1
+	}
+	if c.MaxMsgSize == 0 {
+		c.MaxMsgSize = -1
+	}
+	if c.Replicas == 0 {
+		c.Replicas = 1
+	}
+	if c.Duplicates == 0 {
+		c.Duplicates = defaultDuplicates
+		if c.MaxAge > 0 && c.MaxAge < defaultDuplicates {
+			c.Duplicates = c.MaxAge
+		}
+	}
+	return &c
+}
+
+func diffStreamConfig(desired, actual *nats.StreamConfig) []*ConfigDrift {
+	dv := reflect.ValueOf(normalizeStreamConfig(desired)).Elem()
+	av := reflect.ValueOf(normalizeStreamConfig(actual)).Elem()
+
+	var drift []*ConfigDrift
+	for i := 0; i < dv.NumField(); i++ {
+		d := dv.Field(i).Interface()
+		a := av.Field(i).Interface()
+		if !reflect.DeepEqual(d, a) {
+			drift = append(drift, &ConfigDrift{
+				Field:   dv.Type().Field(i).Name,
+				Desired: d,
+				Actual:  a,
+			})
+		}
+	}
+	return drift
+}
+
+// Ensure creates the event store if it does not exist, or updates it if the
+// config differs from the actual configuration. Differences which cannot be
+// applied to an existing store, such as the storage type, result in
+// ErrIncompatibleConfig without any change. Like Diff, fields whose zero value
+// is desired, such as file storage, must be listed in Explicit. This is
+// intended to be called at startup of a deployment.
+func (s *EventStore) Ensure(ctx context.Context, config *EventStoreConfig) error {
+	if s.bind {
+		return ErrExternallyManaged
+	}
+	if s.backend != nil {
+		return ErrBackendUnsupported
+	}
+
+	cur, sc, err := s.desiredConfig(ctx, config)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		return s.Create(config)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The shared stream exists, but the store has not been added to it.
+	if s.shared {
+		if own, _ := s.ownSubjects(cur.Subjects); len(own) == 0 {
+			return s.Create(config)
+		}
+	}
+
+	drift := diffStreamConfig(sc, cur)
+	if len(drift) == 0 {
+		return nil
+	}
+
+	for _, d := range drift {
+		for _, f := range incompatibleFields {
+			if d.Field == f {
+				return fmt.Errorf("%w: %s cannot be changed", ErrIncompatibleConfig, f)
+			}
+		}
+	}
+
+	_, err = s.rt.js.UpdateStream(sc, nats.Context(ctx))
+	return err
+}
@@ -0,0 +1,157 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrPositionOutOfRange = errors.New("rita: debugger: position out of range")
+)
+
+// DebugFrame is the state of a model after a number of events of a subject
+// were applied.
+type DebugFrame struct {
+	// Position is the number of events applied, starting at zero for the
+	// initial state.
+	Position int
+
+	// Event is the last event applied, or nil at position zero.
+	Event *Event
+
+	// State is the model serialized with the codec of the type registry.
+	State []byte
+
+	// Codec is the name of the codec of the state.
+	Codec string
+
+	// Model is a copy of the model decoded from the state.
+	Model any
+}
+
+// Debugger steps through the events of a subject, exposing the state of the
+// model after each event, such as for a time-travel debugging UI. Stepping
+// back reuses the states already computed, so only stepping forward past the
+// furthest position applies events.
+type Debugger struct {
+	es        *EventStore
+	modelType string
+	events    []*Event
+
+	// model is evolved to the furthest position computed.
+	model  Evolver
+	states [][]byte
+	pos    int
+}
+
+// Debugger loads the events of the subject and returns a debugger of the
+// model registered with the type name, which must implement Evolver. The
+// debugger is positioned at the initial state of the model.
+func (s *EventStore) Debugger(ctx context.Context, subject, modelType string, opts ...LoadOption) (*Debugger, error) {
+	if s.rt.types == nil {
+		return nil, errors.New("rita: debugger: type registry required")
+	}
+
+	v, err := s.rt.types.Init(modelType)
+	if err != nil {
+		return nil, err
+	}
+	model, ok := v.(Evolver)
+	if !ok {
+		return nil, fmt.Errorf("rita: debugger: type %q is not an Evolver", modelType)
+	}
+
+	events, _, err := s.Load(ctx, subject, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := s.rt.types.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Debugger{
+		es:        s,
+		modelType: modelType,
+		events:    events,
+		model:     model,
+		states:    [][]byte{state},
+	}, nil
+}
+
+// Len returns the number of events of the subject.
+func (d *Debugger) Len() int {
+	return len(d.events)
+}
+
+// Position returns the number of events applied at the current frame.
+func (d *Debugger) Position() int {
+	return d.pos
+}
+
+// frame returns the frame at the position, which must be computed.
+func (d *Debugger) frame(pos int) (*DebugFrame, error) {
+	state := d.states[pos]
+
+	model, err := d.es.rt.types.UnmarshalType(state, d.modelType)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &DebugFrame{
+		Position: pos,
+		State:    state,
+		Codec:    d.es.rt.types.Codec().Name(),
+		Model:    model,
+	}
+	if pos > 0 {
+		f.Event = d.events[pos-1]
+	}
+	return f, nil
+}
+
+// Frame returns the current frame.
+func (d *Debugger) Frame() (*DebugFrame, error) {
+	return d.frame(d.pos)
+}
+
+// Seek moves to the frame after the number of events were applied and
+// returns it. ErrPositionOutOfRange is returned if the position is negative
+// or greater than the number of events.
+func (d *Debugger) Seek(pos int) (*DebugFrame, error) {
+	if pos < 0 || pos > len(d.events) {
+		return nil, fmt.Errorf("%w: %d not in [0, %d]", ErrPositionOutOfRange, pos, len(d.events))
+	}
+
+	for len(d.states) <= pos {
+		n := len(d.states) - 1
+		event := d.events[n]
+
+		if err := d.model.Evolve(event); err != nil {
+			return nil, fmt.Errorf("rita: debugger: evolve sequence %d: %w", event.Sequence, err)
+		}
+
+		state, err := d.es.rt.types.Marshal(d.model)
+		if err != nil {
+			return nil, err
+		}
+		d.states = append(d.states, state)
+	}
+
+	d.pos = pos
+	return d.frame(pos)
+}
+
+// Step moves to the frame of the next event and returns it.
+// ErrPositionOutOfRange is returned after the last event.
+func (d *Debugger) Step() (*DebugFrame, error) {
+	return d.Seek(d.pos + 1)
+}
+
+// Back moves to the previous frame and returns it. ErrPositionOutOfRange is
+// returned at the initial state.
+func (d *Debugger) Back() (*DebugFrame, error) {
+	return d.Seek(d.pos - 1)
+}
@@ -0,0 +1,84 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestDebugger(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-summary", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	_, err = es.Debugger(ctx, "orders.1", "order-placed")
+	is.Err(err, nil)
+
+	d, err := es.Debugger(ctx, "orders.1", "order-summary")
+	is.NoErr(err)
+	is.Equal(d.Len(), 3)
+	is.Equal(d.Position(), 0)
+
+	f, err := d.Frame()
+	is.NoErr(err)
+	is.True(f.Event == nil)
+	is.Equal(f.Codec, "json")
+	is.Equal(f.Model.(*OrderSummary).Placed, 0)
+
+	_, err = d.Back()
+	is.Err(err, ErrPositionOutOfRange)
+
+	f, err = d.Step()
+	is.NoErr(err)
+	is.Equal(f.Position, 1)
+	is.Equal(f.Event.Type, "order-placed")
+	is.Equal(f.Model.(*OrderSummary).Placed, 1)
+
+	f, err = d.Seek(3)
+	is.NoErr(err)
+	is.Equal(f.Event.Sequence, uint64(3))
+	is.Equal(f.Model.(*OrderSummary).Shipped, 2)
+
+	_, err = d.Step()
+	is.Err(err, ErrPositionOutOfRange)
+
+	// Stepping back returns the earlier state.
+	f, err = d.Back()
+	is.NoErr(err)
+	is.Equal(f.Position, 2)
+	is.Equal(f.Model.(*OrderSummary).Shipped, 1)
+	is.Equal(string(f.State), `{"Placed":1,"Shipped":1}`)
+
+	// Frames are copies.
+	f.Model.(*OrderSummary).Shipped = 10
+	f, err = d.Frame()
+	is.NoErr(err)
+	is.Equal(f.Model.(*OrderSummary).Shipped, 1)
+}
@@ -1,14 +1,262 @@
 package rita
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/bruth/rita/clock"
+	"github.com/bruth/rita/id"
+)
+
+// Command is a wrapper for application-defined commands.
 type Command struct {
-	ID   string
+	// ID of the command. This will be used as the NATS msg ID.
+	ID string
+
+	// Time is the time of when the command was issued. If no time is
+	// provided, the current local time will be used.
 	Time time.Time
+
+	// Type is a unique name for the command. This can be ommitted if
+	// a type registry is being used, otherwise it must be set explicitly
+	// to identify the encoded data.
 	Type string
+
+	// Data is the command data. This must be a byte slice (pre-encoded) or
+	// a value of a type registered in the type registry.
 	Data any
+
+	// Meta is application-defined metadata about the command.
+	Meta map[string]string
 }
 
 type Decider interface {
 	Decide(command *Command) ([]*Event, error)
 }
+
+// ContextDecider can be implemented by entities in addition to Decider to
+// decide using the decision context, which carries the clock and ID generator
+// of the Rita instance and the command, so decisions needing the current time
+// or new IDs are reproducible with a mock clock and seeded IDs. It takes
+// precedence over Decide.
+type ContextDecider interface {
+	DecideContext(ctx context.Context, command *Command) ([]*Event, error)
+}
+
+type decisionKey struct{}
+
+// decision is the value of the decision context.
+type decision struct {
+	clock clock.Clock
+	id    id.ID
+	cmd   *Command
+}
+
+// withDecision returns the decision context of the command.
+func (r *Rita) withDecision(ctx context.Context, cmd *Command) context.Context {
+	return context.WithValue(ctx, decisionKey{}, &decision{
+		clock: r.clock,
+		id:    r.id,
+		cmd:   cmd,
+	})
+}
+
+// ClockFromContext returns the clock of the decision context, or the real
+// clock if the context is not a decision context.
+func ClockFromContext(ctx context.Context) clock.Clock {
+	if d, ok := ctx.Value(decisionKey{}).(*decision); ok {
+		return d.clock
+	}
+	return clock.Time
+}
+
+// IDFromContext returns the ID generator of the decision context, or NUID if
+// the context is not a decision context.
+func IDFromContext(ctx context.Context) id.ID {
+	if d, ok := ctx.Value(decisionKey{}).(*decision); ok {
+		return d.id
+	}
+	return id.NUID
+}
+
+// CommandFromContext returns the command being decided, including its
+// metadata, or nil if the context is not a decision context.
+func CommandFromContext(ctx context.Context) *Command {
+	if d, ok := ctx.Value(decisionKey{}).(*decision); ok {
+		return d.cmd
+	}
+	return nil
+}
+
+// DecideFunc is an adapter to allow the use of ordinary functions as a Decider.
+type DecideFunc func(command *Command) ([]*Event, error)
+
+// Decide implements Decider.
+func (f DecideFunc) Decide(command *Command) ([]*Event, error) {
+	return f(command)
+}
+
+// EvolveFunc is an adapter to allow the use of ordinary functions as an Evolver.
+type EvolveFunc func(event *Event) error
+
+// Evolve implements Evolver.
+func (f EvolveFunc) Evolve(event *Event) error {
+	return f(event)
+}
+
+// FuncDecider is an Entity composed of an evolve function, a decide function
+// and the current state. The functions do not mutate shared state, rather the
+// evolve function returns the next state given the current state and an event.
+type FuncDecider[S any] struct {
+	evolve func(state S, event *Event) (S, error)
+	decide func(state S, command *Command) ([]*Event, error)
+	state  S
+}
+
+// Evolve implements Evolver by replacing the state with the evolved state.
+func (d *FuncDecider[S]) Evolve(event *Event) error {
+	s, err := d.evolve(d.state, event)
+	if err != nil {
+		return err
+	}
+	d.state = s
+	return nil
+}
+
+// Decide implements Decider by deciding against the current state.
+func (d *FuncDecider[S]) Decide(command *Command) ([]*Event, error) {
+	return d.decide(d.state, command)
+}
+
+// State returns the current state.
+func (d *FuncDecider[S]) State() S {
+	return d.state
+}
+
+// NewDecider returns an Entity following the functional decider pattern
+// given an evolve function, a decide function and the initial state.
+func NewDecider[S any](
+	evolve func(state S, event *Event) (S, error),
+	decide func(state S, command *Command) ([]*Event, error),
+	initial S,
+) *FuncDecider[S] {
+	return &FuncDecider[S]{
+		evolve: evolve,
+		decide: decide,
+		state:  initial,
+	}
+}
+
+// Rejection is the error returned when a command is rejected by a Decider,
+// as opposed to a failure hydrating state or appending events. Any error
+// returned by Decide is considered a rejection. Rejections are transported
+// in command replies so clients can distinguish them from failures.
+type Rejection struct {
+	// Code is an optional application-defined code for the rejection.
+	Code string `json:"code,omitempty"`
+
+	// Reason describes why the command was rejected.
+	Reason string `json:"reason"`
+
+	err error
+}
+
+func (r *Rejection) Error() string {
+	if r.Code != "" {
+		return fmt.Sprintf("rita: command rejected: %s: %s", r.Code, r.Reason)
+	}
+	return fmt.Sprintf("rita: command rejected: %s", r.Reason)
+}
+
+// Unwrap returns the error returned by Decide, if any. This is not preserved
+// when the rejection is transported in a command reply.
+func (r *Rejection) Unwrap() error {
+	return r.err
+}
+
+// Reject returns a rejection error with an application-defined code. This can
+// be returned from Decide so clients can handle rejections by code.
+func Reject(code, reason string) error {
+	return &Rejection{
+		Code:   code,
+		Reason: reason,
+	}
+}
+
+// rejection wraps an error returned by Decide as a Rejection.
+func rejection(err error) error {
+	var r *Rejection
+	if errors.As(err, &r) {
+		return err
+	}
+	return &Rejection{
+		Reason: err.Error(),
+		err:    err,
+	}
+}
+
+// decide invokes the entity's decision for the command and appends the
+// resulting events, expecting the subject to be at the given sequence.
+// The latest sequence of the subject is returned.
+func (s *EventStore) decide(ctx context.Context, subject string, entity Entity, seq uint64, cmd *Command) ([]*Event, uint64, error) {
+	var (
+		events []*Event
+		err    error
+	)
+	if cd, ok := entity.(ContextDecider); ok {
+		events, err = cd.DecideContext(s.rt.withDecision(ctx, cmd), cmd)
+	} else {
+		events, err = entity.Decide(cmd)
+	}
+	if err != nil {
+		return nil, seq, rejection(err)
+	}
+
+	if len(events) == 0 {
+		return nil, seq, nil
+	}
+
+	// Derive event IDs from the command ID so the events of a command that
+	// is handled more than once are de-duplicated by the store.
+	if cmd.ID != "" {
+		for i, e := range events {
+			if e.ID == "" {
+				e.ID = fmt.Sprintf("%s.%d", cmd.ID, i)
+			}
+		}
+	}
+
+	seq, err = s.Append(ctx, subject, events, ExpectSequence(seq))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, seq, nil
+}
+
+// Execute handles a command for an entity subject. The entity is hydrated from
+// the subject's events, the command is decided, and the resulting events are
+// appended expecting no other events to have been appended since hydration.
+// On success, the events have also been applied to the entity. If the entity
+// rejects the command, a *Rejection error is returned.
+func (s *EventStore) Execute(ctx context.Context, subject string, entity Entity, cmd *Command) ([]*Event, uint64, error) {
+	seq, err := s.Evolve(ctx, subject, entity)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events, seq, err := s.decide(ctx, subject, entity, seq, cmd)
+	if err != nil {
+		return nil, seq, err
+	}
+
+	for _, e := range events {
+		if err := entity.Evolve(e); err != nil {
+			return events, seq, err
+		}
+	}
+
+	return events, seq, nil
+}
@@ -0,0 +1,183 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/clock"
+	"github.com/bruth/rita/id"
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestFuncDecider(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	type state struct {
+		Placed bool
+	}
+
+	evolve := func(s state, e *Event) (state, error) {
+		switch e.Data.(type) {
+		case *OrderPlaced:
+			s.Placed = true
+		default:
+			return s, errors.New("unknown event")
+		}
+		return s, nil
+	}
+
+	decide := func(s state, c *Command) ([]*Event, error) {
+		if s.Placed {
+			return nil, nil
+		}
+		return []*Event{{Data: &OrderPlaced{}}}, nil
+	}
+
+	var d Entity = NewDecider(evolve, decide, state{})
+
+	events, err := d.Decide(&Command{Data: &PlaceOrder{}})
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	err = d.Evolve(events[0])
+	is.NoErr(err)
+	is.True(d.(*FuncDecider[state]).State().Placed)
+
+	events, err = d.Decide(&Command{Data: &PlaceOrder{}})
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+
+	// Failed evolve retains the previous state.
+	err = d.Evolve(&Event{Data: &OrderShipped{}})
+	is.Err(err, nil)
+	is.True(d.(*FuncDecider[state]).State().Placed)
+
+	// Adapters.
+	var n int
+	var e Evolver = EvolveFunc(func(*Event) error {
+		n++
+		return nil
+	})
+	is.NoErr(e.Evolve(&Event{}))
+	is.Equal(n, 1)
+
+	var dc Decider = DecideFunc(func(*Command) ([]*Event, error) {
+		return nil, errOrderNotPlaced
+	})
+	_, err = dc.Decide(&Command{})
+	is.Err(err, errOrderNotPlaced)
+}
+
+func TestExecute(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	err = es.Create(&EventStoreConfig{
+		Storage: nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	var o1 Order
+	_, _, err = es.Execute(ctx, "orders.1", &o1, &Command{Data: &ShipOrder{ID: "1"}})
+	var rej *Rejection
+	is.True(errors.As(err, &rej))
+	is.True(errors.Is(err, errOrderNotPlaced))
+
+	events, seq, err := es.Execute(ctx, "orders.1", &o1, &Command{Data: &PlaceOrder{ID: "1"}})
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(seq, uint64(1))
+	is.True(o1.Placed)
+
+	var o2 Order
+	events, seq, err = es.Execute(ctx, "orders.1", &o2, &Command{Data: &ShipOrder{ID: "1"}})
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(seq, uint64(2))
+	is.True(o2.Shipped)
+
+	// Typed rejection codes.
+	var d Entity = NewDecider(
+		func(s int, e *Event) (int, error) { return s, nil },
+		func(s int, c *Command) ([]*Event, error) { return nil, Reject("closed", "store is closed") },
+		0,
+	)
+	_, _, err = es.Execute(ctx, "orders.2", d, &Command{Data: &PlaceOrder{ID: "2"}})
+	is.True(errors.As(err, &rej))
+	is.Equal(rej.Code, "closed")
+}
+
+type seqID struct {
+	n int
+}
+
+func (s *seqID) New() string {
+	s.n++
+	return fmt.Sprintf("id-%d", s.n)
+}
+
+// stampedOrder decides using the decision context.
+type stampedOrder struct {
+	Order
+}
+
+func (o *stampedOrder) DecideContext(ctx context.Context, command *Command) ([]*Event, error) {
+	return []*Event{{
+		ID:   IDFromContext(ctx).New(),
+		Time: ClockFromContext(ctx).Now(),
+		Data: &OrderPlaced{ID: CommandFromContext(ctx).Meta["order"]},
+	}}, nil
+}
+
+func TestExecuteDecisionContext(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	clk := &testClock{t: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)}
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), Clock(clk), ID(&seqID{}))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	var o stampedOrder
+	events, _, err := es.Execute(ctx, "orders.1", &o, &Command{
+		Data: &PlaceOrder{ID: "1"},
+		Meta: map[string]string{"order": "1"},
+	})
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].ID, "id-1")
+	is.True(events[0].Time.Equal(clk.Now()))
+	is.Equal(events[0].Data, &OrderPlaced{ID: "1"})
+	is.True(o.Placed)
+
+	// Outside of a decision, the defaults are returned.
+	is.Equal(ClockFromContext(ctx), clock.Time)
+	is.Equal(IDFromContext(ctx), id.NUID)
+	is.True(CommandFromContext(ctx) == nil)
+}
@@ -0,0 +1,122 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DecodePolicy determines how events which fail to unpack are handled when
+// read, for example due to an unknown codec or a malformed time header.
+type DecodePolicy int
+
+const (
+	// DecodeFail fails the read with a *DecodeError. This is the default.
+	DecodeFail DecodePolicy = iota
+
+	// DecodeSkip skips the event.
+	DecodeSkip
+
+	// DecodeRaw substitutes an event with Unknown set and a *RawEvent as
+	// the data.
+	DecodeRaw
+
+	// DecodeQuarantine copies the event to the quarantine stream of the
+	// context with the error and skips it. See Quarantined and Redrive.
+	DecodeQuarantine
+)
+
+// DecodeError is the error of an event which failed to unpack.
+type DecodeError struct {
+	// Subject and Sequence of the event. The sequence is zero if unknown.
+	Subject  string
+	Sequence uint64
+
+	// Header and Data of the message.
+	Header nats.Header
+	Data   []byte
+
+	// Err is the unpack error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("rita: decode event: %s: sequence %d: %s", e.Subject, e.Sequence, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// RawEvent is the data of an event substituted by the DecodeRaw policy.
+type RawEvent struct {
+	Header nats.Header
+	Data   []byte
+
+	// Err is the unpack error.
+	Err error
+}
+
+// OnDecodeError sets the policy for events which fail to unpack, so one
+// malformed event does not make all events of a subject unreadable. The
+// function, if not nil, is called with the error of each event which is
+// skipped or substituted. Events failing to be dereferenced or to verify
+// against the hash chain are not decode errors and always fail the read.
+func OnDecodeError(policy DecodePolicy, fn func(err *DecodeError)) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		switch policy {
+		case DecodeFail, DecodeSkip, DecodeRaw, DecodeQuarantine:
+		default:
+			return fmt.Errorf("rita: invalid decode policy %d", policy)
+		}
+		o.decode = decodeOpts{
+			policy: policy,
+			fn:     fn,
+		}
+		return nil
+	})
+}
+
+type decodeOpts struct {
+	policy DecodePolicy
+	fn     func(err *DecodeError)
+}
+
+// handle applies the policy to an unpack error of an event of the store. The
+// substituted event is returned, or nil and no error if the event is skipped.
+func (o *decodeOpts) handle(ctx context.Context, s *EventStore, err error) (*Event, error) {
+	var de *DecodeError
+	if o.policy == DecodeFail || !errors.As(err, &de) {
+		return nil, err
+	}
+
+	if o.policy == DecodeQuarantine {
+		if err := s.quarantineDecodeError(ctx, de); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.fn != nil {
+		o.fn(de)
+	}
+
+	if o.policy == DecodeSkip || o.policy == DecodeQuarantine {
+		return nil, nil
+	}
+
+	return &Event{
+		ID:       de.Header.Get(nats.MsgIdHdr),
+		Type:     de.Header.Get(eventTypeHdr),
+		Meta:     unpackMeta(de.Header),
+		Subject:  de.Subject,
+		Sequence: de.Sequence,
+		Unknown:  true,
+		Data: &RawEvent{
+			Header: de.Header,
+			Data:   de.Data,
+			Err:    de.Err,
+		},
+	}, nil
+}
@@ -0,0 +1,92 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestOnDecodeError(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+	js, _ := nc.JetStream()
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	// Legacy event with a malformed time header.
+	msg := nats.NewMsg("orders.1")
+	msg.Header.Set(nats.MsgIdHdr, "legacy")
+	msg.Header.Set(eventTypeHdr, "order-shipped")
+	msg.Header.Set(eventCodecHdr, "json")
+	msg.Header.Set(eventTimeHdr, "yesterday")
+	msg.Data = []byte(`{"ID":"1"}`)
+	_, err = js.PublishMsg(msg)
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	// Fail by default.
+	_, _, err = es.Load(ctx, "orders.1")
+	var de *DecodeError
+	is.True(errors.As(err, &de))
+	is.Equal(de.Sequence, uint64(2))
+	is.Equal(de.Subject, "orders.1")
+
+	var skipped []*DecodeError
+	events, _, err := es.Load(ctx, "orders.1", OnDecodeError(DecodeSkip, func(err *DecodeError) {
+		skipped = append(skipped, err)
+	}))
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(len(skipped), 1)
+	is.Equal(skipped[0].Sequence, uint64(2))
+
+	events, _, err = es.Load(ctx, "orders.1", OnDecodeError(DecodeRaw, nil))
+	is.NoErr(err)
+	is.Equal(len(events), 3)
+	is.True(events[1].Unknown)
+	is.Equal(events[1].ID, "legacy")
+	is.Equal(events[1].Type, "order-shipped")
+	is.Equal(events[1].Sequence, uint64(2))
+	raw, ok := events[1].Data.(*RawEvent)
+	is.True(ok)
+	is.Equal(string(raw.Data), `{"ID":"1"}`)
+
+	_, _, err = es.Load(ctx, "orders.1", OnDecodeError(DecodePolicy(10), nil))
+	is.Err(err, nil)
+
+	// Tails apply the policy as well.
+	tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tail, err := es.Tail(tctx, "", OnDecodeError(DecodeSkip, nil))
+	is.NoErr(err)
+	defer tail.Stop()
+
+	e, err := tail.Next(tctx)
+	is.NoErr(err)
+	is.Equal(e.Sequence, uint64(1))
+
+	e, err = tail.Next(tctx)
+	is.NoErr(err)
+	is.Equal(e.Sequence, uint64(3))
+}
@@ -0,0 +1,90 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrTooManyEvents = errors.New("rita: too many events")
+)
+
+// Defaults are the budgets of operations called with a context without a
+// deadline, such as context.Background(), to protect shared NATS
+// infrastructure from runaway operations. Callers setting a deadline on the
+// context are assumed to have bounded the operation themselves. Zero values
+// mean no limit.
+type Defaults struct {
+	// AppendTimeout bounds Append calls, unless AppendTimeout is set on the
+	// instance or Timeout is passed.
+	AppendTimeout time.Duration
+
+	// LoadTimeout bounds Load and Evolve calls, unless LoadTimeout is set on
+	// the instance or Timeout is passed.
+	LoadTimeout time.Duration
+
+	// MaxLoadEvents is the max number of events returned by Load. Loads
+	// exceeding it fail with ErrTooManyEvents.
+	MaxLoadEvents int
+
+	// MaxAppendBatch is the max number of events in an Append. Appends
+	// exceeding it fail with ErrTooManyEvents before any event is published.
+	MaxAppendBatch int
+}
+
+// WithDefaults sets the budgets of operations called with a context without
+// a deadline.
+func WithDefaults(d Defaults) RitaOption {
+	return ritaOption(func(o *Rita) error {
+		if d.AppendTimeout < 0 || d.LoadTimeout < 0 {
+			return errors.New("rita: default timeouts must be positive")
+		}
+		if d.MaxLoadEvents < 0 || d.MaxAppendBatch < 0 {
+			return errors.New("rita: default limits must be positive")
+		}
+		o.defaults = d
+		return nil
+	})
+}
+
+// unbounded returns true if the context has no deadline.
+func unbounded(ctx context.Context) bool {
+	_, ok := ctx.Deadline()
+	return !ok
+}
+
+// appendTimeoutFor returns the default timeout of an append with the context.
+func (r *Rita) appendTimeoutFor(ctx context.Context) time.Duration {
+	if r.appendTimeout == 0 && unbounded(ctx) {
+		return r.defaults.AppendTimeout
+	}
+	return r.appendTimeout
+}
+
+// loadTimeoutFor returns the default timeout of a load with the context.
+func (r *Rita) loadTimeoutFor(ctx context.Context) time.Duration {
+	if r.loadTimeout == 0 && unbounded(ctx) {
+		return r.defaults.LoadTimeout
+	}
+	return r.loadTimeout
+}
+
+// maxLoadEventsFor returns the max number of events of a load with the
+// context, or zero if there is no limit.
+func (r *Rita) maxLoadEventsFor(ctx context.Context) int {
+	if unbounded(ctx) {
+		return r.defaults.MaxLoadEvents
+	}
+	return 0
+}
+
+// checkAppendBatch returns an error if the number of events of an append with
+// the context exceeds the default limit.
+func (r *Rita) checkAppendBatch(ctx context.Context, n int) error {
+	if max := r.defaults.MaxAppendBatch; max > 0 && n > max && unbounded(ctx) {
+		return fmt.Errorf("%w: %d events exceeds limit of %d", ErrTooManyEvents, n, max)
+	}
+	return nil
+}
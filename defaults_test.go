@@ -0,0 +1,63 @@
+package rita
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestDefaults(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	_, err := New(nc, WithDefaults(Defaults{MaxLoadEvents: -1}))
+	is.Err(err, nil)
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), WithDefaults(Defaults{
+		AppendTimeout:  time.Second,
+		LoadTimeout:    time.Second,
+		MaxLoadEvents:  2,
+		MaxAppendBatch: 2,
+	}))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	events := []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	}
+
+	_, err = es.Append(ctx, "orders.1", events)
+	is.Err(err, ErrTooManyEvents)
+
+	// The limits do not apply to contexts with a deadline.
+	tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err = es.Append(tctx, "orders.1", events)
+	is.NoErr(err)
+
+	_, _, err = es.Load(ctx, "orders.1")
+	is.Err(err, ErrTooManyEvents)
+
+	loaded, _, err := es.Load(tctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(loaded), 3)
+
+	loaded, _, err = es.Load(ctx, "orders.1", AfterSequence(1))
+	is.NoErr(err)
+	is.Equal(len(loaded), 2)
+}
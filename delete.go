@@ -0,0 +1,117 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrDeleteProtected = errors.New("rita: event store is delete protected")
+	ErrDeletesDenied   = errors.New("rita: event store denies deleting events")
+	ErrPurgesDenied    = errors.New("rita: event store denies purging events")
+)
+
+// EventStoreDeleteProtection requires Delete to be called with Force, since
+// deleting an event store is irreversible.
+func EventStoreDeleteProtection() EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		o.deleteProtection = true
+		return nil
+	})
+}
+
+type deleteOpts struct {
+	force  bool
+	export io.Writer
+}
+
+type deleteOptFn func(o *deleteOpts) error
+
+func (f deleteOptFn) deleteOpt(o *deleteOpts) error {
+	return f(o)
+}
+
+// DeleteOption is an option for the event store Delete operation.
+type DeleteOption interface {
+	deleteOpt(o *deleteOpts) error
+}
+
+// Force confirms the deletion of a delete protected event store.
+func Force() DeleteOption {
+	return deleteOptFn(func(o *deleteOpts) error {
+		o.force = true
+		return nil
+	})
+}
+
+// DeleteAfterExport writes the messages of the event store to w as
+// newline-delimited JSON before deleting it. If the export fails, the store
+// is not deleted.
+func DeleteAfterExport(w io.Writer) DeleteOption {
+	return deleteOptFn(func(o *deleteOpts) error {
+		if w == nil {
+			return errors.New("rita: export writer required")
+		}
+		o.export = w
+		return nil
+	})
+}
+
+// checkDeletes returns ErrDeletesDenied if the stream of the store denies
+// deleting messages, which is the case if DenyDelete is set in the advanced
+// config.
+func (s *EventStore) checkDeletes(ctx context.Context) error {
+	info, err := s.rt.js.StreamInfo(s.stream, nats.Context(ctx))
+	if err != nil {
+		return err
+	}
+	if info.Config.DenyDelete {
+		return ErrDeletesDenied
+	}
+	return nil
+}
+
+// exportedMsg is a line of an export.
+type exportedMsg struct {
+	Sequence uint64      `json:"seq"`
+	Subject  string      `json:"subject"`
+	Header   nats.Header `json:"hdrs,omitempty"`
+	Data     []byte      `json:"data,omitempty"`
+}
+
+// export writes the messages of the store to w as newline-delimited JSON.
+// Chunked events are reassembled and claimed data is inlined.
+func (s *EventStore) export(ctx context.Context, w io.Writer) error {
+	// A stream which is not shared may have subjects other than the default.
+	subject := ">"
+	if s.shared {
+		subject = s.filterSubject()
+	}
+
+	enc := json.NewEncoder(w)
+
+	_, err := s.readMsgs(ctx, subject, &loadOpts{}, func(seq uint64, msg *nats.Msg) error {
+		msg, err := s.dereference(msg)
+		if err != nil {
+			return err
+		}
+
+		hdr := make(nats.Header, len(msg.Header))
+		for k, v := range msg.Header {
+			hdr[k] = v
+		}
+		hdr.Del(eventClaimHdr)
+
+		return enc.Encode(&exportedMsg{
+			Sequence: seq,
+			Subject:  contextUnsubject(s.context, msg.Subject),
+			Header:   hdr,
+			Data:     msg.Data,
+		})
+	})
+	return err
+}
@@ -0,0 +1,167 @@
+package rita
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreDelete(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreDeleteProtection(), EventStoreClaimCheck(16))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "a-much-longer-order-id"}},
+	})
+	is.NoErr(err)
+
+	err = es.Delete()
+	is.Err(err, ErrDeleteProtected)
+
+	var buf bytes.Buffer
+	is.NoErr(es.Delete(Force(), DeleteAfterExport(&buf)))
+
+	_, err = r.js.StreamInfo("orders")
+	is.Err(err, nats.ErrStreamNotFound)
+
+	var msgs []*exportedMsg
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var m exportedMsg
+		is.NoErr(json.Unmarshal(sc.Bytes(), &m))
+		msgs = append(msgs, &m)
+	}
+	is.Equal(len(msgs), 2)
+
+	is.Equal(msgs[0].Sequence, uint64(1))
+	is.Equal(msgs[0].Subject, "orders.1")
+	is.Equal(msgs[0].Header.Get(eventTypeHdr), "order-placed")
+
+	// Claimed data is inlined.
+	is.Equal(msgs[1].Header.Get(eventClaimHdr), "")
+	is.Equal(string(msgs[1].Data), `{"ID":"a-much-longer-order-id"}`)
+}
+
+func TestEventStoreDeleteDerived(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-summary", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	dir := t.TempDir()
+
+	opts := []EventStoreOption{
+		EventStoreStream("events"),
+		EventStoreCheckpoint("order-summary", 1),
+		EventStoreIndex(),
+		EventStoreDiskCache(dir),
+	}
+
+	es, err := r.EventStore("orders", opts...)
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	other, err := r.EventStore("payments", EventStoreStream("events"), EventStoreIndex())
+	is.NoErr(err)
+	is.NoErr(other.Create(nil))
+
+	// Stores sharing a stream do not share derived data.
+	is.True(es.indexBucket() != other.indexBucket())
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+	_, err = other.Append(ctx, "payments.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	var summary OrderSummary
+	_, err = es.Evolve(ctx, "orders.1", &summary)
+	is.NoErr(err)
+	_, _, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.NoErr(es.Redact(ctx, 1, ""))
+
+	for _, s := range []*EventStore{es, other} {
+		is.NoErr(s.quarantineDecodeError(ctx, &DecodeError{
+			Subject:  s.Subject("1"),
+			Sequence: 2,
+			Err:      errors.New("unknown type"),
+		}))
+	}
+
+	is.NoErr(es.Delete())
+
+	// Only the quarantined events of the deleted store are purged.
+	qs, err := es.Quarantined(ctx)
+	is.NoErr(err)
+	is.Equal(len(qs), 0)
+	qs, err = other.Quarantined(ctx)
+	is.NoErr(err)
+	is.Equal(len(qs), 1)
+
+	for _, bucket := range []string{es.checkpointBucket(), es.indexBucket(), es.redactionsBucket()} {
+		_, err = r.js.KeyValue(bucket)
+		is.Err(err, nats.ErrBucketNotFound)
+	}
+	_, err = r.js.KeyValue(other.indexBucket())
+	is.NoErr(err)
+
+	entries, err := os.ReadDir(dir)
+	is.NoErr(err)
+	is.Equal(len(entries), 0)
+
+	// A recreated store starts from scratch.
+	es, err = r.EventStore("orders", opts...)
+	is.NoErr(err)
+	is.NoErr(es.Create(nil))
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	summary = OrderSummary{}
+	_, err = es.Evolve(ctx, "orders.1", &summary)
+	is.NoErr(err)
+	is.Equal(summary.Placed, 1)
+	is.Equal(summary.Shipped, 0)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.True(!events[0].Redacted)
+}
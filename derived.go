@@ -0,0 +1,83 @@
+package rita
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// derivedName returns the name of a bucket or other resource of the kind
+// holding data derived from the events of the store, such as indexes or
+// checkpoints. Resources are named "{stream}_{kind}" and prefixed with the
+// store name if the stream is shared, so stores sharing a stream do not share
+// derived data.
+func (s *EventStore) derivedName(kind string) string {
+	if !s.shared {
+		return fmt.Sprintf("%s_%s", s.stream, kind)
+	}
+	return fmt.Sprintf("%s_%s_%s", s.stream, s.name, kind)
+}
+
+// derivedDescription returns the description of the buckets created for the
+// store, which is used to find them when the store is deleted.
+func (s *EventStore) derivedDescription() string {
+	return fmt.Sprintf("rita: derived from event store %s of stream %s", s.name, s.stream)
+}
+
+// derivedKV returns the KV bucket of the kind, creating it with the storage
+// and replicas of the stream if it does not exist.
+func (s *EventStore) derivedKV(kind string) (nats.KeyValue, error) {
+	bucket := s.derivedName(kind)
+
+	kv, err := s.rt.js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		sc, ierr := s.placement()
+		if ierr != nil {
+			return nil, ierr
+		}
+		kv, err = s.rt.js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: s.derivedDescription(),
+			Storage:     sc.Storage,
+			Replicas:    sc.Replicas,
+			Placement:   sc.Placement,
+		})
+	}
+	return kv, err
+}
+
+// deleteDerived deletes the data derived from the events of the store: the
+// buckets created for it, including claimed event data, redaction records,
+// indexes, checkpoints, sidelined events and materialized state, the events
+// copied to the quarantine stream, and the disk cache. Otherwise a store
+// recreated with the same name, whose sequences restart from one, would be
+// served stale data.
+func (s *EventStore) deleteDerived() error {
+	s.mu.Lock()
+	s.obj = nil
+	s.redactions = nil
+	s.indexes = nil
+	s.checkpointsKV = nil
+	s.mu.Unlock()
+
+	desc := s.derivedDescription()
+	for info := range s.rt.js.StreamsInfo() {
+		if info.Config.Description != desc {
+			continue
+		}
+		err := s.rt.js.DeleteStream(info.Config.Name)
+		if err != nil && !errors.Is(err, nats.ErrStreamNotFound) {
+			return err
+		}
+	}
+
+	if err := s.purgeQuarantined(); err != nil {
+		return err
+	}
+
+	if s.cache != nil {
+		return s.cache.clear()
+	}
+	return nil
+}
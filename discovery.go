@@ -0,0 +1,171 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+type natsStreamListRequest struct {
+	Offset int `json:"offset"`
+}
+
+type natsStreamListResponse struct {
+	Type    string             `json:"type"`
+	Error   *natsApiError      `json:"error"`
+	Total   int                `json:"total"`
+	Streams []*nats.StreamInfo `json:"streams"`
+}
+
+// EventStoreInfo describes an event store discovered in the account.
+type EventStoreInfo struct {
+	// Name of the event store, to be passed to EventStore.
+	Name string
+
+	// Stream is the name of the stream the store is mapped onto, without
+	// the context. If the store uses a shared stream, this is the name to
+	// pass to EventStoreStream.
+	Stream string
+
+	// Shared is true if the stream is shared with other stores.
+	Shared bool
+
+	// Config is the configuration of the stream.
+	Config nats.StreamConfig
+
+	// State is the state of the stream. For a shared stream, this covers the
+	// events of all stores.
+	State nats.StreamState
+}
+
+// ListEventStores discovers the event stores in the context of the Rita
+// instance, sorted by name. Streams backing key-value and object stores are
+// skipped. Since streams do not carry metadata identifying them as event
+// stores, any other stream in the context is listed as well. A stream is
+// considered shared if all of its subjects are of the form "{store}.>" and
+// at least one store is not named after the stream.
+func (r *Rita) ListEventStores(ctx context.Context) ([]*EventStoreInfo, error) {
+	var prefix string
+	if r.context != "" {
+		prefix = r.context + "_"
+	}
+
+	streams, err := r.listStreams(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []*EventStoreInfo
+
+	for _, si := range streams {
+		name := si.Config.Name
+		if strings.HasPrefix(name, "KV_") || strings.HasPrefix(name, "OBJ_") {
+			continue
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stream := strings.TrimPrefix(name, prefix)
+
+		stores, ok := r.sharedStores(stream, si.Config.Subjects)
+		if !ok {
+			if si.Config.Mirror == nil && !r.inContext(si.Config.Subjects) {
+				continue
+			}
+			stores = []string{stream}
+		}
+
+		for _, store := range stores {
+			infos = append(infos, &EventStoreInfo{
+				Name:   store,
+				Stream: stream,
+				Shared: ok,
+				Config: si.Config,
+				State:  si.State,
+			})
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos, nil
+}
+
+// listStreams pages through the info of all streams in the account.
+func (r *Rita) listStreams(ctx context.Context) ([]*nats.StreamInfo, error) {
+	var streams []*nats.StreamInfo
+
+	for {
+		data, _ := json.Marshal(&natsStreamListRequest{
+			Offset: len(streams),
+		})
+
+		rctx, cancel := withTimeout(ctx, nil, r.apiTimeout)
+		msg, err := r.nc.RequestWithContext(rctx, "$JS.API.STREAM.LIST", data)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		var rep natsStreamListResponse
+		if err := json.Unmarshal(msg.Data, &rep); err != nil {
+			return nil, err
+		}
+
+		if rep.Error != nil {
+			return nil, apiError("list streams", "", rep.Error)
+		}
+
+		streams = append(streams, rep.Streams...)
+		if len(rep.Streams) == 0 || len(streams) >= rep.Total {
+			return streams, nil
+		}
+	}
+}
+
+// inContext returns true if all subjects are in the context namespace.
+func (r *Rita) inContext(subjects []string) bool {
+	if r.context == "" {
+		return true
+	}
+	for _, subj := range subjects {
+		if !strings.HasPrefix(subj, r.context+".") {
+			return false
+		}
+	}
+	return true
+}
+
+// sharedStores returns the names of the stores sharing the stream, if it is
+// shared, based on its subjects.
+func (r *Rita) sharedStores(stream string, subjects []string) ([]string, bool) {
+	if len(subjects) == 0 || !r.inContext(subjects) {
+		return nil, false
+	}
+
+	var (
+		stores []string
+		shared bool
+	)
+
+	for _, subj := range subjects {
+		store := strings.TrimSuffix(r.unsubject(subj), ".>")
+		if store == r.unsubject(subj) || strings.ContainsAny(store, ".*>") {
+			return nil, false
+		}
+		if store != stream {
+			shared = true
+		}
+		stores = append(stores, store)
+	}
+
+	if !shared {
+		return nil, false
+	}
+	return stores, true
+}
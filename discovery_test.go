@@ -0,0 +1,68 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestListEventStores(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), Context("shop"))
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	infos, err := r.ListEventStores(ctx)
+	is.NoErr(err)
+	is.Equal(len(infos), 0)
+
+	orders, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(orders.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	for _, name := range []string{"returns", "invoices"} {
+		es, err := r.EventStore(name, EventStoreStream("shared"))
+		is.NoErr(err)
+		is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+	}
+
+	_, err = orders.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	// Buckets and stores in other contexts are not listed.
+	_, err = r.StateStore("carts")
+	is.NoErr(err)
+
+	other, err := New(nc, Context("other"))
+	is.NoErr(err)
+
+	oes, err := other.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(oes.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	infos, err = r.ListEventStores(ctx)
+	is.NoErr(err)
+	is.Equal(len(infos), 3)
+
+	is.Equal(infos[0].Name, "invoices")
+	is.Equal(infos[0].Stream, "shared")
+	is.True(infos[0].Shared)
+
+	is.Equal(infos[1].Name, "orders")
+	is.Equal(infos[1].Stream, "orders")
+	is.True(!infos[1].Shared)
+	is.Equal(infos[1].Config.Name, "shop_orders")
+	is.Equal(infos[1].State.Msgs, uint64(1))
+
+	is.Equal(infos[2].Name, "returns")
+	is.Equal(infos[2].Stream, "shared")
+}
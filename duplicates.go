@@ -0,0 +1,190 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Duplicate is an event ID which occurs more than once in a store.
+type Duplicate struct {
+	// ID of the event.
+	ID string
+
+	// Sequences and subjects of the events with the ID in stream order.
+	Sequences []uint64
+	Subjects  []string
+}
+
+// DuplicateReport is the result of a scan for duplicate event IDs.
+type DuplicateReport struct {
+	// Scanned is the number of events scanned.
+	Scanned int
+
+	// Duplicates are the IDs occurring more than once in the order they
+	// were first found.
+	Duplicates []*Duplicate
+
+	// Sidelined is the number of events sidelined.
+	Sidelined int
+}
+
+type scanOpts struct {
+	sideline bool
+}
+
+type scanOptFn func(o *scanOpts) error
+
+func (f scanOptFn) scanOpt(o *scanOpts) error {
+	return f(o)
+}
+
+// ScanOption is an option for scanning a store.
+type ScanOption interface {
+	scanOpt(o *scanOpts) error
+}
+
+// Sideline moves each duplicate event other than the first occurrence out of
+// the store into a KV bucket named "{stream}_sidelined", keyed by the
+// sequence of the event, so it can be inspected and restored if needed.
+// Unlike the quarantine of events which fail to decode, see DecodeQuarantine,
+// the events are deleted from the store. Checkpoints of models which may have
+// applied the events are dropped. Default is to only report duplicates.
+func Sideline() ScanOption {
+	return scanOptFn(func(o *scanOpts) error {
+		o.sideline = true
+		return nil
+	})
+}
+
+// sidelinedEvent is a sidelined event message.
+type sidelinedEvent struct {
+	Subject  string      `json:"subject"`
+	Sequence uint64      `json:"seq"`
+	Header   nats.Header `json:"header"`
+	Data     []byte      `json:"data"`
+	Time     time.Time   `json:"time"`
+}
+
+// sidelineBucket returns the name of the KV bucket of sidelined events.
+func (s *EventStore) sidelineBucket() string {
+	return s.derivedName("sidelined")
+}
+
+// ScanDuplicates scans all events of the store for duplicate event IDs. The
+// stream de-duplicates appends by event ID only within the duplicate window,
+// so events appended outside of the window, or to a different stream which
+// is sourced, may have the same ID which breaks idempotent consumers. Only
+// the headers of events are read.
+func (s *EventStore) ScanDuplicates(ctx context.Context, opts ...ScanOption) (*DuplicateReport, error) {
+	var o scanOpts
+	for _, opt := range opts {
+		if err := opt.scanOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.sideline && s.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	var (
+		report DuplicateReport
+		index  = make(map[string]*Duplicate)
+	)
+
+	lo := loadOpts{headersOnly: true}
+	_, err := s.readMsgs(ctx, s.filterSubject(), &lo, func(seq uint64, msg *nats.Msg) error {
+		report.Scanned++
+
+		id := msg.Header.Get(nats.MsgIdHdr)
+		if id == "" {
+			return nil
+		}
+
+		d, ok := index[id]
+		if !ok {
+			d = &Duplicate{ID: id}
+			index[id] = d
+		}
+		d.Sequences = append(d.Sequences, seq)
+		d.Subjects = append(d.Subjects, contextUnsubject(s.context, msg.Subject))
+
+		if len(d.Sequences) == 2 {
+			report.Duplicates = append(report.Duplicates, d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !o.sideline || len(report.Duplicates) == 0 {
+		return &report, nil
+	}
+
+	if err := s.checkDeletes(ctx); err != nil {
+		return &report, err
+	}
+
+	kv, err := s.sidelineKV()
+	if err != nil {
+		return &report, err
+	}
+
+	for _, d := range report.Duplicates {
+		for _, seq := range d.Sequences[1:] {
+			if err := s.sideline(ctx, kv, seq); err != nil {
+				return &report, err
+			}
+			report.Sidelined++
+		}
+	}
+
+	return &report, nil
+}
+
+// sidelineKV returns the KV bucket of sidelined events, creating it if
+// it does not exist.
+func (s *EventStore) sidelineKV() (nats.KeyValue, error) {
+	return s.derivedKV("sidelined")
+}
+
+// sideline moves the messages of the event at the sequence to the KV
+// bucket and deletes them from the stream. Checkpoints which may have applied
+// the event are dropped.
+func (s *EventStore) sideline(ctx context.Context, kv nats.KeyValue, seq uint64) error {
+	msg, err := s.rt.js.GetMsg(s.stream, seq, nats.Context(ctx))
+	if err != nil {
+		return err
+	}
+
+	parts, err := s.chunkParts(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range parts {
+		b, _ := json.Marshal(&sidelinedEvent{
+			Subject:  p.Subject,
+			Sequence: p.Sequence,
+			Header:   p.Header,
+			Data:     p.Data,
+			Time:     p.Time,
+		})
+		if _, err := kv.Put(fmt.Sprint(p.Sequence), b); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range parts {
+		if err := s.rt.js.DeleteMsg(s.stream, p.Sequence, nats.Context(ctx)); err != nil {
+			return err
+		}
+	}
+
+	return s.dropCheckpoints(contextUnsubject(s.context, msg.Subject))
+}
@@ -0,0 +1,71 @@
+package rita
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreScanDuplicates(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	// Shrink the duplicate window so duplicates can be appended.
+	info, err := r.js.StreamInfo("orders")
+	is.NoErr(err)
+	info.Config.Duplicates = 100 * time.Millisecond
+	_, err = r.js.UpdateStream(&info.Config)
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{ID: "a", Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{ID: "b", Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, err = es.Append(ctx, "orders.3", []*Event{{ID: "a", Data: &OrderPlaced{ID: "3"}}})
+	is.NoErr(err)
+
+	report, err := es.ScanDuplicates(ctx)
+	is.NoErr(err)
+	is.Equal(report.Scanned, 3)
+	is.Equal(len(report.Duplicates), 1)
+	is.Equal(report.Duplicates[0].ID, "a")
+	is.Equal(report.Duplicates[0].Sequences, []uint64{1, 3})
+	is.Equal(report.Duplicates[0].Subjects, []string{"orders.1", "orders.3"})
+	is.Equal(report.Sidelined, 0)
+
+	report, err = es.ScanDuplicates(ctx, Sideline())
+	is.NoErr(err)
+	is.Equal(report.Sidelined, 1)
+
+	events, _, err := es.Load(ctx, "orders.3")
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+
+	kv, err := r.js.KeyValue("orders_sidelined")
+	is.NoErr(err)
+	_, err = kv.Get("3")
+	is.NoErr(err)
+
+	report, err = es.ScanDuplicates(ctx)
+	is.NoErr(err)
+	is.Equal(len(report.Duplicates), 0)
+}
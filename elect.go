@@ -0,0 +1,220 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	leasesBucket    = "rita-leases"
+	defaultLeaseTTL = 10 * time.Second
+)
+
+type electOpts struct {
+	ttl time.Duration
+}
+
+type electOptFn func(o *electOpts) error
+
+func (f electOptFn) electOpt(o *electOpts) error {
+	return f(o)
+}
+
+// ElectOption is an option for leader election.
+type ElectOption interface {
+	electOpt(o *electOpts) error
+}
+
+// LeaseTTL sets the TTL of leadership leases. A leader renews its lease at a
+// third of the TTL, so a failed leader is replaced within roughly one TTL. This
+// only applies when the leases bucket is first created, after which the TTL of
+// the bucket is used. Default is 10 seconds.
+func LeaseTTL(d time.Duration) ElectOption {
+	return electOptFn(func(o *electOpts) error {
+		if d <= 0 {
+			return fmt.Errorf("elect: lease ttl must be positive")
+		}
+		o.ttl = d
+		return nil
+	})
+}
+
+// Leader represents acquired leadership for a name. The lease is renewed in
+// the background until it is resigned, the context passed to Elect is done, or
+// a renewal fails.
+type Leader struct {
+	kv   nats.KeyValue
+	name string
+	id   string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+
+	mu  sync.Mutex
+	rev uint64
+	err error
+}
+
+// ID returns the unique ID of this leader.
+func (l *Leader) ID() string {
+	return l.id
+}
+
+// Done returns a channel that is closed when leadership is lost or resigned.
+func (l *Leader) Done() <-chan struct{} {
+	return l.done
+}
+
+// Err returns the error that caused leadership to be lost, if any.
+func (l *Leader) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Resign gives up leadership by deleting the lease, allowing another candidate
+// to be elected immediately rather than waiting for the lease to expire.
+func (l *Leader) Resign() error {
+	l.cancel()
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return nil
+	}
+	return l.kv.Delete(l.name, nats.LastRevision(l.rev))
+}
+
+func (l *Leader) lose(err error) {
+	l.once.Do(func() {
+		l.mu.Lock()
+		l.err = err
+		l.mu.Unlock()
+		close(l.done)
+	})
+}
+
+func (l *Leader) renew(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.lose(nil)
+			return
+		case <-t.C:
+		}
+
+		l.mu.Lock()
+		rev, err := l.kv.Update(l.name, []byte(l.id), l.rev)
+		if err == nil {
+			l.rev = rev
+		}
+		l.mu.Unlock()
+
+		if err != nil {
+			l.lose(fmt.Errorf("elect: failed to renew lease: %w", err))
+			return
+		}
+	}
+}
+
+func (r *Rita) leases(ttl time.Duration) (nats.KeyValue, error) {
+	bucket := r.resourceName(leasesBucket)
+
+	kv, err := r.js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = r.js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:  bucket,
+			History: 1,
+			TTL:     ttl,
+		})
+	}
+	return kv, err
+}
+
+// leaseInterval returns the interval leases in the bucket must be renewed at.
+// It is derived from the TTL of the bucket rather than the requested TTL, since
+// the bucket may have been created with a different one.
+func leaseInterval(kv nats.KeyValue) (time.Duration, error) {
+	status, err := kv.Status()
+	if err != nil {
+		return 0, err
+	}
+	if status.TTL() <= 0 {
+		return 0, fmt.Errorf("rita: lease bucket %q has no ttl", status.Bucket())
+	}
+	return status.TTL() / 3, nil
+}
+
+// leaseHeld returns true if creating the lease failed because the key exists,
+// which the server reports as a wrong last sequence.
+func leaseHeld(err error) bool {
+	return strings.Contains(err.Error(), "wrong last sequence")
+}
+
+// Elect blocks until leadership for the name is acquired or the context is
+// done. Leadership is modeled as a lease in a KV bucket so singleton components,
+// such as schedulers or projection rebuilders, can be coordinated across
+// instances without another dependency. Errors other than the lease being held
+// by another candidate, e.g. an invalid name, are returned immediately.
+func (r *Rita) Elect(ctx context.Context, name string, opts ...ElectOption) (*Leader, error) {
+	o := electOpts{
+		ttl: defaultLeaseTTL,
+	}
+
+	for _, opt := range opts {
+		if err := opt.electOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	kv, err := r.leases(o.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	interval, err := leaseInterval(kv)
+	if err != nil {
+		return nil, err
+	}
+
+	id := r.id.New()
+
+	for {
+		rev, err := kv.Create(name, []byte(id))
+		if err == nil {
+			lctx, cancel := context.WithCancel(ctx)
+			l := &Leader{
+				kv:     kv,
+				name:   name,
+				id:     id,
+				rev:    rev,
+				cancel: cancel,
+				done:   make(chan struct{}),
+			}
+			go l.renew(lctx, interval)
+			return l, nil
+		}
+
+		// Only a lease held by another candidate is waited out.
+		if !leaseHeld(err) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
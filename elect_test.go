@@ -0,0 +1,94 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestElect(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc)
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	l1, err := r.Elect(ctx, "scheduler", LeaseTTL(time.Second))
+	is.NoErr(err)
+
+	// Second candidate cannot acquire while the first is leader.
+	tctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	_, err = r.Elect(tctx, "scheduler")
+	is.True(errors.Is(err, context.DeadlineExceeded))
+
+	// Errors other than the lease being held are not retried.
+	_, err = r.Elect(ctx, "bad name")
+	is.Err(err, nats.ErrInvalidKey)
+
+	// Other names are independent.
+	l3, err := r.Elect(ctx, "relay")
+	is.NoErr(err)
+	defer l3.Resign() //nolint
+
+	err = l1.Resign()
+	is.NoErr(err)
+
+	select {
+	case <-l1.Done():
+	default:
+		t.Error("expected leadership to be lost")
+	}
+
+	l2, err := r.Elect(ctx, "scheduler")
+	is.NoErr(err)
+	is.True(l1.ID() != l2.ID())
+
+	is.NoErr(l2.Resign())
+}
+
+func TestElectBucketTTL(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc)
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	// The bucket is created with the TTL of the first candidate.
+	l1, err := r.Elect(ctx, "relay", LeaseTTL(time.Second))
+	is.NoErr(err)
+	is.NoErr(l1.Resign())
+
+	// The lease is renewed within the TTL of the bucket rather than the
+	// default TTL, so it does not expire.
+	l2, err := r.Elect(ctx, "scheduler")
+	is.NoErr(err)
+	defer l2.Resign() //nolint
+
+	tctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	_, err = r.Elect(tctx, "scheduler", LeaseTTL(time.Second))
+	is.True(errors.Is(err, context.DeadlineExceeded))
+
+	select {
+	case <-l2.Done():
+		t.Error("expected leadership to be kept")
+	default:
+	}
+}
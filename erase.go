@@ -0,0 +1,247 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/nats-io/nats.go"
+)
+
+// KeyShredder destroys the encryption keys of the data of a subject, also
+// known as crypto shredding, so copies of the events which cannot be
+// deleted, such as in backups or mirrors, can no longer be decrypted.
+type KeyShredder interface {
+	Shred(ctx context.Context, subject string) error
+}
+
+// Purger purges the data of a subject from a read model or other system
+// derived from the events, such as a materializer or search index.
+type Purger interface {
+	Purge(ctx context.Context, subject string) error
+}
+
+type namedPurger struct {
+	name string
+	p    Purger
+}
+
+// EventStoreKeyShredder sets the shredder of the encryption keys of subjects
+// erased with Erase. Default is none, e.g. if the events are not encrypted.
+func EventStoreKeyShredder(k KeyShredder) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if k == nil {
+			return errors.New("rita: key shredder required")
+		}
+		o.shredder = k
+		return nil
+	})
+}
+
+// AddPurger registers the purger with the name, which is called when a
+// subject is erased with Erase.
+func (s *EventStore) AddPurger(name string, p Purger) error {
+	if name == "" || p == nil {
+		return errors.New("rita: purger name and purger required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, np := range s.purgers {
+		if np.name == name {
+			return fmt.Errorf("rita: duplicate purger %q", name)
+		}
+	}
+	s.purgers = append(s.purgers, &namedPurger{name: name, p: p})
+	return nil
+}
+
+type eraseOpts struct {
+	dryRun bool
+}
+
+type eraseOptFn func(o *eraseOpts) error
+
+func (f eraseOptFn) eraseOpt(o *eraseOpts) error {
+	return f(o)
+}
+
+// EraseOption is an option for Erase.
+type EraseOption interface {
+	eraseOpt(o *eraseOpts) error
+}
+
+// DryRun reports what would be erased without changing anything.
+func DryRun() EraseOption {
+	return eraseOptFn(func(o *eraseOpts) error {
+		o.dryRun = true
+		return nil
+	})
+}
+
+// ErasureReport is the result of an erasure.
+type ErasureReport struct {
+	// Subject erased.
+	Subject string
+
+	// DryRun is true if nothing was changed.
+	DryRun bool
+
+	// KeysShredded is true if the encryption keys of the subject were or
+	// would be shredded.
+	KeysShredded bool
+
+	// Sequences of the events redacted.
+	Sequences []uint64
+
+	// Checkpoints are the model types of the checkpoints deleted.
+	Checkpoints []string
+
+	// Purged are the names of the purgers called.
+	Purged []string
+}
+
+// Erase erases the data of the entity subject, e.g. to fulfill a request to
+// erase personal data, in the order:
+//
+//   - the encryption keys are shredded if EventStoreKeyShredder is set
+//   - each event is redacted, see Redact
+//   - the checkpoints of models of the subject are deleted
+//   - each purger registered with AddPurger is called
+//
+// The erasure is recorded in the audit log of the store, if configured, with
+// the reason. On failure, the report of the steps completed is returned
+// along with the error and Erase can be retried. Stores with a storage
+// backend are not supported.
+func (s *EventStore) Erase(ctx context.Context, subject string, reason string, opts ...EraseOption) (*ErasureReport, error) {
+	var o eraseOpts
+	for _, opt := range opts {
+		if err := opt.eraseOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateSubject(subject, false); err != nil {
+		return nil, err
+	}
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	if s.backend != nil {
+		return nil, ErrBackendUnsupported
+	}
+
+	if err := s.authorize(ctx, OpErase, subject, nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	report := &ErasureReport{
+		Subject: subject,
+		DryRun:  o.dryRun,
+	}
+
+	var (
+		types []string
+		ids   []string
+	)
+
+	lo := loadOpts{headersOnly: true}
+	_, err := s.readMsgs(ctx, subject, &lo, func(seq uint64, msg *nats.Msg) error {
+		report.Sequences = append(report.Sequences, seq)
+		types = append(types, msg.Header.Get(eventTypeHdr))
+		ids = append(ids, msg.Header.Get(nats.MsgIdHdr))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for t := range s.checkpoints {
+		report.Checkpoints = append(report.Checkpoints, t)
+	}
+	sort.Strings(report.Checkpoints)
+
+	s.mu.Lock()
+	purgers := append([]*namedPurger(nil), s.purgers...)
+	s.mu.Unlock()
+
+	if o.dryRun {
+		report.KeysShredded = s.shredder != nil
+		for _, np := range purgers {
+			report.Purged = append(report.Purged, np.name)
+		}
+		return report, nil
+	}
+
+	err = s.erase(ctx, subject, reason, report, purgers)
+
+	rec := &AuditRecord{
+		Actor:     ActorFromContext(ctx),
+		Operation: "erase",
+		Store:     s.name,
+		Subject:   subject,
+		Types:     types,
+		IDs:       ids,
+		Outcome:   auditOutcome(err),
+		Reason:    reason,
+	}
+	if n := len(report.Sequences); n > 0 {
+		rec.Sequence = report.Sequences[n-1]
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	s.auditRecord(ctx, rec)
+
+	return report, err
+}
+
+// erase performs the steps of the erasure, updating the report with the
+// steps completed.
+func (s *EventStore) erase(ctx context.Context, subject, reason string, report *ErasureReport, purgers []*namedPurger) error {
+	if s.shredder != nil {
+		if err := s.shredder.Shred(ctx, subject); err != nil {
+			return fmt.Errorf("rita: erase: shred keys: %w", err)
+		}
+		report.KeysShredded = true
+	}
+
+	seqs := report.Sequences
+	report.Sequences = nil
+	for _, seq := range seqs {
+		if err := s.Redact(ctx, seq, reason); err != nil {
+			return fmt.Errorf("rita: erase: redact sequence %d: %w", seq, err)
+		}
+		report.Sequences = append(report.Sequences, seq)
+	}
+
+	modelTypes := report.Checkpoints
+	report.Checkpoints = nil
+	if len(modelTypes) > 0 {
+		kv, err := s.checkpointKV()
+		if err != nil {
+			return fmt.Errorf("rita: erase: checkpoints: %w", err)
+		}
+		for _, t := range modelTypes {
+			if err := kv.Purge(checkpointKey(t, subject)); err != nil {
+				return fmt.Errorf("rita: erase: checkpoint %s: %w", t, err)
+			}
+			report.Checkpoints = append(report.Checkpoints, t)
+		}
+	}
+
+	for _, np := range purgers {
+		if err := np.p.Purge(ctx, subject); err != nil {
+			return fmt.Errorf("rita: erase: purge %s: %w", np.name, err)
+		}
+		report.Purged = append(report.Purged, np.name)
+	}
+
+	return nil
+}
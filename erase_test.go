@@ -0,0 +1,158 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+type testShredder struct {
+	subjects []string
+}
+
+func (s *testShredder) Shred(ctx context.Context, subject string) error {
+	s.subjects = append(s.subjects, subject)
+	return nil
+}
+
+type testPurger func(ctx context.Context, subject string) error
+
+func (f testPurger) Purge(ctx context.Context, subject string) error {
+	return f(ctx, subject)
+}
+
+func TestEventStoreErase(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-summary", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	audit, err := r.AuditLog("audit")
+	is.NoErr(err)
+	is.NoErr(audit.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	var shredder testShredder
+
+	es, err := r.EventStore("orders",
+		EventStoreAudit(audit),
+		EventStoreCheckpoint("order-summary", 2),
+		EventStoreKeyShredder(&shredder),
+	)
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	m, err := es.Materializer("latest", "order-summary")
+	is.NoErr(err)
+	is.NoErr(es.AddPurger("latest", m))
+	is.Err(es.AddPurger("latest", m), nil)
+
+	ctx := WithActor(context.Background(), "privacy-service")
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	// Write the checkpoint and the materialized state.
+	var model OrderSummary
+	_, err = es.Evolve(ctx, "orders.1", &model)
+	is.NoErr(err)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	for _, e := range events {
+		is.NoErr(m.apply(e))
+	}
+
+	kv, err := es.checkpointKV()
+	is.NoErr(err)
+	_, err = kv.Get(checkpointKey("order-summary", "orders.1"))
+	is.NoErr(err)
+
+	_, err = es.Erase(ctx, "orders.*", "erasure request")
+	is.Err(err, nil)
+
+	// Nothing is changed by a dry run.
+	report, err := es.Erase(ctx, "orders.1", "erasure request", DryRun())
+	is.NoErr(err)
+	is.True(report.DryRun)
+	is.True(report.KeysShredded)
+	is.Equal(report.Sequences, []uint64{1, 2})
+	is.Equal(report.Checkpoints, []string{"order-summary"})
+	is.Equal(report.Purged, []string{"latest"})
+	is.Equal(len(shredder.subjects), 0)
+
+	events, _, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.True(!events[0].Redacted)
+
+	report, err = es.Erase(ctx, "orders.1", "erasure request")
+	is.NoErr(err)
+	is.True(!report.DryRun)
+	is.Equal(report.Sequences, []uint64{1, 2})
+	is.Equal(report.Checkpoints, []string{"order-summary"})
+	is.Equal(report.Purged, []string{"latest"})
+	is.Equal(shredder.subjects, []string{"orders.1"})
+
+	events, _, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.True(events[0].Redacted)
+	is.True(events[1].Redacted)
+
+	_, err = kv.Get(checkpointKey("order-summary", "orders.1"))
+	is.Err(err, nats.ErrKeyNotFound)
+
+	_, _, err = m.Get("orders.1")
+	is.Err(err, nats.ErrKeyNotFound)
+
+	// Other subjects are not affected.
+	events, _, err = es.Load(ctx, "orders.2")
+	is.NoErr(err)
+	is.True(!events[0].Redacted)
+
+	records, _, err := audit.Load(ctx, "audit.orders.1")
+	is.NoErr(err)
+
+	rec := records[len(records)-1].Data.(*AuditRecord)
+	is.Equal(rec.Actor, "privacy-service")
+	is.Equal(rec.Operation, "erase")
+	is.Equal(rec.Reason, "erasure request")
+	is.Equal(rec.Sequence, uint64(2))
+	is.Equal(rec.Outcome, AuditOK)
+
+	// A failed purger is reported and the erasure can be retried.
+	fail := errors.New("index unavailable")
+	is.NoErr(es.AddPurger("search", testPurger(func(ctx context.Context, subject string) error {
+		return fail
+	})))
+
+	report, err = es.Erase(ctx, "orders.2", "erasure request")
+	is.Err(err, fail)
+	is.Equal(report.Sequences, []uint64{3})
+	is.Equal(report.Purged, []string{"latest"})
+
+	records, _, err = audit.Load(ctx, "audit.orders.2")
+	is.NoErr(err)
+
+	rec = records[len(records)-1].Data.(*AuditRecord)
+	is.Equal(rec.Operation, "erase")
+	is.True(rec.Error != "")
+}
@@ -0,0 +1,162 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// jsErrCodeWrongLastSequence is the JetStream API error code of a publish
+// with an expected last sequence which does not match.
+const jsErrCodeWrongLastSequence = 10071
+
+// Error is the error of an operation with the details needed to handle it
+// without matching on the message. The underlying error, such as
+// ErrSequenceConflict, is matched using errors.Is and the error itself is
+// retrieved using errors.As.
+type Error struct {
+	// Op is the operation which failed, e.g. "append".
+	Op string `json:"op"`
+
+	// Subject of the operation, if any.
+	Subject string `json:"subject,omitempty"`
+
+	// ExpectedSequence and ActualSequence of the subject if the error is a
+	// sequence conflict. The actual sequence is zero if unknown.
+	ExpectedSequence uint64 `json:"expected_seq,omitempty"`
+	ActualSequence   uint64 `json:"actual_seq,omitempty"`
+
+	// ConflictTypes are the types of the events appended to the subject
+	// after the expected sequence in order, so callers can decide whether
+	// to retry, merge, or report the conflict without reading them.
+	ConflictTypes []string `json:"conflict_types,omitempty"`
+
+	// Code and ErrCode are the status and error codes of the JetStream API
+	// error, if the error was returned by the API.
+	Code    int    `json:"code,omitempty"`
+	ErrCode uint16 `json:"err_code,omitempty"`
+
+	// Err is the underlying error.
+	Err error `json:"-"`
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("rita: ")
+	b.WriteString(e.Op)
+	if e.Subject != "" {
+		b.WriteString(" ")
+		b.WriteString(e.Subject)
+	}
+	b.WriteString(": ")
+	if e.Err != nil {
+		b.WriteString(strings.TrimPrefix(e.Err.Error(), "rita: "))
+	}
+	if errors.Is(e.Err, ErrSequenceConflict) {
+		fmt.Fprintf(&b, " (expected sequence %d, actual %d)", e.ExpectedSequence, e.ActualSequence)
+	}
+	if e.ErrCode != 0 {
+		fmt.Fprintf(&b, " (%d, %d)", e.Code, e.ErrCode)
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// apiError returns the error of a JetStream API response. Wrong last sequence
+// errors are sequence conflicts.
+func apiError(op, subject string, e *natsApiError) *Error {
+	err := &Error{
+		Op:      op,
+		Subject: subject,
+		Code:    e.Code,
+		ErrCode: e.ErrCode,
+		Err:     errors.New(e.Description),
+	}
+
+	if e.ErrCode == jsErrCodeWrongLastSequence {
+		err.Err = ErrSequenceConflict
+		// The description includes the actual sequence.
+		if _, seq, ok := strings.Cut(e.Description, ": "); ok {
+			err.ActualSequence, _ = strconv.ParseUint(seq, 10, 64)
+		}
+	}
+
+	return err
+}
+
+type natsPubAckResponse struct {
+	Error *natsApiError `json:"error"`
+	*nats.PubAck
+}
+
+// publishMsg publishes the message to a stream and returns the ack. Unlike
+// the JetStream context, API errors are returned as *Error with the codes of
+// the error.
+func (r *Rita) publishMsg(ctx context.Context, op string, msg *nats.Msg) (*nats.PubAck, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = withTimeout(ctx, nil, r.apiTimeout)
+		defer cancel()
+	}
+
+	rep, err := r.anc.RequestMsgWithContext(ctx, msg)
+	if errors.Is(err, nats.ErrNoResponders) {
+		return nil, nats.ErrNoStreamResponse
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pa natsPubAckResponse
+	if err := json.Unmarshal(rep.Data, &pa); err != nil {
+		return nil, nats.ErrInvalidJSAck
+	}
+	if pa.Error != nil {
+		err := apiError(op, r.unsubject(msg.Subject), pa.Error)
+		if errors.Is(err, ErrSequenceConflict) {
+			err.ExpectedSequence, _ = strconv.ParseUint(msg.Header.Get(nats.ExpectedLastSubjSeqHdr), 10, 64)
+		}
+		return nil, err
+	}
+	if pa.PubAck == nil || pa.Stream == "" {
+		return nil, nats.ErrInvalidJSAck
+	}
+	return pa.PubAck, nil
+}
+
+// describeConflict sets the actual sequence and the types of the conflicting
+// events of a sequence conflict on appending to the subject. This is best
+// effort since the conflict is reported regardless.
+func (s *EventStore) describeConflict(ctx context.Context, subject string, err error) {
+	var e *Error
+	if !errors.As(err, &e) || !errors.Is(e.Err, ErrSequenceConflict) {
+		return
+	}
+
+	o := loadOpts{
+		headersOnly: true,
+		afterSeq:    &e.ExpectedSequence,
+	}
+
+	var types []string
+	last, rerr := s.readMsgs(ctx, subject, &o, func(seq uint64, msg *nats.Msg) error {
+		types = append(types, msg.Header.Get(eventTypeHdr))
+		return nil
+	})
+	if rerr != nil {
+		return
+	}
+
+	if e.ActualSequence == 0 {
+		e.ActualSequence = last
+	}
+	e.ConflictTypes = types
+}
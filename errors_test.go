@@ -0,0 +1,89 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bruth/rita/storage"
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestError(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, ExpectSequence(1))
+	is.Err(err, ErrSequenceConflict)
+
+	var e *Error
+	is.True(errors.As(err, &e))
+	is.Equal(e.Op, "append")
+	is.Equal(e.Subject, "orders.1")
+	is.Equal(e.ExpectedSequence, uint64(1))
+	is.Equal(e.ActualSequence, uint64(2))
+	is.Equal(e.ErrCode, uint16(jsErrCodeWrongLastSequence))
+	is.Equal(e.ConflictTypes, []string{"order-shipped"})
+	is.Equal(e.Error(), "rita: append orders.1: sequence conflict (expected sequence 1, actual 2) (400, 10071)")
+
+	// Conflicts are retained in command replies.
+	b, err := r.packCommandReply(nil, 0, err)
+	is.NoErr(err)
+	_, _, err = r.unpackCommandReply(&nats.Msg{Data: b})
+	is.Err(err, ErrSequenceConflict)
+	is.True(errors.As(err, &e))
+	is.Equal(e.ActualSequence, uint64(2))
+}
+
+func TestErrorBackendConflict(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreBackend(storage.NewMemory()))
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, ExpectSequence(0))
+	is.Err(err, ErrSequenceConflict)
+
+	var e *Error
+	is.True(errors.As(err, &e))
+	is.Equal(e.ExpectedSequence, uint64(0))
+	is.Equal(e.ActualSequence, uint64(2))
+	is.Equal(e.ConflictTypes, []string{"order-placed", "order-shipped"})
+}
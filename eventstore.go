@@ -5,10 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/bruth/rita/codec"
+	"github.com/bruth/rita/storage"
 	"github.com/nats-io/nats.go"
 )
 
@@ -17,13 +21,19 @@ const (
 	eventTimeHdr       = "rita-time"
 	eventCodecHdr      = "rita-codec"
 	eventMetaPrefixHdr = "rita-meta-"
+	eventVersionHdr    = "rita-version"
+	eventValidFromHdr  = "rita-valid-from"
+	eventValidToHdr    = "rita-valid-to"
 	eventTimeFormat    = time.RFC3339Nano
+
+	defaultAPITimeout = 5 * time.Second
 )
 
 var (
 	ErrSequenceConflict  = errors.New("rita: sequence conflict")
 	ErrEventIDRequired   = errors.New("rita: event id required")
 	ErrEventTypeRequired = errors.New("rita: event type required")
+	ErrExpectedSequence  = errors.New("rita: expected sequence required")
 )
 
 // Validator can be optionally implemented by user-defined types and will be
@@ -32,10 +42,35 @@ type validator interface {
 	Validate() error
 }
 
+// contextValidator can be implemented by user-defined types instead of
+// validator to validate using request-scoped data of the context, such as the
+// tenant or the role of the actor. It takes precedence over Validate.
+type contextValidator interface {
+	ValidateContext(ctx context.Context) error
+}
+
 type Evolver interface {
 	Evolve(event *Event) error
 }
 
+type multiEvolver []Evolver
+
+func (m multiEvolver) Evolve(event *Event) error {
+	for _, e := range m {
+		if err := e.Evolve(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MultiEvolver returns an Evolver which applies each event to all of the
+// models in order. If a model returns an error, the remaining models are
+// not applied.
+func MultiEvolver(models ...Evolver) Evolver {
+	return multiEvolver(models)
+}
+
 // Event is a wrapper for application-defined events.
 type Event struct {
 	// ID of the event. This will be used as the NATS msg ID
@@ -64,10 +99,70 @@ type Event struct {
 
 	// Sequence is the sequence where this event exists in the stream. Read-only.
 	Sequence uint64
+
+	// ValidFrom and ValidTo are the period in business time the event is
+	// effective, also known as valid time, which may differ from when the
+	// event occurred, e.g. a backdated policy change. A zero ValidFrom means
+	// the event is effective from Time and a zero ValidTo means the event is
+	// effective indefinitely.
+	ValidFrom time.Time
+	ValidTo   time.Time
+
+	// Expires is the time after which the event expires, if not zero. Loads
+	// skip expired events and Expire deletes them from the store, so
+	// ephemeral events, such as heartbeats, need not be cleaned up. See TTL
+	// and EventStoreTypeTTL.
+	Expires time.Time
+
+	// Clock is the vector clock of the event if the store is causal. On
+	// append, it may be set to the merged clocks of events in other stores
+	// the event causally depends on. It is ignored if the store is not
+	// causal.
+	Clock VectorClock
+
+	// RecordedTime is the time the event was recorded by the store, as
+	// opposed to Time which is supplied by the application. Read-only.
+	RecordedTime time.Time
+
+	// Hash is the hash of the event chained to the previous event of the
+	// subject if the store is hash chained. Read-only.
+	Hash string
+
+	// prevHash is the hash of the previous event of the subject.
+	prevHash string
+
+	// Redacted is true if the event was redacted. Only the ID, type, times,
+	// subject, and sequence of the event are retained. Read-only.
+	Redacted bool
+
+	// Unknown is true if the type or version of the event is not registered
+	// and Data is the raw encoded data. This only occurs when reading with
+	// the Lenient option, or if the event failed to unpack with the DecodeRaw
+	// policy, in which case Data is a *RawEvent. Read-only.
+	Unknown bool
+}
+
+// EffectiveFrom returns the business time the event is effective from, which
+// is ValidFrom if set, otherwise Time.
+func (e *Event) EffectiveFrom() time.Time {
+	if e.ValidFrom.IsZero() {
+		return e.Time
+	}
+	return e.ValidFrom
+}
+
+// ValidAt returns true if the event is effective at the business time.
+func (e *Event) ValidAt(t time.Time) bool {
+	if t.Before(e.EffectiveFrom()) {
+		return false
+	}
+	return e.ValidTo.IsZero() || t.Before(e.ValidTo)
 }
 
 type appendOpts struct {
-	expSeq *uint64
+	expSeq  *uint64
+	timeout *time.Duration
+	ttl     time.Duration
 }
 
 type appendOptFn func(o *appendOpts) error
@@ -92,6 +187,130 @@ func ExpectSequence(seq uint64) AppendOption {
 
 type loadOpts struct {
 	afterSeq *uint64
+	untilSeq uint64
+	timeout  *time.Duration
+
+	heartbeat    time.Duration
+	inactive     time.Duration
+	pendingMsgs  int
+	pendingBytes int
+	headersOnly  bool
+
+	parallel int
+
+	lenient bool
+	decode  decodeOpts
+
+	timeStart     time.Time
+	timeEnd       time.Time
+	recordedStart time.Time
+	recordedEnd   time.Time
+	orderByTime   bool
+	asAt          time.Time
+
+	meta map[string]string
+}
+
+// match returns true if the event is within the time ranges and has the
+// metadata.
+func (o *loadOpts) match(e *Event) bool {
+	if !o.asAt.IsZero() && !e.ValidAt(o.asAt) {
+		return false
+	}
+	for k, v := range o.meta {
+		if mv, ok := e.Meta[k]; !ok || mv != v {
+			return false
+		}
+	}
+	return inTimeRange(e.Time, o.timeStart, o.timeEnd) &&
+		inTimeRange(e.RecordedTime, o.recordedStart, o.recordedEnd)
+}
+
+// filtered returns true if events read may not match the options, in which
+// case the sequence of the last message read is not of an event loaded.
+func (o *loadOpts) filtered() bool {
+	return !o.asAt.IsZero() ||
+		len(o.meta) > 0 ||
+		!o.timeStart.IsZero() ||
+		!o.timeEnd.IsZero() ||
+		!o.recordedStart.IsZero() ||
+		!o.recordedEnd.IsZero()
+}
+
+func inTimeRange(t, start, end time.Time) bool {
+	if !start.IsZero() && t.Before(start) {
+		return false
+	}
+	if !end.IsZero() && !t.Before(end) {
+		return false
+	}
+	return true
+}
+
+// subOpts returns the subscription options for the consumer used to read
+// events. An ordered consumer is used so events are read as fast as possible
+// with the least overhead. Flow control is always enabled for these consumers.
+func (o *loadOpts) subOpts() []nats.SubOpt {
+	sopts := []nats.SubOpt{
+		nats.OrderedConsumer(),
+	}
+
+	if o.heartbeat > 0 {
+		sopts = append(sopts, nats.IdleHeartbeat(o.heartbeat))
+	}
+
+	if o.inactive > 0 {
+		sopts = append(sopts, nats.InactiveThreshold(o.inactive))
+	}
+
+	if o.headersOnly {
+		sopts = append(sopts, nats.HeadersOnly())
+	}
+
+	return sopts
+}
+
+// timeoutOpt is both an AppendOption and a LoadOption.
+type timeoutOpt time.Duration
+
+func (d timeoutOpt) appendOpt(o *appendOpts) error {
+	if d < 0 {
+		return errors.New("rita: timeout must be positive")
+	}
+	t := time.Duration(d)
+	o.timeout = &t
+	return nil
+}
+
+func (d timeoutOpt) loadOpt(o *loadOpts) error {
+	if d < 0 {
+		return errors.New("rita: timeout must be positive")
+	}
+	t := time.Duration(d)
+	o.timeout = &t
+	return nil
+}
+
+// Timeout overrides the default timeout of the Append, Load, or Evolve call
+// set by AppendTimeout or LoadTimeout. The context passed in still applies
+// if its deadline is earlier. A zero value disables the default timeout.
+func Timeout(d time.Duration) interface {
+	AppendOption
+	LoadOption
+} {
+	return timeoutOpt(d)
+}
+
+// withTimeout returns a context bound by the timeout override, if set, or
+// the default timeout, if positive.
+func withTimeout(ctx context.Context, override *time.Duration, d time.Duration) (context.Context, context.CancelFunc) {
+	if override != nil {
+		d = *override
+	}
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
 }
 
 type loadOptFn func(o *loadOpts) error
@@ -105,10 +324,10 @@ type LoadOption interface {
 	loadOpt(o *loadOpts) error
 }
 
-// AfterSequence specifies the sequence of the first event that should be fetched
-// from the sequence up to the end of the sequence. This useful when partially applied
-// state has been derived up to a specific sequence and only the latest events need
-// to be fetched.
+// AfterSequence specifies the sequence after which events should be fetched,
+// i.e. the event at the sequence is not fetched. This is useful when partially
+// applied state has been derived up to a specific sequence and only the latest
+// events need to be fetched.
 func AfterSequence(seq uint64) LoadOption {
 	return loadOptFn(func(o *loadOpts) error {
 		o.afterSeq = &seq
@@ -116,6 +335,163 @@ func AfterSequence(seq uint64) LoadOption {
 	})
 }
 
+// UntilSequence limits the events fetched to those up to and including the
+// sequence. Combined with AfterSequence, this reads a fixed range of the
+// stream regardless of events appended later.
+func UntilSequence(seq uint64) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		if seq == 0 {
+			return fmt.Errorf("until sequence: sequence must be positive")
+		}
+		o.untilSeq = seq
+		return nil
+	})
+}
+
+// Heartbeat sets the idle heartbeat interval of the consumer used to read events.
+// Missed heartbeats are used to detect a stalled consumer which is then
+// recreated from the last received sequence. Default is 5 seconds.
+func Heartbeat(d time.Duration) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		o.heartbeat = d
+		return nil
+	})
+}
+
+// InactiveThreshold sets the duration the server waits before removing the
+// consumer used to read events if the client is not reading. This should be
+// increased for long reads over slow links to prevent the consumer from being
+// removed mid-read.
+func InactiveThreshold(d time.Duration) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		o.inactive = d
+		return nil
+	})
+}
+
+// PendingLimits sets the maximum number of messages and bytes buffered by
+// the client while reading events. Since the read consumers do not require
+// acks, these limits are the effective bound on in-flight events. A value
+// of zero retains the default and a negative value means no limit.
+func PendingLimits(msgs, bytes int) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		o.pendingMsgs = msgs
+		o.pendingBytes = bytes
+		return nil
+	})
+}
+
+// Parallel loads events for subjects matching a wildcard subject concurrently
+// using up to n workers, one subject at a time. The events are merged by stream
+// sequence so the result is the same as a serial load. This is useful for
+// rebuilding cross-cutting views spanning many subjects.
+func Parallel(n int) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		if n < 1 {
+			return fmt.Errorf("parallel: workers must be positive")
+		}
+		o.parallel = n
+		return nil
+	})
+}
+
+// Lenient returns events of types or versions not registered with the raw
+// data and Unknown set, rather than failing the load. This allows projections
+// to keep working while a producer of new types is rolled out ahead of the
+// consumers.
+func Lenient() LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		o.lenient = true
+		return nil
+	})
+}
+
+// TimeRange limits the events to those with an application-supplied Time
+// within the range. The start is inclusive and the end is exclusive. A zero
+// time leaves that side of the range unbounded.
+func TimeRange(start, end time.Time) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		o.timeStart = start
+		o.timeEnd = end
+		return nil
+	})
+}
+
+// RecordedTimeRange limits the events to those with a RecordedTime within
+// the range. The start is inclusive and the end is exclusive. A zero time
+// leaves that side of the range unbounded. Unless AfterSequence is used, the
+// read starts at the first event recorded at or after the start.
+func RecordedTimeRange(start, end time.Time) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		o.recordedStart = start
+		o.recordedEnd = end
+		return nil
+	})
+}
+
+// OrderByTime orders the loaded events by the application-supplied Time
+// rather than the order they were recorded in. Events with the same time
+// retain their recorded order. This only applies to Load.
+func OrderByTime() LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		o.orderByTime = true
+		return nil
+	})
+}
+
+// AsAt limits the events to those effective at the business time and orders
+// them by the time they are effective from rather than the order they were
+// recorded in. This allows state to be evolved as at a point in business time,
+// including corrections recorded after the fact. Events effective from the
+// same time retain their recorded order.
+func AsAt(t time.Time) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		if t.IsZero() {
+			return fmt.Errorf("as at: time is required")
+		}
+		o.asAt = t
+		return nil
+	})
+}
+
+// AsOf limits the events to those recorded at or before the time, so the
+// state is evolved as it was known at that time, excluding events recorded
+// later, e.g. corrections. Unlike AsAt, this is based on the time events
+// were recorded rather than the business time they are effective.
+func AsOf(t time.Time) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		if t.IsZero() {
+			return fmt.Errorf("as of: time is required")
+		}
+		// The end of the recorded time range is exclusive.
+		o.recordedEnd = t.Add(time.Nanosecond)
+		return nil
+	})
+}
+
+// AsOfSequence limits the events to those appended up to and including the
+// sequence, so the state is evolved as it was once the event with the
+// sequence was appended. This is equivalent to UntilSequence.
+func AsOfSequence(seq uint64) LoadOption {
+	return UntilSequence(seq)
+}
+
+// WhereMeta limits the events to those with the metadata key set to the
+// value. Multiple uses must all match. The filter is applied as events are
+// read, so events of all types are still read from the stream.
+func WhereMeta(key, value string) LoadOption {
+	return loadOptFn(func(o *loadOpts) error {
+		if key == "" {
+			return fmt.Errorf("where meta: key is required")
+		}
+		if o.meta == nil {
+			o.meta = make(map[string]string)
+		}
+		o.meta[key] = value
+		return nil
+	})
+}
+
 type natsApiError struct {
 	Code        int    `json:"code"`
 	ErrCode     uint16 `json:"err_code"`
@@ -133,43 +509,209 @@ type natsGetMsgResponse struct {
 }
 
 type natsStoredMsg struct {
-	Sequence uint64 `json:"seq"`
+	Sequence uint64    `json:"seq"`
+	Time     time.Time `json:"time"`
+}
+
+type natsPurgeRequest struct {
+	Filter string `json:"filter,omitempty"`
+}
+
+type natsPurgeResponse struct {
+	Type  string        `json:"type"`
+	Error *natsApiError `json:"error"`
+}
+
+type natsStreamInfoRequest struct {
+	SubjectsFilter string `json:"subjects_filter,omitempty"`
+}
+
+type natsStreamInfoResponse struct {
+	Type  string           `json:"type"`
+	Error *natsApiError    `json:"error"`
+	State *natsStreamState `json:"state"`
+}
+
+type natsStreamState struct {
+	Subjects map[string]uint64 `json:"subjects"`
+}
+
+type eventStoreOpts struct {
+	stream         string
+	claimThreshold int
+	chunkSize      int
+	maxEventSize   int
+	maxBatchSize   int
+	causal         bool
+	hashChain      bool
+	audit          *EventStore
+
+	deleteProtection bool
+	bind             bool
+	autoCreate       *EventStoreConfig
+	backend          storage.Backend
+	cacheDir         string
+	index            bool
+	indexKeys        []string
+	scope            string
+	loadValidation   ValidationPolicy
+	beforeAppend     []BeforeAppendHook
+	afterAppend      []AfterAppendHook
+	rateLimit        *rateLimiter
+	typeTTL          map[string]time.Duration
+	checkpoints      map[string]uint64
+	shredder         KeyShredder
+}
+
+type eventStoreOptFn func(o *eventStoreOpts) error
+
+func (f eventStoreOptFn) eventStoreOpt(o *eventStoreOpts) error {
+	return f(o)
+}
+
+// EventStoreOption is an option for an event store.
+type EventStoreOption interface {
+	eventStoreOpt(o *eventStoreOpts) error
+}
+
+// EventStoreStream maps the event store onto a stream with the name which can
+// be shared by multiple stores, for example to stay under the stream limits
+// of an account. Each store owns the subjects "{store}.>" of the stream, so
+// loads, appends, and consumers remain scoped to the store. The buckets of
+// data derived from the events, such as indexes and checkpoints, are named
+// "{stream}_{store}_{kind}" rather than "{stream}_{kind}", so they are not
+// shared either. Default is a stream with the same name as the store.
+func EventStoreStream(name string) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if !nameRegex.MatchString(name) {
+			return fmt.Errorf("rita: stream %q has invalid characters", name)
+		}
+		o.stream = name
+		return nil
+	})
 }
 
 // EventStore provides event store semantics over a NATS stream.
 type EventStore struct {
-	name string
-	rt   *Rita
+	name     string
+	stream   string
+	context  string
+	shared   bool
+	readOnly bool
+	rt       *Rita
+
+	deleteProtection bool
+
+	// bind is true if the stream is externally managed and autoCreate is
+	// set if the stream is created on first use. checked is true once the
+	// bound stream has been validated or the stream created.
+	bind       bool
+	autoCreate *EventStoreConfig
+	checked    bool
+
+	// backend is set if the events are stored in a backend other than the
+	// stream.
+	backend storage.Backend
+
+	cache *diskCache
+
+	// index is true if the events are indexed on the metadata keys.
+	index     bool
+	indexKeys []string
+
+	// scope is the namespace of the types of events which can be appended,
+	// if any.
+	scope string
+
+	// loadValidation is the policy of validating events when loaded, if any.
+	loadValidation ValidationPolicy
+
+	beforeAppend []BeforeAppendHook
+	afterAppend  []AfterAppendHook
+
+	// rateLimit limits the rate of appended events, if set.
+	rateLimit *rateLimiter
+
+	// typeTTL is the time to live of events by type.
+	typeTTL map[string]time.Duration
+
+	// checkpoints are the checkpoint intervals by model type.
+	checkpoints map[string]uint64
+
+	// shredder destroys the encryption keys of subjects on erasure, if set.
+	shredder KeyShredder
+
+	claimThreshold int
+	chunkSize      int
+	maxEventSize   int
+	maxBatchSize   int
+	causal         bool
+	hashChain      bool
+	audit          *EventStore
+
+	// asyncTail is closed when the last asynchronous append completes.
+	asyncMu   sync.Mutex
+	asyncTail chan struct{}
+
+	mu            sync.Mutex
+	obj           nats.ObjectStore
+	redactions    nats.KeyValue
+	indexes       nats.KeyValue
+	checkpointsKV nats.KeyValue
+	purgers       []*namedPurger
+
+	sizes sizeStats
 }
 
-// wrapEvent wraps a user-defined event into the Event envelope. It performs
-// validation to ensure all the properties are either defined or defaults are set.
-func (s *EventStore) wrapEvent(event *Event) (*Event, error) {
-	if event.Data == nil {
-		return nil, fmt.Errorf("event data is nil")
+// subject maps a subject into the context namespace of the store. This is
+// usually the context of the Rita instance, but differs for a mirror of a
+// store in another context.
+func (s *EventStore) subject(subj string) string {
+	return contextSubject(s.context, subj)
+}
+
+// unpackEvent unpacks an event read from the store.
+func (s *EventStore) unpackEvent(msg *nats.Msg, lenient bool) (*Event, error) {
+	msg, err := s.dereference(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyHash(msg); err != nil {
+		return nil, err
 	}
 
-	if s.rt.types == nil {
-		if event.Type == "" {
-			return nil, errors.New("event type is not defined")
+	event, err := s.rt.unpackEvent(msg, lenient)
+	if err != nil {
+		de := &DecodeError{
+			Subject: contextUnsubject(s.context, msg.Subject),
+			Header:  msg.Header,
+			Data:    msg.Data,
+			Err:     err,
 		}
-	} else {
-		t, err := s.rt.types.Lookup(event.Data)
-		if err != nil {
-			return nil, err
+		if md, merr := msg.Metadata(); merr == nil {
+			de.Sequence = md.Sequence.Stream
 		}
+		return nil, de
+	}
+	event.Subject = contextUnsubject(s.context, msg.Subject)
+	return event, nil
+}
 
-		if event.Type == "" {
-			event.Type = t
-		} else if event.Type != t {
-			return nil, fmt.Errorf("wrong type for event data: %s", event.Type)
-		}
+// wrapEvent wraps a user-defined event into the Event envelope. It performs
+// validation to ensure all the properties are either defined or defaults are set.
+func (s *EventStore) wrapEvent(ctx context.Context, event *Event) (*Event, error) {
+	t, err := s.rt.resolveType(event.Type, event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("event: %w", err)
+	}
+	if err := s.checkScope(t); err != nil {
+		return nil, err
 	}
+	event.Type = t
 
-	if v, ok := event.Data.(validator); ok {
-		if err := v.Validate(); err != nil {
-			return nil, err
-		}
+	if err := validateData(ctx, event.Data); err != nil {
+		return nil, err
 	}
 
 	// Set ID if empty.
@@ -182,6 +724,8 @@ func (s *EventStore) wrapEvent(event *Event) (*Event, error) {
 		event.Time = s.rt.clock.Now().Local()
 	}
 
+	event.Meta = stampIdentity(ctx, event.Meta)
+
 	return event, nil
 }
 
@@ -189,25 +733,12 @@ func (s *EventStore) wrapEvent(event *Event) (*Event, error) {
 // is that the server supports creating a consumer that _only_ gets the headers
 // without the data as an optimization for some use cases.
 func (s *EventStore) packEvent(subject string, event *Event) (*nats.Msg, error) {
-	// Marshal the data.
-	var (
-		data      []byte
-		err       error
-		codecName string
-	)
-
-	if s.rt.types == nil {
-		data, err = codec.Binary.Marshal(event.Data)
-		codecName = codec.Binary.Name()
-	} else {
-		data, err = s.rt.types.Marshal(event.Data)
-		codecName = s.rt.types.Codec().Name()
-	}
+	data, codecName, err := s.rt.encodeData(event.Data)
 	if err != nil {
 		return nil, err
 	}
 
-	msg := nats.NewMsg(subject)
+	msg := nats.NewMsg(s.subject(subject))
 	msg.Data = data
 
 	// Map event envelope to NATS header.
@@ -216,23 +747,54 @@ func (s *EventStore) packEvent(subject string, event *Event) (*nats.Msg, error)
 	msg.Header.Set(eventTimeHdr, event.Time.Format(eventTimeFormat))
 	msg.Header.Set(eventCodecHdr, codecName)
 
-	for k, v := range event.Meta {
-		msg.Header.Set(fmt.Sprintf("%s%s", eventMetaPrefixHdr, k), v)
+	if s.rt.types != nil {
+		version, err := s.rt.types.Version(event.Data)
+		if err != nil {
+			return nil, err
+		}
+		if version > 0 {
+			msg.Header.Set(eventVersionHdr, strconv.Itoa(version))
+		}
+	}
+
+	if !event.ValidFrom.IsZero() {
+		msg.Header.Set(eventValidFromHdr, event.ValidFrom.Format(eventTimeFormat))
+	}
+	if !event.ValidTo.IsZero() {
+		msg.Header.Set(eventValidToHdr, event.ValidTo.Format(eventTimeFormat))
+	}
+	if !event.Expires.IsZero() {
+		msg.Header.Set(eventExpiresHdr, event.Expires.Format(eventTimeFormat))
+	}
+
+	if event.Clock != nil {
+		msg.Header.Set(eventClockHdr, event.Clock.String())
 	}
 
+	packMeta(msg.Header, event.Meta)
+
 	return msg, nil
 }
 
 // lastSeqForSubject queries the JS API to identify the current latest sequence for a subject.
 // This is used as an best-guess indicator of the current end of the even history.
 func (s *EventStore) lastMsgForSubject(ctx context.Context, subject string) (*natsStoredMsg, error) {
-	rsubject := fmt.Sprintf("$JS.API.STREAM.MSG.GET.%s", s.name)
+	return s.rt.lastMsg(ctx, s.stream, s.subject(subject))
+}
+
+// lastMsg returns the last message of the subject in the stream which is
+// empty if there are no messages.
+func (r *Rita) lastMsg(ctx context.Context, stream, subject string) (*natsStoredMsg, error) {
+	rsubject := fmt.Sprintf("$JS.API.STREAM.MSG.GET.%s", stream)
 
 	data, _ := json.Marshal(&natsGetMsgRequest{
 		LastBySubject: subject,
 	})
 
-	msg, err := s.rt.nc.RequestWithContext(ctx, rsubject, data)
+	ctx, cancel := withTimeout(ctx, nil, r.apiTimeout)
+	defer cancel()
+
+	msg, err := r.nc.RequestWithContext(ctx, rsubject, data)
 	if err != nil {
 		return nil, err
 	}
@@ -247,88 +809,532 @@ func (s *EventStore) lastMsgForSubject(ctx context.Context, subject string) (*na
 		if rep.Error.Code == 404 {
 			return &natsStoredMsg{}, nil
 		}
-		return nil, fmt.Errorf("%s (%d)", rep.Error.Description, rep.Error.Code)
+		return nil, apiError("get last message", r.unsubject(subject), rep.Error)
 	}
 
 	return rep.Message, nil
 }
 
-// Load fetches all events for a specific subject. The primary use case
-// is to use a concrete subject, e.g. "orders.1" corresponding to an
-// aggregate/entity identifier. The second use case is to load events for
-// a cross-cutting view which can use subject wildcards.
-func (s *EventStore) Load(ctx context.Context, subject string, opts ...LoadOption) ([]*Event, uint64, error) {
-	// Configure opts.
-	var o loadOpts
-	for _, opt := range opts {
-		if err := opt.loadOpt(&o); err != nil {
-			return nil, 0, err
+// lastHeader returns the header of the last event of the subject which is
+// empty if there are no events. If the last event was redacted, the header
+// has the clock and hash recorded by the redaction, so the next event
+// continues from it rather than from the last message.
+func (s *EventStore) lastHeader(ctx context.Context, subject string) (nats.Header, error) {
+	if s.backend != nil {
+		m, err := s.backend.LastForSubject(ctx, s.subject(subject))
+		if err != nil || m == nil {
+			return nats.Header{}, err
 		}
+		return m.Header, nil
 	}
 
-	lastMsg, err := s.lastMsgForSubject(ctx, subject)
+	last, err := s.lastMsgForSubject(ctx, subject)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
-	if lastMsg.Sequence == 0 {
-		return nil, 0, nil
+	rs, err := s.redactionsFor(ctx, subject, &loadOpts{afterSeq: &last.Sequence})
+	if err != nil {
+		return nil, err
+	}
+	if len(rs) > 0 {
+		r := rs[len(rs)-1]
+		hdr := nats.Header{}
+		if r.Clock != "" {
+			hdr.Set(eventClockHdr, r.Clock)
+		}
+		if r.Hash != "" {
+			hdr.Set(eventHashHdr, r.Hash)
+		}
+		return hdr, nil
 	}
 
-	// Ephemeral ordered consumer.. read as fast as possible with least overhead.
-	sopts := []nats.SubOpt{
-		nats.OrderedConsumer(),
+	if last.Sequence == 0 {
+		return nats.Header{}, nil
 	}
 
-	// Don't bother creating the consumer if the last seq is smaller than start.
-	if o.afterSeq != nil {
-		if lastMsg.Sequence <= *o.afterSeq {
-			return nil, 0, nil
-		}
-		sopts = append(sopts, nats.StartSequence(*o.afterSeq))
-	} else {
-		sopts = append(sopts, nats.DeliverAll())
+	msg, err := s.rt.js.GetMsg(s.stream, last.Sequence, nats.Context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return msg.Header, nil
+}
+
+// subjectsForFilter queries the JS API for the subjects in the stream matching
+// the filter along with the number of messages for each subject.
+func (s *EventStore) subjectsForFilter(ctx context.Context, filter string) (map[string]uint64, error) {
+	rsubject := fmt.Sprintf("$JS.API.STREAM.INFO.%s", s.stream)
+
+	data, _ := json.Marshal(&natsStreamInfoRequest{
+		SubjectsFilter: s.subject(filter),
+	})
+
+	ctx, cancel := withTimeout(ctx, nil, s.rt.apiTimeout)
+	defer cancel()
+
+	msg, err := s.rt.nc.RequestWithContext(ctx, rsubject, data)
+	if err != nil {
+		return nil, err
 	}
 
-	sub, err := s.rt.js.SubscribeSync(subject, sopts...)
+	var rep natsStreamInfoResponse
+	err = json.Unmarshal(msg.Data, &rep)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-	defer sub.Unsubscribe() //nolint
 
-	// Skip first.
-	if o.afterSeq != nil {
-		_, err := sub.NextMsgWithContext(ctx)
-		if err != nil {
-			return nil, 0, err
-		}
+	if rep.Error != nil {
+		return nil, apiError("list subjects", filter, rep.Error)
 	}
 
-	var events []*Event
-	for {
-		msg, err := sub.NextMsgWithContext(ctx)
-		if err != nil {
-			return nil, 0, err
-		}
+	if rep.State == nil {
+		return nil, nil
+	}
 
-		event, err := s.rt.UnpackEvent(msg)
-		if err != nil {
-			return nil, 0, err
-		}
+	subjects := make(map[string]uint64, len(rep.State.Subjects))
+	for subj, n := range rep.State.Subjects {
+		subjects[contextUnsubject(s.context, subj)] = n
+	}
 
-		events = append(events, event)
+	return subjects, nil
+}
 
-		if event.Sequence == lastMsg.Sequence {
-			break
+func subjectHasWildcard(subject string) bool {
+	for _, t := range strings.Split(subject, ".") {
+		if t == "*" || t == ">" {
+			return true
 		}
 	}
-
-	return events, lastMsg.Sequence, nil
+	return false
 }
 
-// Append appends a one or more events to the subject's event sequence.
-// It returns the resulting sequence number of the last appended event.
+// loadParallel loads the events for each subject matching the wildcard subject
+// using a bounded set of workers and merges the events by stream sequence.
+func (s *EventStore) loadParallel(ctx context.Context, subject string, workers int, opts []LoadOption) ([]*Event, uint64, error) {
+	subjects, err := s.subjectsForFilter(ctx, subject)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each subject is loaded serially.
+	opts = append(opts[:len(opts):len(opts)], Parallel(1))
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		events  []*Event
+		lastSeq uint64
+		lerr    error
+	)
+
+	sem := make(chan struct{}, workers)
+
+	for subj := range subjects {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(subj string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			evs, seq, err := s.Load(ctx, subj, opts...)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if lerr == nil {
+					lerr = err
+					cancel()
+				}
+				return
+			}
+
+			events = append(events, evs...)
+			if seq > lastSeq {
+				lastSeq = seq
+			}
+		}(subj)
+	}
+
+	wg.Wait()
+
+	if lerr != nil {
+		return nil, 0, lerr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence < events[j].Sequence
+	})
+
+	return events, lastSeq, nil
+}
+
+// read streams the events for the subject to the callback in stream order
+// without buffering. The sequence of the last event for the subject is returned
+// or zero if there are no events after the start sequence.
+func (s *EventStore) read(ctx context.Context, subject string, o *loadOpts, fn func(*Event) error) (uint64, error) {
+	chain := make(hashChain)
+
+	now := s.rt.clock.Now()
+
+	emit := func(e *Event) error {
+		if err := chain.link(e); err != nil {
+			return err
+		}
+		if e.expired(now) {
+			return nil
+		}
+		if ok, err := s.validateLoaded(ctx, e); !ok {
+			return err
+		}
+		if !o.match(e) {
+			return nil
+		}
+		return fn(e)
+	}
+
+	if s.backend != nil {
+		return s.backendRead(ctx, subject, o, func(m *storage.Message) error {
+			event, err := s.backendUnpack(m, o.lenient)
+			if err != nil {
+				if event, err = o.decode.handle(ctx, s, err); event == nil {
+					return err
+				}
+			}
+			return emit(event)
+		})
+	}
+
+	// Redacted events are returned in place of the deleted messages.
+	rs, err := s.redactionsFor(ctx, subject, o)
+	if err != nil {
+		return 0, err
+	}
+
+	// Cached events are emitted first and only the events after them are
+	// read from the stream.
+	var (
+		cached   []*storage.Message
+		cacheSeq uint64
+		uncached []*storage.Message
+		useCache = s.cache.eligible(subject, o)
+	)
+	if useCache {
+		cached, err = s.cache.load(subject)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(cached) > 0 {
+			last := cached[len(cached)-1]
+			cacheSeq = last.Sequence
+
+			current := true
+			if next := rs.next(); next > 0 && next <= cacheSeq {
+				current = false
+			} else if current, err = s.cacheCurrent(ctx, last); err != nil {
+				return 0, err
+			}
+			if !current {
+				if err := s.cache.drop(subject); err != nil {
+					return 0, err
+				}
+				cached, cacheSeq = nil, 0
+			}
+		}
+
+		for _, m := range cached {
+			event, err := s.backendUnpack(m, o.lenient)
+			if err != nil {
+				if event, err = o.decode.handle(ctx, s, err); event == nil {
+					if err != nil {
+						return 0, err
+					}
+					continue
+				}
+			}
+			if err := emit(event); err != nil {
+				return 0, err
+			}
+		}
+
+		if cacheSeq > 0 {
+			co := *o
+			co.afterSeq = &cacheSeq
+			o = &co
+		}
+	}
+
+	lastSeq, err := s.readMsgs(ctx, subject, o, func(seq uint64, msg *nats.Msg) error {
+		if err := rs.emitBefore(seq, emit); err != nil {
+			return err
+		}
+
+		// The redaction was recorded but the message was not deleted.
+		if rs.next() == seq {
+			return rs.emitBefore(seq+1, emit)
+		}
+
+		event, err := s.unpackEvent(msg, o.lenient)
+		if err != nil {
+			var de *DecodeError
+			if errors.As(err, &de) && de.Sequence == 0 {
+				de.Sequence = seq
+			}
+			// Neither events failing to decode nor the events after them
+			// are cached, so they are read again from the stream.
+			useCache = false
+			event, err = o.decode.handle(ctx, s, err)
+			if event == nil {
+				return err
+			}
+			return emit(event)
+		}
+		if useCache {
+			uncached = append(uncached, cacheMsg(seq, msg, event))
+		}
+		return emit(event)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Caching is best effort.
+	if len(uncached) > 0 {
+		_ = s.cache.append(subject, uncached)
+	}
+	if lastSeq == 0 {
+		lastSeq = cacheSeq
+	}
+
+	end := uint64(math.MaxUint64)
+	if o.untilSeq > 0 {
+		end = o.untilSeq + 1
+	}
+	if err := rs.emitBefore(end, emit); err != nil {
+		return 0, err
+	}
+
+	return lastSeq, nil
+}
+
+// readMsgs streams the assembled messages for the subject to the callback
+// along with the stream sequence of the last message of each.
+func (s *EventStore) readMsgs(ctx context.Context, subject string, o *loadOpts, fn func(uint64, *nats.Msg) error) (uint64, error) {
+	if s.backend != nil {
+		return s.backendRead(ctx, subject, o, func(m *storage.Message) error {
+			return fn(m.Sequence, backendMsg(m))
+		})
+	}
+
+	lastMsg, err := s.lastMsgForSubject(ctx, subject)
+	if err != nil {
+		return 0, err
+	}
+
+	if lastMsg.Sequence == 0 {
+		return 0, nil
+	}
+
+	sopts := o.subOpts()
+
+	// Don't bother creating the consumer if the last seq is smaller than start.
+	if o.afterSeq != nil {
+		if lastMsg.Sequence <= *o.afterSeq || (o.untilSeq > 0 && o.untilSeq <= *o.afterSeq) {
+			return 0, nil
+		}
+		sopts = append(sopts, nats.StartSequence(*o.afterSeq+1))
+	} else if !o.recordedStart.IsZero() {
+		if lastMsg.Time.Before(o.recordedStart) {
+			return 0, nil
+		}
+		sopts = append(sopts, nats.StartTime(o.recordedStart))
+	} else {
+		sopts = append(sopts, nats.DeliverAll())
+	}
+
+	// Bind to the stream since the subject may not be bound to it if the
+	// store is a mirror.
+	sopts = append(sopts, nats.BindStream(s.stream))
+
+	sub, err := s.rt.js.SubscribeSync(s.subject(subject), sopts...)
+	if err != nil {
+		return 0, err
+	}
+	defer sub.Unsubscribe() //nolint
+
+	if o.pendingMsgs != 0 || o.pendingBytes != 0 {
+		msgs, bytes, err := sub.PendingLimits()
+		if err != nil {
+			return 0, err
+		}
+		if o.pendingMsgs != 0 {
+			msgs = o.pendingMsgs
+		}
+		if o.pendingBytes != 0 {
+			bytes = o.pendingBytes
+		}
+		if err := sub.SetPendingLimits(msgs, bytes); err != nil {
+			return 0, err
+		}
+	}
+
+	asm := newAssembler()
+
+	var lastSeq uint64
+
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		md, err := msg.Metadata()
+		if err != nil {
+			return 0, err
+		}
+
+		if o.untilSeq > 0 && md.Sequence.Stream > o.untilSeq {
+			break
+		}
+		lastSeq = md.Sequence.Stream
+
+		_, msg, err = asm.add(msg)
+		if err != nil {
+			return 0, err
+		}
+
+		// An incomplete chunked event at the end is skipped.
+		if msg != nil {
+			if err := fn(md.Sequence.Stream, msg); err != nil {
+				return 0, err
+			}
+		}
+
+		if md.Sequence.Stream == lastMsg.Sequence {
+			break
+		}
+	}
+
+	return lastSeq, nil
+}
+
+// Load fetches all events for a specific subject. The primary use case
+// is to use a concrete subject, e.g. "orders.1" corresponding to an
+// aggregate/entity identifier. The second use case is to load events for
+// a cross-cutting view which can use subject wildcards.
+func (s *EventStore) Load(ctx context.Context, subject string, opts ...LoadOption) ([]*Event, uint64, error) {
+	if err := validateSubject(subject, true); err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.authorize(ctx, OpLoad, subject, nil); err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.ready(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	// Configure opts.
+	var o loadOpts
+	for _, opt := range opts {
+		if err := opt.loadOpt(&o); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	maxEvents := s.rt.maxLoadEventsFor(ctx)
+
+	ctx, cancel := withTimeout(ctx, o.timeout, s.rt.loadTimeoutFor(ctx))
+	defer cancel()
+
+	var (
+		events  []*Event
+		lastSeq uint64
+		err     error
+	)
+
+	tooMany := fmt.Errorf("%w: load exceeds limit of %d", ErrTooManyEvents, maxEvents)
+
+	if o.parallel > 1 && subjectHasWildcard(subject) && s.backend == nil {
+		events, lastSeq, err = s.loadParallel(ctx, subject, o.parallel, opts)
+		if err == nil && maxEvents > 0 && len(events) > maxEvents {
+			err = tooMany
+		}
+	} else {
+		lastSeq, err = s.read(ctx, subject, &o, func(e *Event) error {
+			if maxEvents > 0 && len(events) == maxEvents {
+				return tooMany
+			}
+			events = append(events, e)
+			return nil
+		})
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !o.asAt.IsZero() {
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].EffectiveFrom().Before(events[j].EffectiveFrom())
+		})
+	} else if o.orderByTime {
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Time.Before(events[j].Time)
+		})
+	}
+
+	return events, lastSeq, nil
+}
+
+// Append appends a one or more events to the subject's event sequence.
+// It returns the resulting sequence number of the last appended event. If
+// the expected sequence does not match, an *Error wrapping
+// ErrSequenceConflict is returned with the actual sequence and the types of
+// the events appended after the expected sequence.
 func (s *EventStore) Append(ctx context.Context, subject string, events []*Event, opts ...AppendOption) (uint64, error) {
+	seq, err := s.append(ctx, subject, events, opts...)
+	if err == nil {
+		s.indexEvents(events)
+		for _, h := range s.afterAppend {
+			h(ctx, subject, events, seq)
+		}
+		s.rt.bus.publish(ctx, events)
+	} else {
+		s.describeConflict(ctx, subject, err)
+	}
+	s.auditAppend(ctx, subject, events, seq, err)
+	return seq, err
+}
+
+func (s *EventStore) append(ctx context.Context, subject string, events []*Event, opts ...AppendOption) (uint64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	if err := validateSubject(subject, true); err != nil {
+		return 0, err
+	}
+	if err := s.checkStoreSubject(subject); err != nil {
+		return 0, err
+	}
+
 	// Configure opts.
 	var o appendOpts
 	for _, opt := range opts {
@@ -337,88 +1343,521 @@ func (s *EventStore) Append(ctx context.Context, subject string, events []*Event
 		}
 	}
 
-	var ack *nats.PubAck
+	if err := s.rt.checkAppendBatch(ctx, len(events)); err != nil {
+		return 0, err
+	}
 
-	for i, event := range events {
-		popts := []nats.PubOpt{
-			nats.Context(ctx),
-			nats.ExpectStream(s.name),
+	ctx, cancel := withTimeout(ctx, o.timeout, s.rt.appendTimeoutFor(ctx))
+	defer cancel()
+
+	if err := s.ready(ctx); err != nil {
+		return 0, err
+	}
+
+	// The clock and hash of the last event are retained on the last message
+	// of the subject. The expected sequence is required so concurrent
+	// appends cannot observe the same clock or fork the chain.
+	var (
+		clock    VectorClock
+		prevHash string
+	)
+	if s.causal || s.hashChain {
+		if o.expSeq == nil {
+			return 0, ErrExpectedSequence
+		}
+
+		hdr, err := s.lastHeader(ctx, subject)
+		if err != nil {
+			return 0, err
+		}
+		clock, err = parseVectorClock(hdr.Get(eventClockHdr))
+		if err != nil {
+			return 0, err
 		}
+		prevHash = hdr.Get(eventHashHdr)
+	}
 
-		if i == 0 && o.expSeq != nil {
-			popts = append(popts, nats.ExpectLastSequencePerSubject(*o.expSeq))
+	for _, h := range s.beforeAppend {
+		if err := h(ctx, subject, events); err != nil {
+			return 0, err
 		}
+	}
+
+	// Pack all events up front so size limits are enforced before any
+	// event is published.
+	wrapped := make([]*Event, len(events))
+	packed := make([]*nats.Msg, len(events))
 
-		e, err := s.wrapEvent(event)
+	var batchSize int
+	for i, event := range events {
+		e, err := s.wrapEvent(ctx, event)
 		if err != nil {
 			return 0, err
 		}
+		if err := s.setExpires(e, o.ttl); err != nil {
+			return 0, err
+		}
+
+		if s.causal {
+			clock = clock.Merge(e.Clock)
+			clock[s.clockName()]++
+			e.Clock = clock
+		} else {
+			e.Clock = nil
+		}
 
 		msg, err := s.packEvent(subject, e)
 		if err != nil {
 			return 0, err
 		}
 
-		// TODO: add retry logic in case of intermittent errors?
-		ack, err = s.rt.js.PublishMsg(msg, popts...)
+		if s.hashChain {
+			prevHash = chainHash(msg, prevHash)
+			e.Hash = prevHash
+		}
+
+		size := len(msg.Data)
+		if s.maxEventSize > 0 && size > s.maxEventSize {
+			return 0, &SizeError{Index: i, Size: size, Limit: s.maxEventSize, err: ErrEventTooLarge}
+		}
+		batchSize += size
+
+		wrapped[i] = e
+		packed[i] = msg
+	}
+
+	if s.maxBatchSize > 0 && batchSize > s.maxBatchSize {
+		return 0, &SizeError{Index: -1, Size: batchSize, Limit: s.maxBatchSize, err: ErrBatchTooLarge}
+	}
+
+	if err := s.authorize(ctx, OpAppend, subject, wrapped); err != nil {
+		return 0, err
+	}
+
+	if err := s.rateLimit.wait(ctx, subject, len(wrapped)); err != nil {
+		return 0, err
+	}
+
+	if s.backend != nil {
+		return s.backendAppend(ctx, subject, wrapped, packed, o.expSeq)
+	}
+
+	var ack *nats.PubAck
+
+	for i, e := range wrapped {
+		msg := packed[i]
+		size := len(msg.Data)
+
+		claimed, err := s.claim(msg)
 		if err != nil {
-			if strings.Contains(err.Error(), "wrong last sequence") {
-				return 0, ErrSequenceConflict
-			}
 			return 0, err
 		}
+
+		for j, cmsg := range s.chunk(msg, e.ID) {
+			// The expected stream header is not used since it is retained in
+			// the stored message and prevents the event from being mirrored.
+			// Subjects outside of the store are rejected before publishing
+			// and the stream is checked on the ack as a safeguard.
+
+			// Only the first message has the expected sequence.
+			if i == 0 && j == 0 && o.expSeq != nil {
+				cmsg.Header.Set(nats.ExpectedLastSubjSeqHdr, strconv.FormatUint(*o.expSeq, 10))
+			}
+
+			// TODO: add retry logic in case of intermittent errors?
+			ack, err = s.rt.publishMsg(ctx, "append", cmsg)
+			if err != nil {
+				s.unclaim(claimed)
+				return 0, err
+			}
+
+			if ack.Stream != s.stream {
+				return 0, fmt.Errorf("rita: subject %q is bound to stream %q", subject, ack.Stream)
+			}
+		}
+
+		if ack.Duplicate {
+			s.unclaim(claimed)
+		}
+
+		s.sizes.observe(size)
+
+		e.Subject = subject
+		e.Sequence = ack.Sequence
 	}
 
 	return ack.Sequence, nil
 }
 
-// Evolve loads events and evolves a model of state. The sequence of the
-// last event that evolved the state is returned, including when an error
-// occurs.
+// Evolve loads events and evolves a model of state. Events are applied to the
+// model as they are received rather than being buffered, so memory usage does
+// not grow with the number of events. The sequence of the last message read
+// is returned, which is the sequence to expect when appending to the subject
+// even if trailing events were skipped, e.g. because they expired. If events
+// are filtered by time or metadata, or an error occurs, the sequence of the
+// last event that evolved the state is returned. Redacted events are not
+// counted since their messages were deleted, so a redacted event at the end
+// of the subject is applied again when loading after the returned sequence.
 func (s *EventStore) Evolve(ctx context.Context, subject string, model Evolver, opts ...LoadOption) (uint64, error) {
-	events, _, err := s.Load(ctx, subject, opts...)
-	if err != nil {
-		return 0, err
+	seq, _, err := s.evolve(ctx, subject, model, opts...)
+	return seq, err
+}
+
+// evolve evolves the model like Evolve and also returns the sequence of the
+// last event applied, including redacted events, so the events after it can
+// be loaded without applying redacted events again.
+func (s *EventStore) evolve(ctx context.Context, subject string, model Evolver, opts ...LoadOption) (uint64, uint64, error) {
+	// Configure opts.
+	var o loadOpts
+	for _, opt := range opts {
+		if err := opt.loadOpt(&o); err != nil {
+			return 0, 0, err
+		}
 	}
 
-	var lastSeq uint64
-	for _, e := range events {
+	ctx, cancel := withTimeout(ctx, o.timeout, s.rt.loadTimeoutFor(ctx))
+	defer cancel()
+
+	// The sequence of the last event applied, including redacted events.
+	var lastSeq, seen uint64
+
+	// Parallel loads must be merged by sequence and events as at a business
+	// time must be ordered by effective time before being applied.
+	if (o.parallel > 1 && subjectHasWildcard(subject) && s.backend == nil) || !o.asAt.IsZero() {
+		events, seq, err := s.Load(ctx, subject, opts...)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		for _, e := range events {
+			if err := model.Evolve(e); err != nil {
+				return lastSeq, seen, err
+			}
+			if e.Sequence > seen {
+				seen = e.Sequence
+			}
+			if !e.Redacted && e.Sequence > lastSeq {
+				lastSeq = e.Sequence
+			}
+		}
+
+		if !o.filtered() && seq > lastSeq {
+			lastSeq = seq
+		}
+		if lastSeq > seen {
+			seen = lastSeq
+		}
+		return lastSeq, seen, nil
+	}
+
+	if err := s.authorize(ctx, OpLoad, subject, nil); err != nil {
+		return 0, 0, err
+	}
+
+	if err := s.ready(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	// The model is restored from the checkpoint, if any, and only the
+	// events after it are read.
+	var (
+		cpSeq            uint64
+		modelType, every = s.checkpointFor(model)
+	)
+	if every > 0 && o.checkpointable() {
+		var err error
+		cpSeq, err = s.restoreCheckpoint(modelType, subject, model)
+		if err != nil {
+			return 0, 0, err
+		}
+		if cpSeq > 0 {
+			lastSeq, seen = cpSeq, cpSeq
+			o.afterSeq = &cpSeq
+		}
+	} else {
+		every = 0
+	}
+
+	// Unless events are filtered, the sequence of the last message read is
+	// returned rather than of the last event applied, so events skipped
+	// because they expired, failed validation or failed to decode do not
+	// cause a sequence conflict on the next append.
+	seq, err := s.read(ctx, subject, &o, func(e *Event) error {
 		if err := model.Evolve(e); err != nil {
-			return lastSeq, err
+			return err
+		}
+		seen = e.Sequence
+		if !e.Redacted {
+			lastSeq = e.Sequence
 		}
-		lastSeq = e.Sequence
+		return nil
+	})
+	if err == nil && !o.filtered() && seq > lastSeq {
+		lastSeq = seq
+	}
+	if lastSeq > seen {
+		seen = lastSeq
 	}
 
-	return lastSeq, nil
+	// Checkpointing is best effort. A checkpoint is not saved while the
+	// last event is redacted, since it would be restored at the sequence of
+	// the redacted event rather than the one to expect.
+	if err == nil && every > 0 && lastSeq-cpSeq >= every && seen == lastSeq {
+		_ = s.saveCheckpoint(modelType, subject, model, lastSeq)
+	}
+
+	return lastSeq, seen, err
+}
+
+// EvolveAll loads events and evolves multiple models in a single read. This
+// is equivalent to calling Evolve with MultiEvolver, which can be used if load
+// options are required.
+func (s *EventStore) EvolveAll(ctx context.Context, subject string, models ...Evolver) (uint64, error) {
+	return s.Evolve(ctx, subject, MultiEvolver(models...))
+}
+
+// EvolveAsOf evolves a model of state as it was known at the time, e.g. to
+// answer what an order looked like last Tuesday. This is equivalent to calling
+// Evolve with AsOf.
+func (s *EventStore) EvolveAsOf(ctx context.Context, subject string, model Evolver, t time.Time, opts ...LoadOption) (uint64, error) {
+	return s.Evolve(ctx, subject, model, append(opts, AsOf(t))...)
 }
 
 // Create creates the event store given the configuration. The stream
-// name is the name of the store and the subjects default to "{name}}.>".
-func (s *EventStore) Create(config *nats.StreamConfig) error {
+// name is the name of the store and the subjects default to "{name}}.>",
+// unless the store is a mirror. If the store is mapped onto a shared stream
+// which already exists, the subjects are added to the stream and the
+// remaining configuration is ignored.
+func (s *EventStore) Create(config *EventStoreConfig) error {
+	if s.bind {
+		return ErrExternallyManaged
+	}
+	if s.backend != nil {
+		return ErrBackendUnsupported
+	}
+
 	if config == nil {
-		config = &nats.StreamConfig{}
+		config = &EventStoreConfig{}
+	}
+
+	if err := config.validate(s.name, s.shared); err != nil {
+		return err
+	}
+
+	sc := s.streamConfig(nil, config)
+
+	if len(sc.Subjects) == 0 && sc.Mirror == nil {
+		sc.Subjects = []string{s.subject(s.filterSubject())}
 	}
-	config.Name = s.name
 
-	if len(config.Subjects) == 0 {
-		config.Subjects = []string{fmt.Sprintf("%s.>", s.name)}
+	if s.shared {
+		info, err := s.rt.js.StreamInfo(s.stream)
+		if err == nil {
+			cur := info.Config
+			for _, subj := range sc.Subjects {
+				if !containsString(cur.Subjects, subj) {
+					cur.Subjects = append(cur.Subjects, subj)
+				}
+			}
+			_, err = s.rt.js.UpdateStream(&cur)
+			return err
+		}
+		if !errors.Is(err, nats.ErrStreamNotFound) {
+			return err
+		}
 	}
 
-	_, err := s.rt.js.AddStream(config)
+	_, err := s.rt.js.AddStream(sc)
 	return err
 }
 
-// Update updates the event store configuration.
-func (s *EventStore) Update(config *nats.StreamConfig) error {
-	if config == nil {
-		config = &nats.StreamConfig{}
+// Update updates the event store configuration. Only the fields set in the
+// config, i.e. not zero or listed in Explicit, are applied, the others retain
+// their current values. If no subjects are defined, the current subjects are
+// retained. For a shared stream, subjects owned by other stores are always
+// retained.
+func (s *EventStore) Update(config *EventStoreConfig) error {
+	if s.bind {
+		return ErrExternallyManaged
+	}
+	if s.backend != nil {
+		return ErrBackendUnsupported
 	}
-	config.Name = s.name
-	_, err := s.rt.js.UpdateStream(config)
+
+	_, sc, err := s.desiredConfig(context.Background(), config)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.rt.js.UpdateStream(sc)
 	return err
 }
 
-// Delete deletes the event store.
-func (s *EventStore) Delete() error {
-	return s.rt.js.DeleteStream(s.name)
+// desiredConfig returns the current stream config and the config after
+// applying the event store config.
+func (s *EventStore) desiredConfig(ctx context.Context, config *EventStoreConfig) (*nats.StreamConfig, *nats.StreamConfig, error) {
+	if config == nil {
+		config = &EventStoreConfig{}
+	}
+
+	if err := config.validate(s.name, s.shared); err != nil {
+		return nil, nil, err
+	}
+
+	info, err := s.rt.js.StreamInfo(s.stream, nats.Context(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sc := s.streamConfig(&info.Config, config)
+
+	subjects := len(config.Subjects) > 0 || (config.Advanced != nil && len(config.Advanced.Subjects) > 0)
+	if subjects && s.shared {
+		_, others := s.ownSubjects(info.Config.Subjects)
+		sc.Subjects = append(sc.Subjects, others...)
+	}
+
+	return &info.Config, sc, nil
+}
+
+// Delete deletes the event store along with the data derived from its
+// events, such as redaction records, indexes, checkpoints, materialized state,
+// quarantined events, and the disk cache of this process. For a shared stream, the events of the
+// store are purged and its subjects removed from the stream, which fails with
+// ErrPurgesDenied if the stream denies purges. The stream is only deleted
+// once no other store uses it. A delete protected store requires the Force
+// option.
+func (s *EventStore) Delete(opts ...DeleteOption) error {
+	if s.bind {
+		return ErrExternallyManaged
+	}
+	if s.backend != nil {
+		return ErrBackendUnsupported
+	}
+
+	var o deleteOpts
+	for _, opt := range opts {
+		if err := opt.deleteOpt(&o); err != nil {
+			return err
+		}
+	}
+
+	if s.deleteProtection && !o.force {
+		return ErrDeleteProtected
+	}
+
+	if o.export != nil {
+		ctx, cancel := withTimeout(context.Background(), nil, s.rt.loadTimeoutFor(context.Background()))
+		defer cancel()
+
+		if err := s.export(ctx, o.export); err != nil {
+			return err
+		}
+	}
+
+	if !s.shared {
+		return s.deleteStream()
+	}
+
+	info, err := s.rt.js.StreamInfo(s.stream)
+	if err != nil {
+		return err
+	}
+
+	own, others := s.ownSubjects(info.Config.Subjects)
+	if len(others) == 0 {
+		return s.deleteStream()
+	}
+
+	if info.Config.DenyPurge {
+		return ErrPurgesDenied
+	}
+
+	for _, subj := range own {
+		if err := s.purgeSubject(subj); err != nil {
+			return err
+		}
+	}
+
+	sc := info.Config
+	sc.Subjects = others
+	if _, err := s.rt.js.UpdateStream(&sc); err != nil {
+		return err
+	}
+	return s.deleteDerived()
+}
+
+// deleteStream deletes the stream along with the data derived from it.
+func (s *EventStore) deleteStream() error {
+	if err := s.rt.js.DeleteStream(s.stream); err != nil {
+		return err
+	}
+	return s.deleteDerived()
+}
+
+// ownSubjects partitions the stream subjects into those owned by the store
+// and those owned by other stores sharing the stream.
+func (s *EventStore) ownSubjects(subjects []string) ([]string, []string) {
+	prefix := s.subject(s.name) + "."
+
+	var own, others []string
+	for _, subj := range subjects {
+		if strings.HasPrefix(subj, prefix) {
+			own = append(own, subj)
+		} else {
+			others = append(others, subj)
+		}
+	}
+	return own, others
+}
+
+// purgeSubject purges all messages in the stream matching the subject. The
+// subject is expected to already be mapped into the context namespace.
+func (s *EventStore) purgeSubject(subject string) error {
+	return s.rt.purge(s.stream, subject)
+}
+
+// purge purges all messages in the stream matching the subject.
+func (r *Rita) purge(stream, subject string) error {
+	rsubject := fmt.Sprintf("$JS.API.STREAM.PURGE.%s", stream)
+
+	data, _ := json.Marshal(&natsPurgeRequest{
+		Filter: subject,
+	})
+
+	msg, err := r.nc.Request(rsubject, data, r.apiTimeout)
+	if err != nil {
+		return err
+	}
+
+	var rep natsPurgeResponse
+	err = json.Unmarshal(msg.Data, &rep)
+	if err != nil {
+		return err
+	}
+
+	if rep.Error != nil {
+		return apiError("purge", r.unsubject(subject), rep.Error)
+	}
+
+	return nil
+}
+
+// durable returns the name of a durable consumer of the store. Consumer names
+// are scoped to the stream, so they are prefixed by the store name when the
+// stream is shared.
+func (s *EventStore) durable(name string) string {
+	if !s.shared {
+		return name
+	}
+	return fmt.Sprintf("%s_%s", s.name, name)
+}
+
+func containsString(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
 }
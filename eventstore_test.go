@@ -2,8 +2,11 @@ package rita
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bruth/rita/id"
 	"github.com/bruth/rita/testutil"
@@ -34,6 +37,18 @@ func (s *OrderStats) Evolve(event *Event) error {
 	return nil
 }
 
+// failingEvolver fails on the first shipped event.
+type failingEvolver struct {
+	OrderStats
+}
+
+func (s *failingEvolver) Evolve(event *Event) error {
+	if _, ok := event.Data.(*OrderShipped); ok {
+		return errors.New("shipped")
+	}
+	return s.OrderStats.Evolve(event)
+}
+
 func TestEventStoreNoRegistry(t *testing.T) {
 	is := testutil.NewIs(t)
 
@@ -45,8 +60,10 @@ func TestEventStoreNoRegistry(t *testing.T) {
 	r, err := New(nc)
 	is.NoErr(err)
 
-	es := r.EventStore("orders")
-	err = es.Create(&nats.StreamConfig{
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	err = es.Create(&EventStoreConfig{
 		Storage: nats.MemoryStorage,
 	})
 	is.NoErr(err)
@@ -150,6 +167,80 @@ func TestEventStoreWithRegistry(t *testing.T) {
 				is.Equal(events[0].Type, "order-shipped")
 			},
 		},
+		{
+			"load-consumer-options",
+			func(t *testing.T, es *EventStore, subject string) {
+				ctx := context.Background()
+
+				seq, err := es.Append(ctx, subject, []*Event{
+					{Data: &OrderPlaced{ID: "123"}},
+					{Data: &OrderShipped{ID: "123"}},
+				})
+				is.NoErr(err)
+				is.Equal(seq, uint64(2))
+
+				events, lseq, err := es.Load(ctx, subject,
+					Heartbeat(time.Second),
+					InactiveThreshold(time.Minute),
+					PendingLimits(1, -1),
+				)
+				is.NoErr(err)
+
+				is.Equal(seq, lseq)
+				is.Equal(len(events), 2)
+			},
+		},
+		{
+			"load-parallel",
+			func(t *testing.T, es *EventStore, _ string) {
+				ctx := context.Background()
+
+				for i := 0; i < 20; i++ {
+					subject := fmt.Sprintf("orders.%d", i%7)
+					_, err := es.Append(ctx, subject, []*Event{{Data: &OrderPlaced{ID: subject}}})
+					is.NoErr(err)
+				}
+
+				events1, seq1, err := es.Load(ctx, "orders.*")
+				is.NoErr(err)
+
+				events2, seq2, err := es.Load(ctx, "orders.*", Parallel(3))
+				is.NoErr(err)
+
+				is.Equal(seq1, seq2)
+				is.Equal(len(events1), 20)
+				is.Equal(len(events2), 20)
+				for i := range events1 {
+					is.Equal(events1[i].Sequence, events2[i].Sequence)
+					is.Equal(events1[i].Subject, events2[i].Subject)
+				}
+
+				events2, seq2, err = es.Load(ctx, "orders.*", Parallel(3), AfterSequence(15))
+				is.NoErr(err)
+				is.Equal(seq2, uint64(20))
+				is.Equal(len(events2), 5)
+			},
+		},
+		{
+			"evolve-error",
+			func(t *testing.T, es *EventStore, subject string) {
+				ctx := context.Background()
+
+				_, err := es.Append(ctx, subject, []*Event{
+					{Data: &OrderPlaced{ID: "1"}},
+					{Data: &OrderPlaced{ID: "2"}},
+					{Data: &OrderShipped{ID: "1"}},
+					{Data: &OrderShipped{ID: "2"}},
+				})
+				is.NoErr(err)
+
+				var model failingEvolver
+				seq, err := es.Evolve(ctx, subject, &model)
+				is.Err(err, nil)
+				is.Equal(seq, uint64(2))
+				is.Equal(model.OrdersPlaced, 2)
+			},
+		},
 		{
 			"duplicate-append",
 			func(t *testing.T, es *EventStore, subject string) {
@@ -211,6 +302,14 @@ func TestEventStoreWithRegistry(t *testing.T) {
 
 				is.Equal(stats.OrdersPlaced, 3)
 				is.Equal(stats.OrdersShipped, 2)
+
+				// Evolve multiple models in one pass.
+				var stats1, stats2 OrderStats
+				seq2, err = es.EvolveAll(ctx, "orders.*", &stats1, &stats2)
+				is.NoErr(err)
+				is.Equal(seq, seq2)
+				is.Equal(stats1, stats)
+				is.Equal(stats2, stats)
 			},
 		},
 	}
@@ -235,11 +334,12 @@ func TestEventStoreWithRegistry(t *testing.T) {
 
 	for i, test := range tests {
 		t.Run(test.Name, func(t *testing.T) {
-			es := r.EventStore("orders")
+			es, err := r.EventStore("orders")
+			is.NoErr(err)
 
 			// Recreate the store for each test.
 			_ = es.Delete()
-			err := es.Create(&nats.StreamConfig{
+			err = es.Create(&EventStoreConfig{
 				Storage: nats.MemoryStorage,
 			})
 			is.NoErr(err)
@@ -249,3 +349,822 @@ func TestEventStoreWithRegistry(t *testing.T) {
 		})
 	}
 }
+
+func TestEventStoreLoadAfterSequence(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	// The sequence is exclusive, so the event at it is not read again.
+	for after, want := range map[uint64][]uint64{
+		0: {1, 2, 3},
+		1: {2, 3},
+		2: {3},
+		3: nil,
+	} {
+		events, _, err := es.Load(ctx, "orders.1", AfterSequence(after))
+		is.NoErr(err)
+
+		var seqs []uint64
+		for _, e := range events {
+			seqs = append(seqs, e.Sequence)
+		}
+		is.Equal(seqs, want)
+	}
+}
+
+func TestEventStoreSharedStream(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	_, err = r.EventStore("orders", EventStoreStream("bad.name"))
+	is.True(err != nil)
+
+	orders, err := r.EventStore("orders", EventStoreStream("shared"))
+	is.NoErr(err)
+	is.NoErr(orders.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	returns, err := r.EventStore("returns", EventStoreStream("shared"))
+	is.NoErr(err)
+	is.NoErr(returns.Create(nil))
+
+	info, err := r.js.StreamInfo("shared")
+	is.NoErr(err)
+	is.Equal(info.Config.Subjects, []string{"orders.>", "returns.>"})
+
+	ctx := context.Background()
+
+	_, err = orders.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+	_, err = returns.Append(ctx, "returns.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	// Loads and consumers are scoped to the store.
+	events, _, err := orders.Load(ctx, "orders.*")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	osub, err := orders.Subscription("audit")
+	is.NoErr(err)
+	defer osub.Delete() //nolint
+
+	rsub, err := returns.Subscription("audit")
+	is.NoErr(err)
+	defer rsub.Delete() //nolint
+
+	lag, err := rsub.Lag()
+	is.NoErr(err)
+	is.Equal(lag, uint64(1))
+
+	tail, err := returns.Tail(ctx, "")
+	is.NoErr(err)
+	defer tail.Stop() //nolint
+
+	event, err := tail.Next(ctx)
+	is.NoErr(err)
+	is.Equal(event.Subject, "returns.1")
+
+	// Deleting a store purges its events and retains the stream.
+	is.NoErr(returns.Delete())
+
+	info, err = r.js.StreamInfo("shared")
+	is.NoErr(err)
+	is.Equal(info.Config.Subjects, []string{"orders.>"})
+	is.Equal(info.State.Msgs, uint64(1))
+
+	is.NoErr(orders.Delete())
+
+	_, err = r.js.StreamInfo("shared")
+	is.Err(err, nats.ErrStreamNotFound)
+}
+
+func TestEventStoreAppendOtherStore(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	orders, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(orders.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	payments, err := r.EventStore("payments")
+	is.NoErr(err)
+	is.NoErr(payments.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	// The subject is rejected before the event is published to the stream
+	// of the other store.
+	_, err = orders.Append(ctx, "payments.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.Err(err, ErrInvalidSubject)
+
+	_, err = orders.BulkAppend(ctx, "payments.1", IterEvents([]*Event{{Data: &OrderPlaced{ID: "1"}}}))
+	is.Err(err, ErrInvalidSubject)
+
+	events, _, err := payments.Load(ctx, "payments.1")
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+}
+
+func TestEventStoreMirror(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	replica, err := r.EventStore("orders-replica")
+	is.NoErr(err)
+
+	err = replica.Create(&EventStoreConfig{
+		Subjects: []string{"orders-replica.>"},
+		Mirror:   &EventStoreSource{Name: "orders"},
+	})
+	is.Err(err, ErrInvalidConfig)
+
+	err = replica.Create(&EventStoreConfig{
+		Storage: nats.MemoryStorage,
+		Mirror:  &EventStoreSource{Name: "orders"},
+	})
+	is.NoErr(err)
+
+	// Wait for the mirror to catch up.
+	var events []*Event
+	for i := 0; i < 50; i++ {
+		events, _, err = replica.Load(ctx, "orders.1")
+		is.NoErr(err)
+		if len(events) == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	is.Equal(len(events), 2)
+	is.Equal(events[1].Type, "order-shipped")
+}
+
+func TestEventStoreVersionedTypes(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	type CustomerV1 struct {
+		Name string
+	}
+
+	type CustomerV2 struct {
+		First string
+		Last  string
+	}
+
+	tr, err := types.NewRegistry(map[string]*types.Type{
+		"customer-added": {
+			Init:    func() any { return &CustomerV2{} },
+			Version: 2,
+			Versions: map[int]func() any{
+				1: func() any { return &CustomerV1{} },
+			},
+			Migrate: func(from int, v any) (any, error) {
+				first, last, _ := strings.Cut(v.(*CustomerV1).Name, " ")
+				return &CustomerV2{First: first, Last: last}, nil
+			},
+		},
+	})
+	is.NoErr(err)
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("customers")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	// Both versions can be appended.
+	_, err = es.Append(ctx, "customers.1", []*Event{
+		{Data: &CustomerV1{Name: "Pam Beesly"}},
+		{Data: &CustomerV2{First: "Jim", Last: "Halpert"}},
+	})
+	is.NoErr(err)
+
+	msg, err := r.js.GetMsg("customers", 1)
+	is.NoErr(err)
+	is.Equal(msg.Header.Get(eventVersionHdr), "1")
+
+	events, _, err := es.Load(ctx, "customers.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(events[0].Data, &CustomerV2{First: "Pam", Last: "Beesly"})
+	is.Equal(events[1].Data, &CustomerV2{First: "Jim", Last: "Halpert"})
+}
+
+func TestEventStoreLenient(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	type OrderCancelled struct {
+		ID string
+	}
+
+	// The producer knows of a type the consumer does not.
+	ptr, err := types.NewRegistry(map[string]*types.Type{
+		"order-placed":    {Init: func() any { return &OrderPlaced{} }},
+		"order-cancelled": {Init: func() any { return &OrderCancelled{} }},
+	})
+	is.NoErr(err)
+
+	p, err := New(nc, TypeRegistry(ptr))
+	is.NoErr(err)
+
+	pes, err := p.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(pes.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = pes.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderCancelled{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	c, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	ces, err := c.EventStore("orders")
+	is.NoErr(err)
+
+	_, _, err = ces.Load(ctx, "orders.1")
+	is.Err(err, types.ErrTypeNotRegistered)
+
+	events, _, err := ces.Load(ctx, "orders.1", Lenient())
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(events[0].Unknown, false)
+	is.Equal(events[0].Data, &OrderPlaced{ID: "1"})
+	is.Equal(events[1].Unknown, true)
+	is.Equal(events[1].Type, "order-cancelled")
+
+	b, err := ptr.Marshal(&OrderCancelled{ID: "1"})
+	is.NoErr(err)
+	is.Equal(events[1].Data, b)
+}
+
+func TestEventStoreRecordedTime(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	t1 := time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2022, time.May, 2, 0, 0, 0, 0, time.UTC)
+
+	// The second event occurred before the first but was recorded after.
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}, Time: t2}})
+	is.NoErr(err)
+
+	time.Sleep(10 * time.Millisecond)
+	mid := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}, Time: t1}})
+	is.NoErr(err)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.True(events[0].RecordedTime.Before(mid))
+	is.True(events[1].RecordedTime.After(mid))
+
+	events, _, err = es.Load(ctx, "orders.1", OrderByTime())
+	is.NoErr(err)
+	is.Equal(events[0].Type, "order-shipped")
+	is.Equal(events[1].Type, "order-placed")
+
+	events, _, err = es.Load(ctx, "orders.1", TimeRange(t2, time.Time{}))
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Type, "order-placed")
+
+	events, _, err = es.Load(ctx, "orders.1", RecordedTimeRange(mid, time.Time{}))
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Type, "order-shipped")
+
+	events, _, err = es.Load(ctx, "orders.1", RecordedTimeRange(time.Time{}, mid))
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Type, "order-placed")
+
+	events, _, err = es.Load(ctx, "orders.1", RecordedTimeRange(time.Now().Add(time.Hour), time.Time{}))
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+}
+
+func TestEventStoreAsOf(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	placedSeq, err := es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	time.Sleep(10 * time.Millisecond)
+	mid := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	_, _, err = es.Load(ctx, "orders.1", AsOf(time.Time{}))
+	is.True(err != nil)
+
+	var stats OrderStats
+	seq, err := es.EvolveAsOf(ctx, "orders.1", &stats, mid)
+	is.NoErr(err)
+	is.Equal(seq, placedSeq)
+	is.Equal(stats, OrderStats{OrdersPlaced: 1})
+
+	stats = OrderStats{}
+	_, err = es.EvolveAsOf(ctx, "orders.1", &stats, time.Now())
+	is.NoErr(err)
+	is.Equal(stats, OrderStats{OrdersPlaced: 1, OrdersShipped: 1})
+
+	events, _, err := es.Load(ctx, "orders.1", AsOfSequence(placedSeq))
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	// The time is inclusive.
+	events, _, err = es.Load(ctx, "orders.1", AsOf(events[0].RecordedTime))
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+}
+
+func TestEventStoreAsAt(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	date := func(m time.Month, d int) time.Time {
+		return time.Date(2022, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// The last event is a correction recorded after the fact which was
+	// effective for a limited period.
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}, ValidFrom: date(time.January, 1)},
+		{Data: &OrderShipped{ID: "1"}, ValidFrom: date(time.March, 1)},
+		{Data: &OrderPlaced{ID: "2"}, ValidFrom: date(time.February, 1), ValidTo: date(time.April, 1)},
+	})
+	is.NoErr(err)
+
+	events, _, err := es.Load(ctx, "orders.1", AsAt(date(time.March, 15)))
+	is.NoErr(err)
+	is.Equal(len(events), 3)
+	is.Equal(events[1].Data, &OrderPlaced{ID: "2"})
+	is.Equal(events[1].ValidFrom, date(time.February, 1))
+	is.Equal(events[1].ValidTo, date(time.April, 1))
+	is.Equal(events[2].Type, "order-shipped")
+
+	var stats OrderStats
+	_, err = es.Evolve(ctx, "orders.1", &stats, AsAt(date(time.February, 15)))
+	is.NoErr(err)
+	is.Equal(stats, OrderStats{OrdersPlaced: 2})
+
+	stats = OrderStats{}
+	seq, err := es.Evolve(ctx, "orders.1", &stats, AsAt(date(time.April, 15)))
+	is.NoErr(err)
+	is.Equal(seq, uint64(2))
+	is.Equal(stats, OrderStats{OrdersPlaced: 1, OrdersShipped: 1})
+}
+
+func TestEventStoreWhereMeta(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}, Meta: map[string]string{"tenant": "acme", "region": "eu"}},
+		{Data: &OrderShipped{ID: "1"}, Meta: map[string]string{"tenant": "acme"}},
+	})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.2", []*Event{
+		{Data: &OrderPlaced{ID: "2"}, Meta: map[string]string{"tenant": "other"}},
+		{Data: &OrderShipped{ID: "2"}},
+	})
+	is.NoErr(err)
+
+	_, _, err = es.Load(ctx, "orders.*", WhereMeta("", "acme"))
+	is.True(err != nil)
+
+	events, _, err := es.Load(ctx, "orders.*", WhereMeta("tenant", "acme"))
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(events[0].Meta["tenant"], "acme")
+
+	events, _, err = es.Load(ctx, "orders.*", WhereMeta("tenant", "acme"), WhereMeta("region", "eu"))
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Type, "order-placed")
+
+	var stats OrderStats
+	_, err = es.Evolve(ctx, "orders.*", &stats, WhereMeta("tenant", "other"))
+	is.NoErr(err)
+	is.Equal(stats, OrderStats{OrdersPlaced: 1})
+}
+
+func TestEventStoreTimeout(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	_, err := New(nc, LoadTimeout(-time.Second))
+	is.True(err != nil)
+
+	_, err = New(nc, APITimeout(0))
+	is.True(err != nil)
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), LoadTimeout(time.Nanosecond), APITimeout(time.Second))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, Timeout(time.Nanosecond))
+	is.Err(err, context.DeadlineExceeded)
+
+	// The default load timeout applies unless overridden.
+	_, _, err = es.Load(ctx, "orders.1")
+	is.Err(err, context.DeadlineExceeded)
+
+	events, _, err := es.Load(ctx, "orders.1", Timeout(time.Second))
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	_, _, err = es.Load(ctx, "orders.1", Timeout(0))
+	is.NoErr(err)
+}
+
+func TestEventStoreCreateConfig(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	err = es.Create(&EventStoreConfig{
+		MaxAge:     time.Minute,
+		Duplicates: time.Hour,
+	})
+	is.Err(err, ErrInvalidConfig)
+
+	is.NoErr(es.Create(&EventStoreConfig{
+		Storage:    nats.MemoryStorage,
+		MaxMsgs:    1,
+		Discard:    nats.DiscardNew,
+		Duplicates: time.Second,
+	}))
+
+	info, err := r.js.StreamInfo("orders")
+	is.NoErr(err)
+	is.Equal(info.Config.Duplicates, time.Second)
+	is.Equal(info.Config.Discard, nats.DiscardNew)
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	// The limit rejects the append rather than discarding history.
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.True(err != nil)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+}
+
+func TestEventStoreAdvancedConfig(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), Context("shop"))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	for _, a := range []*nats.StreamConfig{
+		{Name: "other"},
+		{Retention: nats.WorkQueuePolicy},
+		{AllowRollup: true},
+		{Subjects: []string{"orders..bad"}},
+		{Subjects: []string{"payments.>"}},
+	} {
+		err = es.Create(&EventStoreConfig{Advanced: a})
+		is.Err(err, ErrInvalidConfig)
+	}
+
+	// Subjects must be in the store, since events are read from them.
+	for _, subj := range []string{"payments.>", "orders", "ordersx.>"} {
+		err = es.Create(&EventStoreConfig{Subjects: []string{subj}})
+		is.Err(err, ErrInvalidConfig)
+	}
+
+	is.NoErr(es.Create(&EventStoreConfig{
+		Storage:  nats.MemoryStorage,
+		MaxBytes: 1 << 20,
+		Advanced: &nats.StreamConfig{
+			Subjects:          []string{"orders.*"},
+			MaxBytes:          1 << 10,
+			MaxMsgsPerSubject: 10,
+			MaxMsgSize:        512,
+			DenyDelete:        true,
+			DenyPurge:         true,
+		},
+	}))
+
+	info, err := r.js.StreamInfo("shop_orders")
+	is.NoErr(err)
+	is.Equal(info.Config.Subjects, []string{"shop.orders.*"})
+	is.Equal(info.Config.Storage, nats.MemoryStorage)
+	is.Equal(info.Config.MaxBytes, int64(1<<20))
+	is.Equal(info.Config.MaxMsgsPerSubject, int64(10))
+	is.Equal(info.Config.MaxMsgSize, int32(512))
+
+	// Deletes and purges are denied, so operations deleting events fail.
+	is.True(info.Config.DenyDelete)
+	is.True(info.Config.DenyPurge)
+
+	ctx := context.Background()
+
+	seq, err := es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	is.Err(es.Redact(ctx, seq, "test"), ErrDeletesDenied)
+
+	_, err = es.Expire(ctx)
+	is.Err(err, ErrDeletesDenied)
+}
+
+func TestEventStoreUpdateDiff(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{
+		Description: "Orders",
+		Storage:     nats.MemoryStorage,
+		MaxAge:      time.Hour,
+	}))
+
+	ctx := context.Background()
+
+	drift, err := es.Diff(ctx, &EventStoreConfig{MaxAge: time.Hour})
+	is.NoErr(err)
+	is.Equal(len(drift), 0)
+
+	drift, err = es.Diff(ctx, &EventStoreConfig{MaxMsgs: 100})
+	is.NoErr(err)
+	is.Equal(len(drift), 1)
+	is.Equal(drift[0].Field, "MaxMsgs")
+	is.Equal(drift[0].Desired, int64(100))
+	is.Equal(drift[0].Actual, int64(-1))
+
+	// Unset fields retain their current values.
+	is.NoErr(es.Update(&EventStoreConfig{MaxMsgs: 100}))
+
+	info, err := r.js.StreamInfo("orders")
+	is.NoErr(err)
+	is.Equal(info.Config.Description, "Orders")
+	is.Equal(info.Config.Storage, nats.MemoryStorage)
+	is.Equal(info.Config.MaxAge, time.Hour)
+	is.Equal(info.Config.MaxMsgs, int64(100))
+
+	drift, err = es.Diff(ctx, &EventStoreConfig{MaxMsgs: 100})
+	is.NoErr(err)
+	is.Equal(len(drift), 0)
+
+	// Zero values are only compared and applied if explicit.
+	drift, err = es.Diff(ctx, &EventStoreConfig{Storage: nats.FileStorage})
+	is.NoErr(err)
+	is.Equal(len(drift), 0)
+
+	drift, err = es.Diff(ctx, &EventStoreConfig{Storage: nats.FileStorage, Explicit: []string{"Storage"}})
+	is.NoErr(err)
+	is.Equal(len(drift), 1)
+	is.Equal(drift[0].Field, "Storage")
+
+	is.NoErr(es.Update(&EventStoreConfig{Discard: nats.DiscardNew}))
+	is.NoErr(es.Update(&EventStoreConfig{Explicit: []string{"MaxAge", "MaxMsgs", "Discard"}}))
+
+	info, err = r.js.StreamInfo("orders")
+	is.NoErr(err)
+	is.Equal(info.Config.MaxAge, time.Duration(0))
+	is.Equal(info.Config.MaxMsgs, int64(-1))
+	is.Equal(info.Config.Discard, nats.DiscardOld)
+
+	drift, err = es.Diff(ctx, &EventStoreConfig{Explicit: []string{"MaxAge", "MaxMsgs", "Discard"}})
+	is.NoErr(err)
+	is.Equal(len(drift), 0)
+
+	_, err = es.Diff(ctx, &EventStoreConfig{Explicit: []string{"Replicas"}})
+	is.Err(err, ErrInvalidConfig)
+
+	// Unset limits of an advanced config are the server defaults.
+	adv := &EventStoreConfig{Advanced: &nats.StreamConfig{
+		Storage:           nats.MemoryStorage,
+		MaxMsgsPerSubject: 10,
+	}}
+	is.NoErr(es.Update(adv))
+
+	drift, err = es.Diff(ctx, adv)
+	is.NoErr(err)
+	is.Equal(len(drift), 0)
+}
+
+func TestEventStoreEnsure(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	config := &EventStoreConfig{
+		Storage: nats.MemoryStorage,
+		MaxAge:  time.Hour,
+	}
+
+	// Created, then unchanged.
+	is.NoErr(es.Ensure(ctx, config))
+	is.NoErr(es.Ensure(ctx, config))
+
+	config.MaxAge = 2 * time.Hour
+	is.NoErr(es.Ensure(ctx, config))
+
+	info, err := r.js.StreamInfo("orders")
+	is.NoErr(err)
+	is.Equal(info.Config.MaxAge, 2*time.Hour)
+
+	err = es.Ensure(ctx, &EventStoreConfig{
+		Advanced: &nats.StreamConfig{Storage: nats.FileStorage},
+	})
+	is.Err(err, ErrIncompatibleConfig)
+
+	err = es.Ensure(ctx, &EventStoreConfig{
+		Storage:  nats.FileStorage,
+		Explicit: []string{"Storage"},
+	})
+	is.Err(err, ErrIncompatibleConfig)
+
+	// Stores are added to an existing shared stream.
+	for _, name := range []string{"returns", "invoices"} {
+		es, err := r.EventStore(name, EventStoreStream("shared"))
+		is.NoErr(err)
+		is.NoErr(es.Ensure(ctx, &EventStoreConfig{Storage: nats.MemoryStorage}))
+	}
+
+	info, err = r.js.StreamInfo("shared")
+	is.NoErr(err)
+	is.Equal(info.Config.Subjects, []string{"returns.>", "invoices.>"})
+}
@@ -0,0 +1,421 @@
+package rita
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const defaultTenantKey = "tenant"
+
+type exportOpts struct {
+	key    string
+	stores []string
+	state  []*StateStore
+}
+
+type exportOptFn func(o *exportOpts) error
+
+func (f exportOptFn) exportOpt(o *exportOpts) error {
+	return f(o)
+}
+
+// ExportOption is an option for ExportTenant.
+type ExportOption interface {
+	exportOpt(o *exportOpts) error
+}
+
+// ExportTenantKey sets the key of the event metadata identifying the tenant
+// of an event. Default is "tenant".
+func ExportTenantKey(key string) ExportOption {
+	return exportOptFn(func(o *exportOpts) error {
+		if key == "" {
+			return fmt.Errorf("export: tenant key required")
+		}
+		o.key = key
+		return nil
+	})
+}
+
+// ExportStores sets the names of the event stores exported. Default is the
+// stores discovered by ListEventStores.
+func ExportStores(names ...string) ExportOption {
+	return exportOptFn(func(o *exportOpts) error {
+		o.stores = append(o.stores, names...)
+		return nil
+	})
+}
+
+// ExportStateStores adds the state stores whose entries keyed by a subject
+// of the tenant are exported.
+func ExportStateStores(stores ...*StateStore) ExportOption {
+	return exportOptFn(func(o *exportOpts) error {
+		for _, s := range stores {
+			if s == nil {
+				return fmt.Errorf("export: nil state store")
+			}
+		}
+		o.state = append(o.state, stores...)
+		return nil
+	})
+}
+
+// TenantExport is the manifest of a tenant export, which is written as the
+// first file of the archive.
+type TenantExport struct {
+	// Tenant exported.
+	Tenant string `json:"tenant"`
+
+	// Time of the export.
+	Time time.Time `json:"time"`
+
+	// Stores are the event stores exported.
+	Stores []*TenantExportStore `json:"stores"`
+
+	// State are the state stores exported.
+	State []*TenantExportState `json:"state,omitempty"`
+}
+
+// TenantExportStore describes the data of an event store in an export.
+type TenantExportStore struct {
+	// Name of the event store.
+	Name string `json:"name"`
+
+	// Subjects of the tenant's events.
+	Subjects []string `json:"subjects"`
+
+	// Events is the number of events exported.
+	Events int `json:"events"`
+
+	// Snapshots is the number of checkpoints exported.
+	Snapshots int `json:"snapshots"`
+}
+
+// TenantExportState describes the data of a state store in an export.
+type TenantExportState struct {
+	// Name of the state store.
+	Name string `json:"name"`
+
+	// Entries is the number of entries exported.
+	Entries int `json:"entries"`
+}
+
+// exportedSnapshot is a checkpoint encoded as a line of an export.
+type exportedSnapshot struct {
+	ModelType string `json:"model_type"`
+	Subject   string `json:"subject"`
+	Sequence  uint64 `json:"seq"`
+	Version   int    `json:"version,omitempty"`
+	Codec     string `json:"codec"`
+	Data      []byte `json:"data"`
+}
+
+// exportedState is a state store entry encoded as a line of an export.
+type exportedState struct {
+	Key            string            `json:"key"`
+	Type           string            `json:"type,omitempty"`
+	Revision       uint64            `json:"revision"`
+	Value          any               `json:"value"`
+	Applied        map[string]uint64 `json:"applied,omitempty"`
+	AppliedThrough uint64            `json:"applied_through,omitempty"`
+}
+
+// ExportTenant writes the data of the tenant to w as a tar archive, e.g. to
+// fulfill a data portability request or to migrate the tenant. Events belong
+// to the tenant if their metadata has the tenant key set to the tenant ID.
+// The archive contains:
+//
+//   - manifest.json, the TenantExport
+//   - events/{store}.ndjson, the events of each store
+//   - snapshots/{store}.ndjson, the checkpoints of the tenant's subjects
+//   - state/{name}.ndjson, the entries of each state store set by
+//     ExportStateStores keyed by a subject of the tenant
+//
+// Events of types not in the type registry are exported with their raw
+// data. Read models derived from the events are not exported since they
+// can be rebuilt by replaying the events.
+func (r *Rita) ExportTenant(ctx context.Context, tenantID string, w io.Writer, opts ...ExportOption) (*TenantExport, error) {
+	o := exportOpts{
+		key: defaultTenantKey,
+	}
+
+	for _, opt := range opts {
+		if err := opt.exportOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if tenantID == "" {
+		return nil, errors.New("rita: export: tenant ID required")
+	}
+
+	stores, err := r.exportStores(ctx, o.stores)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &TenantExport{
+		Tenant: tenantID,
+		Time:   r.clock.Now(),
+	}
+
+	type file struct {
+		name string
+		data []byte
+	}
+	var files []*file
+
+	subjects := make(map[string]bool)
+
+	for _, es := range stores {
+		info := &TenantExportStore{
+			Name:     es.name,
+			Subjects: []string{},
+		}
+		manifest.Stores = append(manifest.Stores, info)
+
+		var (
+			buf  bytes.Buffer
+			seen = make(map[string]bool)
+		)
+
+		lo := loadOpts{
+			lenient: true,
+			meta:    map[string]string{o.key: tenantID},
+		}
+
+		_, err := es.read(ctx, es.filterSubject(), &lo, func(e *Event) error {
+			if !seen[e.Subject] {
+				seen[e.Subject] = true
+				info.Subjects = append(info.Subjects, e.Subject)
+			}
+
+			b, err := json.Marshal(&archivedEvent{
+				ID:           e.ID,
+				Type:         e.Type,
+				Subject:      e.Subject,
+				Sequence:     e.Sequence,
+				Time:         e.Time,
+				RecordedTime: e.RecordedTime,
+				Meta:         e.Meta,
+				Data:         e.Data,
+				Redacted:     e.Redacted,
+			})
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+			buf.WriteByte('\n')
+
+			info.Events++
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("rita: export: store %s: %w", es.name, err)
+		}
+
+		files = append(files, &file{
+			name: fmt.Sprintf("events/%s.ndjson", es.name),
+			data: buf.Bytes(),
+		})
+
+		snapshots, n, err := es.exportSnapshots(seen)
+		if err != nil {
+			return nil, fmt.Errorf("rita: export: store %s: snapshots: %w", es.name, err)
+		}
+		info.Snapshots = n
+
+		files = append(files, &file{
+			name: fmt.Sprintf("snapshots/%s.ndjson", es.name),
+			data: snapshots,
+		})
+
+		for s := range seen {
+			subjects[s] = true
+		}
+	}
+
+	keys := make([]string, 0, len(subjects))
+	for s := range subjects {
+		keys = append(keys, s)
+	}
+	sort.Strings(keys)
+
+	for _, ss := range o.state {
+		info := &TenantExportState{
+			Name: ss.name,
+		}
+		manifest.State = append(manifest.State, info)
+
+		var buf bytes.Buffer
+		for _, s := range keys {
+			e, err := ss.Get(s)
+			if errors.Is(err, nats.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("rita: export: state %s: %w", ss.name, err)
+			}
+
+			b, err := json.Marshal(&exportedState{
+				Key:            e.Key,
+				Type:           e.Type,
+				Revision:       e.Revision,
+				Value:          e.Value,
+				Applied:        e.Applied,
+				AppliedThrough: e.AppliedThrough,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("rita: export: state %s: %w", ss.name, err)
+			}
+			buf.Write(b)
+			buf.WriteByte('\n')
+
+			info.Entries++
+		}
+
+		files = append(files, &file{
+			name: fmt.Sprintf("state/%s.ndjson", ss.name),
+			data: buf.Bytes(),
+		})
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files = append([]*file{{name: "manifest.json", data: b}}, files...)
+
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    f.name,
+			Mode:    0o644,
+			Size:    int64(len(f.data)),
+			ModTime: manifest.Time,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// exportStores returns the event stores with the names or, if none, the
+// stores discovered in the context.
+func (r *Rita) exportStores(ctx context.Context, names []string) ([]*EventStore, error) {
+	var stores []*EventStore
+
+	if len(names) > 0 {
+		for _, name := range names {
+			es, err := r.EventStore(name)
+			if err != nil {
+				return nil, err
+			}
+			stores = append(stores, es)
+		}
+		return stores, nil
+	}
+
+	infos, err := r.ListEventStores(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		var opts []EventStoreOption
+		if info.Shared {
+			opts = append(opts, EventStoreStream(info.Stream))
+		}
+		es, err := r.EventStore(info.Name, opts...)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, es)
+	}
+	return stores, nil
+}
+
+// exportSnapshots returns the checkpoints of the subjects, one per line, and
+// the number of checkpoints. The checkpoint bucket is not created if it does
+// not exist.
+func (s *EventStore) exportSnapshots(subjects map[string]bool) ([]byte, int, error) {
+	kv, err := s.rt.js.KeyValue(s.checkpointBucket())
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	keys, err := kv.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		buf bytes.Buffer
+		n   int
+	)
+
+	for _, key := range keys {
+		i := strings.LastIndexByte(key, '.')
+		if i < 0 {
+			continue
+		}
+		subject, err := base64.RawURLEncoding.DecodeString(key[i+1:])
+		if err != nil || !subjects[string(subject)] {
+			continue
+		}
+
+		entry, err := kv.Get(key)
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var cp checkpoint
+		if err := json.Unmarshal(entry.Value(), &cp); err != nil {
+			return nil, 0, err
+		}
+
+		b, err := json.Marshal(&exportedSnapshot{
+			ModelType: key[:i],
+			Subject:   string(subject),
+			Sequence:  cp.Sequence,
+			Version:   cp.Version,
+			Codec:     cp.Codec,
+			Data:      cp.Data,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+		n++
+	}
+
+	return buf.Bytes(), n, nil
+}
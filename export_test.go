@@ -0,0 +1,118 @@
+package rita
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestExportTenant(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-summary", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreCheckpoint("order-summary", 2))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	acme := map[string]string{"tenant": "acme"}
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}, Meta: acme},
+		{Data: &OrderShipped{ID: "1"}, Meta: acme},
+	})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{
+		{Data: &OrderPlaced{ID: "2"}, Meta: map[string]string{"tenant": "globex"}},
+	})
+	is.NoErr(err)
+
+	var model OrderSummary
+	_, err = es.Evolve(ctx, "orders.1", &model)
+	is.NoErr(err)
+
+	carts, err := r.StateStore("carts", StateStorage(nats.MemoryStorage))
+	is.NoErr(err)
+	_, err = carts.Put("orders.1", &OrderSummary{Placed: 1})
+	is.NoErr(err)
+	_, err = carts.Put("orders.2", &OrderSummary{Placed: 1})
+	is.NoErr(err)
+
+	var buf bytes.Buffer
+
+	_, err = r.ExportTenant(ctx, "", &buf)
+	is.Err(err, nil)
+
+	manifest, err := r.ExportTenant(ctx, "acme", &buf, ExportStateStores(carts))
+	is.NoErr(err)
+	is.Equal(manifest.Tenant, "acme")
+	is.Equal(len(manifest.Stores), 1)
+	is.Equal(manifest.Stores[0].Name, "orders")
+	is.Equal(manifest.Stores[0].Subjects, []string{"orders.1"})
+	is.Equal(manifest.Stores[0].Events, 2)
+	is.Equal(manifest.Stores[0].Snapshots, 1)
+	is.Equal(manifest.State[0].Entries, 1)
+
+	files := make(map[string][]byte)
+	ar := tar.NewReader(&buf)
+	for {
+		h, err := ar.Next()
+		if err == io.EOF {
+			break
+		}
+		is.NoErr(err)
+		b, err := io.ReadAll(ar)
+		is.NoErr(err)
+		files[h.Name] = b
+	}
+
+	var m TenantExport
+	is.NoErr(json.Unmarshal(files["manifest.json"], &m))
+	is.Equal(m.Tenant, "acme")
+
+	lines := func(name string) []map[string]any {
+		var out []map[string]any
+		sc := bufio.NewScanner(bytes.NewReader(files[name]))
+		for sc.Scan() {
+			var v map[string]any
+			is.NoErr(json.Unmarshal(sc.Bytes(), &v))
+			out = append(out, v)
+		}
+		return out
+	}
+
+	events := lines("events/orders.ndjson")
+	is.Equal(len(events), 2)
+	is.Equal(events[0]["type"], "order-placed")
+	is.Equal(events[1]["type"], "order-shipped")
+
+	snapshots := lines("snapshots/orders.ndjson")
+	is.Equal(len(snapshots), 1)
+	is.Equal(snapshots[0]["model_type"], "order-summary")
+	is.Equal(snapshots[0]["subject"], "orders.1")
+
+	state := lines("state/carts.ndjson")
+	is.Equal(len(state), 1)
+	is.Equal(state[0]["key"], "orders.1")
+}
@@ -0,0 +1,105 @@
+package rita
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	eventHashHdr     = "rita-hash"
+	eventPrevHashHdr = "rita-prev-hash"
+)
+
+var (
+	ErrHashMismatch = errors.New("rita: event hash mismatch")
+	ErrHashChain    = errors.New("rita: hash chain broken")
+)
+
+// EventStoreHashChain includes the hash of the previous event of the subject
+// in each appended event and the hash of the event itself, making the history
+// of each subject a tamper-evident log. The hash of each event is verified
+// when read and the chain of each subject is verified on Load and Evolve. Once
+// enabled, it should not be disabled since events without a hash following
+// hashed events break the chain. Appends must use ExpectSequence so
+// concurrent appends do not fork the chain, otherwise ErrExpectedSequence is
+// returned. Default is no hash chain.
+func EventStoreHashChain() EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		o.hashChain = true
+		return nil
+	})
+}
+
+// eventHash computes the hash of the subject, the envelope headers, and the
+// data of the message. Headers which are set when the message is stored, such
+// as for claim checks and chunking, are excluded.
+func eventHash(msg *nats.Msg) string {
+	var keys []string
+	for k := range msg.Header {
+		switch k {
+		case eventHashHdr, eventClaimHdr, eventChunkHdr, eventChunkIDHdr:
+			continue
+		}
+		if k == nats.MsgIdHdr || strings.HasPrefix(k, "rita-") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", msg.Subject)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s: %s\n", k, strings.Join(msg.Header.Values(k), ","))
+	}
+	h.Write([]byte("\n"))
+	h.Write(msg.Data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chainHash chains the message to the hash of the previous event and sets
+// the hash of the message, which is returned.
+func chainHash(msg *nats.Msg, prev string) string {
+	if prev != "" {
+		msg.Header.Set(eventPrevHashHdr, prev)
+	}
+	hash := eventHash(msg)
+	msg.Header.Set(eventHashHdr, hash)
+	return hash
+}
+
+// verifyHash verifies the hash of the message, if any.
+func verifyHash(msg *nats.Msg) error {
+	hash := msg.Header.Get(eventHashHdr)
+	if hash == "" {
+		return nil
+	}
+	if eventHash(msg) != hash {
+		return fmt.Errorf("%w: %s: %s", ErrHashMismatch, msg.Subject, msg.Header.Get(nats.MsgIdHdr))
+	}
+	return nil
+}
+
+// hashChain verifies the events of each subject are chained.
+type hashChain map[string]string
+
+// link links the event to the previous event of the subject. The first
+// event of a subject read is assumed to be linked since the read may not
+// start at the beginning of the subject.
+func (c hashChain) link(e *Event) error {
+	prev, ok := c[e.Subject]
+	if !ok && e.Hash == "" {
+		return nil
+	}
+	if ok && (e.Hash == "" || e.prevHash != prev) {
+		return fmt.Errorf("%w: %s: sequence %d", ErrHashChain, e.Subject, e.Sequence)
+	}
+	c[e.Subject] = e.Hash
+	return nil
+}
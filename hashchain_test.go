@@ -0,0 +1,120 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreHashChain(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreHashChain())
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	}, ExpectSequence(0))
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}}, ExpectSequence(0))
+	is.NoErr(err)
+
+	// Concurrent appends could fork the chain.
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.Err(err, ErrExpectedSequence)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, ExpectSequence(2))
+	is.NoErr(err)
+
+	events, _, err := es.Load(ctx, "orders.*")
+	is.NoErr(err)
+	is.Equal(len(events), 4)
+	is.True(events[0].Hash != "")
+	is.Equal(events[0].prevHash, "")
+	is.Equal(events[1].prevHash, events[0].Hash)
+	is.Equal(events[2].prevHash, "")
+	is.Equal(events[3].prevHash, events[1].Hash)
+
+	// Loading part of the history verifies from the first event read.
+	events, _, err = es.Load(ctx, "orders.1", AfterSequence(1))
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+
+	// A tampered copy of an event fails its hash.
+	msg, err := r.js.GetMsg("orders", 1)
+	is.NoErr(err)
+
+	forged := nats.NewMsg("orders.2")
+	forged.Header = msg.Header
+	forged.Header.Set(nats.MsgIdHdr, "forged")
+	forged.Header.Del(nats.ExpectedLastSubjSeqHdr)
+	forged.Data = []byte(`{"ID":"3"}`)
+	_, err = r.js.PublishMsg(forged)
+	is.NoErr(err)
+
+	_, _, err = es.Load(ctx, "orders.2")
+	is.Err(err, ErrHashMismatch)
+
+	// An event appended without the chain breaks it.
+	ues, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	_, err = ues.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	_, _, err = es.Load(ctx, "orders.1")
+	is.Err(err, ErrHashChain)
+}
+
+func TestEventStoreHashChainRedact(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreHashChain(), EventStoreCausal())
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	seq, err := es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	}, ExpectSequence(0))
+	is.NoErr(err)
+
+	// The next event continues from the redacted last event.
+	is.NoErr(es.Redact(ctx, seq, "erasure request"))
+
+	e := &Event{Data: &OrderShipped{ID: "1"}}
+	_, err = es.Append(ctx, "orders.1", []*Event{e}, ExpectSequence(seq-1))
+	is.NoErr(err)
+	is.Equal(e.Clock, VectorClock{"orders": 3})
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 3)
+	is.True(events[1].Redacted)
+	is.Equal(events[2].prevHash, events[1].Hash)
+}
@@ -0,0 +1,42 @@
+package rita
+
+import (
+	"context"
+	"errors"
+)
+
+// BeforeAppendHook is called with the events passed to Append before they
+// are packed, so the events can be inspected or mutated, e.g. to add
+// metadata. Returning an error fails the append.
+type BeforeAppendHook func(ctx context.Context, subject string, events []*Event) error
+
+// AfterAppendHook is called after the events have been acknowledged by the
+// store, with the sequence of the last event. The ID, time, and sequence of
+// the events are set. This is useful for publishing to an outbox, warming
+// caches, or recording metrics.
+type AfterAppendHook func(ctx context.Context, subject string, events []*Event, seq uint64)
+
+// EventStoreBeforeAppend adds a hook called before the events of an append
+// are packed. Hooks are called in the order they are added.
+func EventStoreBeforeAppend(hook BeforeAppendHook) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if hook == nil {
+			return errors.New("rita: before append hook required")
+		}
+		o.beforeAppend = append(o.beforeAppend, hook)
+		return nil
+	})
+}
+
+// EventStoreAfterAppend adds a hook called after a successful append. Hooks
+// are called synchronously in the order they are added, before Append
+// returns.
+func EventStoreAfterAppend(hook AfterAppendHook) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if hook == nil {
+			return errors.New("rita: after append hook required")
+		}
+		o.afterAppend = append(o.afterAppend, hook)
+		return nil
+	})
+}
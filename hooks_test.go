@@ -0,0 +1,81 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreAppendHooks(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	var (
+		calls    []string
+		appended []*Event
+		lastSeq  uint64
+	)
+
+	es, err := r.EventStore("orders",
+		EventStoreBeforeAppend(func(ctx context.Context, subject string, events []*Event) error {
+			calls = append(calls, "before")
+			for _, e := range events {
+				if e.Meta == nil {
+					e.Meta = make(map[string]string)
+				}
+				e.Meta["source"] = "hook"
+			}
+			return nil
+		}),
+		EventStoreBeforeAppend(func(ctx context.Context, subject string, events []*Event) error {
+			calls = append(calls, "before2")
+			if subject == "orders.denied" {
+				return errors.New("denied")
+			}
+			return nil
+		}),
+		EventStoreAfterAppend(func(ctx context.Context, subject string, events []*Event, seq uint64) {
+			calls = append(calls, "after")
+			appended = events
+			lastSeq = seq
+		}),
+	)
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	seq, err := es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+	is.Equal(calls, []string{"before", "before2", "after"})
+	is.Equal(lastSeq, seq)
+	is.Equal(len(appended), 2)
+	is.Equal(appended[1].Sequence, seq)
+	is.True(appended[0].ID != "")
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(events[0].Meta["source"], "hook")
+
+	// A failing before hook fails the append.
+	calls = nil
+	_, err = es.Append(ctx, "orders.denied", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.Err(err, nil)
+	is.Equal(calls, []string{"before", "before2"})
+
+	_, err = r.EventStore("orders", EventStoreAfterAppend(nil))
+	is.Err(err, nil)
+}
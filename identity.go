@@ -0,0 +1,92 @@
+package rita
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	// MetaActor is the metadata key of the actor stamped on events and
+	// commands.
+	MetaActor = "actor"
+
+	// MetaClaimPrefix is the prefix of the metadata keys of the claims
+	// stamped on events and commands, e.g. "claim.role".
+	MetaClaimPrefix = "claim."
+)
+
+type claimsKey struct{}
+
+// WithClaims returns a context with the authorization claims of the actor,
+// such as the tenant or role, merged with the claims of the parent context.
+// Claims are stamped on the events appended and commands sent with the
+// context, like the actor set by WithActor.
+func WithClaims(ctx context.Context, claims map[string]string) context.Context {
+	merged := make(map[string]string)
+	for k, v := range ClaimsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range claims {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, claimsKey{}, merged)
+}
+
+// ClaimsFromContext returns the claims of the context, if any. The map must
+// not be modified.
+func ClaimsFromContext(ctx context.Context) map[string]string {
+	claims, _ := ctx.Value(claimsKey{}).(map[string]string)
+	return claims
+}
+
+// stampIdentity returns the metadata with the actor and claims of the
+// context, unless set explicitly. The metadata passed in is not modified
+// since it may be shared by multiple events.
+func stampIdentity(ctx context.Context, meta map[string]string) map[string]string {
+	actor := ActorFromContext(ctx)
+	claims := ClaimsFromContext(ctx)
+	if actor == "" && len(claims) == 0 {
+		return meta
+	}
+
+	stamped := make(map[string]string, len(meta)+len(claims)+1)
+	if actor != "" {
+		stamped[MetaActor] = actor
+	}
+	for k, v := range claims {
+		stamped[MetaClaimPrefix+k] = v
+	}
+	for k, v := range meta {
+		stamped[k] = v
+	}
+	return stamped
+}
+
+// identityContext returns a context with the actor and claims stamped in the
+// metadata of a command or event, so the identity is propagated to the
+// events decided by a command received by actors or the commands dispatched
+// by a reactor. The actor and claims of the context take precedence, so a
+// sender cannot override the identity established by the receiver.
+func identityContext(ctx context.Context, meta map[string]string) context.Context {
+	if actor, ok := meta[MetaActor]; ok && ActorFromContext(ctx) == "" {
+		ctx = WithActor(ctx, actor)
+	}
+
+	var claims map[string]string
+	for k, v := range meta {
+		if name := strings.TrimPrefix(k, MetaClaimPrefix); name != k {
+			if claims == nil {
+				claims = make(map[string]string)
+			}
+			claims[name] = v
+		}
+	}
+	if claims == nil {
+		return ctx
+	}
+
+	for k, v := range ClaimsFromContext(ctx) {
+		claims[k] = v
+	}
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
@@ -0,0 +1,106 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestIdentityPropagation(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ictx := WithActor(ctx, "alice")
+	ictx = WithClaims(ictx, map[string]string{"role": "clerk"})
+	ictx = WithClaims(ictx, map[string]string{"tenant": "acme"})
+	is.Equal(ClaimsFromContext(ictx), map[string]string{"role": "clerk", "tenant": "acme"})
+
+	// Appends are stamped without overriding explicit metadata or modifying
+	// the metadata passed in.
+	meta := map[string]string{"claim.role": "admin"}
+	_, err = es.Append(ictx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}, Meta: meta}})
+	is.NoErr(err)
+	is.Equal(meta, map[string]string{"claim.role": "admin"})
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(events[0].Meta, map[string]string{
+		MetaActor:      "alice",
+		"claim.role":   "admin",
+		"claim.tenant": "acme",
+	})
+
+	// Events without an identity are not stamped.
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	events, _, err = es.Load(ctx, "orders.2")
+	is.NoErr(err)
+	is.Equal(len(events[0].Meta), 0)
+
+	// The identity of a sent command is stamped on the decided events.
+	actors, err := es.Actors(func() Entity { return &Order{} }, ActorsTrustIdentity())
+	is.NoErr(err)
+	is.NoErr(actors.Listen(ctx))
+
+	cmd := &Command{Data: &ShipOrder{ID: "1"}}
+	_, _, err = actors.Send(ictx, "orders.1", cmd)
+	is.NoErr(err)
+	is.Equal(cmd.Meta[MetaActor], "alice")
+
+	events, _, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(events[1].Meta, map[string]string{
+		MetaActor:      "alice",
+		"claim.role":   "clerk",
+		"claim.tenant": "acme",
+	})
+
+	// The identity is restored from metadata.
+	rctx := identityContext(ctx, events[1].Meta)
+	is.Equal(ActorFromContext(rctx), "alice")
+	is.Equal(ClaimsFromContext(rctx), map[string]string{"role": "clerk", "tenant": "acme"})
+
+	// The identity of the receiver takes precedence.
+	rctx = identityContext(WithClaims(WithActor(ctx, "bob"), map[string]string{"role": "clerk"}), map[string]string{
+		MetaActor:    "mallory",
+		"claim.role": "admin",
+		"claim.team": "ops",
+	})
+	is.Equal(ActorFromContext(rctx), "bob")
+	is.Equal(ClaimsFromContext(rctx), map[string]string{"role": "clerk", "team": "ops"})
+
+	// The identity of commands is ignored unless trusted.
+	untrusted, err := es.Actors(func() Entity { return &Order{} }, ActorSubjectPrefix("rita.untrusted"))
+	is.NoErr(err)
+	is.NoErr(untrusted.Listen(ctx))
+	defer untrusted.Close()
+
+	_, err = es.Append(ctx, "orders.3", []*Event{{Data: &OrderPlaced{ID: "3"}}})
+	is.NoErr(err)
+
+	_, _, err = untrusted.Send(ictx, "orders.3", &Command{Data: &ShipOrder{ID: "3"}})
+	is.NoErr(err)
+
+	events, _, err = es.Load(ctx, "orders.3")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(len(events[1].Meta), 0)
+}
@@ -0,0 +1,252 @@
+package rita
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrNotIndexed = errors.New("rita: not indexed")
+
+	indexKeyRegex = regexp.MustCompile(`^[-_a-zA-Z0-9]+$`)
+)
+
+// maxIndexRetries is the number of times an index entry is retried on a
+// concurrent update.
+const maxIndexRetries = 10
+
+// EventStoreIndex maintains secondary indexes of the events appended to the
+// store in a KV bucket named "{stream}_index". The event ID is always indexed
+// to the sequence of the event, which is looked up with LookupID. Each of the
+// metadata keys is indexed to the subjects of the events having the key, which
+// are looked up with LookupMeta, e.g. the subjects related to a correlation ID.
+// Indexing is best effort, a failure to index does not fail the append, so
+// Reindex can be used to rebuild the indexes. Default is no indexes.
+func EventStoreIndex(metaKeys ...string) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		for _, k := range metaKeys {
+			if !indexKeyRegex.MatchString(k) {
+				return fmt.Errorf("rita: index key %q has invalid characters", k)
+			}
+		}
+		o.index = true
+		o.indexKeys = metaKeys
+		return nil
+	})
+}
+
+// indexBucket returns the name of the KV bucket of the indexes.
+func (s *EventStore) indexBucket() string {
+	return s.derivedName("index")
+}
+
+// indexKV returns the KV bucket of the indexes, creating it if it does not
+// exist.
+func (s *EventStore) indexKV() (nats.KeyValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexes != nil {
+		return s.indexes, nil
+	}
+
+	kv, err := s.derivedKV("index")
+	if err != nil {
+		return nil, err
+	}
+
+	s.indexes = kv
+	return kv, nil
+}
+
+// indexValue encodes an ID or metadata value as a single key token.
+func indexValue(v string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(v))
+}
+
+func idIndexKey(id string) string {
+	return fmt.Sprintf("id.%s", indexValue(id))
+}
+
+func metaIndexKey(key, value string) string {
+	return fmt.Sprintf("meta.%s.%s", key, indexValue(value))
+}
+
+// indexEvents indexes the appended events. Errors are ignored since the
+// events have already been appended.
+func (s *EventStore) indexEvents(events []*Event) {
+	if !s.index {
+		return
+	}
+
+	kv, err := s.indexKV()
+	if err != nil {
+		return
+	}
+
+	for _, e := range events {
+		_ = s.indexEvent(kv, e.ID, e.Subject, e.Sequence, e.Meta)
+	}
+}
+
+// indexEvent indexes the ID and metadata of an event.
+func (s *EventStore) indexEvent(kv nats.KeyValue, id, subject string, seq uint64, meta map[string]string) error {
+	if id != "" {
+		if _, err := kv.Put(idIndexKey(id), []byte(strconv.FormatUint(seq, 10))); err != nil {
+			return err
+		}
+	}
+
+	for _, k := range s.indexKeys {
+		v, ok := meta[k]
+		if !ok {
+			continue
+		}
+		if err := addIndexSubject(kv, metaIndexKey(k, v), subject); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addIndexSubject adds the subject to the set of subjects of the key using
+// optimistic concurrency control.
+func addIndexSubject(kv nats.KeyValue, key, subject string) error {
+	var err error
+	for i := 0; i < maxIndexRetries; i++ {
+		var (
+			entry    nats.KeyValueEntry
+			subjects []string
+		)
+
+		entry, err = kv.Get(key)
+		if err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+			return err
+		}
+
+		if entry != nil {
+			if err := json.Unmarshal(entry.Value(), &subjects); err != nil {
+				return err
+			}
+			for _, s := range subjects {
+				if s == subject {
+					return nil
+				}
+			}
+		}
+
+		b, _ := json.Marshal(append(subjects, subject))
+
+		if entry == nil {
+			_, err = kv.Create(key, b)
+		} else {
+			_, err = kv.Update(key, b, entry.Revision())
+		}
+		if err == nil {
+			return nil
+		}
+		// Retry if the key was created or updated concurrently.
+		if !indexEntryChanged(kv, key, entry) {
+			return err
+		}
+	}
+	return err
+}
+
+// indexEntryChanged returns true if the entry of the key is no longer the
+// entry read, or the key exists if no entry was read.
+func indexEntryChanged(kv nats.KeyValue, key string, entry nats.KeyValueEntry) bool {
+	cur, err := kv.Get(key)
+	if err != nil {
+		return false
+	}
+	return entry == nil || cur.Revision() != entry.Revision()
+}
+
+// LookupID returns the sequence of the event with the ID. If the ID is not
+// indexed, nats.ErrKeyNotFound is returned. ErrNotIndexed is returned if the
+// store is not configured with EventStoreIndex.
+func (s *EventStore) LookupID(ctx context.Context, id string) (uint64, error) {
+	if !s.index {
+		return 0, ErrNotIndexed
+	}
+
+	kv, err := s.indexKV()
+	if err != nil {
+		return 0, err
+	}
+
+	entry, err := kv.Get(idIndexKey(id))
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(string(entry.Value()), 10, 64)
+}
+
+// LookupMeta returns the subjects of the events with the metadata key set to
+// the value in the order they were first indexed. ErrNotIndexed is returned
+// if the key is not indexed.
+func (s *EventStore) LookupMeta(ctx context.Context, key, value string) ([]string, error) {
+	if !s.indexed(key) {
+		return nil, fmt.Errorf("%w: %q", ErrNotIndexed, key)
+	}
+
+	kv, err := s.indexKV()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := kv.Get(metaIndexKey(key, value))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var subjects []string
+	if err := json.Unmarshal(entry.Value(), &subjects); err != nil {
+		return nil, err
+	}
+	return subjects, nil
+}
+
+// indexed returns true if the metadata key is indexed.
+func (s *EventStore) indexed(key string) bool {
+	for _, k := range s.indexKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Reindex indexes all events of the store, for example after indexing failed
+// or a metadata key was added to the indexed keys. Only the headers of events
+// are read.
+func (s *EventStore) Reindex(ctx context.Context) error {
+	if !s.index {
+		return ErrNotIndexed
+	}
+
+	kv, err := s.indexKV()
+	if err != nil {
+		return err
+	}
+
+	lo := loadOpts{headersOnly: true}
+	_, err = s.readMsgs(ctx, s.filterSubject(), &lo, func(seq uint64, msg *nats.Msg) error {
+		subject := contextUnsubject(s.context, msg.Subject)
+		return s.indexEvent(kv, msg.Header.Get(nats.MsgIdHdr), subject, seq, unpackMeta(msg.Header))
+	})
+	return err
+}
@@ -0,0 +1,86 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreIndex(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	_, err = r.EventStore("orders", EventStoreIndex("correlation id"))
+	is.True(err != nil)
+
+	es, err := r.EventStore("orders", EventStoreIndex("correlation"))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	placed := &Event{
+		ID:   "order/1",
+		Data: &OrderPlaced{ID: "1"},
+		Meta: map[string]string{"correlation": "checkout.42"},
+	}
+	_, err = es.Append(ctx, "orders.1", []*Event{placed})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.2", []*Event{
+		{Data: &OrderPlaced{ID: "2"}, Meta: map[string]string{"correlation": "checkout.42"}},
+		{Data: &OrderShipped{ID: "2"}, Meta: map[string]string{"correlation": "checkout.42"}},
+	})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.3", []*Event{{Data: &OrderPlaced{ID: "3"}}})
+	is.NoErr(err)
+
+	seq, err := es.LookupID(ctx, "order/1")
+	is.NoErr(err)
+	is.Equal(seq, placed.Sequence)
+
+	_, err = es.LookupID(ctx, "missing")
+	is.Err(err, nats.ErrKeyNotFound)
+
+	subjects, err := es.LookupMeta(ctx, "correlation", "checkout.42")
+	is.NoErr(err)
+	is.Equal(subjects, []string{"orders.1", "orders.2"})
+
+	subjects, err = es.LookupMeta(ctx, "correlation", "missing")
+	is.NoErr(err)
+	is.Equal(len(subjects), 0)
+
+	_, err = es.LookupMeta(ctx, "tenant", "acme")
+	is.Err(err, ErrNotIndexed)
+
+	// Rebuilding the indexes from scratch yields the same result.
+	is.NoErr(r.js.DeleteKeyValue(es.indexBucket()))
+
+	es, err = r.EventStore("orders", EventStoreIndex("correlation"))
+	is.NoErr(err)
+	is.NoErr(es.Reindex(ctx))
+
+	seq, err = es.LookupID(ctx, "order/1")
+	is.NoErr(err)
+	is.Equal(seq, placed.Sequence)
+
+	subjects, err = es.LookupMeta(ctx, "correlation", "checkout.42")
+	is.NoErr(err)
+	is.Equal(subjects, []string{"orders.1", "orders.2"})
+
+	unindexed, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	_, err = unindexed.LookupID(ctx, "order/1")
+	is.Err(err, ErrNotIndexed)
+}
@@ -0,0 +1,222 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+// IngestFunc maps a message from an external stream to an event and the
+// subject it is appended to in the store. If the event is nil, the message
+// is skipped.
+type IngestFunc func(msg *nats.Msg) (string, *Event, error)
+
+type ingestOpts struct {
+	subject    string
+	batch      int
+	retryDelay time.Duration
+	onError    func(msg *nats.Msg, err error)
+}
+
+type ingestOptFn func(o *ingestOpts) error
+
+func (f ingestOptFn) ingestOpt(o *ingestOpts) error {
+	return f(o)
+}
+
+// IngestOption is an option for an ingester.
+type IngestOption interface {
+	ingestOpt(o *ingestOpts) error
+}
+
+// IngestSubject sets the subject filter of messages consumed from the
+// external stream. Default is all subjects of the stream.
+func IngestSubject(subject string) IngestOption {
+	return ingestOptFn(func(o *ingestOpts) error {
+		o.subject = subject
+		return nil
+	})
+}
+
+// IngestBatch sets the max number of messages fetched at a time. Default is 100.
+func IngestBatch(n int) IngestOption {
+	return ingestOptFn(func(o *ingestOpts) error {
+		if n < 1 {
+			return fmt.Errorf("ingest: batch must be positive")
+		}
+		o.batch = n
+		return nil
+	})
+}
+
+// IngestRetryDelay sets the delay before a message is redelivered after the
+// append fails with an error which is retried. Default is 1 second.
+func IngestRetryDelay(d time.Duration) IngestOption {
+	return ingestOptFn(func(o *ingestOpts) error {
+		o.retryDelay = d
+		return nil
+	})
+}
+
+// IngestErrorHandler sets a function which is called when a message cannot
+// be mapped or the event cannot be appended.
+func IngestErrorHandler(fn func(msg *nats.Msg, err error)) IngestOption {
+	return ingestOptFn(func(o *ingestOpts) error {
+		o.onError = fn
+		return nil
+	})
+}
+
+// Ingester consumes messages from an existing stream not managed by Rita and
+// appends them as events to a store, so brownfield NATS data can be used as
+// integration events. The position of the ingester is checkpointed with a
+// durable consumer on the external stream. Events without an ID are given an
+// ID derived from the stream sequence of the message, so messages redelivered
+// within the duplicate window of the store are de-duplicated.
+//
+// Messages whose event cannot be appended are terminated if the failure is
+// permanent, e.g. the event is invalid, the ingester is not authorized, or
+// the store requires an expected sequence, and are otherwise redelivered
+// after the retry delay. In both cases the error handler is called. Since
+// later messages are appended in the meantime, a redelivered event may be
+// appended after events which followed it in the external stream.
+type Ingester struct {
+	es     *EventStore
+	name   string
+	stream string
+	fn     IngestFunc
+	opts   ingestOpts
+}
+
+func (i *Ingester) error(msg *nats.Msg, err error) {
+	if i.opts.onError != nil {
+		i.opts.onError(msg, err)
+	}
+}
+
+// retryable returns false if the append failed for a reason which would not
+// change on a retry, such as the event being invalid or the ingester not
+// being authorized.
+func retryable(err error) bool {
+	for _, target := range []error{
+		ErrInvalidEvent,
+		ErrInvalidSubject,
+		ErrEventTooLarge,
+		ErrEventTypeRequired,
+		ErrExpectedSequence,
+		ErrUnauthorized,
+		ErrReadOnly,
+		ErrTypeOutOfScope,
+		types.ErrTypeNotRegistered,
+		types.ErrMarshal,
+	} {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
+
+func (i *Ingester) handle(ctx context.Context, msg *nats.Msg) {
+	md, err := msg.Metadata()
+	if err != nil {
+		i.error(msg, err)
+		_ = msg.Term()
+		return
+	}
+
+	// Mapping is expected to be deterministic, so a failure is not retried.
+	subject, event, err := i.fn(msg)
+	if err != nil {
+		i.error(msg, err)
+		_ = msg.Term()
+		return
+	}
+
+	if event == nil {
+		_ = msg.Ack()
+		return
+	}
+
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("%s.%d", i.stream, md.Sequence.Stream)
+	}
+
+	if event.Time.IsZero() {
+		event.Time = md.Timestamp
+	}
+
+	if _, err := i.es.Append(ctx, subject, []*Event{event}); err != nil {
+		i.error(msg, err)
+		if retryable(err) {
+			_ = msg.NakWithDelay(i.opts.retryDelay)
+		} else {
+			_ = msg.Term()
+		}
+		return
+	}
+
+	_ = msg.Ack()
+}
+
+// Run consumes messages and appends events until the context is done.
+func (i *Ingester) Run(ctx context.Context) error {
+	js := i.es.rt.js
+
+	_, err := js.ConsumerInfo(i.stream, i.name)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		_, err = js.AddConsumer(i.stream, &nats.ConsumerConfig{
+			Durable:       i.name,
+			FilterSubject: i.opts.subject,
+			AckPolicy:     nats.AckExplicitPolicy,
+			DeliverPolicy: nats.DeliverAllPolicy,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	sub, err := js.PullSubscribe(i.opts.subject, i.name, nats.Bind(i.stream, i.name))
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe() //nolint
+
+	return fetchLoop(ctx, sub, i.opts.batch, func(msg *nats.Msg) error {
+		i.handle(ctx, msg)
+		return nil
+	})
+}
+
+// Ingest returns an ingester which consumes messages from the external
+// stream, maps them to events with the function, and appends them to the
+// store. The name is used as the durable consumer name on the external
+// stream.
+func (s *EventStore) Ingest(name string, stream string, fn IngestFunc, opts ...IngestOption) (*Ingester, error) {
+	o := ingestOpts{
+		batch:      100,
+		retryDelay: time.Second,
+	}
+
+	for _, opt := range opts {
+		if err := opt.ingestOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	return &Ingester{
+		es:     s,
+		name:   name,
+		stream: stream,
+		fn:     fn,
+		opts:   o,
+	}, nil
+}
@@ -0,0 +1,165 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestIngest(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	// Existing stream of raw order messages.
+	_, err = r.js.AddStream(&nats.StreamConfig{
+		Name:     "legacy",
+		Subjects: []string{"legacy.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	_, err = r.js.Publish("legacy.placed", []byte(`{"ID": "1"}`))
+	is.NoErr(err)
+	_, err = r.js.Publish("legacy.unknown", nil)
+	is.NoErr(err)
+	_, err = r.js.Publish("legacy.placed", []byte(`{"ID": "2"}`))
+	is.NoErr(err)
+
+	ingest := func(msg *nats.Msg) (string, *Event, error) {
+		if !strings.HasSuffix(msg.Subject, ".placed") {
+			return "", nil, nil
+		}
+		var e OrderPlaced
+		if err := json.Unmarshal(msg.Data, &e); err != nil {
+			return "", nil, err
+		}
+		return es.Subject(e.ID), &Event{Data: &e}, nil
+	}
+
+	ing, err := es.Ingest("orders-import", "legacy", ingest)
+	is.NoErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- ing.Run(ctx)
+	}()
+
+	var events []*Event
+	for i := 0; i < 50; i++ {
+		events, _, err = es.Load(ctx, "orders.*")
+		is.NoErr(err)
+		if len(events) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	is.Equal(len(events), 2)
+	is.Equal(events[0].ID, "legacy.1")
+	is.Equal(events[1].ID, "legacy.3")
+	is.Equal(events[1].Data.(*OrderPlaced).ID, "2")
+
+	cancel()
+	is.NoErr(<-done)
+}
+
+func TestIngestPermanentError(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	_, err = r.js.AddStream(&nats.StreamConfig{
+		Name:     "legacy",
+		Subjects: []string{"legacy.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	// The first order maps to an invalid subject.
+	_, err = r.js.Publish("legacy.placed", []byte(`{"ID": "1 2"}`))
+	is.NoErr(err)
+	_, err = r.js.Publish("legacy.placed", []byte(`{"ID": "3"}`))
+	is.NoErr(err)
+
+	ingest := func(msg *nats.Msg) (string, *Event, error) {
+		var e OrderPlaced
+		if err := json.Unmarshal(msg.Data, &e); err != nil {
+			return "", nil, err
+		}
+		return es.Subject(e.ID), &Event{Data: &e}, nil
+	}
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	ing, err := es.Ingest("orders-import", "legacy", ingest,
+		IngestRetryDelay(10*time.Millisecond),
+		IngestErrorHandler(func(msg *nats.Msg, err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}),
+	)
+	is.NoErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- ing.Run(ctx)
+	}()
+
+	var events []*Event
+	for i := 0; i < 50; i++ {
+		events, _, err = es.Load(ctx, "orders.*")
+		is.NoErr(err)
+		if len(events) == 1 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	is.Equal(len(events), 1)
+
+	// The message is terminated rather than redelivered.
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	is.Equal(len(errs), 1)
+	is.Err(errs[0], ErrInvalidSubject)
+	mu.Unlock()
+
+	cancel()
+	is.NoErr(<-done)
+}
@@ -0,0 +1,374 @@
+// Package gen generates Go types and type registry wiring from a declarative
+// contract of events, commands, and queries, so services sharing a contract
+// stay consistent.
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var (
+	ErrInvalidContract = errors.New("gen: invalid contract")
+
+	nameRegex = regexp.MustCompile(`^[\w-]+(\.[\w-]+)*$`)
+)
+
+// Contract declares the types shared by services.
+type Contract struct {
+	// Package is the name of the generated Go package.
+	Package string `json:"package"`
+
+	Types []*Type `json:"types"`
+}
+
+// Type is an event, command, or query type.
+type Type struct {
+	// Name of the type in the registry, e.g. "order-placed".
+	Name string `json:"name"`
+
+	// Kind of the type, one of "event", "command", or "query". Default is
+	// "event".
+	Kind string `json:"kind,omitempty"`
+
+	// GoName is the name of the Go struct. Default is derived from the name,
+	// e.g. "OrderPlaced".
+	GoName string `json:"go_name,omitempty"`
+
+	// Doc is the documentation of the type, which is also the catalog
+	// description.
+	Doc string `json:"doc,omitempty"`
+
+	// Owner of the type in the catalog, e.g. the owning team.
+	Owner string `json:"owner,omitempty"`
+
+	// PII is the catalog classification of personal data in the type, e.g.
+	// "none", "personal", or "sensitive".
+	PII string `json:"pii,omitempty"`
+
+	// Version of the type. Zero means the type is not versioned.
+	Version int `json:"version,omitempty"`
+
+	// Fields of the current version.
+	Fields []*Field `json:"fields"`
+
+	// Previous versions of the type, which are migrated to the current
+	// version.
+	Previous []*Version `json:"previous,omitempty"`
+}
+
+// Version is a prior version of a type.
+type Version struct {
+	Version int      `json:"version"`
+	Fields  []*Field `json:"fields"`
+}
+
+// Field is a field of a type.
+type Field struct {
+	// Name of the Go field.
+	Name string `json:"name"`
+
+	// Type is the Go type of the field, e.g. "string" or "[]int".
+	Type string `json:"type"`
+
+	// JSON is the name of the field when encoded. Default is the Go name.
+	JSON string `json:"json,omitempty"`
+
+	// Doc is the documentation of the field.
+	Doc string `json:"doc,omitempty"`
+}
+
+// Parse parses and validates a JSON contract.
+func Parse(b []byte) (*Contract, error) {
+	var c Contract
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidContract, err)
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// goName returns the name of the Go struct of the type.
+func (t *Type) goName() string {
+	if t.GoName != "" {
+		return t.GoName
+	}
+
+	var b strings.Builder
+	for _, p := range strings.FieldsFunc(t.Name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	}) {
+		b.WriteString(strings.ToUpper(p[:1]) + p[1:])
+	}
+	return b.String()
+}
+
+func (t *Type) kind() string {
+	if t.Kind == "" {
+		return "event"
+	}
+	return t.Kind
+}
+
+func (c *Contract) validate() error {
+	if !token.IsIdentifier(c.Package) {
+		return fmt.Errorf("%w: invalid package %q", ErrInvalidContract, c.Package)
+	}
+
+	names := make(map[string]bool)
+	goNames := make(map[string]bool)
+
+	for _, t := range c.Types {
+		if !nameRegex.MatchString(t.Name) {
+			return fmt.Errorf("%w: invalid type name %q", ErrInvalidContract, t.Name)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("%w: duplicate type %q", ErrInvalidContract, t.Name)
+		}
+		names[t.Name] = true
+
+		switch t.kind() {
+		case "event", "command", "query":
+		default:
+			return fmt.Errorf("%w: %s: invalid kind %q", ErrInvalidContract, t.Name, t.Kind)
+		}
+
+		gn := t.goName()
+		if !token.IsIdentifier(gn) || !token.IsExported(gn) {
+			return fmt.Errorf("%w: %s: invalid Go name %q", ErrInvalidContract, t.Name, gn)
+		}
+		if goNames[gn] {
+			return fmt.Errorf("%w: %s: duplicate Go name %q", ErrInvalidContract, t.Name, gn)
+		}
+		goNames[gn] = true
+
+		if err := validateFields(t.Name, t.Fields); err != nil {
+			return err
+		}
+
+		versions := make(map[int]bool)
+		for _, v := range t.Previous {
+			if v.Version < 1 || v.Version >= t.Version {
+				return fmt.Errorf("%w: %s: version %d must be between 1 and %d", ErrInvalidContract, t.Name, v.Version, t.Version)
+			}
+			if versions[v.Version] {
+				return fmt.Errorf("%w: %s: duplicate version %d", ErrInvalidContract, t.Name, v.Version)
+			}
+			versions[v.Version] = true
+
+			vgn := fmt.Sprintf("%sV%d", gn, v.Version)
+			if goNames[vgn] {
+				return fmt.Errorf("%w: %s: duplicate Go name %q", ErrInvalidContract, t.Name, vgn)
+			}
+			goNames[vgn] = true
+
+			if err := validateFields(fmt.Sprintf("%s@%d", t.Name, v.Version), v.Fields); err != nil {
+				return err
+			}
+		}
+
+		sort.Slice(t.Previous, func(i, j int) bool {
+			return t.Previous[i].Version < t.Previous[j].Version
+		})
+	}
+
+	return nil
+}
+
+func validateFields(name string, fields []*Field) error {
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		if !token.IsIdentifier(f.Name) || !token.IsExported(f.Name) {
+			return fmt.Errorf("%w: %s: invalid field name %q", ErrInvalidContract, name, f.Name)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("%w: %s: duplicate field %q", ErrInvalidContract, name, f.Name)
+		}
+		seen[f.Name] = true
+
+		if strings.TrimSpace(f.Type) == "" {
+			return fmt.Errorf("%w: %s: field %q has no type", ErrInvalidContract, name, f.Name)
+		}
+	}
+	return nil
+}
+
+func comment(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	return "// " + strings.ReplaceAll(s, "\n", "\n// ")
+}
+
+func tag(f *Field) string {
+	n := f.JSON
+	if n == "" {
+		n = f.Name
+	}
+	return fmt.Sprintf("`json:%q`", n)
+}
+
+var funcs = template.FuncMap{
+	"comment": comment,
+	"tag":     tag,
+}
+
+var typesTmpl = template.Must(template.New("types").Funcs(funcs).Parse(`// Code generated by rita gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"github.com/bruth/rita/types"
+)
+{{range $t := .Types}}
+{{if .Doc}}{{comment .Doc}}
+{{else}}// {{.GoName}} is the {{.Kind}} {{printf "%q" .Name}}{{if .Version}} at version {{.Version}}{{end}}.
+{{end}}type {{.GoName}} struct {
+{{- range .Fields}}
+{{- if .Doc}}
+	{{comment .Doc}}{{end}}
+	{{.Name}} {{.Type}} {{tag .}}
+{{- end}}
+}
+{{range .Previous}}
+// {{$t.GoName}}V{{.Version}} is version {{.Version}} of the {{$t.Kind}} {{printf "%q" $t.Name}}.
+type {{$t.GoName}}V{{.Version}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} {{tag .}}
+{{- end}}
+}
+{{end}}{{end}}
+// Types returns the types of the contract, which can be passed to
+// types.NewRegistry.
+func Types() map[string]*types.Type {
+	return map[string]*types.Type{
+{{- range $t := .Types}}
+		{{printf "%q" .Name}}: {
+			Init: func() any { return &{{.GoName}}{} },
+{{- if or .Doc .Owner .PII}}
+			Catalog: &types.Catalog{
+{{- if .Doc}}
+				Description: {{printf "%q" .Doc}},
+{{- end}}
+{{- if .Owner}}
+				Owner: {{printf "%q" .Owner}},
+{{- end}}
+{{- if .PII}}
+				PII: {{printf "%q" .PII}},
+{{- end}}
+			},
+{{- end}}
+{{- if .Version}}
+			Version: {{.Version}},
+{{- end}}
+{{- if .Previous}}
+			Versions: map[int]func() any{
+{{- range .Previous}}
+				{{.Version}}: func() any { return &{{$t.GoName}}V{{.Version}}{} },
+{{- end}}
+			},
+			Migrate: migrate{{.GoName}},
+{{- end}}
+		},
+{{- end}}
+	}
+}
+`))
+
+var migrateTmpl = template.Must(template.New("migrate").Funcs(funcs).Parse(`package {{.Package}}
+
+import (
+	"fmt"
+)
+{{range $t := .Types}}{{if .Previous}}
+// migrate{{.GoName}} migrates a prior version of {{printf "%q" .Name}} to version {{.Version}}.
+func migrate{{.GoName}}(from int, v any) (any, error) {
+	switch x := v.(type) {
+{{- range .Previous}}
+	case *{{$t.GoName}}V{{.Version}}:
+		// TODO: map the fields of version {{.Version}}.
+		_ = x
+		return &{{$t.GoName}}{}, nil
+{{- end}}
+	}
+	return nil, fmt.Errorf("{{.Name}}: cannot migrate version %d", from)
+}
+{{end}}{{end}}`))
+
+type tmplType struct {
+	*Type
+	GoName string
+	Kind   string
+}
+
+type tmplData struct {
+	Package string
+	Types   []*tmplType
+}
+
+func (c *Contract) data() *tmplData {
+	d := &tmplData{
+		Package: c.Package,
+	}
+	for _, t := range c.Types {
+		d.Types = append(d.Types, &tmplType{
+			Type:   t,
+			GoName: t.goName(),
+			Kind:   t.kind(),
+		})
+	}
+	return d
+}
+
+func execute(t *template.Template, c *Contract) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, c.data()); err != nil {
+		return nil, err
+	}
+
+	b, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%w: generated code is invalid: %s", ErrInvalidContract, err)
+	}
+	return b, nil
+}
+
+// Generate returns the source of the Go structs of the types and the
+// function returning the types for a registry.
+func Generate(c *Contract) ([]byte, error) {
+	return execute(typesTmpl, c)
+}
+
+// GenerateMigrations returns the source of the skeletons of the migrate
+// functions of versioned types, which are completed by hand. Nil is returned
+// if no type has prior versions.
+func GenerateMigrations(c *Contract) ([]byte, error) {
+	var versioned bool
+	for _, t := range c.Types {
+		if len(t.Previous) > 0 {
+			versioned = true
+			break
+		}
+	}
+	if !versioned {
+		return nil, nil
+	}
+
+	return execute(migrateTmpl, c)
+}
@@ -0,0 +1,95 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+)
+
+const contract = `{
+	"package": "orders",
+	"types": [
+		{
+			"name": "order-placed",
+			"version": 2,
+			"fields": [
+				{"name": "ID", "type": "string", "json": "id", "doc": "ID of the order."},
+				{"name": "Items", "type": "[]string", "json": "items"}
+			],
+			"previous": [
+				{"version": 1, "fields": [{"name": "ID", "type": "string", "json": "id"}]}
+			]
+		},
+		{
+			"name": "place-order",
+			"kind": "command",
+			"doc": "PlaceOrder places an order.",
+			"owner": "checkout",
+			"pii": "personal",
+			"fields": [{"name": "ID", "type": "string"}]
+		}
+	]
+}`
+
+func TestGenerate(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	c, err := Parse([]byte(contract))
+	is.NoErr(err)
+
+	src, err := Generate(c)
+	is.NoErr(err)
+
+	s := string(src)
+	is.True(strings.HasPrefix(s, "// Code generated by rita gen. DO NOT EDIT."))
+	is.True(strings.Contains(s, "package orders"))
+	is.True(strings.Contains(s, "// OrderPlaced is the event \"order-placed\" at version 2."))
+	is.True(strings.Contains(s, "\t// ID of the order.\n"))
+	is.True(strings.Contains(s, "Items []string `json:\"items\"`"))
+	is.True(strings.Contains(s, "type OrderPlacedV1 struct"))
+	is.True(strings.Contains(s, "// PlaceOrder places an order."))
+	is.True(strings.Contains(s, "Description: \"PlaceOrder places an order.\","))
+	is.True(strings.Contains(s, "Owner:       \"checkout\","))
+	is.True(strings.Contains(s, "PII:         \"personal\","))
+	is.True(strings.Contains(s, "ID string `json:\"ID\"`"))
+	is.True(strings.Contains(s, "1: func() any { return &OrderPlacedV1{} },"))
+	is.True(strings.Contains(s, "Migrate: migrateOrderPlaced,"))
+
+	src, err = GenerateMigrations(c)
+	is.NoErr(err)
+	is.True(strings.Contains(string(src), "func migrateOrderPlaced(from int, v any) (any, error) {"))
+	is.True(strings.Contains(string(src), "case *OrderPlacedV1:"))
+
+	c.Types = c.Types[1:]
+	src, err = GenerateMigrations(c)
+	is.NoErr(err)
+	is.True(src == nil)
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"package":       `{"package": "my-orders", "types": []}`,
+		"unknown field": `{"package": "orders", "typez": []}`,
+		"type name":     `{"package": "orders", "types": [{"name": "order placed"}]}`,
+		"duplicate":     `{"package": "orders", "types": [{"name": "a"}, {"name": "a"}]}`,
+		"kind":          `{"package": "orders", "types": [{"name": "a", "kind": "view"}]}`,
+		"go name":       `{"package": "orders", "types": [{"name": "a", "go_name": "a"}]}`,
+		"field name":    `{"package": "orders", "types": [{"name": "a", "fields": [{"name": "id", "type": "string"}]}]}`,
+		"field type":    `{"package": "orders", "types": [{"name": "a", "fields": [{"name": "ID"}]}]}`,
+		"version":       `{"package": "orders", "types": [{"name": "a", "version": 1, "previous": [{"version": 1}]}]}`,
+		"go type":       `{"package": "orders", "types": [{"name": "a", "fields": [{"name": "ID", "type": "map[string"}]}]}`,
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			is := testutil.NewIs(t)
+
+			c, err := Parse([]byte(data))
+			if err == nil {
+				_, err = Generate(c)
+			}
+			is.Err(err, ErrInvalidContract)
+		})
+	}
+}
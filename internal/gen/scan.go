@@ -0,0 +1,149 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"text/template"
+)
+
+// Scanned is a package scanned for types implementing a marker method.
+type Scanned struct {
+	// Package is the name of the package.
+	Package string
+
+	// Marker is the name of the marker method returning the type name.
+	Marker string
+
+	// Func is the name of the generated function returning the types.
+	Func string
+
+	// Types are the names of the Go types implementing the marker method.
+	Types []string
+}
+
+// Scan parses the Go package in the directory and returns the exported,
+// non-generic struct types with a method named marker which takes no
+// arguments and returns a string, e.g. `EventType() string`. The method
+// may have a value or pointer receiver. Test files and files excluded by
+// build constraints are skipped.
+func Scan(dir, marker, fn string) (*Scanned, error) {
+	if !token.IsIdentifier(marker) || !token.IsExported(marker) {
+		return nil, fmt.Errorf("gen: invalid marker method %q", marker)
+	}
+	if !token.IsIdentifier(fn) || !token.IsExported(fn) {
+		return nil, fmt.Errorf("gen: invalid function name %q", fn)
+	}
+
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+
+	structs := make(map[string]bool)
+	marked := make(map[string]bool)
+
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.GenDecl:
+				for _, s := range d.Specs {
+					ts, ok := s.(*ast.TypeSpec)
+					if !ok || ts.Assign.IsValid() || ts.TypeParams != nil || !ts.Name.IsExported() {
+						continue
+					}
+					if _, ok := ts.Type.(*ast.StructType); ok {
+						structs[ts.Name.Name] = true
+					}
+				}
+
+			case *ast.FuncDecl:
+				if recv := markerRecv(d, marker); recv != "" {
+					marked[recv] = true
+				}
+			}
+		}
+	}
+
+	s := &Scanned{
+		Package: bp.Name,
+		Marker:  marker,
+		Func:    fn,
+	}
+	for n := range marked {
+		if structs[n] {
+			s.Types = append(s.Types, n)
+		}
+	}
+	sort.Strings(s.Types)
+
+	return s, nil
+}
+
+// markerRecv returns the name of the receiver type if the function is the
+// marker method.
+func markerRecv(d *ast.FuncDecl, marker string) string {
+	if d.Recv == nil || len(d.Recv.List) != 1 || d.Name.Name != marker {
+		return ""
+	}
+	if d.Type.Params.NumFields() != 0 || d.Type.Results.NumFields() != 1 {
+		return ""
+	}
+	if r, ok := d.Type.Results.List[0].Type.(*ast.Ident); !ok || r.Name != "string" {
+		return ""
+	}
+
+	t := d.Recv.List[0].Type
+	if st, ok := t.(*ast.StarExpr); ok {
+		t = st.X
+	}
+	id, ok := t.(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	return id.Name
+}
+
+var scanTmpl = template.Must(template.New("scan").Parse(`// Code generated by rita scan. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"github.com/bruth/rita/types"
+)
+
+// {{.Func}} returns the types of the package implementing {{.Marker}}, keyed by
+// the names they return, which can be passed to types.NewRegistry.
+func {{.Func}}() map[string]*types.Type {
+	return map[string]*types.Type{
+{{- range .Types}}
+		(&{{.}}{}).{{$.Marker}}(): {
+			Init: func() any { return &{{.}}{} },
+		},
+{{- end}}
+	}
+}
+`))
+
+// GenerateScan returns the source of the function returning the scanned
+// types for a registry.
+func GenerateScan(s *Scanned) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := scanTmpl.Execute(&buf, s); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
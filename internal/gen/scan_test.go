@@ -0,0 +1,74 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+)
+
+const scanSource = `package orders
+
+type OrderPlaced struct{}
+
+func (OrderPlaced) EventType() string { return "order-placed" }
+
+type OrderShipped struct{}
+
+func (*OrderShipped) EventType() string { return "order-shipped" }
+
+// Not a struct.
+type Status string
+
+func (Status) EventType() string { return "status" }
+
+// Not exported.
+type orderDeleted struct{}
+
+func (orderDeleted) EventType() string { return "order-deleted" }
+
+// Wrong signature.
+type OrderCanceled struct{}
+
+func (OrderCanceled) EventType(v int) string { return "order-canceled" }
+
+// No marker.
+type Order struct{}
+`
+
+const scanTestSource = `package orders
+
+type OrderTested struct{}
+
+func (OrderTested) EventType() string { return "order-tested" }
+`
+
+func TestScan(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	dir := t.TempDir()
+	is.NoErr(os.WriteFile(filepath.Join(dir, "orders.go"), []byte(scanSource), 0o644))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "orders_test.go"), []byte(scanTestSource), 0o644))
+
+	s, err := Scan(dir, "EventType", "Types")
+	is.NoErr(err)
+	is.Equal(s.Package, "orders")
+	is.Equal(s.Types, []string{"OrderPlaced", "OrderShipped"})
+
+	src, err := GenerateScan(s)
+	is.NoErr(err)
+
+	out := string(src)
+	is.True(strings.HasPrefix(out, "// Code generated by rita scan. DO NOT EDIT."))
+	is.True(strings.Contains(out, "func Types() map[string]*types.Type {"))
+	is.True(strings.Contains(out, "(&OrderPlaced{}).EventType(): {"))
+	is.True(strings.Contains(out, "Init: func() any { return &OrderShipped{} },"))
+
+	_, err = Scan(dir, "eventType", "Types")
+	is.Err(err, nil)
+
+	_, err = Scan(dir, "EventType", "types")
+	is.Err(err, nil)
+}
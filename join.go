@@ -0,0 +1,277 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const defaultJoinWait = 100 * time.Millisecond
+
+type joinOpts struct {
+	key        func(e *Event) string
+	batch      int
+	wait       time.Duration
+	retryDelay time.Duration
+}
+
+type joinOptFn func(o *joinOpts) error
+
+func (f joinOptFn) joinOpt(o *joinOpts) error {
+	return f(o)
+}
+
+// JoinOption is an option for a join projection.
+type JoinOption interface {
+	joinOpt(o *joinOpts) error
+}
+
+// JoinKey sets the function returning the key events are joined by, such as
+// an order ID in the data of order and payment events. Default is the
+// correlation ID of the event, which is the ID of the first command or event
+// in the chain the event ID was derived from. See Timeline.
+func JoinKey(fn func(e *Event) string) JoinOption {
+	return joinOptFn(func(o *joinOpts) error {
+		if fn == nil {
+			return fmt.Errorf("join: key function required")
+		}
+		o.key = fn
+		return nil
+	})
+}
+
+// JoinBatch sets the max number of events fetched from a store at a time.
+// Default is 100.
+func JoinBatch(n int) JoinOption {
+	return joinOptFn(func(o *joinOpts) error {
+		if n < 1 {
+			return fmt.Errorf("join: batch must be positive")
+		}
+		o.batch = n
+		return nil
+	})
+}
+
+// JoinWait sets the max time to wait for events from a store which has no
+// events buffered before merging the events of the other stores. A longer
+// wait merges events arriving at the same time across stores more
+// accurately at the cost of latency. Default is 100 milliseconds.
+func JoinWait(d time.Duration) JoinOption {
+	return joinOptFn(func(o *joinOpts) error {
+		if d <= 0 {
+			return fmt.Errorf("join: wait must be positive")
+		}
+		o.wait = d
+		return nil
+	})
+}
+
+// JoinRetryDelay sets the delay before an event is redelivered when the
+// handler returns an error. Default is 1 second.
+func JoinRetryDelay(d time.Duration) JoinOption {
+	return joinOptFn(func(o *joinOpts) error {
+		o.retryDelay = d
+		return nil
+	})
+}
+
+// JoinEvent is an event delivered by a join projection.
+type JoinEvent struct {
+	*Event
+
+	// Store is the name of the store of the event.
+	Store string
+
+	// Key is the key the event is joined by.
+	Key string
+}
+
+// joinSource is a store consumed by a join projection.
+type joinSource struct {
+	es  *EventStore
+	sub *Subscription
+	buf []*Delivery
+}
+
+// JoinProjection consumes the events of multiple stores, such as orders and
+// payments, to project read models spanning bounded contexts. Each store is
+// consumed by a durable subscription, so the position in each store is
+// checkpointed independently. The events of the stores are merged in the
+// order of their time as they are available, and each event is keyed, by
+// default by its correlation ID. Events appended with a time earlier than
+// events already delivered from another store are not reordered.
+type JoinProjection struct {
+	name    string
+	sources []*joinSource
+	opts    joinOpts
+}
+
+// JoinProjection returns a projection consuming the stores. The name is used
+// for the durable subscription of each store and must be unique per store.
+func (r *Rita) JoinProjection(name string, stores []*EventStore, opts ...JoinOption) (*JoinProjection, error) {
+	o := joinOpts{
+		key: func(e *Event) string {
+			return timelineRoot(e.ID)
+		},
+		batch:      100,
+		wait:       defaultJoinWait,
+		retryDelay: time.Second,
+	}
+
+	for _, opt := range opts {
+		if err := opt.joinOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(stores) < 2 {
+		return nil, errors.New("rita: join: at least two stores required")
+	}
+
+	p := &JoinProjection{
+		name: name,
+		opts: o,
+	}
+
+	seen := make(map[*EventStore]bool)
+	for _, es := range stores {
+		if seen[es] {
+			return nil, fmt.Errorf("rita: join: duplicate store %q", es.name)
+		}
+		seen[es] = true
+
+		sub, err := es.Subscription(name, SubscriptionBatch(o.batch), SubscriptionRetryDelay(o.retryDelay))
+		if err != nil {
+			p.Close() //nolint
+			return nil, err
+		}
+
+		p.sources = append(p.sources, &joinSource{
+			es:  es,
+			sub: sub,
+		})
+	}
+
+	return p, nil
+}
+
+// fill fetches events into the buffer of the source if it is empty.
+func (p *JoinProjection) fill(ctx context.Context, src *joinSource) error {
+	if len(src.buf) > 0 {
+		return nil
+	}
+
+	fctx, cancel := context.WithTimeout(ctx, p.opts.wait)
+	defer cancel()
+
+	ds, err := src.sub.Fetch(fctx, p.opts.batch)
+	if err != nil {
+		if ctx.Err() == nil && (errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout)) {
+			return nil
+		}
+		return err
+	}
+
+	src.buf = ds
+	return nil
+}
+
+// next returns the source of the earliest buffered event, or nil if there
+// are none.
+func (p *JoinProjection) next() *joinSource {
+	var first *joinSource
+	for _, src := range p.sources {
+		if len(src.buf) == 0 {
+			continue
+		}
+		if first == nil || src.buf[0].Time.Before(first.buf[0].Time) {
+			first = src
+		}
+	}
+	return first
+}
+
+// Run passes the events of the stores to the handler in the order of their
+// time until the context is done. If the handler returns nil, the event is
+// acked, otherwise it and the buffered events of its store are redelivered
+// after the retry delay.
+func (p *JoinProjection) Run(ctx context.Context, handle func(ctx context.Context, event *JoinEvent) error) error {
+	for {
+		// Ensure each store has buffered events, if available, so the
+		// earliest event is selected across the stores.
+		for _, src := range p.sources {
+			if err := p.fill(ctx, src); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		for {
+			src := p.next()
+			if src == nil {
+				break
+			}
+
+			d := src.buf[0]
+			src.buf = src.buf[1:]
+
+			err := handle(ctx, &JoinEvent{
+				Event: d.Event,
+				Store: src.es.name,
+				Key:   p.opts.key(d.Event),
+			})
+			if err != nil {
+				if err := d.Nak(p.opts.retryDelay); err != nil {
+					return err
+				}
+				for _, b := range src.buf {
+					if err := b.Nak(p.opts.retryDelay); err != nil {
+						return err
+					}
+				}
+				src.buf = nil
+			} else if err := d.Ack(); err != nil {
+				return err
+			}
+
+			// Refill once a store is drained to merge its next events.
+			if len(src.buf) == 0 {
+				break
+			}
+		}
+	}
+}
+
+// Lag returns the total number of events of the stores that have not yet
+// been processed.
+func (p *JoinProjection) Lag() (uint64, error) {
+	var lag uint64
+	for _, src := range p.sources {
+		n, err := src.sub.Lag()
+		if err != nil {
+			return 0, err
+		}
+		lag += n
+	}
+	return lag, nil
+}
+
+// Close closes the subscriptions of the stores. The durable consumers are
+// retained.
+func (p *JoinProjection) Close() error {
+	var err error
+	for _, src := range p.sources {
+		if cerr := src.sub.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package rita
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestJoinProjection(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	orders, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(orders.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	payments, err := r.EventStore("payments")
+	is.NoErr(err)
+	is.NoErr(payments.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	_, err = r.JoinProjection("summary", []*EventStore{orders})
+	is.Err(err, nil)
+	_, err = r.JoinProjection("summary", []*EventStore{orders, orders})
+	is.Err(err, nil)
+
+	ctx := context.Background()
+	t0 := time.Now().Add(-time.Hour)
+
+	// Appended out of time order across the stores.
+	_, err = payments.Append(ctx, "payments.1", []*Event{
+		{ID: "c1.1", Time: t0.Add(2 * time.Second), Data: &OrderPlaced{ID: "p1"}},
+	})
+	is.NoErr(err)
+	_, err = orders.Append(ctx, "orders.1", []*Event{
+		{ID: "c1", Time: t0.Add(time.Second), Data: &OrderPlaced{ID: "1"}},
+		{ID: "c1.2", Time: t0.Add(3 * time.Second), Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	run := func(n int) []*JoinEvent {
+		p, err := r.JoinProjection("summary", []*EventStore{orders, payments})
+		is.NoErr(err)
+		defer p.Close() //nolint
+
+		rctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		var events []*JoinEvent
+		err = p.Run(rctx, func(ctx context.Context, e *JoinEvent) error {
+			events = append(events, e)
+			if len(events) == n {
+				cancel()
+			}
+			return nil
+		})
+		is.NoErr(err)
+		return events
+	}
+
+	events := run(3)
+	is.Equal(len(events), 3)
+	is.Equal(events[0].ID, "c1")
+	is.Equal(events[0].Store, "orders")
+	is.Equal(events[1].ID, "c1.1")
+	is.Equal(events[1].Store, "payments")
+	is.Equal(events[2].ID, "c1.2")
+	for _, e := range events {
+		is.Equal(e.Key, "c1")
+	}
+
+	// Each store resumes from its checkpoint.
+	_, err = payments.Append(ctx, "payments.2", []*Event{
+		{ID: "c2", Data: &OrderPlaced{ID: "p2"}},
+	})
+	is.NoErr(err)
+
+	events = run(1)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].ID, "c2")
+}
@@ -0,0 +1,124 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Materializer maintains the latest state of a model evolved from the events
+// of each subject in a KV bucket, so entities can be looked up without
+// evolving them per request. Events are applied with StateStore.Apply, so
+// redelivered events are not applied twice.
+type Materializer struct {
+	es        *EventStore
+	sub       *Subscription
+	state     *StateStore
+	modelType string
+}
+
+// materializerBucket returns the name of the KV bucket of the materializer.
+func (s *EventStore) materializerBucket(name string) string {
+	return s.derivedName(name)
+}
+
+// Materializer returns a materializer of the model registered with the type
+// name, which must implement Evolver. The latest state of each subject is
+// stored in a KV bucket named "{stream}_{name}", which is created with the
+// storage and replicas of the stream if it does not exist. The name is also
+// used for the durable subscription, so the materializer resumes where it
+// left off after restarts.
+func (s *EventStore) Materializer(name, modelType string, opts ...SubscriptionOption) (*Materializer, error) {
+	if s.rt.types == nil {
+		return nil, errors.New("rita: materializer: type registry required")
+	}
+
+	v, err := s.rt.types.Init(modelType)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := v.(Evolver); !ok {
+		return nil, fmt.Errorf("rita: materializer: type %q is not an Evolver", modelType)
+	}
+
+	kv, err := s.derivedKV(name)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := s.Subscription(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Materializer{
+		es:  s,
+		sub: sub,
+		state: &StateStore{
+			name: s.materializerBucket(name),
+			kv:   kv,
+			rt:   s.rt,
+		},
+		modelType: modelType,
+	}, nil
+}
+
+// apply evolves the state of the subject of the event.
+func (m *Materializer) apply(event *Event) error {
+	_, err := m.state.Apply(event.Subject, event, func(v any) (any, error) {
+		if v == nil {
+			var err error
+			v, err = m.es.rt.types.Init(m.modelType)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		model, ok := v.(Evolver)
+		if !ok {
+			return nil, fmt.Errorf("rita: materializer: %s: stored value is %T", event.Subject, v)
+		}
+		if err := model.Evolve(event); err != nil {
+			return nil, err
+		}
+		return model, nil
+	})
+	return err
+}
+
+// Run applies events until the context is done. Like the subscription, it
+// is authorized as a watch.
+func (m *Materializer) Run(ctx context.Context) error {
+	defer m.sub.Close() //nolint
+
+	return m.sub.Run(ctx, func(ctx context.Context, event *Event) error {
+		return m.apply(event)
+	})
+}
+
+// Get returns the latest state of the subject and the sequence of the last
+// event applied to it. If no events of the subject have been applied,
+// nats.ErrKeyNotFound is returned.
+func (m *Materializer) Get(subject string) (Evolver, uint64, error) {
+	e, err := m.state.Get(subject)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	model, ok := e.Value.(Evolver)
+	if !ok {
+		return nil, 0, fmt.Errorf("rita: materializer: %s: stored value is %T", subject, e.Value)
+	}
+	return model, e.Applied[subject], nil
+}
+
+// Purge deletes the state of the subject, so the materializer can be
+// registered with AddPurger to be purged on erasure.
+func (m *Materializer) Purge(ctx context.Context, subject string) error {
+	return m.state.kv.Purge(subject)
+}
+
+// Lag returns the number of events that have not yet been applied.
+func (m *Materializer) Lag() (uint64, error) {
+	return m.sub.Lag()
+}
@@ -0,0 +1,73 @@
+package rita
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestMaterializer(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-summary", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	_, err = es.Materializer("latest", "order-placed")
+	is.Err(err, nil)
+
+	m, err := es.Materializer("latest", "order-summary")
+	is.NoErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go m.Run(ctx) //nolint
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if lag, _ := m.Lag(); lag == 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	model, seq, err := m.Get("orders.1")
+	is.NoErr(err)
+	is.Equal(seq, uint64(2))
+	is.Equal(model.(*OrderSummary).Placed, 1)
+	is.Equal(model.(*OrderSummary).Shipped, 1)
+
+	model, seq, err = m.Get("orders.2")
+	is.NoErr(err)
+	is.Equal(seq, uint64(3))
+	is.Equal(model.(*OrderSummary).Shipped, 0)
+
+	_, _, err = m.Get("orders.3")
+	is.Err(err, nats.ErrKeyNotFound)
+}
@@ -0,0 +1,230 @@
+package rita
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrEventNotInTree = errors.New("rita: event not in merkle tree")
+)
+
+// MerkleTree is a Merkle tree over the events of a subject, which may be a
+// wildcard to cover a whole store, for a range of the stream. The root can be
+// anchored externally, e.g. published to a transparency log, and inclusion
+// proofs used later to prove an event was part of the history.
+//
+// The leaves are the event hashes in stream order, which are the Hash of
+// each event if the store is hash chained. Leaves and nodes are hashed with
+// distinct prefixes following RFC 6962.
+type MerkleTree struct {
+	// Subject the tree covers.
+	Subject string
+
+	// FirstSequence and LastSequence are the range of the stream the tree
+	// covers. The next period starts after LastSequence.
+	FirstSequence uint64
+	LastSequence  uint64
+
+	// Root is the hex-encoded root hash. It is empty if there are no events.
+	Root string
+
+	seqs   []uint64
+	leaves [][]byte
+	levels [][][]byte
+}
+
+// Len returns the number of events in the tree.
+func (t *MerkleTree) Len() int {
+	return len(t.leaves)
+}
+
+// MerkleStep is a step in an inclusion proof.
+type MerkleStep struct {
+	// Hash is the hex-encoded sibling hash.
+	Hash string
+
+	// Left is true if the sibling is on the left.
+	Left bool
+}
+
+// MerkleProof proves the inclusion of an event in a tree with a root.
+type MerkleProof struct {
+	// Sequence of the event.
+	Sequence uint64
+
+	// Leaf is the hex-encoded hash of the event.
+	Leaf string
+
+	// Path of sibling hashes from the leaf to the root.
+	Path []MerkleStep
+}
+
+// Verify returns true if the proof resolves to the hex-encoded root.
+func (p *MerkleProof) Verify(root string) bool {
+	leaf, err := hex.DecodeString(p.Leaf)
+	if err != nil {
+		return false
+	}
+
+	h := merkleLeaf(leaf)
+	for _, s := range p.Path {
+		sib, err := hex.DecodeString(s.Hash)
+		if err != nil {
+			return false
+		}
+		if s.Left {
+			h = merkleNode(sib, h)
+		} else {
+			h = merkleNode(h, sib)
+		}
+	}
+
+	r, err := hex.DecodeString(root)
+	return err == nil && bytes.Equal(h, r)
+}
+
+// Proof returns the inclusion proof of the event at the sequence.
+func (t *MerkleTree) Proof(seq uint64) (*MerkleProof, error) {
+	idx := -1
+	for i, s := range t.seqs {
+		if s == seq {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: sequence %d", ErrEventNotInTree, seq)
+	}
+
+	p := &MerkleProof{
+		Sequence: seq,
+		Leaf:     hex.EncodeToString(t.leaves[idx]),
+	}
+
+	i := idx
+	for _, level := range t.levels[:len(t.levels)-1] {
+		// A node without a sibling is promoted as is.
+		if i%2 == 1 {
+			p.Path = append(p.Path, MerkleStep{Hash: hex.EncodeToString(level[i-1]), Left: true})
+		} else if i+1 < len(level) {
+			p.Path = append(p.Path, MerkleStep{Hash: hex.EncodeToString(level[i+1])})
+		}
+		i /= 2
+	}
+
+	return p, nil
+}
+
+func merkleLeaf(b []byte) []byte {
+	h := sha256.Sum256(append([]byte{0}, b...))
+	return h[:]
+}
+
+func merkleNode(l, r []byte) []byte {
+	b := make([]byte, 0, 1+len(l)+len(r))
+	b = append(b, 1)
+	b = append(b, l...)
+	b = append(b, r...)
+	h := sha256.Sum256(b)
+	return h[:]
+}
+
+// build builds the levels of the tree from the leaves.
+func (t *MerkleTree) build() {
+	if len(t.leaves) == 0 {
+		return
+	}
+
+	level := make([][]byte, len(t.leaves))
+	for i, l := range t.leaves {
+		level[i] = merkleLeaf(l)
+	}
+	t.levels = append(t.levels, level)
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				next = append(next, merkleNode(level[i], level[i+1]))
+			} else {
+				next = append(next, level[i])
+			}
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+
+	t.Root = hex.EncodeToString(level[0])
+}
+
+// MerkleTree computes a Merkle tree over the events of the subject. Periodic
+// roots can be computed using AfterSequence with the last sequence of the
+// previous tree and UntilSequence to fix the end of the period. Only the
+// consumer, sequence, and timeout options of LoadOption apply. Redacted events are not
+// part of the tree, so trees should be computed before events are redacted.
+func (s *EventStore) MerkleTree(ctx context.Context, subject string, opts ...LoadOption) (*MerkleTree, error) {
+	if err := validateSubject(subject, true); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorize(ctx, OpLoad, subject, nil); err != nil {
+		return nil, err
+	}
+
+	var o loadOpts
+	for _, opt := range opts {
+		if err := opt.loadOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, o.timeout, s.rt.loadTimeoutFor(ctx))
+	defer cancel()
+
+	t := &MerkleTree{
+		Subject: subject,
+	}
+
+	_, err := s.readMsgs(ctx, subject, &o, func(seq uint64, msg *nats.Msg) error {
+		msg, err := s.dereference(msg)
+		if err != nil {
+			return err
+		}
+		if err := verifyHash(msg); err != nil {
+			return err
+		}
+
+		hash := msg.Header.Get(eventHashHdr)
+		if hash == "" {
+			hash = eventHash(msg)
+		}
+
+		leaf, err := hex.DecodeString(hash)
+		if err != nil {
+			return err
+		}
+
+		if t.FirstSequence == 0 {
+			t.FirstSequence = seq
+		}
+		t.LastSequence = seq
+
+		t.seqs = append(t.seqs, seq)
+		t.leaves = append(t.leaves, leaf)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t.build()
+
+	return t, nil
+}
@@ -0,0 +1,81 @@
+package rita
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreMerkleTree(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreHashChain())
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	last := make(map[string]uint64)
+	for i := 1; i <= 5; i++ {
+		subject := fmt.Sprintf("orders.%d", i%2)
+		last[subject], err = es.Append(ctx, subject, []*Event{{Data: &OrderPlaced{ID: fmt.Sprint(i)}}}, ExpectSequence(last[subject]))
+		is.NoErr(err)
+	}
+
+	tree, err := es.MerkleTree(ctx, "orders.>")
+	is.NoErr(err)
+	is.Equal(tree.Len(), 5)
+	is.Equal(tree.FirstSequence, uint64(1))
+	is.Equal(tree.LastSequence, uint64(5))
+
+	for seq := uint64(1); seq <= 5; seq++ {
+		p, err := tree.Proof(seq)
+		is.NoErr(err)
+		is.True(p.Verify(tree.Root))
+	}
+
+	// The leaves are the event hashes.
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+
+	p, err := tree.Proof(events[0].Sequence)
+	is.NoErr(err)
+	is.Equal(p.Leaf, events[0].Hash)
+
+	// A proof does not verify against another root.
+	sub, err := es.MerkleTree(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(sub.Len(), 3)
+	is.True(!p.Verify(sub.Root))
+
+	_, err = sub.Proof(2)
+	is.Err(err, ErrEventNotInTree)
+
+	// Periods are fixed ranges of the stream.
+	first, err := es.MerkleTree(ctx, "orders.>", UntilSequence(3))
+	is.NoErr(err)
+	is.Equal(first.Len(), 3)
+
+	next, err := es.MerkleTree(ctx, "orders.>", AfterSequence(first.LastSequence))
+	is.NoErr(err)
+	is.Equal(next.Len(), 2)
+	is.Equal(next.FirstSequence, uint64(4))
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "6"}}}, ExpectSequence(last["orders.1"]))
+	is.NoErr(err)
+
+	again, err := es.MerkleTree(ctx, "orders.>", UntilSequence(3))
+	is.NoErr(err)
+	is.Equal(again.Root, first.Root)
+}
@@ -0,0 +1,114 @@
+package rita
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrReadOnly       = errors.New("rita: event store is read-only")
+	ErrMirrorFidelity = errors.New("rita: mirror does not preserve events")
+)
+
+// MirrorTo creates a read-only mirror of the event store using the target
+// Rita instance and returns the mirrored store. The target is typically
+// connected to another JetStream domain or account to support low-latency
+// loads in another region. The mirror has the same name as the store, so the
+// target must differ by domain, account, or context. If the config defines a
+// Mirror, its External, start, and filter settings are used to reach this
+// store, the name is always this store. Once created, the mirror is verified
+// to preserve the last event of the store, waiting for it to be mirrored
+// until the context is done.
+func (s *EventStore) MirrorTo(ctx context.Context, target *Rita, config *EventStoreConfig) (*EventStore, error) {
+	var c EventStoreConfig
+	if config != nil {
+		c = *config
+	}
+
+	var src EventStoreSource
+	if c.Mirror != nil {
+		src = *c.Mirror
+	}
+
+	// The source is resolved relative to this store rather than the target.
+	src.Name = s.stream
+	if src.FilterSubject != "" {
+		src.FilterSubject = s.subject(src.FilterSubject)
+	}
+	src.raw = true
+	c.Mirror = &src
+
+	m, err := target.EventStore(s.name)
+	if err != nil {
+		return nil, err
+	}
+	m.context = s.context
+	m.readOnly = true
+
+	if err := m.Create(&c); err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyMirror(ctx, m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// verifyMirror waits for the last event in the store to be mirrored and
+// ensures the event envelope is preserved.
+func (s *EventStore) verifyMirror(ctx context.Context, m *EventStore) error {
+	info, err := s.rt.js.StreamInfo(s.stream)
+	if err != nil {
+		return err
+	}
+
+	seq := info.State.LastSeq
+	if seq == 0 {
+		return nil
+	}
+
+	orig, err := s.rt.js.GetMsg(s.stream, seq)
+	if err != nil {
+		return err
+	}
+
+	var mirrored *nats.RawStreamMsg
+	for {
+		mirrored, err = m.rt.js.GetMsg(m.stream, seq)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, nats.ErrMsgNotFound) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	if mirrored.Subject != orig.Subject {
+		return fmt.Errorf("%w: subject differs", ErrMirrorFidelity)
+	}
+
+	for _, h := range []string{nats.MsgIdHdr, eventTypeHdr, eventTimeHdr, eventCodecHdr} {
+		if mirrored.Header.Get(h) != orig.Header.Get(h) {
+			return fmt.Errorf("%w: %s header differs", ErrMirrorFidelity, h)
+		}
+	}
+
+	if !bytes.Equal(mirrored.Data, orig.Data) {
+		return fmt.Errorf("%w: data differs", ErrMirrorFidelity)
+	}
+
+	return nil
+}
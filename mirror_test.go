@@ -0,0 +1,55 @@
+package rita
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestMirrorTo(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	// A distinct context stands in for another domain or account.
+	target, err := New(nc, TypeRegistry(tr), Context("replica"))
+	is.NoErr(err)
+
+	mctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	m, err := es.MirrorTo(mctx, target, &EventStoreConfig{Storage: nats.MemoryStorage})
+	is.NoErr(err)
+
+	events, seq, err := m.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(seq, uint64(2))
+	is.Equal(events[0].Type, "order-placed")
+
+	_, err = m.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.Err(err, ErrReadOnly)
+}
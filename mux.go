@@ -0,0 +1,184 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/bruth/rita/types"
+)
+
+var (
+	ErrUnhandledEvent = errors.New("rita: unhandled event type")
+)
+
+// EventHandler handles an event, such as by updating a read model. It is
+// the signature of the handler passed to Subscription.Run and
+// ProjectionWorker.Run.
+type EventHandler func(ctx context.Context, event *Event) error
+
+// EventMiddleware wraps an event handler, such as for logging, metrics, or
+// recovering from panics.
+type EventMiddleware func(next EventHandler) EventHandler
+
+// UnhandledPolicy determines how events of types without a handler are
+// handled by an EventMux.
+type UnhandledPolicy int
+
+const (
+	// UnhandledSkip ignores the event. This is the default.
+	UnhandledSkip UnhandledPolicy = iota
+
+	// UnhandledFail returns an error wrapping ErrUnhandledEvent, so the
+	// event is redelivered by a subscription.
+	UnhandledFail
+)
+
+type eventMuxOpts struct {
+	unhandled UnhandledPolicy
+	types     *types.Registry
+}
+
+type eventMuxOptFn func(o *eventMuxOpts) error
+
+func (f eventMuxOptFn) eventMuxOpt(o *eventMuxOpts) error {
+	return f(o)
+}
+
+// EventMuxOption is an option for an event mux.
+type EventMuxOption interface {
+	eventMuxOpt(o *eventMuxOpts) error
+}
+
+// MuxUnhandled sets the policy for events of types without a handler.
+// Default is UnhandledSkip.
+func MuxUnhandled(policy UnhandledPolicy) EventMuxOption {
+	return eventMuxOptFn(func(o *eventMuxOpts) error {
+		switch policy {
+		case UnhandledSkip, UnhandledFail:
+		default:
+			return fmt.Errorf("rita: invalid unhandled policy %d", policy)
+		}
+		o.unhandled = policy
+		return nil
+	})
+}
+
+// MuxTypes sets the type registry used by Handle to look up the event type
+// of handlers. This is typically the registry set with TypeRegistry.
+func MuxTypes(r *types.Registry) EventMuxOption {
+	return eventMuxOptFn(func(o *eventMuxOpts) error {
+		if r == nil {
+			return errors.New("rita: mux: type registry required")
+		}
+		o.types = r
+		return nil
+	})
+}
+
+// EventMux routes events to the handler registered for the event type, so
+// projection handlers do not need to switch on the type. Handle is passed as
+// the handler, e.g. to Subscription.Run. Handlers must be registered before
+// events are handled.
+type EventMux struct {
+	opts       eventMuxOpts
+	handlers   map[string]EventHandler
+	middleware []EventMiddleware
+}
+
+// NewEventMux returns an empty event mux.
+func NewEventMux(opts ...EventMuxOption) (*EventMux, error) {
+	var o eventMuxOpts
+	for _, opt := range opts {
+		if err := opt.eventMuxOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	return &EventMux{
+		opts:     o,
+		handlers: make(map[string]EventHandler),
+	}, nil
+}
+
+// On registers the handler for events of the type. It panics if the type is
+// empty or already has a handler.
+func (m *EventMux) On(eventType string, h EventHandler) {
+	if eventType == "" {
+		panic("rita: mux: event type required")
+	}
+	if h == nil {
+		panic("rita: mux: nil handler")
+	}
+	if _, ok := m.handlers[eventType]; ok {
+		panic(fmt.Sprintf("rita: mux: multiple handlers for event type %q", eventType))
+	}
+	m.handlers[eventType] = h
+}
+
+// Use appends middleware wrapping every handler, including for events
+// without a handler. The first middleware is the outermost.
+func (m *EventMux) Use(mw ...EventMiddleware) {
+	m.middleware = append(m.middleware, mw...)
+}
+
+// Types returns the sorted event types with a handler.
+func (m *EventMux) Types() []string {
+	types := make([]string, 0, len(m.handlers))
+	for t := range m.handlers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// route handles the event with the handler of its type.
+func (m *EventMux) route(ctx context.Context, event *Event) error {
+	h, ok := m.handlers[event.Type]
+	if ok {
+		return h(ctx, event)
+	}
+	if m.opts.unhandled == UnhandledFail {
+		return fmt.Errorf("%w: %s", ErrUnhandledEvent, event.Type)
+	}
+	return nil
+}
+
+// Handle handles the event with the handler registered for its type,
+// wrapped by the middleware. Besides subscriptions and workers, it can be
+// called for each event returned by Tail.Next, or from a ReactFunc which
+// appends the dispatches of the handlers to a slice.
+func (m *EventMux) Handle(ctx context.Context, event *Event) error {
+	h := EventHandler(m.route)
+	for i := len(m.middleware) - 1; i >= 0; i-- {
+		h = m.middleware[i](h)
+	}
+	return h(ctx, event)
+}
+
+// On registers a handler for events of the type which is passed the data of
+// the event as a *T. An event whose data is not a *T fails with an error.
+func On[T any](m *EventMux, eventType string, fn func(ctx context.Context, event *Event, data *T) error) {
+	m.On(eventType, func(ctx context.Context, event *Event) error {
+		data, ok := event.Data.(*T)
+		if !ok {
+			return fmt.Errorf("rita: mux: event %s data is %T, expected %T", eventType, event.Data, data)
+		}
+		return fn(ctx, event, data)
+	})
+}
+
+// Handle registers a handler like On for the name of T in the type registry
+// set with MuxTypes, so the event type does not need to be repeated. It
+// panics if the mux has no type registry or T is not registered.
+func Handle[T any](m *EventMux, fn func(ctx context.Context, event *Event, data *T) error) {
+	if m.opts.types == nil {
+		panic("rita: mux: type registry required")
+	}
+	t, err := m.opts.types.Lookup(new(T))
+	if err != nil {
+		panic(fmt.Sprintf("rita: mux: %s", err))
+	}
+	On(m, t, fn)
+}
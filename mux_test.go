@@ -0,0 +1,98 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+)
+
+func TestEventMux(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	_, err := NewEventMux(MuxUnhandled(UnhandledPolicy(5)))
+	is.Err(err, nil)
+
+	m, err := NewEventMux(MuxUnhandled(UnhandledFail))
+	is.NoErr(err)
+
+	var (
+		placed  []string
+		shipped []string
+		trace   []string
+	)
+
+	On(m, "order-placed", func(ctx context.Context, e *Event, data *OrderPlaced) error {
+		placed = append(placed, data.ID)
+		return nil
+	})
+	m.On("order-shipped", func(ctx context.Context, e *Event) error {
+		shipped = append(shipped, e.Data.(*OrderShipped).ID)
+		return nil
+	})
+
+	m.Use(
+		func(next EventHandler) EventHandler {
+			return func(ctx context.Context, e *Event) error {
+				trace = append(trace, "outer")
+				return next(ctx, e)
+			}
+		},
+		func(next EventHandler) EventHandler {
+			return func(ctx context.Context, e *Event) error {
+				trace = append(trace, "inner")
+				return next(ctx, e)
+			}
+		},
+	)
+
+	is.Equal(m.Types(), []string{"order-placed", "order-shipped"})
+
+	ctx := context.Background()
+
+	is.NoErr(m.Handle(ctx, &Event{Type: "order-placed", Data: &OrderPlaced{ID: "1"}}))
+	is.NoErr(m.Handle(ctx, &Event{Type: "order-shipped", Data: &OrderShipped{ID: "1"}}))
+	is.Equal(placed, []string{"1"})
+	is.Equal(shipped, []string{"1"})
+	is.Equal(trace[:2], []string{"outer", "inner"})
+
+	err = m.Handle(ctx, &Event{Type: "order-cancelled"})
+	is.Err(err, ErrUnhandledEvent)
+
+	// Mismatched data fails rather than panics.
+	err = m.Handle(ctx, &Event{Type: "order-placed", Data: &OrderShipped{ID: "1"}})
+	is.Err(err, nil)
+
+	defer func() {
+		is.True(recover() != nil)
+	}()
+	m.On("order-placed", func(ctx context.Context, e *Event) error { return nil })
+}
+
+func TestHandle(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	_, err := NewEventMux(MuxTypes(nil))
+	is.Err(err, nil)
+
+	m, err := NewEventMux(MuxTypes(newOrderTypes(t)))
+	is.NoErr(err)
+
+	var placed []string
+	Handle(m, func(ctx context.Context, e *Event, data *OrderPlaced) error {
+		placed = append(placed, data.ID)
+		return nil
+	})
+	is.Equal(m.Types(), []string{"order-placed"})
+
+	ctx := context.Background()
+	is.NoErr(m.Handle(ctx, &Event{Type: "order-placed", Data: &OrderPlaced{ID: "1"}}))
+	is.Equal(placed, []string{"1"})
+
+	type unregistered struct{}
+
+	defer func() {
+		is.True(recover() != nil)
+	}()
+	Handle(m, func(ctx context.Context, e *Event, data *unregistered) error { return nil })
+}
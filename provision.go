@@ -0,0 +1,102 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrExternallyManaged = errors.New("rita: event store is externally managed")
+)
+
+// EventStoreBind binds the event store to an existing stream which is
+// provisioned outside of Rita, for example by Terraform. Create, Update,
+// Ensure, and Delete return ErrExternallyManaged. The stream is validated on
+// first use to be usable as an event store.
+func EventStoreBind() EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		o.bind = true
+		return nil
+	})
+}
+
+// EventStoreAutoCreate creates the stream of the event store with the config
+// on first use if it does not exist, rather than requiring an explicit call
+// to Create. This is mainly intended to simplify development and tests.
+func EventStoreAutoCreate(config *EventStoreConfig) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if config == nil {
+			config = &EventStoreConfig{}
+		}
+		o.autoCreate = config
+		return nil
+	})
+}
+
+// ready validates the bound stream or creates the stream, if configured,
+// once before the store is first used.
+func (s *EventStore) ready(ctx context.Context) error {
+	if !s.bind && s.autoCreate == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.checked {
+		return nil
+	}
+
+	info, err := s.rt.js.StreamInfo(s.stream, nats.Context(ctx))
+	if s.autoCreate != nil {
+		switch {
+		case errors.Is(err, nats.ErrStreamNotFound):
+			err = s.Create(s.autoCreate)
+		case err == nil && s.shared:
+			// The shared stream exists, but the store has not been added.
+			if own, _ := s.ownSubjects(info.Config.Subjects); len(own) == 0 {
+				err = s.Create(s.autoCreate)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("rita: create stream %q: %w", s.stream, err)
+		}
+		s.checked = true
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("rita: bind stream %q: %w", s.stream, err)
+	}
+
+	if err := s.validateBinding(&info.Config); err != nil {
+		return fmt.Errorf("%w: bind stream %q: %s", ErrIncompatibleConfig, s.stream, err)
+	}
+
+	s.checked = true
+	return nil
+}
+
+// validateBinding checks the settings of a stream which the store depends
+// on.
+func (s *EventStore) validateBinding(sc *nats.StreamConfig) error {
+	switch {
+	case sc.Retention != nats.LimitsPolicy:
+		return errors.New("retention must be limits")
+	case sc.NoAck:
+		return errors.New("acks must be enabled")
+	case len(sc.Subjects) == 0 && sc.Mirror == nil && len(sc.Sources) == 0:
+		return errors.New("no subjects")
+	}
+
+	if s.shared {
+		if own, _ := s.ownSubjects(sc.Subjects); len(own) == 0 {
+			return fmt.Errorf("subject %q not bound", s.subject(s.filterSubject()))
+		}
+	}
+
+	return nil
+}
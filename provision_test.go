@@ -0,0 +1,111 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreBind(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreBind())
+	is.NoErr(err)
+
+	is.Err(es.Create(nil), ErrExternallyManaged)
+	is.Err(es.Update(nil), ErrExternallyManaged)
+	is.Err(es.Delete(), ErrExternallyManaged)
+
+	ctx := context.Background()
+
+	_, _, err = es.Load(ctx, "orders.1")
+	is.Err(err, nats.ErrStreamNotFound)
+
+	// Provisioned externally with an incompatible retention.
+	_, err = r.js.AddStream(&nats.StreamConfig{
+		Name:      "orders",
+		Subjects:  []string{"orders.>"},
+		Storage:   nats.MemoryStorage,
+		Retention: nats.WorkQueuePolicy,
+	})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.Err(err, ErrIncompatibleConfig)
+
+	is.NoErr(r.js.DeleteStream("orders"))
+	_, err = r.js.AddStream(&nats.StreamConfig{
+		Name:     "orders",
+		Subjects: []string{"orders.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	// A shared stream must include the subjects of the store.
+	returns, err := r.EventStore("returns", EventStoreStream("orders"), EventStoreBind())
+	is.NoErr(err)
+
+	_, _, err = returns.Load(ctx, "returns.1")
+	is.Err(err, ErrIncompatibleConfig)
+}
+
+func TestEventStoreAutoCreate(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	_, err = r.EventStore("orders", EventStoreBind(), EventStoreAutoCreate(nil))
+	is.True(err != nil)
+
+	config := &EventStoreConfig{Storage: nats.MemoryStorage}
+
+	es, err := r.EventStore("orders", EventStoreAutoCreate(config))
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+
+	info, err := r.js.StreamInfo("orders")
+	is.NoErr(err)
+	is.Equal(info.Config.Storage, nats.MemoryStorage)
+
+	// Stores are added to a shared stream on first append.
+	for _, name := range []string{"returns", "invoices"} {
+		es, err := r.EventStore(name, EventStoreStream("shared"), EventStoreAutoCreate(config))
+		is.NoErr(err)
+
+		_, err = es.Append(ctx, name+".1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+		is.NoErr(err)
+	}
+
+	info, err = r.js.StreamInfo("shared")
+	is.NoErr(err)
+	is.Equal(info.Config.Subjects, []string{"returns.>", "invoices.>"})
+	is.Equal(info.State.Msgs, uint64(2))
+}
@@ -0,0 +1,243 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	quarantineStream = "rita_quarantine"
+
+	quarantineErrorHdr   = "rita-quarantine-error"
+	quarantineSubjectHdr = "rita-quarantine-subject"
+	quarantineSeqHdr     = "rita-quarantine-seq"
+	quarantineIDHdr      = "rita-quarantine-id"
+)
+
+// QuarantinedEvent is an event copied to the quarantine stream by the
+// DecodeQuarantine policy.
+type QuarantinedEvent struct {
+	// Subject and Sequence of the event in the store.
+	Subject  string
+	Sequence uint64
+
+	// Header and Data of the message.
+	Header nats.Header
+	Data   []byte
+
+	// Error is the unpack error of the event.
+	Error string
+
+	// Time the event was quarantined.
+	Time time.Time
+
+	// qseqs are the sequences of the copies in the quarantine stream.
+	qseqs []uint64
+}
+
+// quarantineSubject returns the subject of the quarantined events of the
+// store.
+func (s *EventStore) quarantineSubject() string {
+	return s.rt.subject(fmt.Sprintf("rita.quarantine.%s", s.name))
+}
+
+// quarantineStream returns the name of the quarantine stream, creating it if
+// it does not exist. The stream is shared by all stores of the context.
+func (s *EventStore) quarantineStream() (string, error) {
+	name := s.rt.resourceName(quarantineStream)
+
+	_, err := s.rt.js.StreamInfo(name)
+	if err == nil || !errors.Is(err, nats.ErrStreamNotFound) {
+		return name, err
+	}
+
+	sc := &nats.StreamConfig{
+		Name:     name,
+		Subjects: []string{s.rt.subject("rita.quarantine.>")},
+		Storage:  nats.FileStorage,
+	}
+
+	// Quarantined events are stored like the events of the store.
+	pc, err := s.placement()
+	if err != nil {
+		return "", err
+	}
+	sc.Storage = pc.Storage
+	sc.Replicas = pc.Replicas
+	sc.Placement = pc.Placement
+
+	_, err = s.rt.js.AddStream(sc)
+	return name, err
+}
+
+// purgeQuarantined purges the events of the store from the quarantine stream.
+func (s *EventStore) purgeQuarantined() error {
+	stream := s.rt.resourceName(quarantineStream)
+
+	_, err := s.rt.js.StreamInfo(stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.rt.purge(stream, s.quarantineSubject())
+}
+
+// quarantineDecodeError copies the message of the event which failed to
+// unpack to the quarantine stream along with the error. The event remains in
+// the store. Copies of the same event are de-duplicated within the duplicate
+// window of the quarantine stream.
+func (s *EventStore) quarantineDecodeError(ctx context.Context, de *DecodeError) error {
+	if _, err := s.quarantineStream(); err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(s.quarantineSubject())
+	for k, v := range de.Header {
+		msg.Header[k] = v
+	}
+	// The event ID is replaced to de-duplicate copies of the event.
+	msg.Header.Set(quarantineIDHdr, de.Header.Get(nats.MsgIdHdr))
+	msg.Header.Set(nats.MsgIdHdr, fmt.Sprintf("%s.%d", s.name, de.Sequence))
+	msg.Header.Set(quarantineErrorHdr, de.Err.Error())
+	msg.Header.Set(quarantineSubjectHdr, de.Subject)
+	msg.Header.Set(quarantineSeqHdr, strconv.FormatUint(de.Sequence, 10))
+	msg.Data = de.Data
+
+	_, err := s.rt.js.PublishMsg(msg, nats.Context(ctx))
+	return err
+}
+
+// Quarantined returns the events of the store copied to the quarantine
+// stream by the DecodeQuarantine policy in the order they were quarantined.
+// Copies of the same event are returned once.
+func (s *EventStore) Quarantined(ctx context.Context) ([]*QuarantinedEvent, error) {
+	stream := s.rt.resourceName(quarantineStream)
+
+	_, err := s.rt.js.StreamInfo(stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := s.rt.lastMsg(ctx, stream, s.quarantineSubject())
+	if err != nil {
+		return nil, err
+	}
+	if last.Sequence == 0 {
+		return nil, nil
+	}
+
+	sub, err := s.rt.js.SubscribeSync(s.quarantineSubject(),
+		nats.OrderedConsumer(),
+		nats.DeliverAll(),
+		nats.BindStream(stream),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe() //nolint
+
+	var (
+		events []*QuarantinedEvent
+		index  = make(map[uint64]*QuarantinedEvent)
+	)
+
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		md, err := msg.Metadata()
+		if err != nil {
+			return nil, err
+		}
+
+		seq, _ := strconv.ParseUint(msg.Header.Get(quarantineSeqHdr), 10, 64)
+
+		// Copies of the same event quarantined outside of the duplicate
+		// window are combined.
+		if e, ok := index[seq]; ok && seq > 0 {
+			e.qseqs = append(e.qseqs, md.Sequence.Stream)
+		} else {
+			e := &QuarantinedEvent{
+				Subject:  msg.Header.Get(quarantineSubjectHdr),
+				Sequence: seq,
+				Header:   make(nats.Header),
+				Data:     msg.Data,
+				Error:    msg.Header.Get(quarantineErrorHdr),
+				Time:     md.Timestamp,
+				qseqs:    []uint64{md.Sequence.Stream},
+			}
+			for k, v := range msg.Header {
+				switch k {
+				case quarantineErrorHdr, quarantineSubjectHdr, quarantineSeqHdr, quarantineIDHdr, nats.MsgIdHdr:
+				default:
+					e.Header[k] = v
+				}
+			}
+			if id := msg.Header.Get(quarantineIDHdr); id != "" {
+				e.Header.Set(nats.MsgIdHdr, id)
+			}
+			index[seq] = e
+			events = append(events, e)
+		}
+
+		if md.Sequence.Stream >= last.Sequence {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// Redrive unpacks each quarantined event of the store again, for example after
+// the missing type or codec was registered, and passes the events which now
+// unpack to the function in the order they were quarantined. Events passed to
+// the function without error are removed from the quarantine stream. Events
+// which still fail to unpack remain quarantined. The number of events removed
+// is returned.
+func (s *EventStore) Redrive(ctx context.Context, fn func(*Event) error) (int, error) {
+	events, err := s.Quarantined(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	stream := s.rt.resourceName(quarantineStream)
+
+	var n int
+	for _, e := range events {
+		event, err := s.rt.unpackEvent(&nats.Msg{
+			Subject: s.subject(e.Subject),
+			Header:  e.Header,
+			Data:    e.Data,
+		}, false)
+		if err != nil {
+			continue
+		}
+		event.Subject = e.Subject
+		event.Sequence = e.Sequence
+
+		if err := fn(event); err != nil {
+			return n, err
+		}
+
+		for _, seq := range e.qseqs {
+			if err := s.rt.js.DeleteMsg(stream, seq, nats.Context(ctx)); err != nil {
+				return n, err
+			}
+		}
+		n++
+	}
+
+	return n, nil
+}
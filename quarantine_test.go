@@ -0,0 +1,101 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+type OrderCancelled struct {
+	ID string
+}
+
+func TestDecodeQuarantine(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+	js, _ := nc.JetStream()
+
+	tr := newOrderTypes(t)
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	// Event of a type not yet registered by this version.
+	msg := nats.NewMsg("orders.1")
+	msg.Header.Set(nats.MsgIdHdr, "cancelled")
+	msg.Header.Set(eventTypeHdr, "order-cancelled")
+	msg.Header.Set(eventCodecHdr, "json")
+	msg.Header.Set(eventTimeHdr, "2022-05-01T00:00:00Z")
+	msg.Data = []byte(`{"ID":"1"}`)
+	_, err = js.PublishMsg(msg)
+	is.NoErr(err)
+
+	// Nothing is quarantined yet.
+	qs, err := es.Quarantined(ctx)
+	is.NoErr(err)
+	is.Equal(len(qs), 0)
+
+	var errs []*DecodeError
+	events, _, err := es.Load(ctx, "orders.1", OnDecodeError(DecodeQuarantine, func(err *DecodeError) {
+		errs = append(errs, err)
+	}))
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(len(errs), 1)
+
+	// Reading again does not quarantine a second copy.
+	_, _, err = es.Load(ctx, "orders.1", OnDecodeError(DecodeQuarantine, nil))
+	is.NoErr(err)
+
+	qs, err = es.Quarantined(ctx)
+	is.NoErr(err)
+	is.Equal(len(qs), 1)
+	is.Equal(qs[0].Subject, "orders.1")
+	is.Equal(qs[0].Sequence, uint64(2))
+	is.Equal(qs[0].Header.Get(nats.MsgIdHdr), "cancelled")
+	is.Equal(string(qs[0].Data), `{"ID":"1"}`)
+	is.True(qs[0].Error != "")
+
+	// The event remains in the store.
+	_, _, err = es.Load(ctx, "orders.1")
+	is.Err(err, nil)
+
+	// Still failing, so it remains quarantined.
+	n, err := es.Redrive(ctx, func(*Event) error { return nil })
+	is.NoErr(err)
+	is.Equal(n, 0)
+
+	is.NoErr(tr.Add("order-cancelled", &types.Type{
+		Init: func() any { return &OrderCancelled{} },
+	}))
+
+	var redriven []*Event
+	n, err = es.Redrive(ctx, func(e *Event) error {
+		redriven = append(redriven, e)
+		return nil
+	})
+	is.NoErr(err)
+	is.Equal(n, 1)
+	is.Equal(redriven[0].ID, "cancelled")
+	is.Equal(redriven[0].Sequence, uint64(2))
+	is.Equal(redriven[0].Data, &OrderCancelled{ID: "1"})
+
+	qs, err = es.Quarantined(ctx)
+	is.NoErr(err)
+	is.Equal(len(qs), 0)
+}
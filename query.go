@@ -0,0 +1,268 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrNoQueryHandler = errors.New("rita: no query handler")
+)
+
+// Query is a request for data from a read model.
+type Query struct {
+	// Type is a unique name for the query. This can be omitted if a type
+	// registry is being used, otherwise it must be set explicitly to
+	// identify the encoded data.
+	Type string
+
+	// Data is the query data. This must be a byte slice (pre-encoded) or a
+	// value of a type registered in the type registry.
+	Data any
+
+	// Meta is application-defined metadata about the query.
+	Meta map[string]string
+}
+
+// QueryHandler handles a query and returns the result, which must be a byte
+// slice (pre-encoded) or a value of a type registered in the type registry.
+type QueryHandler func(ctx context.Context, query *Query) (any, error)
+
+// queryReply is the reply to a query sent over NATS.
+type queryReply struct {
+	Type  string `json:"type,omitempty"`
+	Codec string `json:"codec,omitempty"`
+	Data  []byte `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type queryBusOpts struct {
+	prefix string
+}
+
+type queryBusOptFn func(o *queryBusOpts) error
+
+func (f queryBusOptFn) queryBusOpt(o *queryBusOpts) error {
+	return f(o)
+}
+
+// QueryBusOption is an option for a query bus.
+type QueryBusOption interface {
+	queryBusOpt(o *queryBusOpts) error
+}
+
+// QuerySubjectPrefix sets the prefix of the subjects queries are sent on.
+// Default is "rita.queries".
+func QuerySubjectPrefix(prefix string) QueryBusOption {
+	return queryBusOptFn(func(o *queryBusOpts) error {
+		o.prefix = prefix
+		return nil
+	})
+}
+
+// QueryBus serves queries over NATS request/reply, mirroring commands sent
+// to actors. Queries and results are encoded using the type registry. Each
+// query type is sent on the subject "{prefix}.{type}".
+type QueryBus struct {
+	rt     *Rita
+	prefix string
+
+	mu       sync.RWMutex
+	handlers map[string]QueryHandler
+	sub      *nats.Subscription
+}
+
+// QueryBus returns a query bus.
+func (r *Rita) QueryBus(opts ...QueryBusOption) (*QueryBus, error) {
+	o := queryBusOpts{
+		prefix: "rita.queries",
+	}
+
+	for _, opt := range opts {
+		if err := opt.queryBusOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	return &QueryBus{
+		rt:       r,
+		prefix:   r.subject(o.prefix),
+		handlers: make(map[string]QueryHandler),
+	}, nil
+}
+
+// Handle registers the handler for the query type, replacing an existing
+// handler.
+func (b *QueryBus) Handle(queryType string, h QueryHandler) error {
+	if err := validateSubject(queryType, false); err != nil {
+		return fmt.Errorf("rita: query type: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[queryType] = h
+	return nil
+}
+
+// HandleQuery registers a typed handler for the query type. Queries whose
+// data is not of type Q are answered with an error.
+func HandleQuery[Q, R any](b *QueryBus, queryType string, fn func(ctx context.Context, query Q) (R, error)) error {
+	return b.Handle(queryType, func(ctx context.Context, query *Query) (any, error) {
+		q, ok := query.Data.(Q)
+		if !ok {
+			return nil, fmt.Errorf("rita: query %q has data of type %T", query.Type, query.Data)
+		}
+		return fn(ctx, q)
+	})
+}
+
+// Subject returns the subject of the query type.
+func (b *QueryBus) Subject(queryType string) string {
+	return fmt.Sprintf("%s.%s", b.prefix, queryType)
+}
+
+// Ask sends the query and returns the decoded result. If the handler fails,
+// an error with the message of the handler error is returned.
+func (b *QueryBus) Ask(ctx context.Context, query *Query) (any, error) {
+	t, err := b.rt.resolveType(query.Type, query.Data)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	query.Type = t
+
+	data, codecName, err := b.rt.encodeData(query.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := nats.NewMsg(b.Subject(t))
+	msg.Data = data
+	msg.Header.Set(eventTypeHdr, t)
+	msg.Header.Set(eventCodecHdr, codecName)
+	packMeta(msg.Header, query.Meta)
+
+	rep, err := b.rt.nc.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var qr queryReply
+	if err := json.Unmarshal(rep.Data, &qr); err != nil {
+		return nil, fmt.Errorf("unpack: failed to decode query reply: %w", err)
+	}
+
+	if qr.Error != "" {
+		if qr.Error == ErrNoQueryHandler.Error() {
+			return nil, ErrNoQueryHandler
+		}
+		return nil, errors.New(qr.Error)
+	}
+
+	if qr.Data == nil && qr.Codec == "" {
+		return nil, nil
+	}
+
+	return b.rt.decodeData(qr.Codec, qr.Type, 0, qr.Data)
+}
+
+// Listen subscribes to the subjects of all query types in a queue group, so
+// queries are load balanced across instances. The subscription is drained
+// when the context is done.
+func (b *QueryBus) Listen(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.sub != nil {
+		return errors.New("rita: query bus already listening")
+	}
+
+	sub, err := b.rt.nc.QueueSubscribe(b.prefix+".>", b.prefix, func(msg *nats.Msg) {
+		b.handle(ctx, msg)
+	})
+	if err != nil {
+		return err
+	}
+	b.sub = sub
+
+	go func() {
+		<-ctx.Done()
+		_ = b.Close()
+	}()
+
+	return nil
+}
+
+func (b *QueryBus) handle(ctx context.Context, msg *nats.Msg) {
+	data, err := b.answer(ctx, msg)
+	if err != nil {
+		data, _ = json.Marshal(&queryReply{Error: err.Error()})
+	}
+	_ = msg.Respond(data)
+}
+
+// answer handles the query message and returns the encoded reply.
+func (b *QueryBus) answer(ctx context.Context, msg *nats.Msg) ([]byte, error) {
+	qt := strings.TrimPrefix(msg.Subject, b.prefix+".")
+
+	b.mu.RLock()
+	h, ok := b.handlers[qt]
+	b.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNoQueryHandler
+	}
+
+	data, err := b.rt.decodeData(msg.Header.Get(eventCodecHdr), qt, 0, msg.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := h(ctx, &Query{
+		Type: qt,
+		Data: data,
+		Meta: unpackMeta(msg.Header),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return json.Marshal(&queryReply{})
+	}
+
+	var rep queryReply
+	if b.rt.types != nil {
+		rep.Type, err = b.rt.types.Lookup(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rep.Data, rep.Codec, err = b.rt.encodeData(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&rep)
+}
+
+// Close drains the subscription created by Listen.
+func (b *QueryBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.sub == nil {
+		return nil
+	}
+
+	err := b.sub.Drain()
+	b.sub = nil
+	return err
+}
@@ -0,0 +1,102 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+type GetOrder struct {
+	ID string
+}
+
+type OrderView struct {
+	ID      string
+	Shipped bool
+}
+
+func newQueryTypes(t *testing.T) *types.Registry {
+	tr, err := types.NewRegistry(map[string]*types.Type{
+		"get-order": {
+			Init: func() any { return &GetOrder{} },
+		},
+		"order-view": {
+			Init: func() any { return &OrderView{} },
+		},
+		"place-order": {
+			Init: func() any { return &PlaceOrder{} },
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestQueryBus(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newQueryTypes(t)))
+	is.NoErr(err)
+
+	qb, err := r.QueryBus()
+	is.NoErr(err)
+
+	is.True(qb.Handle("get.*", nil) != nil)
+
+	err = HandleQuery(qb, "get-order", func(ctx context.Context, q *GetOrder) (*OrderView, error) {
+		if q.ID == "missing" {
+			return nil, errors.New("order not found")
+		}
+		return &OrderView{ID: q.ID, Shipped: true}, nil
+	})
+	is.NoErr(err)
+
+	// Data of the wrong type is rejected by the typed handler.
+	err = HandleQuery(qb, "place-order", func(ctx context.Context, q *GetOrder) (*OrderView, error) {
+		return nil, nil
+	})
+	is.NoErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	is.NoErr(qb.Listen(ctx))
+
+	v, err := qb.Ask(ctx, &Query{Data: &GetOrder{ID: "1"}})
+	is.NoErr(err)
+	is.Equal(v, &OrderView{ID: "1", Shipped: true})
+
+	_, err = qb.Ask(ctx, &Query{Data: &GetOrder{ID: "missing"}})
+	is.True(err != nil)
+	is.Equal(err.Error(), "order not found")
+
+	_, err = qb.Ask(ctx, &Query{Data: &PlaceOrder{ID: "1"}})
+	is.True(err != nil)
+
+	other, err := New(nc, TypeRegistry(newQueryTypes(t)))
+	is.NoErr(err)
+
+	ob, err := other.QueryBus(QuerySubjectPrefix("other.queries"))
+	is.NoErr(err)
+	is.NoErr(ob.Handle("order-view", func(ctx context.Context, q *Query) (any, error) {
+		return nil, nil
+	}))
+	is.NoErr(ob.Listen(ctx))
+
+	_, err = ob.Ask(ctx, &Query{Data: &GetOrder{ID: "1"}})
+	is.Err(err, ErrNoQueryHandler)
+
+	v, err = ob.Ask(ctx, &Query{Data: &OrderView{ID: "1"}})
+	is.NoErr(err)
+	is.True(v == nil)
+}
@@ -0,0 +1,198 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a token bucket refilled at a rate of tokens per second up to
+// the burst. Tokens are reserved ahead, so a reservation exceeding the
+// available tokens waits until they are refilled.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// reserve takes n tokens and returns the time to wait until they are
+// available.
+func (b *tokenBucket) reserve(now time.Time, n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns n reserved tokens.
+func (b *tokenBucket) cancel(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += float64(n)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// subjectLimit is a rate limit of the subjects with the prefix.
+type subjectLimit struct {
+	prefix string
+	bucket *tokenBucket
+}
+
+// RateLimitStats are the stats of appends throttled by the rate limits of a
+// store by this instance.
+type RateLimitStats struct {
+	// Throttled is the number of appends which waited.
+	Throttled uint64
+
+	// Canceled is the number of appends which failed since the context was
+	// done while waiting.
+	Canceled uint64
+
+	// Wait is the total time appends waited.
+	Wait time.Duration
+}
+
+type rateLimiter struct {
+	store    *tokenBucket
+	subjects []*subjectLimit
+
+	mu    sync.Mutex
+	stats RateLimitStats
+}
+
+// wait blocks until the rate limits of the subject allow appending the
+// number of events or the context is done.
+func (l *rateLimiter) wait(ctx context.Context, subject string, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	var (
+		buckets []*tokenBucket
+		d       time.Duration
+	)
+	if l.store != nil {
+		buckets = append(buckets, l.store)
+	}
+	for _, sl := range l.subjects {
+		if strings.HasPrefix(subject, sl.prefix) {
+			buckets = append(buckets, sl.bucket)
+		}
+	}
+	for _, b := range buckets {
+		if w := b.reserve(now, n); w > d {
+			d = w
+		}
+	}
+
+	if d == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		l.observe(d, false)
+		return nil
+	case <-ctx.Done():
+		for _, b := range buckets {
+			b.cancel(n)
+		}
+		l.observe(time.Since(now), true)
+		return ctx.Err()
+	}
+}
+
+func (l *rateLimiter) observe(d time.Duration, canceled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.Throttled++
+	l.stats.Wait += d
+	if canceled {
+		l.stats.Canceled++
+	}
+}
+
+// EventStoreRateLimit limits the rate of events appended to the store by
+// this instance to the rate per second, allowing bursts of events up to the
+// burst. Appends exceeding the limit wait until the events are allowed or
+// the context is done, so bulk backfills and loops do not overwhelm the
+// replication of the stream. Default is no limit.
+func EventStoreRateLimit(rate float64, burst int) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if rate <= 0 || burst < 1 {
+			return errors.New("rita: rate limit and burst must be positive")
+		}
+		if o.rateLimit == nil {
+			o.rateLimit = &rateLimiter{}
+		}
+		o.rateLimit.store = newTokenBucket(rate, burst)
+		return nil
+	})
+}
+
+// EventStoreSubjectRateLimit limits the rate of events appended to the
+// subjects with the prefix, e.g. "orders.", like EventStoreRateLimit. The
+// limits of all matching prefixes and the store apply.
+func EventStoreSubjectRateLimit(prefix string, rate float64, burst int) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if prefix == "" {
+			return errors.New("rita: rate limit subject prefix required")
+		}
+		if rate <= 0 || burst < 1 {
+			return errors.New("rita: rate limit and burst must be positive")
+		}
+		if o.rateLimit == nil {
+			o.rateLimit = &rateLimiter{}
+		}
+		o.rateLimit.subjects = append(o.rateLimit.subjects, &subjectLimit{
+			prefix: prefix,
+			bucket: newTokenBucket(rate, burst),
+		})
+		return nil
+	})
+}
+
+// RateLimitStats returns the stats of appends throttled by the rate limits
+// of the store.
+func (s *EventStore) RateLimitStats() RateLimitStats {
+	if s.rateLimit == nil {
+		return RateLimitStats{}
+	}
+
+	s.rateLimit.mu.Lock()
+	defer s.rateLimit.mu.Unlock()
+	return s.rateLimit.stats
+}
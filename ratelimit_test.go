@@ -0,0 +1,74 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestTokenBucket(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	b := newTokenBucket(10, 2)
+	now := time.Now()
+
+	is.Equal(b.reserve(now, 2), time.Duration(0))
+	is.Equal(b.reserve(now, 1), 100*time.Millisecond)
+
+	// Refilled after the wait.
+	is.Equal(b.reserve(now.Add(300*time.Millisecond), 1), time.Duration(0))
+
+	b.cancel(10)
+	is.Equal(b.tokens, float64(2))
+}
+
+func TestEventStoreRateLimit(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	_, err = r.EventStore("orders", EventStoreRateLimit(0, 1))
+	is.Err(err, nil)
+	_, err = r.EventStore("orders", EventStoreSubjectRateLimit("", 1, 1))
+	is.Err(err, nil)
+
+	es, err := r.EventStore("orders",
+		EventStoreRateLimit(1000, 10),
+		EventStoreSubjectRateLimit("orders.1", 20, 1),
+	)
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	// Other subjects are only limited by the store limit.
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+	is.Equal(es.RateLimitStats().Throttled, uint64(0))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+		is.NoErr(err)
+	}
+	is.True(time.Since(start) >= 80*time.Millisecond)
+
+	stats := es.RateLimitStats()
+	is.Equal(stats.Throttled, uint64(2))
+	is.True(stats.Wait > 0)
+
+	// Waiting is bound by the context.
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, Timeout(time.Millisecond))
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	is.Equal(es.RateLimitStats().Canceled, uint64(1))
+}
@@ -0,0 +1,271 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Dispatcher dispatches a command to the entity identified by the subject.
+// Actors implements Dispatcher.
+type Dispatcher interface {
+	Send(ctx context.Context, subject string, cmd *Command) ([]*Event, uint64, error)
+}
+
+// Dispatch is a command to be dispatched to an entity subject.
+type Dispatch struct {
+	Subject string
+	Command *Command
+}
+
+// ReactFunc returns the commands to dispatch in reaction to an event.
+type ReactFunc func(ctx context.Context, event *Event) ([]*Dispatch, error)
+
+type reactorOpts struct {
+	subject    string
+	types      map[string]struct{}
+	batch      int
+	retryDelay time.Duration
+	onError    func(event *Event, err error)
+	trust      bool
+}
+
+type reactorOptFn func(o *reactorOpts) error
+
+func (f reactorOptFn) reactorOpt(o *reactorOpts) error {
+	return f(o)
+}
+
+// ReactorOption is an option for a reactor.
+type ReactorOption interface {
+	reactorOpt(o *reactorOpts) error
+}
+
+// ReactTo limits the reactor to events of the given types. Other events are
+// skipped. Default is all event types.
+func ReactTo(types ...string) ReactorOption {
+	return reactorOptFn(func(o *reactorOpts) error {
+		if o.types == nil {
+			o.types = make(map[string]struct{})
+		}
+		for _, t := range types {
+			o.types[t] = struct{}{}
+		}
+		return nil
+	})
+}
+
+// ReactorSubject sets the subject filter of events the reactor consumes.
+// Default is all subjects of the store.
+func ReactorSubject(subject string) ReactorOption {
+	return reactorOptFn(func(o *reactorOpts) error {
+		o.subject = subject
+		return nil
+	})
+}
+
+// ReactorBatch sets the max number of events fetched at a time. Default is 100.
+func ReactorBatch(n int) ReactorOption {
+	return reactorOptFn(func(o *reactorOpts) error {
+		if n < 1 {
+			return fmt.Errorf("reactor: batch must be positive")
+		}
+		o.batch = n
+		return nil
+	})
+}
+
+// ReactorRetryDelay sets the delay before an event is redelivered after the
+// reaction or a dispatch fails. Default is 1 second.
+func ReactorRetryDelay(d time.Duration) ReactorOption {
+	return reactorOptFn(func(o *reactorOpts) error {
+		o.retryDelay = d
+		return nil
+	})
+}
+
+// ReactorErrorHandler sets a function which is called when a reaction fails,
+// a dispatched command is rejected, or a dispatch fails.
+func ReactorErrorHandler(fn func(event *Event, err error)) ReactorOption {
+	return reactorOptFn(func(o *reactorOpts) error {
+		o.onError = fn
+		return nil
+	})
+}
+
+// ReactorTrustIdentity propagates the actor and claims stamped in the
+// metadata of events to the context of the react function, so they are
+// stamped on the dispatched commands. Only enable it if every writer of the
+// store is trusted to assert an identity. The claims of the reactor context
+// always take precedence. Default is to ignore the identity of events.
+func ReactorTrustIdentity() ReactorOption {
+	return reactorOptFn(func(o *reactorOpts) error {
+		o.trust = true
+		return nil
+	})
+}
+
+// Reactor reacts to events appended to a store by dispatching commands, for
+// example, when an order is placed then reserve inventory. The position of the
+// reactor is checkpointed with a durable consumer, so it resumes where it left
+// off after restarts. Commands without an ID are given an ID derived from the
+// event ID, and events decided by a command are given IDs derived from the
+// command ID, so re-dispatching after a redelivery results in events which are
+// de-duplicated by the store. Events which fail to decode are quarantined, see
+// Quarantined, while other failures to read an event are retried.
+type Reactor struct {
+	es         *EventStore
+	name       string
+	react      ReactFunc
+	dispatcher Dispatcher
+	opts       reactorOpts
+}
+
+func (r *Reactor) error(event *Event, err error) {
+	if r.opts.onError != nil {
+		r.opts.onError(event, err)
+	}
+}
+
+// handle reacts to an event given the messages it is comprised of and the
+// assembled message.
+func (r *Reactor) handle(ctx context.Context, parts []*nats.Msg, msg *nats.Msg) {
+	dl := &Delivery{msgs: parts}
+
+	// Events which failed to decode are quarantined, but other failures,
+	// e.g. of loading claimed data, are retried.
+	event, err := r.es.unpackEvent(msg, false)
+	if err != nil {
+		r.error(nil, err)
+		_ = r.es.reject(ctx, parts, err, r.opts.retryDelay)
+		return
+	}
+
+	if r.opts.types != nil {
+		if _, ok := r.opts.types[event.Type]; !ok {
+			_ = dl.Ack()
+			return
+		}
+	}
+
+	if r.opts.trust {
+		ctx = identityContext(ctx, event.Meta)
+	}
+
+	ds, err := r.react(ctx, event)
+	if err != nil {
+		r.error(event, err)
+		_ = dl.Nak(r.opts.retryDelay)
+		return
+	}
+
+	for i, d := range ds {
+		if d.Command.ID == "" {
+			d.Command.ID = fmt.Sprintf("%s.%d", event.ID, i)
+		}
+
+		_, _, err := r.dispatcher.Send(ctx, d.Subject, d.Command)
+		if err != nil {
+			r.error(event, err)
+
+			// Rejections are an outcome of the dispatch, not a failure.
+			var rej *Rejection
+			if !errors.As(err, &rej) {
+				_ = dl.Nak(r.opts.retryDelay)
+				return
+			}
+		}
+	}
+
+	_ = dl.Ack()
+}
+
+// Run consumes events and dispatches commands until the context is done.
+func (r *Reactor) Run(ctx context.Context) error {
+	if err := r.es.authorize(ctx, OpWatch, r.opts.subject, nil); err != nil {
+		return err
+	}
+
+	sub, err := r.es.bindDurable(&nats.ConsumerConfig{
+		Durable:       r.name,
+		FilterSubject: r.opts.subject,
+		AckPolicy:     nats.AckExplicitPolicy,
+		DeliverPolicy: nats.DeliverAllPolicy,
+	})
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe() //nolint
+
+	asm := newAssembler()
+
+	return fetchLoop(ctx, sub, r.opts.batch, func(msg *nats.Msg) error {
+		parts, amsg, err := asm.add(msg)
+		if err != nil {
+			r.error(nil, err)
+			_ = msg.Term()
+			return nil
+		}
+
+		if amsg != nil {
+			r.handle(ctx, parts, amsg)
+		}
+		return nil
+	})
+}
+
+// fetchLoop fetches batches of messages from a pull subscription and passes
+// each message to the handler until the context is done or the handler
+// returns an error.
+func fetchLoop(ctx context.Context, sub *nats.Subscription, batch int, handle func(msg *nats.Msg) error) error {
+	for {
+		fctx, cancel := context.WithTimeout(ctx, time.Second)
+		msgs, err := sub.Fetch(batch, nats.Context(fctx))
+		cancel()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+				continue
+			}
+			return err
+		}
+
+		for _, msg := range msgs {
+			if err := handle(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Reactor returns a reactor which consumes events from the store and
+// dispatches commands returned by the react function. The name is used as
+// the durable consumer name and must be unique per store.
+func (s *EventStore) Reactor(name string, react ReactFunc, dispatcher Dispatcher, opts ...ReactorOption) (*Reactor, error) {
+	o := reactorOpts{
+		subject:    s.filterSubject(),
+		batch:      100,
+		retryDelay: time.Second,
+	}
+
+	for _, opt := range opts {
+		if err := opt.reactorOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Reactor{
+		es:         s,
+		name:       name,
+		react:      react,
+		dispatcher: dispatcher,
+		opts:       o,
+	}, nil
+}
@@ -0,0 +1,183 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestReactor(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	err = es.Create(&EventStoreConfig{
+		Storage: nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	actors, err := es.Actors(func() Entity { return &Order{} })
+	is.NoErr(err)
+
+	// When an order is placed, ship it.
+	react := func(ctx context.Context, event *Event) ([]*Dispatch, error) {
+		e := event.Data.(*OrderPlaced)
+		return []*Dispatch{{
+			Subject: event.Subject,
+			Command: &Command{Data: &ShipOrder{ID: e.ID}},
+		}}, nil
+	}
+
+	reactor, err := es.Reactor("shipper", react, actors, ReactTo("order-placed"))
+	is.NoErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = actors.Listen(ctx)
+	is.NoErr(err)
+
+	done := make(chan error)
+	go func() {
+		done <- reactor.Run(ctx)
+	}()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	var events []*Event
+	for i := 0; i < 50; i++ {
+		events, _, err = es.Load(ctx, "orders.1")
+		is.NoErr(err)
+		if len(events) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	is.Equal(len(events), 2)
+	is.Equal(events[1].Type, "order-shipped")
+	is.Equal(events[1].ID, events[0].ID+".0.0")
+
+	cancel()
+	is.NoErr(<-done)
+}
+
+func TestReactorUnpackError(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreClaimCheck(64))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Event of an unknown type.
+	msg := nats.NewMsg("orders.1")
+	msg.Header.Set(nats.MsgIdHdr, "cancelled")
+	msg.Header.Set(eventTypeHdr, "order-cancelled")
+	msg.Header.Set(eventCodecHdr, "json")
+	msg.Header.Set(eventTimeHdr, "2022-05-01T00:00:00Z")
+	msg.Data = []byte(`{"ID":"1"}`)
+	_, err = r.js.PublishMsg(msg)
+	is.NoErr(err)
+
+	// Event whose claimed data is temporarily unavailable.
+	id := strings.Repeat("2", 100)
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: id}}})
+	is.NoErr(err)
+
+	raw, err := r.js.GetMsg("orders", 2)
+	is.NoErr(err)
+	name := raw.Header.Get(eventClaimHdr)
+	is.True(name != "")
+
+	obj, err := es.payloads()
+	is.NoErr(err)
+	data, err := obj.GetBytes(name)
+	is.NoErr(err)
+	is.NoErr(r.js.DeleteObjectStore(es.payloadsBucket()))
+
+	actors, err := es.Actors(func() Entity { return &Order{} })
+	is.NoErr(err)
+	is.NoErr(actors.Listen(ctx))
+
+	react := func(ctx context.Context, event *Event) ([]*Dispatch, error) {
+		e := event.Data.(*OrderPlaced)
+		return []*Dispatch{{
+			Subject: event.Subject,
+			Command: &Command{Data: &ShipOrder{ID: e.ID}},
+		}}, nil
+	}
+
+	errs := make(chan error, 100)
+	reactor, err := es.Reactor("shipper", react, actors,
+		ReactTo("order-placed"),
+		ReactorRetryDelay(10*time.Millisecond),
+		ReactorErrorHandler(func(event *Event, err error) {
+			errs <- err
+		}),
+	)
+	is.NoErr(err)
+
+	done := make(chan error)
+	go func() {
+		done <- reactor.Run(ctx)
+	}()
+
+	// The undecodable event is quarantined and the other is retried.
+	var de *DecodeError
+	is.True(errors.As(<-errs, &de))
+	is.True(!errors.As(<-errs, &de))
+	is.True(!errors.As(<-errs, &de))
+
+	obj, err = r.js.CreateObjectStore(&nats.ObjectStoreConfig{
+		Bucket:  es.payloadsBucket(),
+		Storage: nats.MemoryStorage,
+	})
+	is.NoErr(err)
+	_, err = obj.PutBytes(name, data)
+	is.NoErr(err)
+
+	var events []*Event
+	for i := 0; i < 50; i++ {
+		events, _, err = es.Load(ctx, "orders.2")
+		is.NoErr(err)
+		if len(events) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	is.Equal(len(events), 2)
+
+	qs, err := es.Quarantined(ctx)
+	is.NoErr(err)
+	is.Equal(len(qs), 1)
+	is.Equal(qs[0].Sequence, uint64(1))
+
+	cancel()
+	is.NoErr(<-done)
+}
@@ -0,0 +1,321 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// redaction is the record of a redacted event. Only the envelope fields
+// needed to retain the place of the event are recorded.
+type redaction struct {
+	Subject      string    `json:"subject"`
+	Sequence     uint64    `json:"seq"`
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	Time         time.Time `json:"time"`
+	ValidFrom    time.Time `json:"valid_from,omitempty"`
+	ValidTo      time.Time `json:"valid_to,omitempty"`
+	RecordedTime time.Time `json:"recorded_time"`
+	Hash         string    `json:"hash,omitempty"`
+	PrevHash     string    `json:"prev_hash,omitempty"`
+	Clock        string    `json:"clock,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	RedactedTime time.Time `json:"redacted_time"`
+}
+
+func (r *redaction) event() *Event {
+	return &Event{
+		ID:           r.ID,
+		Type:         r.Type,
+		Time:         r.Time,
+		ValidFrom:    r.ValidFrom,
+		ValidTo:      r.ValidTo,
+		RecordedTime: r.RecordedTime,
+		Subject:      r.Subject,
+		Sequence:     r.Sequence,
+		Hash:         r.Hash,
+		prevHash:     r.PrevHash,
+		Redacted:     true,
+	}
+}
+
+// redactions are the redactions for a read ordered by sequence.
+type redactions []*redaction
+
+// next returns the sequence of the next redaction or zero if there are none.
+func (rs *redactions) next() uint64 {
+	if len(*rs) == 0 {
+		return 0
+	}
+	return (*rs)[0].Sequence
+}
+
+// emitBefore passes the events of the redactions before the sequence to
+// the callback.
+func (rs *redactions) emitBefore(seq uint64, fn func(*Event) error) error {
+	for len(*rs) > 0 && (*rs)[0].Sequence < seq {
+		r := (*rs)[0]
+		*rs = (*rs)[1:]
+		if err := fn(r.event()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redactionsBucket returns the name of the KV bucket for redaction records.
+func (s *EventStore) redactionsBucket() string {
+	return s.derivedName("redactions")
+}
+
+// redactionsKV returns the KV bucket of redaction records. If the bucket does
+// not exist, it is created if create is true, otherwise nil is returned.
+func (s *EventStore) redactionsKV(create bool) (nats.KeyValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.redactions != nil {
+		return s.redactions, nil
+	}
+
+	kv, err := s.rt.js.KeyValue(s.redactionsBucket())
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		if !create {
+			return nil, nil
+		}
+		kv, err = s.derivedKV("redactions")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.redactions = kv
+	return kv, nil
+}
+
+// redactionsFor returns the redactions of events matching the subject.
+func (s *EventStore) redactionsFor(ctx context.Context, subject string, o *loadOpts) (redactions, error) {
+	kv, err := s.redactionsKV(false)
+	if err != nil || kv == nil {
+		return nil, err
+	}
+
+	// Keys are the subject followed by the sequence.
+	keys := subject
+	if !strings.HasSuffix(subject, ">") {
+		keys = fmt.Sprintf("%s.*", subject)
+	}
+
+	w, err := kv.Watch(keys, nats.IgnoreDeletes(), nats.Context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer w.Stop() //nolint
+
+	var rs redactions
+	for e := range w.Updates() {
+		if e == nil {
+			break
+		}
+
+		var r redaction
+		if err := json.Unmarshal(e.Value(), &r); err != nil {
+			return nil, err
+		}
+
+		if o.afterSeq != nil && r.Sequence <= *o.afterSeq {
+			continue
+		}
+		rs = append(rs, &r)
+	}
+
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Sequence < rs[j].Sequence
+	})
+
+	return rs, nil
+}
+
+// Redact redacts the event at the sequence for compliance purposes, such as
+// a request to erase personal data. The messages of the event are deleted
+// from the stream, which overwrites the data in storage, and claimed data is
+// deleted from the object store. A record of the redaction is kept in a KV
+// bucket named "{stream}_redactions" so loads return a placeholder event
+// with Redacted set in place of the event, preserving the sequence of the
+// events. The clock and hash of the event are recorded, so an event appended
+// after a redacted last event continues the clock and the hash chain.
+// Checkpoints of models which may have applied the event are dropped. Where
+// event data is encrypted, the key should also be destroyed. Mirrors of the
+// store retain the event and must be redacted separately.
+func (s *EventStore) Redact(ctx context.Context, seq uint64, reason string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if s.backend != nil {
+		return ErrBackendUnsupported
+	}
+
+	msg, err := s.rt.js.GetMsg(s.stream, seq, nats.Context(ctx))
+	if err != nil {
+		return err
+	}
+
+	subject := contextUnsubject(s.context, msg.Subject)
+	if !strings.HasPrefix(subject, s.name+".") {
+		return fmt.Errorf("rita: sequence %d is not an event of the store", seq)
+	}
+
+	if err := s.authorize(ctx, OpErase, subject, nil); err != nil {
+		return err
+	}
+
+	if err := s.checkDeletes(ctx); err != nil {
+		return err
+	}
+
+	parts, err := s.chunkParts(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	// The envelope headers are on the first part and the sequence of the
+	// event is the last part.
+	first := parts[0]
+	if parts[len(parts)-1].Sequence != seq {
+		return fmt.Errorf("rita: sequence %d is not the last message of an event", seq)
+	}
+
+	r := redaction{
+		Subject:      subject,
+		Sequence:     seq,
+		ID:           first.Header.Get(nats.MsgIdHdr),
+		Type:         first.Header.Get(eventTypeHdr),
+		RecordedTime: first.Time,
+		Hash:         first.Header.Get(eventHashHdr),
+		PrevHash:     first.Header.Get(eventPrevHashHdr),
+		Clock:        first.Header.Get(eventClockHdr),
+		Reason:       reason,
+		RedactedTime: s.rt.clock.Now(),
+	}
+
+	r.Time, _ = time.Parse(eventTimeFormat, first.Header.Get(eventTimeHdr))
+	if v := first.Header.Get(eventValidFromHdr); v != "" {
+		r.ValidFrom, _ = time.Parse(eventTimeFormat, v)
+	}
+	if v := first.Header.Get(eventValidToHdr); v != "" {
+		r.ValidTo, _ = time.Parse(eventTimeFormat, v)
+	}
+
+	// The record is put first so the event is hidden even if deleting the
+	// messages fails.
+	kv, err := s.redactionsKV(true)
+	if err != nil {
+		return err
+	}
+
+	b, _ := json.Marshal(&r)
+	if _, err := kv.Put(fmt.Sprintf("%s.%d", subject, seq), b); err != nil {
+		return err
+	}
+
+	if name := first.Header.Get(eventClaimHdr); name != "" {
+		obj, err := s.payloads()
+		if err != nil {
+			return err
+		}
+		if err := obj.Delete(name); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return err
+		}
+	}
+
+	for _, p := range parts {
+		if err := s.rt.js.DeleteMsg(s.stream, p.Sequence, nats.Context(ctx)); err != nil {
+			return err
+		}
+	}
+
+	return s.dropCheckpoints(subject)
+}
+
+// chunkParts returns the messages of the chunked event the message is part
+// of, ordered by chunk. Messages which are not chunked are returned as is.
+func (s *EventStore) chunkParts(ctx context.Context, msg *nats.RawStreamMsg) ([]*nats.RawStreamMsg, error) {
+	hdr := msg.Header.Get(eventChunkHdr)
+	if hdr == "" {
+		return []*nats.RawStreamMsg{msg}, nil
+	}
+
+	idx, total, _ := strings.Cut(hdr, "/")
+	i, err1 := strconv.Atoi(idx)
+	n, err2 := strconv.Atoi(total)
+	if err1 != nil || err2 != nil || n < 1 || i < 0 || i >= n {
+		return nil, fmt.Errorf("rita: invalid chunk header %q", hdr)
+	}
+
+	id := msg.Header.Get(eventChunkIDHdr)
+	parts := make([]*nats.RawStreamMsg, n)
+	parts[i] = msg
+
+	last, err := s.rt.lastMsg(ctx, s.stream, msg.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chunks are appended to the subject of the event, so only the messages
+	// of the subject are read rather than every message of the stream.
+	sub, err := s.rt.js.SubscribeSync(msg.Subject,
+		nats.OrderedConsumer(),
+		nats.DeliverAll(),
+		nats.BindStream(s.stream),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe() //nolint
+
+	for found := 1; found < n && last.Sequence > 0; {
+		m, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		md, err := m.Metadata()
+		if err != nil {
+			return nil, err
+		}
+
+		idx, _, _ := strings.Cut(m.Header.Get(eventChunkHdr), "/")
+		j, err := strconv.Atoi(idx)
+		if err == nil && j >= 0 && j < n && parts[j] == nil &&
+			m.Header.Get(eventChunkIDHdr) == id && m.Header.Get(eventChunkHdr) == fmt.Sprintf("%d/%d", j, n) {
+			parts[j] = &nats.RawStreamMsg{
+				Subject:  m.Subject,
+				Sequence: md.Sequence.Stream,
+				Header:   m.Header,
+				Data:     m.Data,
+				Time:     md.Timestamp,
+			}
+			found++
+		}
+
+		if md.Sequence.Stream >= last.Sequence {
+			break
+		}
+	}
+
+	for _, p := range parts {
+		if p == nil {
+			return nil, fmt.Errorf("rita: incomplete chunked event %q", id)
+		}
+	}
+
+	return parts, nil
+}
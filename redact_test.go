@@ -0,0 +1,139 @@
+package rita
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreRedact(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreChunkSize(32))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	// A chunked event spanning multiple messages.
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: strings.Repeat("x", 64)}}})
+	is.NoErr(err)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 3)
+
+	shipped, chunked := events[1], events[2]
+
+	is.NoErr(es.Redact(ctx, shipped.Sequence, "erasure request"))
+	is.NoErr(es.Redact(ctx, chunked.Sequence, "erasure request"))
+
+	_, err = r.js.GetMsg("orders", shipped.Sequence)
+	is.Err(err, nats.ErrMsgNotFound)
+
+	info, err := r.js.StreamInfo("orders")
+	is.NoErr(err)
+	is.Equal(info.State.Msgs, uint64(2))
+
+	events, _, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 3)
+	is.Equal(events[0].Redacted, false)
+	is.Equal(events[1].Redacted, true)
+	is.Equal(events[1].ID, shipped.ID)
+	is.Equal(events[1].Type, "order-shipped")
+	is.Equal(events[1].Sequence, shipped.Sequence)
+	is.True(events[1].Data == nil)
+	is.Equal(events[2].Redacted, true)
+	is.Equal(events[2].Sequence, chunked.Sequence)
+
+	events, _, err = es.Load(ctx, "orders.*")
+	is.NoErr(err)
+	is.Equal(len(events), 4)
+	is.Equal(events[2].Subject, "orders.2")
+
+	events, _, err = es.Load(ctx, "orders.1", AfterSequence(shipped.Sequence))
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Sequence, chunked.Sequence)
+
+	// Only the last message of an event can be redacted.
+	err = es.Redact(ctx, chunked.Sequence-1, "")
+	is.True(err != nil)
+}
+
+func TestEventStoreRedactTail(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, seq, err := es.Execute(ctx, "orders.1", &Order{}, &Command{Data: &PlaceOrder{ID: "1"}})
+	is.NoErr(err)
+	_, seq, err = es.Execute(ctx, "orders.1", &Order{}, &Command{Data: &ShipOrder{ID: "1"}})
+	is.NoErr(err)
+
+	// The sequence to expect is of the last message which was not deleted.
+	is.NoErr(es.Redact(ctx, seq, "erasure request"))
+
+	n, err := es.Evolve(ctx, "orders.1", &Order{})
+	is.NoErr(err)
+	is.Equal(n, seq-1)
+
+	events, _, err := es.Execute(ctx, "orders.1", &Order{}, &Command{Data: &ShipOrder{ID: "1"}})
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	// Actors re-hydrate once their cached sequence conflicts.
+	actors, err := es.Actors(func() Entity { return &Order{} })
+	is.NoErr(err)
+
+	_, seq, err = actors.Execute(ctx, "orders.2", &Command{Data: &PlaceOrder{ID: "2"}})
+	is.NoErr(err)
+
+	// Every event of the subject is redacted.
+	is.NoErr(es.Redact(ctx, seq, "erasure request"))
+
+	_, _, err = actors.Execute(ctx, "orders.2", &Command{Data: &ShipOrder{ID: "2"}})
+	is.Err(err, errOrderNotPlaced)
+
+	events, _, err = actors.Execute(ctx, "orders.2", &Command{Data: &PlaceOrder{ID: "2"}})
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	events, _, err = es.Load(ctx, "orders.2")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.True(events[0].Redacted)
+}
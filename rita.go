@@ -1,7 +1,10 @@
 package rita
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,6 +15,10 @@ import (
 	"github.com/nats-io/nats.go"
 )
 
+var (
+	nameRegex = regexp.MustCompile(`^[\w-]+$`)
+)
+
 type ritaOption func(o *Rita) error
 
 func (f ritaOption) addOption(o *Rita) error {
@@ -47,25 +54,166 @@ func ID(id id.ID) RitaOption {
 	})
 }
 
+// Context sets the name of the bounded context the Rita instance belongs to.
+// All streams, KV buckets, and subjects Rita creates are namespaced by the
+// context, allowing multiple Rita-based services to coexist in one account.
+// Subjects passed to and returned from the API are not namespaced, the
+// mapping is transparent.
+func Context(name string) RitaOption {
+	return ritaOption(func(o *Rita) error {
+		if !nameRegex.MatchString(name) {
+			return fmt.Errorf("rita: context %q has invalid characters", name)
+		}
+		o.context = name
+		return nil
+	})
+}
+
+// StrictDecode fails unpacking data which contains fields unknown to the
+// registered type, for codecs which support it (json, msgpack, protobuf).
+// This is useful to catch drift between producers and consumers early, for
+// example, in staging.
+func StrictDecode() RitaOption {
+	return ritaOption(func(o *Rita) error {
+		o.strict = true
+		return nil
+	})
+}
+
+// AppendTimeout sets the default timeout of Append calls, including the
+// publishes of the events. By default, Append is bound only by the context
+// passed in. It can be overridden per call using Timeout.
+func AppendTimeout(d time.Duration) RitaOption {
+	return ritaOption(func(o *Rita) error {
+		if d < 0 {
+			return errors.New("rita: append timeout must be positive")
+		}
+		o.appendTimeout = d
+		return nil
+	})
+}
+
+// LoadTimeout sets the default timeout of Load and Evolve calls, which
+// otherwise wait for the next message until the context passed in is done.
+// It can be overridden per call using Timeout.
+func LoadTimeout(d time.Duration) RitaOption {
+	return ritaOption(func(o *Rita) error {
+		if d < 0 {
+			return errors.New("rita: load timeout must be positive")
+		}
+		o.loadTimeout = d
+		return nil
+	})
+}
+
+// APITimeout sets the timeout of JetStream API requests, such as getting the
+// last message of a subject or stream info. The default is five seconds.
+func APITimeout(d time.Duration) RitaOption {
+	return ritaOption(func(o *Rita) error {
+		if d <= 0 {
+			return errors.New("rita: api timeout must be positive")
+		}
+		o.apiTimeout = d
+		return nil
+	})
+}
+
+// AppendConn sets a separate connection used to publish appended events, so
+// publishes are not starved by consumers on the main connection, and the
+// connections can use different credentials. Other operations, including
+// storing claim checked data, use the main connection.
+func AppendConn(nc *nats.Conn) RitaOption {
+	return ritaOption(func(o *Rita) error {
+		if nc == nil {
+			return errors.New("rita: append connection required")
+		}
+		o.anc = nc
+		return nil
+	})
+}
+
 type Rita struct {
 	nc *nats.Conn
 	js nats.JetStreamContext
 
+	// anc is the connection used to publish appended events, which is nc
+	// unless set.
+	anc *nats.Conn
+	ajs nats.JetStreamContext
+
+	// ownsConn is true if the connections were opened by the instance.
+	ownsConn bool
+
+	appendTimeout time.Duration
+	loadTimeout   time.Duration
+	apiTimeout    time.Duration
+	defaults      Defaults
+
+	context string
+	strict  bool
+
+	// audit is true if the instance is for an audit log.
+	audit bool
+
+	authz  Authorizer
+	search SearchIndex
+	bus    *EventBus
+
 	id    id.ID
 	clock clock.Clock
 	types *types.Registry
 }
 
-// UnpackEvent unpacks an Event from a NATS message.
-func (r *Rita) UnpackEvent(msg *nats.Msg) (*Event, error) {
-	eventType := msg.Header.Get(eventTypeHdr)
-	codecName := msg.Header.Get(eventCodecHdr)
+// subject maps a subject into the context namespace.
+func (r *Rita) subject(s string) string {
+	return contextSubject(r.context, s)
+}
 
-	var (
-		data interface{}
-		err  error
-	)
+// unsubject maps a subject out of the context namespace.
+func (r *Rita) unsubject(s string) string {
+	return contextUnsubject(r.context, s)
+}
+
+func contextSubject(context, s string) string {
+	if context == "" {
+		return s
+	}
+	return fmt.Sprintf("%s.%s", context, s)
+}
+
+func contextUnsubject(context, s string) string {
+	if context == "" {
+		return s
+	}
+	return strings.TrimPrefix(s, context+".")
+}
+
+// resourceName maps the name of a stream or KV bucket into the context
+// namespace.
+func (r *Rita) resourceName(name string) string {
+	if r.context == "" {
+		return name
+	}
+	return fmt.Sprintf("%s_%s", r.context, name)
+}
+
+// encodeData marshals a value using the type registry codec, or assumes it is
+// pre-encoded binary if no registry is configured. The codec name is returned
+// so it can be recorded alongside the data.
+func (r *Rita) encodeData(v any) ([]byte, string, error) {
+	if r.types == nil {
+		b, err := codec.Binary.Marshal(v)
+		return b, codec.Binary.Name(), err
+	}
 
+	b, err := r.types.Marshal(v)
+	return b, r.types.Codec().Name(), err
+}
+
+// decodeData unmarshals data of the given type and version using the named
+// codec. Data of a prior version is migrated to the current version. A
+// version of zero is the current version.
+func (r *Rita) decodeData(codecName, typeName string, version int, b []byte) (any, error) {
 	c, ok := codec.Codecs[codecName]
 	if !ok {
 		return nil, fmt.Errorf("%w: %s", codec.ErrCodecNotRegistered, codecName)
@@ -73,22 +221,107 @@ func (r *Rita) UnpackEvent(msg *nats.Msg) (*Event, error) {
 
 	// No type registry, so assume byte slice.
 	if r.types == nil {
-		var b []byte
-		err = c.Unmarshal(msg.Data, &b)
-		data = b
+		var v []byte
+		err := c.Unmarshal(b, &v)
+		return v, err
+	}
+
+	v, err := r.types.InitVersion(typeName, version)
+	if err != nil {
+		return nil, err
+	}
+	if sc, ok := c.(codec.StrictCodec); ok && r.strict {
+		err = sc.UnmarshalStrict(b, v)
 	} else {
-		var v any
-		v, err = r.types.Init(eventType)
-		if err == nil {
-			err = c.Unmarshal(msg.Data, v)
-			data = v
-		}
+		err = c.Unmarshal(b, v)
 	}
 	if err != nil {
 		return nil, err
 	}
+	return r.types.Migrate(typeName, version, v)
+}
+
+// resolveType returns the type name of the data, validating it against
+// the explicit type if provided.
+func (r *Rita) resolveType(typeName string, data any) (string, error) {
+	if data == nil {
+		return "", fmt.Errorf("data is nil")
+	}
+
+	if r.types == nil {
+		if typeName == "" {
+			return "", ErrEventTypeRequired
+		}
+		return typeName, nil
+	}
+
+	t, err := r.types.Lookup(data)
+	if err != nil {
+		return "", err
+	}
+
+	if typeName != "" && typeName != t {
+		return "", fmt.Errorf("wrong type for data: %s", typeName)
+	}
+
+	return t, nil
+}
+
+// packMeta maps application-defined metadata to prefixed headers.
+func packMeta(h nats.Header, meta map[string]string) {
+	for k, v := range meta {
+		h.Set(fmt.Sprintf("%s%s", eventMetaPrefixHdr, k), v)
+	}
+}
+
+// unpackMeta extracts application-defined metadata from prefixed headers.
+func unpackMeta(h nats.Header) map[string]string {
+	meta := make(map[string]string)
+
+	for k := range h {
+		if strings.HasPrefix(k, eventMetaPrefixHdr) {
+			meta[k[len(eventMetaPrefixHdr):]] = h.Get(k)
+		}
+	}
+
+	return meta
+}
 
-	var seq uint64
+// UnpackEvent unpacks an Event from a NATS message.
+func (r *Rita) UnpackEvent(msg *nats.Msg) (*Event, error) {
+	return r.unpackEvent(msg, false)
+}
+
+// unpackEvent unpacks an Event from a NATS message. If lenient, an event of
+// an unregistered type or version is returned with the raw data rather than
+// failing.
+func (r *Rita) unpackEvent(msg *nats.Msg, lenient bool) (*Event, error) {
+	eventType := msg.Header.Get(eventTypeHdr)
+	codecName := msg.Header.Get(eventCodecHdr)
+
+	var version int
+	if v := msg.Header.Get(eventVersionHdr); v != "" {
+		var err error
+		version, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("unpack: invalid version %q", v)
+		}
+	}
+
+	var unknown bool
+	data, err := r.decodeData(codecName, eventType, version, msg.Data)
+	if err != nil {
+		if !lenient || !(errors.Is(err, types.ErrTypeNotRegistered) || errors.Is(err, types.ErrVersionNotRegistered)) {
+			return nil, err
+		}
+		data = msg.Data
+		unknown = true
+	}
+
+	var (
+		seq      uint64
+		recorded time.Time
+	)
 	// If this message is not from a native JS subscription, the reply will not
 	// be set. This is where metadata is parsed from. In cases where a message is
 	// re-published, we don't want to fail if we can't get the sequence.
@@ -98,6 +331,7 @@ func (r *Rita) UnpackEvent(msg *nats.Msg) (*Event, error) {
 			return nil, fmt.Errorf("unpack: failed to get metadata: %s", err)
 		}
 		seq = md.Sequence.Stream
+		recorded = md.Timestamp
 	}
 
 	eventTime, err := time.Parse(eventTimeFormat, msg.Header.Get(eventTimeHdr))
@@ -105,31 +339,119 @@ func (r *Rita) UnpackEvent(msg *nats.Msg) (*Event, error) {
 		return nil, fmt.Errorf("unpack: failed to parse event time: %s", err)
 	}
 
-	meta := make(map[string]string)
+	var validFrom, validTo time.Time
+	if v := msg.Header.Get(eventValidFromHdr); v != "" {
+		validFrom, err = time.Parse(eventTimeFormat, v)
+		if err != nil {
+			return nil, fmt.Errorf("unpack: failed to parse valid from: %s", err)
+		}
+	}
+	if v := msg.Header.Get(eventValidToHdr); v != "" {
+		validTo, err = time.Parse(eventTimeFormat, v)
+		if err != nil {
+			return nil, fmt.Errorf("unpack: failed to parse valid to: %s", err)
+		}
+	}
+
+	var expires time.Time
+	if v := msg.Header.Get(eventExpiresHdr); v != "" {
+		expires, err = time.Parse(eventTimeFormat, v)
+		if err != nil {
+			return nil, fmt.Errorf("unpack: failed to parse expires: %s", err)
+		}
+	}
 
-	for h := range msg.Header {
-		if strings.HasPrefix(h, eventMetaPrefixHdr) {
-			key := h[len(eventMetaPrefixHdr):]
-			meta[key] = msg.Header.Get(h)
+	var clock VectorClock
+	if c := msg.Header.Get(eventClockHdr); c != "" {
+		clock, err = parseVectorClock(c)
+		if err != nil {
+			return nil, err
 		}
 	}
 
+	meta := unpackMeta(msg.Header)
+
 	return &Event{
-		ID:       msg.Header.Get(nats.MsgIdHdr),
-		Type:     msg.Header.Get(eventTypeHdr),
-		Time:     eventTime,
-		Data:     data,
-		Meta:     meta,
-		Subject:  msg.Subject,
-		Sequence: seq,
+		ID:           msg.Header.Get(nats.MsgIdHdr),
+		Type:         msg.Header.Get(eventTypeHdr),
+		Time:         eventTime,
+		Data:         data,
+		Meta:         meta,
+		Subject:      r.unsubject(msg.Subject),
+		Sequence:     seq,
+		Hash:         msg.Header.Get(eventHashHdr),
+		prevHash:     msg.Header.Get(eventPrevHashHdr),
+		ValidFrom:    validFrom,
+		ValidTo:      validTo,
+		Expires:      expires,
+		Clock:        clock,
+		RecordedTime: recorded,
+		Unknown:      unknown,
 	}, nil
 }
 
-func (r *Rita) EventStore(name string) *EventStore {
-	return &EventStore{
-		name: name,
-		rt:   r,
+// EventStore returns an event store with the name. The store must be
+// created before it is used.
+func (r *Rita) EventStore(name string, opts ...EventStoreOption) (*EventStore, error) {
+	o := eventStoreOpts{
+		stream: name,
+	}
+
+	for _, opt := range opts {
+		if err := opt.eventStoreOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.bind && o.autoCreate != nil {
+		return nil, errors.New("rita: a bound event store cannot be auto created")
+	}
+	if o.backend != nil && (o.bind || o.autoCreate != nil) {
+		return nil, errors.New("rita: an event store with a backend cannot be bound or auto created")
 	}
+	if o.loadValidation == ValidationSideline && (o.backend != nil || o.hashChain || o.cacheDir != "") {
+		return nil, errors.New("rita: invalid events of an event store with a backend, hash chain, or disk cache cannot be sidelined")
+	}
+
+	var cache *diskCache
+	if o.cacheDir != "" {
+		dir := r.resourceName(o.stream)
+		if o.stream != name {
+			dir = fmt.Sprintf("%s_%s", dir, name)
+		}
+		cache = newDiskCache(o.cacheDir, dir)
+	}
+
+	return &EventStore{
+		name:           name,
+		stream:         r.resourceName(o.stream),
+		context:        r.context,
+		shared:         o.stream != name,
+		claimThreshold: o.claimThreshold,
+		chunkSize:      o.chunkSize,
+		maxEventSize:   o.maxEventSize,
+		maxBatchSize:   o.maxBatchSize,
+		causal:         o.causal,
+		hashChain:      o.hashChain,
+		audit:          o.audit,
+		rt:             r,
+
+		deleteProtection: o.deleteProtection,
+		bind:             o.bind,
+		autoCreate:       o.autoCreate,
+		backend:          o.backend,
+		cache:            cache,
+		index:            o.index,
+		indexKeys:        o.indexKeys,
+		scope:            o.scope,
+		loadValidation:   o.loadValidation,
+		beforeAppend:     o.beforeAppend,
+		afterAppend:      o.afterAppend,
+		rateLimit:        o.rateLimit,
+		typeTTL:          o.typeTTL,
+		checkpoints:      o.checkpoints,
+		shredder:         o.shredder,
+	}, nil
 }
 
 // New initializes a new Rita instance with a NATS connection.
@@ -140,10 +462,12 @@ func New(nc *nats.Conn, opts ...RitaOption) (*Rita, error) {
 	}
 
 	rt := &Rita{
-		nc:    nc,
-		js:    js,
-		id:    id.NUID,
-		clock: clock.Time,
+		nc:         nc,
+		js:         js,
+		id:         id.NUID,
+		clock:      clock.Time,
+		apiTimeout: defaultAPITimeout,
+		bus:        newEventBus(),
 	}
 
 	for _, o := range opts {
@@ -152,5 +476,22 @@ func New(nc *nats.Conn, opts ...RitaOption) (*Rita, error) {
 		}
 	}
 
+	if rt.apiTimeout != defaultAPITimeout {
+		rt.js, err = nc.JetStream(nats.MaxWait(rt.apiTimeout))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rt.anc == nil {
+		rt.anc = nc
+		rt.ajs = rt.js
+	} else {
+		rt.ajs, err = rt.anc.JetStream(nats.MaxWait(rt.apiTimeout))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return rt, nil
 }
@@ -0,0 +1,158 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/codec"
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestContext(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	_, err := New(nc, Context("bad.name"))
+	is.True(err != nil)
+
+	tr := newOrderTypes(t)
+
+	billing, err := New(nc, TypeRegistry(tr), Context("billing"))
+	is.NoErr(err)
+
+	shipping, err := New(nc, TypeRegistry(tr), Context("shipping"))
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	// Same store name in two contexts are independent streams.
+	bes, err := billing.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(bes.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ses, err := shipping.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(ses.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	seq, err := bes.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+	is.Equal(seq, uint64(1))
+
+	_, err = bes.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}}, ExpectSequence(1))
+	is.NoErr(err)
+
+	info, err := billing.js.StreamInfo("billing_orders")
+	is.NoErr(err)
+	is.Equal(info.Config.Subjects, []string{"billing.orders.>"})
+	is.Equal(info.State.Msgs, uint64(2))
+
+	events, _, err := bes.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(events[0].Subject, "orders.1")
+
+	events, _, err = ses.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+
+	sub, err := bes.Subscription("audit")
+	is.NoErr(err)
+	defer sub.Delete() //nolint
+
+	ds, err := sub.Fetch(ctx, 2)
+	is.NoErr(err)
+	is.Equal(len(ds), 2)
+	is.Equal(ds[1].Subject, "orders.1")
+}
+
+func TestStrictDecode(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	type OrderPlacedV2 struct {
+		ID       string
+		Priority bool
+	}
+
+	// The producer has a newer shape of the type than the consumer.
+	ptr, err := types.NewRegistry(map[string]*types.Type{
+		"order-placed": {Init: func() any { return &OrderPlacedV2{} }},
+	})
+	is.NoErr(err)
+
+	p, err := New(nc, TypeRegistry(ptr))
+	is.NoErr(err)
+
+	pes, err := p.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(pes.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = pes.Append(ctx, "orders.1", []*Event{{Data: &OrderPlacedV2{ID: "1", Priority: true}}})
+	is.NoErr(err)
+
+	c, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	ces, err := c.EventStore("orders")
+	is.NoErr(err)
+
+	events, _, err := ces.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(events[0].Data, &OrderPlaced{ID: "1"})
+
+	sc, err := New(nc, TypeRegistry(newOrderTypes(t)), StrictDecode())
+	is.NoErr(err)
+
+	ses, err := sc.EventStore("orders")
+	is.NoErr(err)
+
+	_, _, err = ses.Load(ctx, "orders.1")
+	is.Err(err, codec.ErrUnknownField)
+}
+
+func TestAppendConn(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+	anc, _ := nats.Connect(srv.ClientURL())
+
+	_, err := New(nc, AppendConn(nil))
+	is.True(err != nil)
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), AppendConn(anc))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	is.Equal(anc.Stats().OutMsgs, uint64(2))
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+	is.Equal(anc.Stats().OutMsgs, uint64(2))
+}
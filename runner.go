@@ -0,0 +1,330 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRestartMinBackoff = 100 * time.Millisecond
+	defaultRestartMaxBackoff = 30 * time.Second
+)
+
+// Component is a long-running component, such as a projection, reactor, or
+// command handler. Run blocks until the context is done or the component
+// fails. Reactor, SearchProjection, Materializer, Ingester, and Actors
+// implement Component, and other components, such as a subscription with a
+// handler, can be adapted with ComponentFunc.
+type Component interface {
+	Run(ctx context.Context) error
+}
+
+// ComponentFunc adapts a function to a Component.
+type ComponentFunc func(ctx context.Context) error
+
+// Run calls the function.
+func (f ComponentFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// RestartPolicy determines when a component supervised by a runner is
+// restarted after it returns.
+type RestartPolicy int
+
+const (
+	// RestartNever does not restart the component. An error is a permanent
+	// failure. This is the default.
+	RestartNever RestartPolicy = iota
+
+	// RestartOnFailure restarts the component when it returns an error,
+	// such as a transient NATS error.
+	RestartOnFailure
+
+	// RestartAlways restarts the component when it returns, including
+	// without an error.
+	RestartAlways
+)
+
+type componentOpts struct {
+	restart     RestartPolicy
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	maxRestarts int
+}
+
+type componentOptFn func(o *componentOpts) error
+
+func (f componentOptFn) componentOpt(o *componentOpts) error {
+	return f(o)
+}
+
+// ComponentOption is an option for a component added to a runner.
+type ComponentOption interface {
+	componentOpt(o *componentOpts) error
+}
+
+// Restart sets the restart policy of the component. Default is RestartNever.
+func Restart(policy RestartPolicy) ComponentOption {
+	return componentOptFn(func(o *componentOpts) error {
+		switch policy {
+		case RestartNever, RestartOnFailure, RestartAlways:
+		default:
+			return fmt.Errorf("rita: runner: invalid restart policy %d", policy)
+		}
+		o.restart = policy
+		return nil
+	})
+}
+
+// RestartBackoff sets the delay before the component is restarted, which
+// starts at min and doubles after each consecutive restart up to max. The
+// delay is reset once the component runs for at least max. Default is 100
+// milliseconds up to 30 seconds.
+func RestartBackoff(min, max time.Duration) ComponentOption {
+	return componentOptFn(func(o *componentOpts) error {
+		if min <= 0 || max < min {
+			return errors.New("rita: runner: invalid restart backoff")
+		}
+		o.minBackoff = min
+		o.maxBackoff = max
+		return nil
+	})
+}
+
+// MaxRestarts sets the max number of consecutive restarts of the component,
+// after which an error is a permanent failure. Default is no limit.
+func MaxRestarts(n int) ComponentOption {
+	return componentOptFn(func(o *componentOpts) error {
+		if n < 1 {
+			return errors.New("rita: runner: max restarts must be positive")
+		}
+		o.maxRestarts = n
+		return nil
+	})
+}
+
+type runnerOpts struct {
+	onRestart func(name string, err error, delay time.Duration)
+	onFailure func(name string, err error)
+}
+
+type runnerOptFn func(o *runnerOpts)
+
+func (f runnerOptFn) runnerOpt(o *runnerOpts) {
+	f(o)
+}
+
+// RunnerOption is an option for a runner.
+type RunnerOption interface {
+	runnerOpt(o *runnerOpts)
+}
+
+// RunnerOnRestart sets a function which is called with the error, if any,
+// of a component before it is restarted after the delay.
+func RunnerOnRestart(fn func(name string, err error, delay time.Duration)) RunnerOption {
+	return runnerOptFn(func(o *runnerOpts) {
+		o.onRestart = fn
+	})
+}
+
+// RunnerOnFailure sets a function which is called with the error of a
+// component which failed permanently, before the other components are
+// stopped.
+func RunnerOnFailure(fn func(name string, err error)) RunnerOption {
+	return runnerOptFn(func(o *runnerOpts) {
+		o.onFailure = fn
+	})
+}
+
+type namedComponent struct {
+	name string
+	c    Component
+	opts componentOpts
+}
+
+// run runs the component once, returning a panic as an error.
+func (nc *namedComponent) run(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return nc.c.Run(ctx)
+}
+
+// Runner runs a set of components in the background and stops them together.
+// Components are supervised, so a component which panics or returns is
+// restarted according to its restart policy rather than silently stopping.
+// It is itself a Component, so it can be run by errgroup-style service
+// managers or nested in another runner.
+type Runner struct {
+	opts runnerOpts
+
+	mu         sync.Mutex
+	components []*namedComponent
+	running    bool
+}
+
+// NewRunner returns an empty runner.
+func NewRunner(opts ...RunnerOption) *Runner {
+	var o runnerOpts
+	for _, opt := range opts {
+		opt.runnerOpt(&o)
+	}
+
+	return &Runner{
+		opts: o,
+	}
+}
+
+// Add adds the component with the name, which is used in errors. Components
+// cannot be added while the runner is running.
+func (r *Runner) Add(name string, c Component, opts ...ComponentOption) error {
+	o := componentOpts{
+		minBackoff: defaultRestartMinBackoff,
+		maxBackoff: defaultRestartMaxBackoff,
+	}
+	for _, opt := range opts {
+		if err := opt.componentOpt(&o); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running {
+		return errors.New("rita: runner: already running")
+	}
+	if name == "" || c == nil {
+		return errors.New("rita: runner: component name and component required")
+	}
+	for _, nc := range r.components {
+		if nc.name == name {
+			return fmt.Errorf("rita: runner: duplicate component %q", name)
+		}
+	}
+
+	r.components = append(r.components, &namedComponent{
+		name: name,
+		c:    c,
+		opts: o,
+	})
+	return nil
+}
+
+// isDone returns true if the error is due to the context being done.
+func isDone(ctx context.Context, err error) bool {
+	return ctx.Err() != nil && (err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
+// supervise runs the component, restarting it according to its policy, and
+// returns the error of a permanent failure.
+func (r *Runner) supervise(ctx context.Context, nc *namedComponent) error {
+	var (
+		restarts int
+		delay    = nc.opts.minBackoff
+	)
+
+	for {
+		start := time.Now()
+
+		err := nc.run(ctx)
+		if isDone(ctx, err) {
+			return nil
+		}
+
+		switch nc.opts.restart {
+		case RestartNever:
+			return err
+		case RestartOnFailure:
+			if err == nil {
+				return nil
+			}
+		}
+
+		if time.Since(start) >= nc.opts.maxBackoff {
+			restarts = 0
+			delay = nc.opts.minBackoff
+		}
+		if nc.opts.maxRestarts > 0 && restarts >= nc.opts.maxRestarts {
+			return err
+		}
+		restarts++
+
+		if r.opts.onRestart != nil {
+			r.opts.onRestart(nc.name, err, delay)
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+
+		delay *= 2
+		if delay > nc.opts.maxBackoff {
+			delay = nc.opts.maxBackoff
+		}
+	}
+}
+
+// Run runs all components until the context is done or a component fails
+// permanently, and then cancels the context of the other components and
+// waits for all of them to return. A component returning nil stops without
+// affecting the others unless it is restarted. The error of the first
+// component which failed permanently is returned, or nil if the components
+// were stopped by the context being done.
+func (r *Runner) Run(ctx context.Context) error {
+	r.mu.Lock()
+	if r.running {
+		r.mu.Unlock()
+		return errors.New("rita: runner: already running")
+	}
+	r.running = true
+	components := r.components
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.mu.Unlock()
+	}()
+
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		ferr error
+	)
+
+	for _, nc := range components {
+		wg.Add(1)
+		go func(nc *namedComponent) {
+			defer wg.Done()
+
+			err := r.supervise(cctx, nc)
+			if err == nil {
+				return
+			}
+
+			once.Do(func() {
+				if r.opts.onFailure != nil {
+					r.opts.onFailure(nc.name, err)
+				}
+				ferr = fmt.Errorf("rita: runner: %s: %w", nc.name, err)
+				cancel()
+			})
+		}(nc)
+	}
+
+	wg.Wait()
+	return ferr
+}
@@ -0,0 +1,193 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+// Long-running components can be added to a runner.
+var _ = []Component{
+	(*Actors)(nil),
+	(*Archiver)(nil),
+	(*Ingester)(nil),
+	(*Materializer)(nil),
+	(*Reactor)(nil),
+	(*SearchProjection)(nil),
+	NewRunner(),
+}
+
+func TestRunner(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	stopped := make(chan string, 3)
+	block := func(name string) Component {
+		return ComponentFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			stopped <- name
+			return ctx.Err()
+		})
+	}
+
+	rn := NewRunner()
+	is.NoErr(rn.Add("a", block("a")))
+	is.NoErr(rn.Add("b", block("b")))
+	is.Err(rn.Add("a", block("a")), nil)
+
+	// Components returning nil do not stop the others.
+	is.NoErr(rn.Add("once", ComponentFunc(func(ctx context.Context) error {
+		return nil
+	})))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- rn.Run(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	is.Err(rn.Add("c", block("c")), nil)
+
+	cancel()
+	is.NoErr(<-done)
+	is.Equal(len(stopped), 2)
+
+	// A failing component stops the others.
+	errBoom := errors.New("boom")
+
+	rn = NewRunner()
+	is.NoErr(rn.Add("a", block("a")))
+	is.NoErr(rn.Add("fail", ComponentFunc(func(ctx context.Context) error {
+		return errBoom
+	})))
+
+	err := rn.Run(context.Background())
+	is.Err(err, errBoom)
+	is.Equal(err.Error(), "rita: runner: fail: boom")
+}
+
+func TestRunnerRestart(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	var (
+		mu       sync.Mutex
+		restarts []time.Duration
+		failed   []string
+	)
+
+	rn := NewRunner(
+		RunnerOnRestart(func(name string, err error, delay time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			restarts = append(restarts, delay)
+		}),
+		RunnerOnFailure(func(name string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, name)
+		}),
+	)
+
+	is.Err(rn.Add("x", ComponentFunc(nil), Restart(RestartPolicy(5))), nil)
+	is.Err(rn.Add("x", ComponentFunc(nil), RestartBackoff(time.Second, time.Millisecond)), nil)
+
+	errTransient := errors.New("transient")
+
+	// Fails twice, panics once, and then runs until stopped.
+	var runs int
+	is.NoErr(rn.Add("projection", ComponentFunc(func(ctx context.Context) error {
+		runs++
+		switch runs {
+		case 1, 2:
+			return errTransient
+		case 3:
+			panic("boom")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}), Restart(RestartOnFailure), RestartBackoff(time.Millisecond, 4*time.Millisecond)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- rn.Run(ctx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	is.NoErr(<-done)
+
+	is.Equal(runs, 4)
+	is.Equal(restarts, []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond})
+	is.Equal(len(failed), 0)
+
+	// Restarts are exhausted.
+	restarts = nil
+
+	rn = NewRunner(
+		RunnerOnRestart(func(name string, err error, delay time.Duration) {
+			restarts = append(restarts, delay)
+		}),
+		RunnerOnFailure(func(name string, err error) {
+			failed = append(failed, name)
+		}),
+	)
+	is.NoErr(rn.Add("reactor", ComponentFunc(func(ctx context.Context) error {
+		return errTransient
+	}), Restart(RestartAlways), RestartBackoff(time.Millisecond, time.Millisecond), MaxRestarts(2)))
+
+	err := rn.Run(context.Background())
+	is.Err(err, errTransient)
+	is.Equal(len(restarts), 2)
+	is.Equal(failed, []string{"reactor"})
+}
+
+func TestRunnerActors(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	actors, err := es.Actors(func() Entity { return &Order{} })
+	is.NoErr(err)
+
+	rn := NewRunner()
+	is.NoErr(rn.Add("actors", actors))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- rn.Run(ctx)
+	}()
+
+	var (
+		events []*Event
+		serr   error
+	)
+	for i := 0; i < 20; i++ {
+		events, _, serr = actors.Send(ctx, "orders.1", &Command{Data: &PlaceOrder{ID: "1"}})
+		if serr == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	is.NoErr(serr)
+	is.Equal(len(events), 1)
+
+	cancel()
+	is.NoErr(<-done)
+}
@@ -0,0 +1,363 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	errSandboxRevision = errors.New("rita: sandbox: wrong last revision")
+)
+
+type sandboxKey struct{}
+
+// InSandbox returns true if the context is of a handler replayed in a
+// Sandbox. Handlers with side effects not stubbed by the sandbox, such as
+// calls to external services, should skip them.
+func InSandbox(ctx context.Context) bool {
+	_, ok := ctx.Value(sandboxKey{}).(*Sandbox)
+	return ok
+}
+
+// SandboxDispatch is a command which would have been dispatched.
+type SandboxDispatch struct {
+	// Sequence of the event being handled.
+	Sequence uint64
+
+	// Subject the command would have been sent to.
+	Subject string
+
+	// Command which would have been sent.
+	Command *Command
+}
+
+// SandboxWrite is a write to a state store which would have been made.
+type SandboxWrite struct {
+	// Sequence of the event being handled.
+	Sequence uint64
+
+	// Store is the name of the state store.
+	Store string
+
+	// Key written.
+	Key string
+
+	// Operation is nats.KeyValuePut, nats.KeyValueDelete or
+	// nats.KeyValuePurge.
+	Operation nats.KeyValueOp
+}
+
+// SandboxError is an error returned by a handler.
+type SandboxError struct {
+	// Sequence of the event being handled.
+	Sequence uint64
+
+	// Err returned.
+	Err error
+}
+
+// SandboxReport is the outcome of a replay.
+type SandboxReport struct {
+	// Events is the number of events replayed.
+	Events int
+
+	// Dispatches are the commands which would have been dispatched.
+	Dispatches []*SandboxDispatch
+
+	// Writes are the writes to state stores which would have been made.
+	Writes []*SandboxWrite
+
+	// Errors are the errors returned by the handler.
+	Errors []*SandboxError
+}
+
+// Sandbox replays historical events of a store through a projection or
+// reactor with its side effects stubbed, to report what would happen before
+// a changed handler is deployed. Commands sent with the Dispatcher of the
+// sandbox are captured, and writes to state stores wrapped with StateStore
+// are kept in memory, so no durable consumers are created and nothing is
+// written. Appends to event stores and other side effects are not stubbed
+// and should be skipped by the handler using InSandbox.
+type Sandbox struct {
+	es   *EventStore
+	opts []LoadOption
+
+	mu     sync.Mutex
+	seq    uint64
+	report *SandboxReport
+}
+
+// Sandbox returns a sandbox replaying the events of the store. The load
+// options, such as a time range, limit the events replayed.
+func (s *EventStore) Sandbox(opts ...LoadOption) *Sandbox {
+	return &Sandbox{
+		es:   s,
+		opts: opts,
+	}
+}
+
+type sandboxDispatcher struct {
+	sb *Sandbox
+}
+
+func (d *sandboxDispatcher) Send(ctx context.Context, subject string, cmd *Command) ([]*Event, uint64, error) {
+	d.sb.mu.Lock()
+	defer d.sb.mu.Unlock()
+
+	if d.sb.report != nil {
+		d.sb.report.Dispatches = append(d.sb.report.Dispatches, &SandboxDispatch{
+			Sequence: d.sb.seq,
+			Subject:  subject,
+			Command:  cmd,
+		})
+	}
+	return nil, 0, nil
+}
+
+// Dispatcher returns a dispatcher capturing the commands sent. No events are
+// returned for the commands.
+func (sb *Sandbox) Dispatcher() Dispatcher {
+	return &sandboxDispatcher{sb: sb}
+}
+
+// StateStore returns a copy of the state store whose writes are kept in
+// memory by the sandbox. Reads return the writes of the sandbox, falling
+// back to the entries of the state store. Watches are not stubbed.
+func (sb *Sandbox) StateStore(ss *StateStore) *StateStore {
+	return &StateStore{
+		name: ss.name,
+		kv: &sandboxKV{
+			KeyValue: ss.kv,
+			sb:       sb,
+			store:    ss.name,
+			entries:  make(map[string]*sandboxEntry),
+		},
+		rt: ss.rt,
+	}
+}
+
+// Replay passes the events of the subject to the handler, recording the
+// dispatches and writes made by the handler and the errors it returns. A
+// failed event is not retried.
+func (sb *Sandbox) Replay(ctx context.Context, subject string, handle func(ctx context.Context, event *Event) error) (*SandboxReport, error) {
+	if err := validateSubject(subject, true); err != nil {
+		return nil, err
+	}
+
+	var o loadOpts
+	for _, opt := range sb.opts {
+		if err := opt.loadOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sb.es.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	report := &SandboxReport{}
+
+	sb.mu.Lock()
+	sb.report = report
+	sb.mu.Unlock()
+
+	defer func() {
+		sb.mu.Lock()
+		sb.report = nil
+		sb.seq = 0
+		sb.mu.Unlock()
+	}()
+
+	hctx := context.WithValue(ctx, sandboxKey{}, sb)
+
+	_, err := sb.es.read(ctx, subject, &o, func(e *Event) error {
+		sb.mu.Lock()
+		sb.seq = e.Sequence
+		report.Events++
+		sb.mu.Unlock()
+
+		if err := handle(hctx, e); err != nil {
+			sb.mu.Lock()
+			report.Errors = append(report.Errors, &SandboxError{
+				Sequence: e.Sequence,
+				Err:      err,
+			})
+			sb.mu.Unlock()
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// ReplayReactor replays the events through the react function as a reactor
+// with the options would, capturing the commands it returns.
+func (sb *Sandbox) ReplayReactor(ctx context.Context, react ReactFunc, opts ...ReactorOption) (*SandboxReport, error) {
+	o := reactorOpts{
+		subject: sb.es.filterSubject(),
+	}
+
+	for _, opt := range opts {
+		if err := opt.reactorOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	dispatcher := sb.Dispatcher()
+
+	return sb.Replay(ctx, o.subject, func(ctx context.Context, event *Event) error {
+		if o.types != nil {
+			if _, ok := o.types[event.Type]; !ok {
+				return nil
+			}
+		}
+
+		ds, err := react(ctx, event)
+		if err != nil {
+			return err
+		}
+
+		for i, d := range ds {
+			if d.Command.ID == "" {
+				d.Command.ID = fmt.Sprintf("%s.%d", event.ID, i)
+			}
+			_, _, _ = dispatcher.Send(ctx, d.Subject, d.Command)
+		}
+		return nil
+	})
+}
+
+// sandboxEntry is an entry written in a sandbox.
+type sandboxEntry struct {
+	bucket   string
+	key      string
+	value    []byte
+	revision uint64
+	created  time.Time
+	op       nats.KeyValueOp
+}
+
+func (e *sandboxEntry) Bucket() string             { return e.bucket }
+func (e *sandboxEntry) Key() string                { return e.key }
+func (e *sandboxEntry) Value() []byte              { return e.value }
+func (e *sandboxEntry) Revision() uint64           { return e.revision }
+func (e *sandboxEntry) Created() time.Time         { return e.created }
+func (e *sandboxEntry) Delta() uint64              { return 0 }
+func (e *sandboxEntry) Operation() nats.KeyValueOp { return e.op }
+
+// sandboxKV overlays the writes of a sandbox on a KV bucket. Methods not
+// overridden are passed to the bucket.
+type sandboxKV struct {
+	nats.KeyValue
+
+	sb      *Sandbox
+	store   string
+	entries map[string]*sandboxEntry
+}
+
+// get returns the latest entry of the key, or nil if it does not exist.
+// Entries deleted in the sandbox are returned with the operation set.
+func (kv *sandboxKV) get(key string) (nats.KeyValueEntry, error) {
+	if e, ok := kv.entries[key]; ok {
+		return e, nil
+	}
+	e, err := kv.KeyValue.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, nil
+	}
+	return e, err
+}
+
+func (kv *sandboxKV) write(key string, value []byte, op nats.KeyValueOp, check func(cur nats.KeyValueEntry) error) (uint64, error) {
+	kv.sb.mu.Lock()
+	defer kv.sb.mu.Unlock()
+
+	cur, err := kv.get(key)
+	if err != nil {
+		return 0, err
+	}
+	if check != nil {
+		if err := check(cur); err != nil {
+			return 0, err
+		}
+	}
+
+	var rev uint64
+	if cur != nil {
+		rev = cur.Revision()
+	}
+	rev++
+
+	kv.entries[key] = &sandboxEntry{
+		bucket:   kv.Bucket(),
+		key:      key,
+		value:    value,
+		revision: rev,
+		created:  kv.sb.es.rt.clock.Now(),
+		op:       op,
+	}
+
+	if kv.sb.report != nil {
+		kv.sb.report.Writes = append(kv.sb.report.Writes, &SandboxWrite{
+			Sequence:  kv.sb.seq,
+			Store:     kv.store,
+			Key:       key,
+			Operation: op,
+		})
+	}
+	return rev, nil
+}
+
+func (kv *sandboxKV) Get(key string) (nats.KeyValueEntry, error) {
+	kv.sb.mu.Lock()
+	defer kv.sb.mu.Unlock()
+
+	e, err := kv.get(key)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.Operation() != nats.KeyValuePut {
+		return nil, nats.ErrKeyNotFound
+	}
+	return e, nil
+}
+
+func (kv *sandboxKV) Put(key string, value []byte) (uint64, error) {
+	return kv.write(key, value, nats.KeyValuePut, nil)
+}
+
+func (kv *sandboxKV) Create(key string, value []byte) (uint64, error) {
+	return kv.write(key, value, nats.KeyValuePut, func(cur nats.KeyValueEntry) error {
+		if cur != nil && cur.Operation() == nats.KeyValuePut {
+			return errSandboxRevision
+		}
+		return nil
+	})
+}
+
+func (kv *sandboxKV) Update(key string, value []byte, last uint64) (uint64, error) {
+	return kv.write(key, value, nats.KeyValuePut, func(cur nats.KeyValueEntry) error {
+		if cur == nil || cur.Revision() != last {
+			return errSandboxRevision
+		}
+		return nil
+	})
+}
+
+func (kv *sandboxKV) Delete(key string, opts ...nats.DeleteOpt) error {
+	_, err := kv.write(key, nil, nats.KeyValueDelete, nil)
+	return err
+}
+
+func (kv *sandboxKV) Purge(key string, opts ...nats.DeleteOpt) error {
+	_, err := kv.write(key, nil, nats.KeyValuePurge, nil)
+	return err
+}
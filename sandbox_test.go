@@ -0,0 +1,125 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestSandbox(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-summary", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	summaries, err := r.StateStore("summaries", StateStorage(nats.MemoryStorage))
+	is.NoErr(err)
+	_, err = summaries.Put("orders.2", &OrderSummary{Placed: 1})
+	is.NoErr(err)
+
+	sb := es.Sandbox()
+
+	// Reactor commands are captured rather than dispatched.
+	react := func(ctx context.Context, event *Event) ([]*Dispatch, error) {
+		e := event.Data.(*OrderPlaced)
+		return []*Dispatch{{
+			Subject: event.Subject,
+			Command: &Command{Data: &ShipOrder{ID: e.ID}},
+		}}, nil
+	}
+
+	report, err := sb.ReplayReactor(ctx, react, ReactTo("order-placed"))
+	is.NoErr(err)
+	is.Equal(report.Events, 3)
+	is.Equal(len(report.Dispatches), 2)
+	is.Equal(report.Dispatches[0].Sequence, uint64(1))
+	is.Equal(report.Dispatches[0].Subject, "orders.1")
+	is.Equal(report.Dispatches[1].Sequence, uint64(3))
+	is.Equal(report.Dispatches[1].Command.Data, &ShipOrder{ID: "2"})
+	is.Equal(len(report.Errors), 0)
+
+	// Projection writes are kept in memory.
+	ss := sb.StateStore(summaries)
+	fail := errors.New("unsupported")
+
+	report, err = sb.Replay(ctx, "orders.*", func(ctx context.Context, event *Event) error {
+		is.True(InSandbox(ctx))
+
+		if event.Subject == "orders.2" {
+			return fail
+		}
+
+		_, err := ss.Apply(event.Subject, event, func(v any) (any, error) {
+			if v == nil {
+				v = &OrderSummary{}
+			}
+			m := v.(*OrderSummary)
+			return m, m.Evolve(event)
+		})
+		return err
+	})
+	is.NoErr(err)
+	is.Equal(report.Events, 3)
+	is.Equal(len(report.Dispatches), 0)
+	is.Equal(len(report.Writes), 2)
+	is.Equal(report.Writes[0].Store, "summaries")
+	is.Equal(report.Writes[0].Key, "orders.1")
+	is.Equal(report.Writes[1].Sequence, uint64(2))
+	is.Equal(report.Writes[1].Operation, nats.KeyValuePut)
+	is.Equal(len(report.Errors), 1)
+	is.Equal(report.Errors[0].Sequence, uint64(3))
+	is.Err(report.Errors[0].Err, fail)
+
+	e, err := ss.Get("orders.1")
+	is.NoErr(err)
+	is.Equal(e.Value.(*OrderSummary).Shipped, 1)
+
+	// Reads fall back to the state store.
+	e, err = ss.Get("orders.2")
+	is.NoErr(err)
+	is.Equal(e.Value.(*OrderSummary).Placed, 1)
+
+	is.NoErr(ss.Delete("orders.2"))
+	_, err = ss.Get("orders.2")
+	is.Err(err, nats.ErrKeyNotFound)
+
+	// Nothing is written.
+	_, err = summaries.Get("orders.1")
+	is.Err(err, nats.ErrKeyNotFound)
+	_, err = summaries.Get("orders.2")
+	is.NoErr(err)
+
+	events, _, err := es.Load(ctx, "orders.*")
+	is.NoErr(err)
+	is.Equal(len(events), 3)
+
+	is.True(!InSandbox(ctx))
+}
@@ -0,0 +1,115 @@
+package rita
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	schemasBucket = "rita-schemas"
+)
+
+var (
+	ErrSchemaNotFound = errors.New("rita: schema not found")
+)
+
+// SchemaRegistry is a registry of type schemas stored in a KV bucket, so
+// multiple services share one source of truth for the shape of events.
+type SchemaRegistry struct {
+	kv nats.KeyValue
+}
+
+// Register stores the schema for the type and returns the revision.
+func (s *SchemaRegistry) Register(typ string, schema *types.Schema) (uint64, error) {
+	if schema == nil {
+		return 0, fmt.Errorf("schema: nil schema for %s", typ)
+	}
+
+	b, err := json.Marshal(schema)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.kv.Put(typ, b)
+}
+
+// Get returns the schema for the type.
+func (s *SchemaRegistry) Get(typ string) (*types.Schema, error) {
+	e, err := s.kv.Get(typ)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrSchemaNotFound, typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var schema types.Schema
+	if err := json.Unmarshal(e.Value(), &schema); err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
+
+// Types returns the names of the types with schemas.
+func (s *SchemaRegistry) Types() ([]string, error) {
+	keys, err := s.kv.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return nil, nil
+	}
+	return keys, err
+}
+
+// Sync sets the schema of each type in the type registry from the schema
+// registry. If any of the types do not have a schema, ErrSchemaNotFound is
+// returned after the remaining types are synced.
+func (s *SchemaRegistry) Sync(reg *types.Registry) error {
+	var missing []string
+
+	for _, t := range reg.Names() {
+		schema, err := s.Get(t)
+		if errors.Is(err, ErrSchemaNotFound) {
+			missing = append(missing, t)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := reg.SetSchema(t, schema); err != nil {
+			return err
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrSchemaNotFound, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// SchemaRegistry returns the schema registry, creating the underlying KV
+// bucket if it does not exist.
+func (r *Rita) SchemaRegistry() (*SchemaRegistry, error) {
+	bucket := r.resourceName(schemasBucket)
+
+	kv, err := r.js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = r.js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:  bucket,
+			History: 10,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &SchemaRegistry{
+		kv: kv,
+	}, nil
+}
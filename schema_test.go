@@ -0,0 +1,51 @@
+package rita
+
+import (
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestSchemaRegistry(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	sr, err := r.SchemaRegistry()
+	is.NoErr(err)
+
+	names, err := sr.Types()
+	is.NoErr(err)
+	is.Equal(len(names), 0)
+
+	_, err = sr.Register("order-placed", &types.Schema{
+		Format: "jsonschema",
+		Data:   []byte(`{"type": "object"}`),
+	})
+	is.NoErr(err)
+
+	s, err := sr.Get("order-placed")
+	is.NoErr(err)
+	is.Equal(s.Format, "jsonschema")
+
+	_, err = sr.Get("order-cancelled")
+	is.Err(err, ErrSchemaNotFound)
+
+	// Other types are missing schemas.
+	err = sr.Sync(tr)
+	is.Err(err, ErrSchemaNotFound)
+
+	s, err = tr.Schema("order-placed")
+	is.NoErr(err)
+	is.Equal(string(s.Data), `{"type": "object"}`)
+}
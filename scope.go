@@ -0,0 +1,39 @@
+package rita
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bruth/rita/types"
+)
+
+var (
+	ErrTypeOutOfScope = errors.New("rita: type out of scope")
+)
+
+// EventStoreScope restricts the events appended to the store to the types of
+// the namespace, e.g. "orders" for types named "orders/order-placed", so a
+// decider cannot accidentally decide events of another aggregate. Appending
+// an event of another type fails with ErrTypeOutOfScope. Loads are not
+// restricted. Default is no restriction.
+func EventStoreScope(namespace string) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if !nameRegex.MatchString(namespace) {
+			return fmt.Errorf("rita: scope %q has invalid characters", namespace)
+		}
+		o.scope = namespace
+		return nil
+	})
+}
+
+// checkScope returns an error if the event type is not in the scope of the
+// store.
+func (s *EventStore) checkScope(eventType string) error {
+	if s.scope == "" {
+		return nil
+	}
+	if ns, _ := types.SplitName(eventType); ns != s.scope {
+		return fmt.Errorf("%w: %s not in %q", ErrTypeOutOfScope, eventType, s.scope)
+	}
+	return nil
+}
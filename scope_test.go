@@ -0,0 +1,63 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreScope(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr, err := types.NewRegistry(map[string]*types.Type{
+		"orders/order-placed": {
+			Init: func() any { return &OrderPlaced{} },
+		},
+		"shipping/order-shipped": {
+			Init: func() any { return &OrderShipped{} },
+		},
+	})
+	is.NoErr(err)
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	_, err = r.EventStore("orders", EventStoreScope("orders/x"))
+	is.Err(err, nil)
+
+	es, err := r.EventStore("orders", EventStoreScope("orders"))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.Err(err, ErrTypeOutOfScope)
+
+	// A decider cannot decide events of another aggregate.
+	d := NewDecider(
+		func(n int, e *Event) (int, error) { return n + 1, nil },
+		func(n int, c *Command) ([]*Event, error) {
+			return []*Event{{Data: &OrderShipped{ID: "1"}}}, nil
+		},
+		0,
+	)
+	_, _, err = es.Execute(ctx, "orders.1", d, &Command{Data: &ShipOrder{}})
+	is.Err(err, ErrTypeOutOfScope)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Type, "orders/order-placed")
+}
@@ -0,0 +1,264 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var (
+	ErrSearchNotConfigured = errors.New("rita: search index not configured")
+)
+
+// SearchDocument is a document in a search index. Documents are identified
+// by the subject of the events they are projected from.
+type SearchDocument struct {
+	// ID of the document, which is the subject of the events.
+	ID string
+
+	// Fields of the document by name.
+	Fields map[string]string
+}
+
+// SearchHit is a document matching a search query.
+type SearchHit struct {
+	// ID of the document.
+	ID string
+
+	// Score of the match. Higher is better.
+	Score float64
+
+	// Fields of the document.
+	Fields map[string]string
+}
+
+// SearchIndex indexes documents for full-text search. The in-process index
+// returned by NewMemorySearchIndex is provided, and adapters for engines such
+// as Bleve or Elasticsearch can be implemented using this interface.
+type SearchIndex interface {
+	// Index merges the fields of the document into the indexed document with
+	// the same ID, replacing the values of existing fields.
+	Index(ctx context.Context, doc *SearchDocument) error
+
+	// Search returns up to limit documents matching the query ordered by
+	// score. A limit of zero returns all matches.
+	Search(ctx context.Context, query string, limit int) ([]*SearchHit, error)
+}
+
+// SearchEngine sets the search index used by search projections and Search.
+func SearchEngine(idx SearchIndex) RitaOption {
+	return ritaOption(func(o *Rita) error {
+		if idx == nil {
+			return errors.New("rita: search index required")
+		}
+		o.search = idx
+		return nil
+	})
+}
+
+// Search searches the index set with SearchEngine.
+func (r *Rita) Search(ctx context.Context, query string, limit int) ([]*SearchHit, error) {
+	if r.search == nil {
+		return nil, ErrSearchNotConfigured
+	}
+	return r.search.Search(ctx, query, limit)
+}
+
+// SearchProjection indexes selected fields of events into the search index
+// set with SearchEngine.
+type SearchProjection struct {
+	sub    *Subscription
+	fields map[string][]string
+	idx    SearchIndex
+}
+
+// SearchProjection returns a projection which indexes the fields of events
+// by event type, e.g. {"order-placed": {"customer", "sku"}}. Fields are the
+// top-level fields of the event data as encoded to JSON. Events of other
+// types are skipped. The name is used for the durable subscription, so the
+// projection resumes where it left off after restarts.
+func (s *EventStore) SearchProjection(name string, fields map[string][]string, opts ...SubscriptionOption) (*SearchProjection, error) {
+	if s.rt.search == nil {
+		return nil, ErrSearchNotConfigured
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("rita: search fields required")
+	}
+
+	sub, err := s.Subscription(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SearchProjection{
+		sub:    sub,
+		fields: fields,
+		idx:    s.rt.search,
+	}, nil
+}
+
+// Run indexes events until the context is done. Like the subscription, it
+// is authorized as a watch.
+func (p *SearchProjection) Run(ctx context.Context) error {
+	defer p.sub.Close() //nolint
+
+	return p.sub.Run(ctx, func(ctx context.Context, event *Event) error {
+		doc, err := p.document(event)
+		if err != nil || doc == nil {
+			return err
+		}
+		return p.idx.Index(ctx, doc)
+	})
+}
+
+// document returns the document of the selected fields of the event, or nil
+// if the type is not indexed.
+func (p *SearchProjection) document(event *Event) (*SearchDocument, error) {
+	names, ok := p.fields[event.Type]
+	if !ok || event.Data == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("rita: search: event data is not an object: %w", err)
+	}
+
+	doc := &SearchDocument{
+		ID:     event.Subject,
+		Fields: make(map[string]string, len(names)),
+	}
+	for _, n := range names {
+		v, ok := data[n]
+		if !ok || v == nil {
+			continue
+		}
+		doc.Fields[n] = fmt.Sprint(v)
+	}
+
+	return doc, nil
+}
+
+// memorySearchIndex is an in-process inverted index.
+type memorySearchIndex struct {
+	mu    sync.RWMutex
+	docs  map[string]map[string]string
+	terms map[string]map[string]int
+}
+
+// NewMemorySearchIndex returns an in-process search index. Fields are split
+// into lower case terms on non-alphanumeric characters. A document matches a
+// query if it contains all terms of the query, scored by the number of
+// occurrences of the terms.
+func NewMemorySearchIndex() SearchIndex {
+	return &memorySearchIndex{
+		docs:  make(map[string]map[string]string),
+		terms: make(map[string]map[string]int),
+	}
+}
+
+func searchTerms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (m *memorySearchIndex) count(id string, fields map[string]string, delta int) {
+	for _, v := range fields {
+		for _, t := range searchTerms(v) {
+			ids, ok := m.terms[t]
+			if !ok {
+				ids = make(map[string]int)
+				m.terms[t] = ids
+			}
+			ids[id] += delta
+			if ids[id] <= 0 {
+				delete(ids, id)
+			}
+			if len(ids) == 0 {
+				delete(m.terms, t)
+			}
+		}
+	}
+}
+
+func (m *memorySearchIndex) Index(ctx context.Context, doc *SearchDocument) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, ok := m.docs[doc.ID]
+	if !ok {
+		cur = make(map[string]string)
+		m.docs[doc.ID] = cur
+	}
+
+	m.count(doc.ID, cur, -1)
+	for k, v := range doc.Fields {
+		cur[k] = v
+	}
+	m.count(doc.ID, cur, 1)
+
+	return nil
+}
+
+func (m *memorySearchIndex) Search(ctx context.Context, query string, limit int) ([]*SearchHit, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := make(map[string]int)
+	for id, n := range m.terms[terms[0]] {
+		scores[id] = n
+	}
+	for _, t := range terms[1:] {
+		ids := m.terms[t]
+		for id := range scores {
+			n, ok := ids[id]
+			if !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += n
+		}
+	}
+
+	hits := make([]*SearchHit, 0, len(scores))
+	for id, n := range scores {
+		fields := make(map[string]string, len(m.docs[id]))
+		for k, v := range m.docs[id] {
+			fields[k] = v
+		}
+		hits = append(hits, &SearchHit{
+			ID:     id,
+			Score:  float64(n),
+			Fields: fields,
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,111 @@
+package rita
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestMemorySearchIndex(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	idx := NewMemorySearchIndex()
+	ctx := context.Background()
+
+	is.NoErr(idx.Index(ctx, &SearchDocument{ID: "orders.1", Fields: map[string]string{"customer": "Jane Doe", "note": "gift"}}))
+	is.NoErr(idx.Index(ctx, &SearchDocument{ID: "orders.2", Fields: map[string]string{"customer": "John Doe"}}))
+
+	hits, err := idx.Search(ctx, "doe", 0)
+	is.NoErr(err)
+	is.Equal(len(hits), 2)
+	is.Equal(hits[0].ID, "orders.1")
+
+	hits, err = idx.Search(ctx, "jane DOE", 0)
+	is.NoErr(err)
+	is.Equal(len(hits), 1)
+	is.Equal(hits[0].Fields["note"], "gift")
+
+	// Replaced values are no longer matched.
+	is.NoErr(idx.Index(ctx, &SearchDocument{ID: "orders.1", Fields: map[string]string{"customer": "Jane Roe"}}))
+
+	hits, err = idx.Search(ctx, "doe", 0)
+	is.NoErr(err)
+	is.Equal(len(hits), 1)
+	is.Equal(hits[0].ID, "orders.2")
+
+	hits, err = idx.Search(ctx, "gift", 1)
+	is.NoErr(err)
+	is.Equal(len(hits), 1)
+	is.Equal(hits[0].Fields["customer"], "Jane Roe")
+}
+
+func TestSearchProjection(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = r.Search(ctx, "1", 0)
+	is.Err(err, ErrSearchNotConfigured)
+
+	_, err = es.SearchProjection("search", map[string][]string{"order-placed": {"ID"}})
+	is.Err(err, ErrSearchNotConfigured)
+
+	r, err = New(nc, TypeRegistry(newOrderTypes(t)), SearchEngine(NewMemorySearchIndex()))
+	is.NoErr(err)
+
+	es, err = r.EventStore("orders")
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "abc-1"}},
+		{Data: &OrderShipped{ID: "abc-1"}},
+	})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "xyz-2"}}})
+	is.NoErr(err)
+
+	p, err := es.SearchProjection("search", map[string][]string{"order-placed": {"ID"}})
+	is.NoErr(err)
+
+	rctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- p.Run(rctx) }()
+
+	var hits []*SearchHit
+	for rctx.Err() == nil {
+		hits, err = r.Search(ctx, "xyz", 0)
+		is.NoErr(err)
+		if len(hits) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	is.NoErr(<-done)
+
+	is.Equal(len(hits), 1)
+	is.Equal(hits[0].ID, "orders.2")
+	is.Equal(hits[0].Fields, map[string]string{"ID": "xyz-2"})
+
+	hits, err = r.Search(ctx, "abc", 0)
+	is.NoErr(err)
+	is.Equal(len(hits), 1)
+	is.Equal(hits[0].ID, "orders.1")
+}
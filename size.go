@@ -0,0 +1,128 @@
+package rita
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+var (
+	ErrEventTooLarge = errors.New("rita: event too large")
+	ErrBatchTooLarge = errors.New("rita: batch too large")
+)
+
+// SizeError is returned by Append when an event or the batch of events
+// exceeds a size limit of the store. It wraps ErrEventTooLarge or
+// ErrBatchTooLarge.
+type SizeError struct {
+	// Index of the event in the batch exceeding the limit, or -1 if the
+	// batch exceeds the limit.
+	Index int
+
+	// Size of the encoded event data or the batch in bytes.
+	Size int
+
+	// Limit that was exceeded in bytes.
+	Limit int
+
+	err error
+}
+
+func (e *SizeError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%s: %d bytes exceeds limit of %d", e.err, e.Size, e.Limit)
+	}
+	return fmt.Sprintf("%s: event %d: %d bytes exceeds limit of %d", e.err, e.Index, e.Size, e.Limit)
+}
+
+func (e *SizeError) Unwrap() error {
+	return e.err
+}
+
+// EventStoreMaxEventSize sets the max size, in bytes, of the encoded data of
+// an event. Appends exceeding the limit fail before any event is published.
+// Default is no limit.
+func EventStoreMaxEventSize(n int) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if n < 1 {
+			return fmt.Errorf("rita: max event size must be positive")
+		}
+		o.maxEventSize = n
+		return nil
+	})
+}
+
+// EventStoreMaxBatchSize sets the max total size, in bytes, of the encoded
+// data of the events in a single append. Default is no limit.
+func EventStoreMaxBatchSize(n int) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if n < 1 {
+			return fmt.Errorf("rita: max batch size must be positive")
+		}
+		o.maxBatchSize = n
+		return nil
+	})
+}
+
+// SizeStats is the distribution of the encoded data sizes of events
+// appended to a store by this instance.
+type SizeStats struct {
+	// Count of events appended.
+	Count uint64
+
+	// Sum of the sizes in bytes.
+	Sum uint64
+
+	// Max size in bytes.
+	Max int
+
+	// Buckets maps the upper bound of a power-of-two bucket to the number
+	// of events whose size is at most the bound and greater than the bound
+	// of the previous bucket.
+	Buckets map[int]uint64
+}
+
+type sizeStats struct {
+	mu    sync.Mutex
+	stats SizeStats
+}
+
+func (s *sizeStats) observe(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stats.Buckets == nil {
+		s.stats.Buckets = make(map[int]uint64)
+	}
+
+	s.stats.Count++
+	s.stats.Sum += uint64(size)
+	if size > s.stats.Max {
+		s.stats.Max = size
+	}
+
+	bound := 0
+	if size > 0 {
+		bound = 1 << bits.Len(uint(size-1))
+	}
+	s.stats.Buckets[bound]++
+}
+
+func (s *sizeStats) snapshot() SizeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := s.stats
+	cp.Buckets = make(map[int]uint64, len(s.stats.Buckets))
+	for k, v := range s.stats.Buckets {
+		cp.Buckets[k] = v
+	}
+	return cp
+}
+
+// SizeStats returns the distribution of the encoded data sizes of events
+// appended to the store by this instance.
+func (s *EventStore) SizeStats() SizeStats {
+	return s.sizes.snapshot()
+}
@@ -0,0 +1,67 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestSizeLimits(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreMaxEventSize(64), EventStoreMaxBatchSize(100))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderPlaced{ID: strings.Repeat("x", 100)}},
+	})
+	is.Err(err, ErrEventTooLarge)
+
+	var serr *SizeError
+	is.True(errors.As(err, &serr))
+	is.Equal(serr.Index, 1)
+	is.Equal(serr.Limit, 64)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: strings.Repeat("x", 45)}},
+		{Data: &OrderPlaced{ID: strings.Repeat("x", 45)}},
+	})
+	is.Err(err, ErrBatchTooLarge)
+
+	// Nothing was published.
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	stats := es.SizeStats()
+	is.Equal(stats.Count, uint64(2))
+	is.True(stats.Max > 0)
+
+	var n uint64
+	for _, c := range stats.Buckets {
+		n += c
+	}
+	is.Equal(n, uint64(2))
+}
@@ -0,0 +1,227 @@
+//go:build soak
+
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+// TestSoak runs sustained append, load, and command workloads against a
+// three-node cluster while restarting servers and forcing stream leader
+// elections, then asserts no events were lost or duplicated. It is excluded
+// from the default build and run with:
+//
+//	RITA_SOAK_DURATION=5m go test -tags soak -run TestSoak -timeout 30m
+func TestSoak(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	duration := 30 * time.Second
+	if v := os.Getenv("RITA_SOAK_DURATION"); v != "" {
+		d, err := time.ParseDuration(v)
+		is.NoErr(err)
+		duration = d
+	}
+
+	c := testutil.NewNatsCluster(3)
+	defer c.Shutdown()
+
+	nc, err := nats.Connect(c.ClientURL(), nats.MaxReconnects(-1), nats.ReconnectWait(100*time.Millisecond))
+	is.NoErr(err)
+	defer nc.Close()
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{
+		Storage:  nats.FileStorage,
+		Replicas: 3,
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids = make(map[string][]string)
+	)
+
+	// Appenders each own a subject and append with the expected sequence,
+	// retrying with the same event ID until the outcome is known.
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			subject := fmt.Sprintf("orders.a%d", w)
+			var seq uint64
+
+			for i := 0; ctx.Err() == nil; i++ {
+				id := fmt.Sprintf("a%d-%d", w, i)
+				s, ok := soakAppend(ctx, es, subject, id, seq)
+				if !ok {
+					return
+				}
+				seq = s
+
+				mu.Lock()
+				ids[subject] = append(ids[subject], id)
+				mu.Unlock()
+			}
+		}(w)
+	}
+
+	// Loaders continuously load the appended subjects.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			lctx, lcancel := context.WithTimeout(ctx, 2*time.Second)
+			_, _, _ = es.Load(lctx, "orders.a0")
+			lcancel()
+		}
+	}()
+
+	// Commands place and ship an order per subject. Retries are idempotent
+	// since the decisions are.
+	var shipped []string
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ctx.Err() == nil; i++ {
+			subject := fmt.Sprintf("orders.c%d", i)
+			for _, cmd := range []any{&PlaceOrder{ID: subject}, &ShipOrder{ID: subject}} {
+				if !soakExecute(ctx, es, subject, &Command{ID: fmt.Sprintf("%s-%T", subject, cmd), Data: cmd}) {
+					return
+				}
+			}
+			mu.Lock()
+			shipped = append(shipped, subject)
+			mu.Unlock()
+		}
+	}()
+
+	// Chaos alternates between restarting a server and forcing a stream
+	// leader election.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(3 * time.Second):
+			}
+
+			if i%2 == 0 {
+				n := rand.Intn(len(c.Servers))
+				t.Logf("restarting server %d", n)
+				c.Restart(n)
+			} else {
+				t.Log("stepping down stream leader")
+				_, _ = nc.Request("$JS.API.STREAM.LEADER.STEPDOWN.orders", nil, time.Second)
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	// Verify with a fresh context once the cluster has settled.
+	c.WaitReady(30 * time.Second)
+	vctx, vcancel := context.WithTimeout(context.Background(), time.Minute)
+	defer vcancel()
+
+	for subject, want := range ids {
+		events := soakLoad(vctx, t, es, subject)
+
+		// An append whose outcome was unknown when the run ended may have
+		// been stored.
+		if len(events) == len(want)+1 {
+			events = events[:len(want)]
+		}
+
+		got := make([]string, len(events))
+		for i, e := range events {
+			got[i] = e.ID
+		}
+		is.Equal(got, want)
+		t.Logf("%s: %d events", subject, len(want))
+	}
+
+	for _, subject := range shipped {
+		events := soakLoad(vctx, t, es, subject)
+		is.Equal(len(events), 2)
+	}
+	t.Logf("%d orders shipped", len(shipped))
+}
+
+// soakAppend appends an event until the outcome is known, returning false if
+// the context is done. A conflict after a retry is resolved by checking if the
+// event was appended by the previous attempt.
+func soakAppend(ctx context.Context, es *EventStore, subject, id string, seq uint64) (uint64, bool) {
+	for ctx.Err() == nil {
+		actx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		s, err := es.Append(actx, subject, []*Event{{ID: id, Data: &OrderPlaced{ID: id}}}, ExpectSequence(seq))
+		cancel()
+
+		if err == nil {
+			return s, true
+		}
+
+		if errors.Is(err, ErrSequenceConflict) {
+			lctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			events, last, lerr := es.Load(lctx, subject, AfterSequence(seq))
+			cancel()
+			if lerr == nil && len(events) == 1 && events[0].ID == id {
+				return last, true
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+	return 0, false
+}
+
+// soakExecute executes a command until it succeeds, returning false if the
+// context is done.
+func soakExecute(ctx context.Context, es *EventStore, subject string, cmd *Command) bool {
+	for ctx.Err() == nil {
+		ectx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		_, _, err := es.Execute(ectx, subject, &Order{}, cmd)
+		cancel()
+		if err == nil {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
+
+// soakLoad loads the events of the subject, retrying while the cluster
+// recovers.
+func soakLoad(ctx context.Context, t *testing.T, es *EventStore, subject string) []*Event {
+	for {
+		lctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		events, _, err := es.Load(lctx, subject)
+		cancel()
+		if err == nil {
+			return events
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("load %s: %s", subject, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,402 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	maxApplyRetries = 10
+
+	// maxAppliedSubjects is the number of subjects whose last applied
+	// sequence is tracked per key by Apply.
+	maxAppliedSubjects = 64
+)
+
+type stateStoreOpts struct {
+	history  uint8
+	ttl      time.Duration
+	storage  nats.StorageType
+	replicas int
+}
+
+type stateStoreOptFn func(o *stateStoreOpts) error
+
+func (f stateStoreOptFn) stateStoreOpt(o *stateStoreOpts) error {
+	return f(o)
+}
+
+// StateStoreOption is an option for a state store. Options only apply when
+// the underlying bucket is created.
+type StateStoreOption interface {
+	stateStoreOpt(o *stateStoreOpts) error
+}
+
+// StateHistory sets the number of historical values retained per key.
+// Default is 1.
+func StateHistory(n uint8) StateStoreOption {
+	return stateStoreOptFn(func(o *stateStoreOpts) error {
+		if n < 1 {
+			return fmt.Errorf("state: history must be positive")
+		}
+		o.history = n
+		return nil
+	})
+}
+
+// StateTTL sets the max age of values in the store. Default is no limit.
+func StateTTL(d time.Duration) StateStoreOption {
+	return stateStoreOptFn(func(o *stateStoreOpts) error {
+		o.ttl = d
+		return nil
+	})
+}
+
+// StateStorage sets the storage type of the store. Default is file storage.
+func StateStorage(t nats.StorageType) StateStoreOption {
+	return stateStoreOptFn(func(o *stateStoreOpts) error {
+		o.storage = t
+		return nil
+	})
+}
+
+// StateReplicas sets the number of replicas of the store in a cluster.
+func StateReplicas(n int) StateStoreOption {
+	return stateStoreOptFn(func(o *stateStoreOpts) error {
+		o.replicas = n
+		return nil
+	})
+}
+
+// stateValue is the envelope of a value stored in the KV bucket since KV
+// entries do not support headers.
+type stateValue struct {
+	Type    string            `json:"type,omitempty"`
+	Codec   string            `json:"codec"`
+	Data    []byte            `json:"data"`
+	Applied map[string]uint64 `json:"applied,omitempty"`
+	Through uint64            `json:"through,omitempty"`
+}
+
+// StateEntry is a value in a state store.
+type StateEntry struct {
+	// Key of the entry.
+	Key string
+
+	// Type of the value.
+	Type string
+
+	// Value decoded using the type registry. If no registry is configured,
+	// the value is a byte slice.
+	Value any
+
+	// Revision of the entry, which can be used for optimistic concurrency
+	// control with Update.
+	Revision uint64
+
+	// Time the entry was stored.
+	Time time.Time
+
+	// Deleted is true if the entry represents a delete. This only occurs
+	// when watching.
+	Deleted bool
+
+	// Applied is the sequence of the last event applied to the value by
+	// Apply per subject, for the most recently applied subjects.
+	Applied map[string]uint64
+
+	// AppliedThrough is the sequence up to which events are considered
+	// applied by Apply, regardless of their subject.
+	AppliedThrough uint64
+}
+
+// StateStore stores typed state that is not event-sourced in a KV bucket,
+// using the type registry for serialization.
+type StateStore struct {
+	name string
+	kv   nats.KeyValue
+	rt   *Rita
+}
+
+func (s *StateStore) pack(v any, applied map[string]uint64, through uint64) ([]byte, error) {
+	var t string
+	if s.rt.types != nil {
+		var err error
+		t, err = s.rt.types.Lookup(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, codecName, err := s.rt.encodeData(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&stateValue{
+		Type:    t,
+		Codec:   codecName,
+		Data:    data,
+		Applied: applied,
+		Through: through,
+	})
+}
+
+func (s *StateStore) unpack(e nats.KeyValueEntry) (*StateEntry, error) {
+	se := &StateEntry{
+		Key:      e.Key(),
+		Revision: e.Revision(),
+		Time:     e.Created(),
+	}
+
+	if e.Operation() != nats.KeyValuePut {
+		se.Deleted = true
+		return se, nil
+	}
+
+	var sv stateValue
+	if err := json.Unmarshal(e.Value(), &sv); err != nil {
+		return nil, err
+	}
+
+	v, err := s.rt.decodeData(sv.Codec, sv.Type, 0, sv.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	se.Type = sv.Type
+	se.Value = v
+	se.Applied = sv.Applied
+	se.AppliedThrough = sv.Through
+
+	return se, nil
+}
+
+// Put stores the value for the key and returns the revision.
+func (s *StateStore) Put(key string, v any) (uint64, error) {
+	b, err := s.pack(v, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	return s.kv.Put(key, b)
+}
+
+// Update stores the value for the key only if the current revision matches
+// the expected revision. A revision of zero expects the key to not exist.
+func (s *StateStore) Update(key string, v any, rev uint64) (uint64, error) {
+	b, err := s.pack(v, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	if rev == 0 {
+		return s.kv.Create(key, b)
+	}
+	return s.kv.Update(key, b, rev)
+}
+
+// Apply applies the event to the value of the key exactly once, such as in
+// the handler of a projection. The sequence of the event is stored atomically
+// with the value returned by fn using the revision of the key, so an event
+// redelivered after it was applied, e.g. since the ack was lost, is not
+// applied again. fn is passed the current value, or nil if the key does not
+// exist, and may be called again if the key is changed concurrently. False is
+// returned if the event was already applied. The sequences are tracked per
+// subject of the events applied to the key, and are discarded by Put and
+// Update.
+//
+// To keep the value bounded, only the subjects applied most recently are
+// tracked. The sequences of older subjects are folded into a high-water mark,
+// and events up to it are considered applied. This assumes the events of the
+// key are delivered in stream order, as by a single subscription, once more
+// subjects than tracked are applied to it.
+func (s *StateStore) Apply(key string, event *Event, fn func(v any) (any, error)) (bool, error) {
+	if event.Subject == "" || event.Sequence == 0 {
+		return false, errors.New("rita: state: event subject and sequence required")
+	}
+
+	var err error
+	for i := 0; i < maxApplyRetries; i++ {
+		var (
+			entry   nats.KeyValueEntry
+			cur     any
+			applied map[string]uint64
+			through uint64
+		)
+
+		entry, err = s.kv.Get(key)
+		if err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+			return false, err
+		}
+
+		if entry != nil {
+			se, err := s.unpack(entry)
+			if err != nil {
+				return false, err
+			}
+			cur = se.Value
+			applied = se.Applied
+			through = se.AppliedThrough
+		}
+
+		if through >= event.Sequence || applied[event.Subject] >= event.Sequence {
+			return false, nil
+		}
+
+		v, err := fn(cur)
+		if err != nil {
+			return false, err
+		}
+
+		if applied == nil {
+			applied = make(map[string]uint64)
+		}
+		applied[event.Subject] = event.Sequence
+		through = pruneApplied(applied, through)
+
+		b, err := s.pack(v, applied, through)
+		if err != nil {
+			return false, err
+		}
+
+		if entry == nil {
+			_, err = s.kv.Create(key, b)
+		} else {
+			_, err = s.kv.Update(key, b, entry.Revision())
+		}
+		if err == nil {
+			return true, nil
+		}
+		// Retry if the key was created or updated concurrently.
+		if !indexEntryChanged(s.kv, key, entry) {
+			return false, err
+		}
+	}
+	return false, err
+}
+
+// pruneApplied removes the oldest sequences from applied once more than
+// maxAppliedSubjects are tracked and returns the high-water mark raised to
+// the latest sequence removed.
+func pruneApplied(applied map[string]uint64, through uint64) uint64 {
+	if len(applied) <= maxAppliedSubjects {
+		return through
+	}
+
+	seqs := make([]uint64, 0, len(applied))
+	for _, seq := range applied {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] > seqs[j] })
+
+	if cut := seqs[maxAppliedSubjects]; cut > through {
+		through = cut
+	}
+	for subject, seq := range applied {
+		if seq <= through {
+			delete(applied, subject)
+		}
+	}
+	return through
+}
+
+// Get returns the entry for the key. If the key does not exist, nats.ErrKeyNotFound
+// is returned.
+func (s *StateStore) Get(key string) (*StateEntry, error) {
+	e, err := s.kv.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.unpack(e)
+}
+
+// Delete deletes the key.
+func (s *StateStore) Delete(key string) error {
+	return s.kv.Delete(key)
+}
+
+// StateWatcher receives changes to keys in a state store.
+type StateWatcher struct {
+	s *StateStore
+	w nats.KeyWatcher
+}
+
+// Next blocks until the next change is received or the context is done.
+// The current values are received first.
+func (w *StateWatcher) Next(ctx context.Context) (*StateEntry, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case e, ok := <-w.w.Updates():
+			if !ok {
+				return nil, nats.ErrBadSubscription
+			}
+			// Marker that the initial values have been received.
+			if e == nil {
+				continue
+			}
+			return w.s.unpack(e)
+		}
+	}
+}
+
+// Stop stops the watcher.
+func (w *StateWatcher) Stop() error {
+	return w.w.Stop()
+}
+
+// Watch watches keys matching the pattern, which may contain wildcards. The
+// watcher is stopped when the context is done.
+func (s *StateStore) Watch(ctx context.Context, keys string) (*StateWatcher, error) {
+	w, err := s.kv.Watch(keys, nats.Context(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return &StateWatcher{
+		s: s,
+		w: w,
+	}, nil
+}
+
+// StateStore returns a state store with the name, creating the underlying
+// KV bucket if it does not exist.
+func (r *Rita) StateStore(name string, opts ...StateStoreOption) (*StateStore, error) {
+	o := stateStoreOpts{
+		history: 1,
+	}
+
+	for _, opt := range opts {
+		if err := opt.stateStoreOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	bucket := r.resourceName(name)
+
+	kv, err := r.js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = r.js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:   bucket,
+			History:  o.history,
+			TTL:      o.ttl,
+			Storage:  o.storage,
+			Replicas: o.replicas,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &StateStore{
+		name: name,
+		kv:   kv,
+		rt:   r,
+	}, nil
+}
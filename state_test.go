@@ -0,0 +1,174 @@
+package rita
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+func TestStateStore(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	ss, err := r.StateStore("carts", StateStorage(nats.MemoryStorage))
+	is.NoErr(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	w, err := ss.Watch(ctx, "*")
+	is.NoErr(err)
+	defer w.Stop() //nolint
+
+	rev, err := ss.Put("1", &OrderPlaced{ID: "1"})
+	is.NoErr(err)
+
+	e, err := ss.Get("1")
+	is.NoErr(err)
+	is.Equal(e.Type, "order-placed")
+	is.Equal(e.Value, &OrderPlaced{ID: "1"})
+	is.Equal(e.Revision, rev)
+
+	_, err = ss.Update("1", &OrderShipped{ID: "1"}, rev+1)
+	is.True(err != nil)
+
+	_, err = ss.Update("1", &OrderShipped{ID: "1"}, rev)
+	is.NoErr(err)
+
+	_, err = ss.Update("2", &OrderPlaced{ID: "2"}, 0)
+	is.NoErr(err)
+
+	is.NoErr(ss.Delete("2"))
+
+	_, err = ss.Get("2")
+	is.Err(err, nats.ErrKeyNotFound)
+
+	var entries []*StateEntry
+	for i := 0; i < 4; i++ {
+		e, err := w.Next(ctx)
+		is.NoErr(err)
+		entries = append(entries, e)
+	}
+
+	is.Equal(entries[1].Type, "order-shipped")
+	is.Equal(entries[2].Key, "2")
+	is.True(entries[3].Deleted)
+}
+
+func TestStateStoreApply(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr := newOrderTypes(t)
+	is.NoErr(tr.Add("order-summary", &types.Type{
+		Init: func() any { return &OrderSummary{} },
+	}))
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ss, err := r.StateStore("order-summaries", StateStorage(nats.MemoryStorage))
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	events, _, err := es.Load(ctx, "orders.*")
+	is.NoErr(err)
+
+	apply := func(event *Event) (bool, error) {
+		return ss.Apply("all", event, func(v any) (any, error) {
+			m, _ := v.(*OrderSummary)
+			if m == nil {
+				m = &OrderSummary{}
+			}
+			return m, m.Evolve(event)
+		})
+	}
+
+	for _, e := range events {
+		ok, err := apply(e)
+		is.NoErr(err)
+		is.True(ok)
+	}
+
+	// Redelivered events are not applied again.
+	ok, err := apply(events[1])
+	is.NoErr(err)
+	is.True(!ok)
+
+	e, err := ss.Get("all")
+	is.NoErr(err)
+	is.Equal(e.Value.(*OrderSummary).Placed, 2)
+	is.Equal(e.Value.(*OrderSummary).Shipped, 1)
+	is.Equal(e.Applied, map[string]uint64{"orders.1": 2, "orders.2": 3})
+
+	_, err = ss.Apply("all", &Event{}, nil)
+	is.Err(err, nil)
+
+	// Only the most recently applied subjects are tracked.
+	count := func(event *Event) (bool, error) {
+		return ss.Apply("count", event, func(v any) (any, error) {
+			m, _ := v.(*OrderSummary)
+			if m == nil {
+				m = &OrderSummary{}
+			}
+			m.Placed++
+			return m, nil
+		})
+	}
+
+	n := maxAppliedSubjects + 10
+	for i := 1; i <= n; i++ {
+		ok, err := count(&Event{Subject: fmt.Sprintf("orders.%d", i), Sequence: uint64(i)})
+		is.NoErr(err)
+		is.True(ok)
+	}
+
+	e, err = ss.Get("count")
+	is.NoErr(err)
+	is.Equal(len(e.Applied), maxAppliedSubjects)
+	is.Equal(e.AppliedThrough, uint64(10))
+	is.Equal(e.Applied[fmt.Sprintf("orders.%d", n)], uint64(n))
+
+	// Redelivered events of untracked subjects are not applied again.
+	ok, err = count(&Event{Subject: "orders.1", Sequence: 1})
+	is.NoErr(err)
+	is.True(!ok)
+	ok, err = count(&Event{Subject: "orders.1", Sequence: uint64(n + 1)})
+	is.NoErr(err)
+	is.True(ok)
+
+	e, err = ss.Get("count")
+	is.NoErr(err)
+	is.Equal(e.Value.(*OrderSummary).Placed, n+1)
+	is.Equal(len(e.Applied), maxAppliedSubjects)
+	is.Equal(e.AppliedThrough, uint64(11))
+}
@@ -0,0 +1,41 @@
+package rita
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// DiffState evolves a model of the subject up to and including each of the
+// sequences and returns a human-readable structural diff of the model at
+// sequence a to the model at sequence b, or an empty string if they are
+// equal. A sequence of zero is the initial state. The model function must
+// return a new model each time it is called. This is useful for debugging
+// unexpected state transitions and explaining changes in an audit.
+func (s *EventStore) DiffState(ctx context.Context, subject string, model func() Evolver, a, b uint64) (string, error) {
+	ma, err := s.evolveUntil(ctx, subject, model(), a)
+	if err != nil {
+		return "", err
+	}
+
+	mb, err := s.evolveUntil(ctx, subject, model(), b)
+	if err != nil {
+		return "", err
+	}
+
+	// Models are compared including unexported fields.
+	return cmp.Diff(ma, mb, cmp.Exporter(func(reflect.Type) bool { return true })), nil
+}
+
+// evolveUntil evolves the model up to and including the sequence.
+func (s *EventStore) evolveUntil(ctx context.Context, subject string, model Evolver, seq uint64) (Evolver, error) {
+	if seq == 0 {
+		return model, nil
+	}
+	if _, err := s.Evolve(ctx, subject, model, UntilSequence(seq)); err != nil {
+		return nil, fmt.Errorf("evolve until %d: %w", seq, err)
+	}
+	return model, nil
+}
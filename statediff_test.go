@@ -0,0 +1,48 @@
+package rita
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreDiffState(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	model := func() Evolver { return &OrderStats{} }
+
+	diff, err := es.DiffState(ctx, "orders.1", model, 1, 1)
+	is.NoErr(err)
+	is.Equal(diff, "")
+
+	diff, err = es.DiffState(ctx, "orders.1", model, 1, 2)
+	is.NoErr(err)
+	is.True(strings.Contains(strings.Join(strings.Fields(diff), " "), "+ OrdersShipped: 1"))
+
+	diff, err = es.DiffState(ctx, "orders.1", model, 0, 2)
+	is.NoErr(err)
+	is.True(strings.Contains(strings.Join(strings.Fields(diff), " "), "+ OrdersPlaced: 1"))
+}
@@ -0,0 +1,130 @@
+package rita
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// TypeStats are the count and time distribution of the events of a type.
+type TypeStats struct {
+	// Type of the events.
+	Type string
+
+	// Count of events.
+	Count uint64
+
+	// First and Last are the earliest and latest event times. A Last time
+	// far in the past indicates the type stopped flowing.
+	First time.Time
+	Last  time.Time
+
+	// Histogram maps the start of each interval, in UTC, to the number of
+	// events whose time is in the interval.
+	Histogram map[time.Time]uint64
+}
+
+type statsOpts struct {
+	interval time.Duration
+}
+
+type statsOptFn func(o *statsOpts) error
+
+func (f statsOptFn) statsOpt(o *statsOpts) error {
+	return f(o)
+}
+
+// StatsOption is an option for computing type stats.
+type StatsOption interface {
+	statsOpt(o *statsOpts) error
+}
+
+// StatsInterval sets the interval of the histogram buckets. Default is one
+// hour.
+func StatsInterval(d time.Duration) StatsOption {
+	return statsOptFn(func(o *statsOpts) error {
+		if d <= 0 {
+			return fmt.Errorf("rita: stats interval must be positive")
+		}
+		o.interval = d
+		return nil
+	})
+}
+
+// TypeStats computes the count and histogram of event times per event type
+// for the events matching the subject, which may contain wildcards. Only the
+// headers of events are read. The stats are sorted by type.
+func (s *EventStore) TypeStats(ctx context.Context, subject string, opts ...StatsOption) ([]*TypeStats, error) {
+	if err := validateSubject(subject, true); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorize(ctx, OpLoad, subject, nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	o := statsOpts{
+		interval: time.Hour,
+	}
+	for _, opt := range opts {
+		if err := opt.statsOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, nil, s.rt.loadTimeoutFor(ctx))
+	defer cancel()
+
+	index := make(map[string]*TypeStats)
+
+	lo := loadOpts{headersOnly: true}
+	_, err := s.readMsgs(ctx, subject, &lo, func(seq uint64, msg *nats.Msg) error {
+		typ := msg.Header.Get(eventTypeHdr)
+
+		ts, ok := index[typ]
+		if !ok {
+			ts = &TypeStats{
+				Type:      typ,
+				Histogram: make(map[time.Time]uint64),
+			}
+			index[typ] = ts
+		}
+		ts.Count++
+
+		t, err := time.Parse(eventTimeFormat, msg.Header.Get(eventTimeHdr))
+		if err != nil {
+			return nil
+		}
+		t = t.UTC()
+
+		if ts.First.IsZero() || t.Before(ts.First) {
+			ts.First = t
+		}
+		if t.After(ts.Last) {
+			ts.Last = t
+		}
+		ts.Histogram[t.Truncate(o.interval)]++
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*TypeStats, 0, len(index))
+	for _, ts := range index {
+		stats = append(stats, ts)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Type < stats[j].Type
+	})
+
+	return stats, nil
+}
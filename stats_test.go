@@ -0,0 +1,69 @@
+package rita
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestEventStoreTypeStats(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	t0 := time.Date(2022, 5, 1, 10, 15, 0, 0, time.UTC)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}, Time: t0},
+		{Data: &OrderShipped{ID: "1"}, Time: t0.Add(2 * time.Hour)},
+	})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.2", []*Event{
+		{Data: &OrderPlaced{ID: "2"}, Time: t0.Add(30 * time.Minute)},
+	})
+	is.NoErr(err)
+
+	_, err = es.TypeStats(ctx, "orders.*", StatsInterval(0))
+	is.True(err != nil)
+
+	stats, err := es.TypeStats(ctx, "orders.*")
+	is.NoErr(err)
+	is.Equal(len(stats), 2)
+
+	placed := stats[0]
+	is.Equal(placed.Type, "order-placed")
+	is.Equal(placed.Count, uint64(2))
+	is.Equal(placed.First, t0)
+	is.Equal(placed.Last, t0.Add(30*time.Minute))
+	is.Equal(placed.Histogram, map[time.Time]uint64{
+		t0.Truncate(time.Hour): 2,
+	})
+
+	shipped := stats[1]
+	is.Equal(shipped.Type, "order-shipped")
+	is.Equal(shipped.Count, uint64(1))
+
+	stats, err = es.TypeStats(ctx, "orders.1", StatsInterval(24*time.Hour))
+	is.NoErr(err)
+	is.Equal(len(stats), 2)
+	is.Equal(stats[0].Count, uint64(1))
+	is.Equal(stats[0].Histogram, map[time.Time]uint64{
+		t0.Truncate(24 * time.Hour): 1,
+	})
+}
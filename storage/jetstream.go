@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+)
+
+// JetStream is a backend which stores messages in a JetStream stream. The
+// stream must exist and be bound to the subjects appended to. Event stores do
+// not use it by default since they access their stream directly. It serves as
+// the reference implementation the semantics of other backends are tested
+// against.
+type JetStream struct {
+	nc     *nats.Conn
+	js     nats.JetStreamContext
+	stream string
+}
+
+// NewJetStream returns a backend for the stream.
+func NewJetStream(nc *nats.Conn, stream string) (*JetStream, error) {
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	return &JetStream{
+		nc:     nc,
+		js:     js,
+		stream: stream,
+	}, nil
+}
+
+// errCodeWrongLastSequence is the JetStream API error code of a publish with
+// an expected last sequence which does not match.
+const errCodeWrongLastSequence = 10071
+
+type apiError struct {
+	Code        int    `json:"code"`
+	ErrCode     uint16 `json:"err_code"`
+	Description string `json:"description"`
+}
+
+type pubAckResponse struct {
+	Error    *apiError `json:"error"`
+	Stream   string    `json:"stream"`
+	Sequence uint64    `json:"seq"`
+}
+
+type getLastRequest struct {
+	LastBySubject string `json:"last_by_subj"`
+}
+
+type getLastResponse struct {
+	Error   *apiError `json:"error"`
+	Message *struct {
+		Sequence uint64 `json:"seq"`
+	} `json:"message"`
+}
+
+// lastSeq returns the sequence of the last message matching the filter, or
+// zero if there are none.
+func (b *JetStream) lastSeq(ctx context.Context, filter string) (uint64, error) {
+	data, _ := json.Marshal(&getLastRequest{
+		LastBySubject: filter,
+	})
+
+	msg, err := b.nc.RequestWithContext(ctx, fmt.Sprintf("$JS.API.STREAM.MSG.GET.%s", b.stream), data)
+	if err != nil {
+		return 0, err
+	}
+
+	var rep getLastResponse
+	if err := json.Unmarshal(msg.Data, &rep); err != nil {
+		return 0, err
+	}
+
+	if rep.Error != nil {
+		if rep.Error.Code == 404 {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("%s (%d)", rep.Error.Description, rep.Error.Code)
+	}
+
+	return rep.Message.Sequence, nil
+}
+
+// message converts a message received from a consumer.
+func message(msg *nats.Msg) (*Message, error) {
+	md, err := msg.Metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject:  msg.Subject,
+		Sequence: md.Sequence.Stream,
+		Time:     md.Timestamp,
+		Header:   msg.Header,
+		Data:     msg.Data,
+	}, nil
+}
+
+// Append implements Backend. Each message is published separately, so a
+// failure may leave some messages of the batch stored.
+func (b *JetStream) Append(ctx context.Context, subject string, msgs []*Message, expSeq *uint64) error {
+	for i, m := range msgs {
+		msg := nats.NewMsg(subject)
+		for k, v := range m.Header {
+			msg.Header[k] = v
+		}
+		msg.Data = m.Data
+
+		if i == 0 && expSeq != nil {
+			msg.Header.Set(nats.ExpectedLastSubjSeqHdr, strconv.FormatUint(*expSeq, 10))
+		}
+
+		seq, err := b.publish(ctx, msg)
+		if err != nil {
+			return err
+		}
+
+		m.Subject = subject
+		m.Sequence = seq
+	}
+
+	return nil
+}
+
+// publish publishes the message and returns its sequence. The ack is decoded
+// directly, since the JetStream context does not return the code of errors.
+func (b *JetStream) publish(ctx context.Context, msg *nats.Msg) (uint64, error) {
+	rep, err := b.nc.RequestMsgWithContext(ctx, msg)
+	if errors.Is(err, nats.ErrNoResponders) {
+		return 0, nats.ErrNoStreamResponse
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var pa pubAckResponse
+	if err := json.Unmarshal(rep.Data, &pa); err != nil {
+		return 0, nats.ErrInvalidJSAck
+	}
+	if pa.Error != nil {
+		if pa.Error.ErrCode == errCodeWrongLastSequence {
+			return 0, ErrSequenceConflict
+		}
+		return 0, fmt.Errorf("%s (%d)", pa.Error.Description, pa.Error.Code)
+	}
+	if pa.Stream == "" {
+		return 0, nats.ErrInvalidJSAck
+	}
+	return pa.Sequence, nil
+}
+
+// LoadRange implements Backend.
+func (b *JetStream) LoadRange(ctx context.Context, filter string, after, until uint64, fn func(*Message) error) error {
+	last, err := b.lastSeq(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if last <= after || (until > 0 && until <= after) {
+		return nil
+	}
+
+	sub, err := b.js.SubscribeSync(filter,
+		nats.OrderedConsumer(),
+		nats.BindStream(b.stream),
+		nats.StartSequence(after+1),
+	)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe() //nolint
+
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		m, err := message(msg)
+		if err != nil {
+			return err
+		}
+
+		if until > 0 && m.Sequence > until {
+			return nil
+		}
+
+		if err := fn(m); err != nil {
+			return err
+		}
+
+		if m.Sequence >= last {
+			return nil
+		}
+	}
+}
+
+// LastForSubject implements Backend.
+func (b *JetStream) LastForSubject(ctx context.Context, filter string) (*Message, error) {
+	seq, err := b.lastSeq(ctx, filter)
+	if err != nil || seq == 0 {
+		return nil, err
+	}
+
+	raw, err := b.js.GetMsg(b.stream, seq, nats.Context(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject:  raw.Subject,
+		Sequence: raw.Sequence,
+		Time:     raw.Time,
+		Header:   raw.Header,
+		Data:     raw.Data,
+	}, nil
+}
+
+// Watch implements Backend.
+func (b *JetStream) Watch(ctx context.Context, filter string, after uint64) (Watcher, error) {
+	sopts := []nats.SubOpt{
+		nats.OrderedConsumer(),
+		nats.BindStream(b.stream),
+	}
+	if after > 0 {
+		sopts = append(sopts, nats.StartSequence(after+1))
+	} else {
+		sopts = append(sopts, nats.DeliverAll())
+	}
+
+	sub, err := b.js.SubscribeSync(filter, sopts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jetStreamWatcher{sub: sub}, nil
+}
+
+type jetStreamWatcher struct {
+	sub *nats.Subscription
+}
+
+func (w *jetStreamWatcher) Next(ctx context.Context) (*Message, error) {
+	msg, err := w.sub.NextMsgWithContext(ctx)
+	if errors.Is(err, nats.ErrBadSubscription) {
+		return nil, ErrStopped
+	}
+	if err != nil {
+		return nil, err
+	}
+	return message(msg)
+}
+
+func (w *jetStreamWatcher) Stop() error {
+	return w.sub.Unsubscribe()
+}
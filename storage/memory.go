@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Memory is a backend which stores messages in memory. It is intended for
+// tests and development.
+type Memory struct {
+	mu     sync.Mutex
+	msgs   []*Message
+	notify chan struct{}
+}
+
+// NewMemory returns an empty in-memory backend.
+func NewMemory() *Memory {
+	return &Memory{
+		notify: make(chan struct{}),
+	}
+}
+
+// copyMessage returns a copy so callers cannot modify stored messages.
+func copyMessage(m *Message) *Message {
+	cp := *m
+	if m.Header != nil {
+		cp.Header = make(nats.Header, len(m.Header))
+		for k, v := range m.Header {
+			cp.Header[k] = append([]string(nil), v...)
+		}
+	}
+	cp.Data = append([]byte(nil), m.Data...)
+	return &cp
+}
+
+// last returns the last message matching the filter. The lock must be held.
+func (b *Memory) last(filter string) *Message {
+	for i := len(b.msgs) - 1; i >= 0; i-- {
+		if MatchSubject(filter, b.msgs[i].Subject) {
+			return b.msgs[i]
+		}
+	}
+	return nil
+}
+
+// Append implements Backend.
+func (b *Memory) Append(ctx context.Context, subject string, msgs []*Message, expSeq *uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if expSeq != nil {
+		var seq uint64
+		if m := b.last(subject); m != nil {
+			seq = m.Sequence
+		}
+		if seq != *expSeq {
+			return ErrSequenceConflict
+		}
+	}
+
+	now := time.Now()
+	for _, m := range msgs {
+		m.Subject = subject
+		m.Sequence = uint64(len(b.msgs)) + 1
+		m.Time = now
+		b.msgs = append(b.msgs, copyMessage(m))
+	}
+
+	// Wake up watchers.
+	close(b.notify)
+	b.notify = make(chan struct{})
+
+	return nil
+}
+
+// LoadRange implements Backend.
+func (b *Memory) LoadRange(ctx context.Context, filter string, after, until uint64, fn func(*Message) error) error {
+	b.mu.Lock()
+	msgs := b.msgs
+	b.mu.Unlock()
+
+	// Sequences are indexes offset by one.
+	for _, m := range msgs[min(after, uint64(len(msgs))):] {
+		if until > 0 && m.Sequence > until {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !MatchSubject(filter, m.Subject) {
+			continue
+		}
+		if err := fn(copyMessage(m)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LastForSubject implements Backend.
+func (b *Memory) LastForSubject(ctx context.Context, filter string) (*Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if m := b.last(filter); m != nil {
+		return copyMessage(m), nil
+	}
+	return nil, nil
+}
+
+// Watch implements Backend.
+func (b *Memory) Watch(ctx context.Context, filter string, after uint64) (Watcher, error) {
+	return &memoryWatcher{
+		b:      b,
+		filter: filter,
+		seq:    after,
+		stop:   make(chan struct{}),
+	}, nil
+}
+
+type memoryWatcher struct {
+	b      *Memory
+	filter string
+	seq    uint64
+
+	once sync.Once
+	stop chan struct{}
+}
+
+func (w *memoryWatcher) Next(ctx context.Context) (*Message, error) {
+	for {
+		w.b.mu.Lock()
+		msgs := w.b.msgs
+		notify := w.b.notify
+		w.b.mu.Unlock()
+
+		for _, m := range msgs[min(w.seq, uint64(len(msgs))):] {
+			w.seq = m.Sequence
+			if MatchSubject(w.filter, m.Subject) {
+				return copyMessage(m), nil
+			}
+		}
+
+		select {
+		case <-notify:
+		case <-w.stop:
+			return nil, ErrStopped
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (w *memoryWatcher) Stop() error {
+	w.once.Do(func() { close(w.stop) })
+	return nil
+}
+
+func min(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
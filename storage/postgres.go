@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	tableRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// watchPageSize is the max number of messages a watcher reads per poll.
+const watchPageSize = 256
+
+// Postgres is a backend which stores messages in a Postgres table. The
+// database handle must use a Postgres driver, such as pgx or lib/pq, which
+// the caller imports.
+//
+// Appends are serialized by a lock on the table, so sequences are committed
+// in order and readers never observe gaps which are filled later. Messages
+// with the same Nats-Msg-Id header, i.e. the event ID, are deduplicated like
+// JetStream does, although without a window.
+type Postgres struct {
+	db    *sql.DB
+	table string
+
+	// PollInterval is the interval at which watchers poll for new messages.
+	// Default is 250ms.
+	PollInterval time.Duration
+}
+
+// NewPostgres returns a backend storing messages in the table.
+func NewPostgres(db *sql.DB, table string) (*Postgres, error) {
+	if !tableRegex.MatchString(table) {
+		return nil, fmt.Errorf("storage: invalid table name %q", table)
+	}
+
+	return &Postgres{
+		db:           db,
+		table:        table,
+		PollInterval: 250 * time.Millisecond,
+	}, nil
+}
+
+// Init creates the table and its index if they do not exist.
+func (b *Postgres) Init(ctx context.Context) error {
+	_, err := b.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			seq BIGSERIAL PRIMARY KEY,
+			subject TEXT NOT NULL,
+			time TIMESTAMPTZ NOT NULL DEFAULT now(),
+			msg_id TEXT UNIQUE,
+			header JSONB,
+			data BYTEA
+		);
+		CREATE INDEX IF NOT EXISTS %[1]s_subject_seq ON %[1]s (subject, seq);
+	`, b.table))
+	return err
+}
+
+// subjectFilter returns the SQL condition matching the filter using the
+// numbered parameter and the argument of the parameter.
+func subjectFilter(filter string, n int) (string, string) {
+	if !strings.ContainsAny(filter, "*>") {
+		return fmt.Sprintf("subject = $%d", n), filter
+	}
+	return fmt.Sprintf("subject ~ $%d", n), subjectRegex(filter)
+}
+
+// subjectRegex translates a subject filter into a POSIX regular expression.
+func subjectRegex(filter string) string {
+	toks := strings.Split(filter, ".")
+	for i, t := range toks {
+		switch t {
+		case "*":
+			toks[i] = `[^.]+`
+		case ">":
+			toks[i] = `.+`
+		default:
+			toks[i] = regexp.QuoteMeta(t)
+		}
+	}
+	return "^" + strings.Join(toks, `\.`) + "$"
+}
+
+// Append implements Backend.
+func (b *Postgres) Append(ctx context.Context, subject string, msgs []*Message, expSeq *uint64) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint
+
+	// Concurrent reads are allowed, but appends are serialized.
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("LOCK TABLE %s IN SHARE ROW EXCLUSIVE MODE", b.table))
+	if err != nil {
+		return err
+	}
+
+	for i, m := range msgs {
+		var msgID sql.NullString
+		if id := m.Header.Get(nats.MsgIdHdr); id != "" {
+			msgID = sql.NullString{String: id, Valid: true}
+
+			// Like JetStream, a duplicate is acknowledged with the sequence
+			// of the original before the expected sequence is checked.
+			seq, err := b.lookup(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			if seq > 0 {
+				m.Subject = subject
+				m.Sequence = seq
+				continue
+			}
+		}
+
+		if i == 0 && expSeq != nil {
+			var last sql.NullInt64
+			err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT max(seq) FROM %s WHERE subject = $1", b.table), subject).Scan(&last)
+			if err != nil {
+				return err
+			}
+			if uint64(last.Int64) != *expSeq {
+				return ErrSequenceConflict
+			}
+		}
+
+		hdr, err := json.Marshal(m.Header)
+		if err != nil {
+			return err
+		}
+
+		var (
+			seq int64
+			tm  time.Time
+		)
+		err = tx.QueryRowContext(ctx,
+			fmt.Sprintf("INSERT INTO %s (subject, msg_id, header, data) VALUES ($1, $2, $3, $4) RETURNING seq, time", b.table),
+			subject, msgID, string(hdr), m.Data,
+		).Scan(&seq, &tm)
+		if err != nil {
+			return err
+		}
+
+		m.Subject = subject
+		m.Sequence = uint64(seq)
+		m.Time = tm
+	}
+
+	return tx.Commit()
+}
+
+// lookup returns the sequence of the message with the ID, or zero if there is
+// none.
+func (b *Postgres) lookup(ctx context.Context, tx *sql.Tx, id string) (uint64, error) {
+	var seq int64
+	err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT seq FROM %s WHERE msg_id = $1", b.table), id).Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return uint64(seq), err
+}
+
+// scanMessage scans a row into a message.
+func scanMessage(rows interface{ Scan(...any) error }) (*Message, error) {
+	var (
+		m   Message
+		seq int64
+		hdr []byte
+	)
+	if err := rows.Scan(&seq, &m.Subject, &m.Time, &hdr, &m.Data); err != nil {
+		return nil, err
+	}
+	m.Sequence = uint64(seq)
+
+	if len(hdr) > 0 {
+		if err := json.Unmarshal(hdr, &m.Header); err != nil {
+			return nil, err
+		}
+	}
+
+	return &m, nil
+}
+
+// LoadRange implements Backend.
+func (b *Postgres) LoadRange(ctx context.Context, filter string, after, until uint64, fn func(*Message) error) error {
+	return b.loadRange(ctx, filter, after, until, 0, fn)
+}
+
+// loadRange is LoadRange reading at most limit messages, if not zero.
+func (b *Postgres) loadRange(ctx context.Context, filter string, after, until uint64, limit int, fn func(*Message) error) error {
+	cond, arg := subjectFilter(filter, 4)
+
+	q := fmt.Sprintf("SELECT seq, subject, time, header, data FROM %s WHERE seq > $1 AND ($2::bigint = 0 OR seq <= $2) AND %s ORDER BY seq LIMIT $3", b.table, cond)
+
+	// A null limit is no limit.
+	lim := sql.NullInt64{Int64: int64(limit), Valid: limit > 0}
+
+	rows, err := b.db.QueryContext(ctx, q, int64(after), int64(until), lim, arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// LastForSubject implements Backend.
+func (b *Postgres) LastForSubject(ctx context.Context, filter string) (*Message, error) {
+	cond, arg := subjectFilter(filter, 1)
+
+	q := fmt.Sprintf("SELECT seq, subject, time, header, data FROM %s WHERE %s ORDER BY seq DESC LIMIT 1", b.table, cond)
+
+	m, err := scanMessage(b.db.QueryRowContext(ctx, q, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return m, err
+}
+
+// Watch implements Backend by polling for new messages.
+func (b *Postgres) Watch(ctx context.Context, filter string, after uint64) (Watcher, error) {
+	return &pollWatcher{
+		b:        b,
+		filter:   filter,
+		seq:      after,
+		interval: b.PollInterval,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// pollWatcher reads the messages after the last one returned a page at a
+// time, so a watcher far behind does not buffer all of them.
+type pollWatcher struct {
+	b        *Postgres
+	filter   string
+	seq      uint64
+	interval time.Duration
+	buf      []*Message
+
+	once sync.Once
+	stop chan struct{}
+}
+
+func (w *pollWatcher) Next(ctx context.Context) (*Message, error) {
+	for len(w.buf) == 0 {
+		err := w.b.loadRange(ctx, w.filter, w.seq, 0, watchPageSize, func(m *Message) error {
+			w.buf = append(w.buf, m)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(w.buf) > 0 {
+			break
+		}
+
+		select {
+		case <-time.After(w.interval):
+		case <-w.stop:
+			return nil, ErrStopped
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	m := w.buf[0]
+	w.buf = w.buf[1:]
+	w.seq = m.Sequence
+	return m, nil
+}
+
+func (w *pollWatcher) Stop() error {
+	w.once.Do(func() { close(w.stop) })
+	return nil
+}
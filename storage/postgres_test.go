@@ -0,0 +1,67 @@
+//go:build postgres
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nuid"
+
+	_ "github.com/lib/pq"
+)
+
+// TestPostgresBackend runs the backend tests against a Postgres database in
+// a table which is dropped afterwards. It is excluded from the default build
+// and run with:
+//
+//	RITA_POSTGRES_DSN=postgres://localhost/rita?sslmode=disable go test -tags postgres -run TestPostgres ./storage
+func TestPostgresBackend(t *testing.T) {
+	dsn := os.Getenv("RITA_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("RITA_POSTGRES_DSN not set")
+	}
+
+	is := testutil.NewIs(t)
+
+	db, err := sql.Open("postgres", dsn)
+	is.NoErr(err)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	table := fmt.Sprintf("rita_test_%s", strings.ToLower(nuid.Next()))
+
+	b, err := NewPostgres(db, table)
+	is.NoErr(err)
+	b.PollInterval = 10 * time.Millisecond
+
+	is.NoErr(b.Init(ctx))
+	defer db.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", table)) //nolint
+
+	testBackend(t, b)
+
+	// A watcher far behind reads a page at a time.
+	msgs := make([]*Message, watchPageSize+1)
+	for i := range msgs {
+		msgs[i] = &Message{Data: []byte("5")}
+	}
+	is.NoErr(b.Append(ctx, "orders.3", msgs, nil))
+
+	w, err := b.Watch(ctx, "orders.3", 0)
+	is.NoErr(err)
+	defer w.Stop() //nolint
+
+	for i := range msgs {
+		m, err := w.Next(ctx)
+		is.NoErr(err)
+		is.Equal(m.Sequence, msgs[i].Sequence)
+		is.True(len(w.(*pollWatcher).buf) < watchPageSize)
+	}
+}
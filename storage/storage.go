@@ -0,0 +1,93 @@
+// Package storage defines an optional plug-in point for storing the messages
+// of an event store outside of its JetStream stream, such as in memory or in
+// a SQL database. Event stores use their stream directly unless a Backend is
+// set with rita.EventStoreBackend, in which case features depending on
+// JetStream are not supported.
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrSequenceConflict = errors.New("storage: sequence conflict")
+	ErrStopped          = errors.New("storage: watcher stopped")
+)
+
+// Message is a message stored by a backend. The envelope of an event is
+// carried in the header, so a backend must store and return the header as
+// is.
+type Message struct {
+	// Subject of the message.
+	Subject string
+
+	// Sequence is the position of the message in the backend. Sequences are
+	// positive and strictly increasing across all subjects.
+	Sequence uint64
+
+	// Time the message was stored.
+	Time time.Time
+
+	Header nats.Header
+	Data   []byte
+}
+
+// Backend stores messages by subject. Filters are subjects which may contain
+// the wildcards "*" and ">" following NATS subject semantics.
+type Backend interface {
+	// Append stores the messages for the subject in order. If expSeq is not
+	// nil, it must be the sequence of the last message of the subject, or
+	// zero if there are none, otherwise ErrSequenceConflict is returned and
+	// no messages are stored. On success, the Subject and Sequence of each
+	// message are set.
+	Append(ctx context.Context, subject string, msgs []*Message, expSeq *uint64) error
+
+	// LoadRange calls fn for each message matching the filter in sequence
+	// order, starting after the sequence, up to and including the until
+	// sequence, if not zero. It returns once the last message at the time
+	// of the call is read.
+	LoadRange(ctx context.Context, filter string, after, until uint64, fn func(*Message) error) error
+
+	// LastForSubject returns the last message matching the filter, or nil
+	// if there are none.
+	LastForSubject(ctx context.Context, filter string) (*Message, error)
+
+	// Watch returns a watcher of the messages matching the filter, starting
+	// after the sequence, including messages appended later.
+	Watch(ctx context.Context, filter string, after uint64) (Watcher, error)
+}
+
+// Watcher returns messages as they are stored.
+type Watcher interface {
+	// Next blocks until the next message is available or the context is
+	// done.
+	Next(ctx context.Context) (*Message, error)
+
+	// Stop stops the watcher.
+	Stop() error
+}
+
+// MatchSubject returns true if the subject matches the filter.
+func MatchSubject(filter, subject string) bool {
+	ft := strings.Split(filter, ".")
+	st := strings.Split(subject, ".")
+
+	for i, t := range ft {
+		if t == ">" {
+			return len(st) > i
+		}
+		if i >= len(st) {
+			return false
+		}
+		if t != "*" && t != st[i] {
+			return false
+		}
+	}
+
+	return len(ft) == len(st)
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestMatchSubject(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	is.True(MatchSubject("orders.1", "orders.1"))
+	is.True(MatchSubject("orders.*", "orders.1"))
+	is.True(MatchSubject("orders.>", "orders.1.items"))
+	is.True(MatchSubject(">", "orders"))
+	is.True(!MatchSubject("orders.*", "orders.1.items"))
+	is.True(!MatchSubject("orders.>", "orders"))
+	is.True(!MatchSubject("orders.1", "orders.2"))
+}
+
+func TestBackends(t *testing.T) {
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+	defer nc.Close()
+
+	js, _ := nc.JetStream()
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "orders",
+		Subjects: []string{"orders.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsb, err := NewJetStream(nc, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backends := map[string]Backend{
+		"memory":    NewMemory(),
+		"jetstream": jsb,
+	}
+
+	for name, b := range backends {
+		t.Run(name, func(t *testing.T) {
+			testBackend(t, b)
+		})
+	}
+}
+
+func testBackend(t *testing.T, b Backend) {
+	is := testutil.NewIs(t)
+
+	ctx := context.Background()
+
+	last, err := b.LastForSubject(ctx, "orders.>")
+	is.NoErr(err)
+	is.True(last == nil)
+
+	seq := uint64(0)
+	msgs := []*Message{
+		{Header: nats.Header{"rita-type": []string{"a"}}, Data: []byte("1")},
+		{Data: []byte("2")},
+	}
+	is.NoErr(b.Append(ctx, "orders.1", msgs, &seq))
+	is.Equal(msgs[0].Sequence, uint64(1))
+	is.Equal(msgs[1].Sequence, uint64(2))
+
+	err = b.Append(ctx, "orders.1", []*Message{{Data: []byte("3")}}, &seq)
+	is.Err(err, ErrSequenceConflict)
+
+	is.NoErr(b.Append(ctx, "orders.2", []*Message{{Data: []byte("3")}}, &seq))
+
+	last, err = b.LastForSubject(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(last.Sequence, uint64(2))
+	is.Equal(string(last.Data), "2")
+
+	var loaded []*Message
+	is.NoErr(b.LoadRange(ctx, "orders.*", 0, 0, func(m *Message) error {
+		loaded = append(loaded, m)
+		return nil
+	}))
+	is.Equal(len(loaded), 3)
+	is.Equal(loaded[0].Subject, "orders.1")
+	is.Equal(loaded[0].Header.Get("rita-type"), "a")
+	is.Equal(loaded[2].Subject, "orders.2")
+
+	loaded = nil
+	is.NoErr(b.LoadRange(ctx, "orders.1", 1, 0, func(m *Message) error {
+		loaded = append(loaded, m)
+		return nil
+	}))
+	is.Equal(len(loaded), 1)
+	is.Equal(loaded[0].Sequence, uint64(2))
+
+	loaded = nil
+	is.NoErr(b.LoadRange(ctx, "orders.*", 0, 2, func(m *Message) error {
+		loaded = append(loaded, m)
+		return nil
+	}))
+	is.Equal(len(loaded), 2)
+
+	w, err := b.Watch(ctx, "orders.2", 0)
+	is.NoErr(err)
+	defer w.Stop() //nolint
+
+	m, err := w.Next(ctx)
+	is.NoErr(err)
+	is.Equal(m.Sequence, uint64(3))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = b.Append(ctx, "orders.2", []*Message{{Data: []byte("4")}}, nil)
+	}()
+
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	m, err = w.Next(wctx)
+	is.NoErr(err)
+	is.Equal(m.Sequence, uint64(4))
+	is.Equal(string(m.Data), "4")
+}
+
+func TestPostgresSubjectFilter(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	_, err := NewPostgres(nil, "events; drop table x")
+	is.True(err != nil)
+
+	cond, arg := subjectFilter("orders.1", 2)
+	is.Equal(cond, "subject = $2")
+	is.Equal(arg, "orders.1")
+
+	cond, arg = subjectFilter("orders.*.items.>", 1)
+	is.Equal(cond, "subject ~ $1")
+	is.Equal(arg, `^orders\.[^.]+\.items\..+$`)
+}
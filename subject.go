@@ -0,0 +1,107 @@
+package rita
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidSubject = errors.New("rita: invalid subject")
+)
+
+// SubjectParts are the components of a subject following the convention
+// "{store}.{entity}[.{aggregate}]".
+type SubjectParts struct {
+	// Store is the name of the event store.
+	Store string
+
+	// Entity is the identifier of the entity.
+	Entity string
+
+	// Aggregate optionally identifies an aggregate within the entity. This
+	// may contain multiple tokens.
+	Aggregate string
+}
+
+// String returns the subject for the parts.
+func (p *SubjectParts) String() string {
+	if p.Aggregate == "" {
+		return Subject(p.Store, p.Entity)
+	}
+	return Subject(p.Store, p.Entity, p.Aggregate)
+}
+
+// Subject returns a subject following the convention "{store}.{entity}" with
+// optional aggregate tokens appended, e.g. Subject("orders", "1", "address")
+// returns "orders.1.address". The store and entity must be single tokens, that
+// is not contain ".", wildcards, or whitespace.
+func Subject(store, entity string, aggregate ...string) string {
+	toks := append([]string{store, entity}, aggregate...)
+	return strings.Join(toks, ".")
+}
+
+// ParseSubject parses a subject following the convention used by Subject.
+func ParseSubject(s string) (*SubjectParts, error) {
+	if err := validateSubject(s, false); err != nil {
+		return nil, err
+	}
+
+	toks := strings.SplitN(s, ".", 3)
+	if len(toks) < 2 {
+		return nil, fmt.Errorf("%w: %q: expected store and entity tokens", ErrInvalidSubject, s)
+	}
+
+	p := &SubjectParts{
+		Store:  toks[0],
+		Entity: toks[1],
+	}
+	if len(toks) == 3 {
+		p.Aggregate = toks[2]
+	}
+
+	return p, nil
+}
+
+// validateSubject ensures the subject is well-formed and, unless allowed,
+// does not contain wildcards.
+func validateSubject(s string, wildcards bool) error {
+	if s == "" {
+		return fmt.Errorf("%w: empty subject", ErrInvalidSubject)
+	}
+
+	for _, t := range strings.Split(s, ".") {
+		if t == "" {
+			return fmt.Errorf("%w: %q: empty token", ErrInvalidSubject, s)
+		}
+		if strings.ContainsAny(t, " \t\r\n") {
+			return fmt.Errorf("%w: %q: whitespace in token", ErrInvalidSubject, s)
+		}
+		if !wildcards && (t == "*" || t == ">") {
+			return fmt.Errorf("%w: %q: wildcards not allowed", ErrInvalidSubject, s)
+		}
+	}
+
+	return nil
+}
+
+// Subject returns a subject in the store for the entity following the
+// convention used by the package-level Subject function.
+func (s *EventStore) Subject(entity string, aggregate ...string) string {
+	return Subject(s.name, entity, aggregate...)
+}
+
+// checkStoreSubject returns an error if the subject is not in the store, so
+// events are never published to a subject bound to the stream of another
+// store.
+func (s *EventStore) checkStoreSubject(subject string) error {
+	if !strings.HasPrefix(subject, s.name+".") {
+		return fmt.Errorf("%w: %q is not a subject of store %q", ErrInvalidSubject, subject, s.name)
+	}
+	return nil
+}
+
+// filterSubject returns the subject filter matching all events in the store.
+func (s *EventStore) filterSubject() string {
+	return fmt.Sprintf("%s.>", s.name)
+}
@@ -0,0 +1,32 @@
+package rita
+
+import (
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+)
+
+func TestSubject(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	is.Equal(Subject("orders", "1"), "orders.1")
+	is.Equal(Subject("orders", "1", "address"), "orders.1.address")
+
+	p, err := ParseSubject("orders.1.address.home")
+	is.NoErr(err)
+	is.Equal(*p, SubjectParts{
+		Store:     "orders",
+		Entity:    "1",
+		Aggregate: "address.home",
+	})
+	is.Equal(p.String(), "orders.1.address.home")
+
+	p, err = ParseSubject("orders.1")
+	is.NoErr(err)
+	is.Equal(p.Aggregate, "")
+
+	for _, s := range []string{"", "orders", "orders.*", "orders..1", "orders.>", "orders.a b"} {
+		_, err := ParseSubject(s)
+		is.Err(err, ErrInvalidSubject)
+	}
+}
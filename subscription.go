@@ -0,0 +1,378 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const defaultFetchWait = 5 * time.Second
+
+type subscriptionOpts struct {
+	subject       string
+	startSeq      uint64
+	ackWait       time.Duration
+	maxAckPending int
+	maxDeliver    int
+	batch         int
+	retryDelay    time.Duration
+	ackSync       bool
+}
+
+type subscriptionOptFn func(o *subscriptionOpts) error
+
+func (f subscriptionOptFn) subscriptionOpt(o *subscriptionOpts) error {
+	return f(o)
+}
+
+// SubscriptionOption is an option for a durable subscription.
+type SubscriptionOption interface {
+	subscriptionOpt(o *subscriptionOpts) error
+}
+
+// SubscriptionSubject sets the subject filter of the subscription. Default
+// is all subjects of the store.
+func SubscriptionSubject(subject string) SubscriptionOption {
+	return subscriptionOptFn(func(o *subscriptionOpts) error {
+		o.subject = subject
+		return nil
+	})
+}
+
+// SubscriptionStartSequence sets the sequence of the first event delivered
+// when the subscription is first created. Default is the first event.
+func SubscriptionStartSequence(seq uint64) SubscriptionOption {
+	return subscriptionOptFn(func(o *subscriptionOpts) error {
+		o.startSeq = seq
+		return nil
+	})
+}
+
+// SubscriptionAckWait sets the duration the server waits for an ack before
+// redelivering an event. Default is 30 seconds.
+func SubscriptionAckWait(d time.Duration) SubscriptionOption {
+	return subscriptionOptFn(func(o *subscriptionOpts) error {
+		o.ackWait = d
+		return nil
+	})
+}
+
+// SubscriptionMaxAckPending sets the max number of delivered events that
+// have not been acked. Default is 1000.
+func SubscriptionMaxAckPending(n int) SubscriptionOption {
+	return subscriptionOptFn(func(o *subscriptionOpts) error {
+		o.maxAckPending = n
+		return nil
+	})
+}
+
+// SubscriptionMaxDeliver sets the max number of times an event is delivered.
+// Default is no limit.
+func SubscriptionMaxDeliver(n int) SubscriptionOption {
+	return subscriptionOptFn(func(o *subscriptionOpts) error {
+		o.maxDeliver = n
+		return nil
+	})
+}
+
+// SubscriptionBatch sets the max number of events fetched at a time by Run.
+// Default is 100.
+func SubscriptionBatch(n int) SubscriptionOption {
+	return subscriptionOptFn(func(o *subscriptionOpts) error {
+		if n < 1 {
+			return fmt.Errorf("subscription: batch must be positive")
+		}
+		o.batch = n
+		return nil
+	})
+}
+
+// SubscriptionRetryDelay sets the delay before an event is redelivered when
+// the handler passed to Run returns an error. Default is 1 second.
+func SubscriptionRetryDelay(d time.Duration) SubscriptionOption {
+	return subscriptionOptFn(func(o *subscriptionOpts) error {
+		o.retryDelay = d
+		return nil
+	})
+}
+
+// SubscriptionAckSync sets Run to ack events with AckSync rather than Ack.
+// Default is false.
+func SubscriptionAckSync() SubscriptionOption {
+	return subscriptionOptFn(func(o *subscriptionOpts) error {
+		o.ackSync = true
+		return nil
+	})
+}
+
+// Delivery is an event delivered by a subscription which must be acked.
+type Delivery struct {
+	*Event
+
+	// msgs are the messages of the event, which are multiple if the event
+	// is chunked.
+	msgs []*nats.Msg
+}
+
+func (d *Delivery) each(fn func(msg *nats.Msg) error) error {
+	for _, msg := range d.msgs {
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ack acknowledges the event has been processed.
+func (d *Delivery) Ack() error {
+	return d.each(func(msg *nats.Msg) error {
+		return msg.Ack()
+	})
+}
+
+// AckSync acknowledges the event and waits for the server to confirm the
+// ack, also known as a double ack. Once it returns without error, the event
+// will not be redelivered. Combined with a handler that is idempotent, such
+// as one using StateStore.Apply, this provides exactly-once processing.
+func (d *Delivery) AckSync() error {
+	return d.each(func(msg *nats.Msg) error {
+		return msg.AckSync()
+	})
+}
+
+// Nak indicates the event was not processed and should be redelivered
+// after the delay.
+func (d *Delivery) Nak(delay time.Duration) error {
+	return d.each(func(msg *nats.Msg) error {
+		return msg.NakWithDelay(delay)
+	})
+}
+
+// InProgress resets the ack wait for events taking a long time to process.
+func (d *Delivery) InProgress() error {
+	return d.each(func(msg *nats.Msg) error {
+		return msg.InProgress()
+	})
+}
+
+// Term indicates the event cannot be processed and must not be redelivered.
+func (d *Delivery) Term() error {
+	return d.each(func(msg *nats.Msg) error {
+		return msg.Term()
+	})
+}
+
+// NumDelivered returns the number of times the event has been delivered.
+func (d *Delivery) NumDelivered() uint64 {
+	md, err := d.msgs[len(d.msgs)-1].Metadata()
+	if err != nil {
+		return 0
+	}
+	return md.NumDelivered
+}
+
+// Subscription is a durable consumer of events in a store. The position of
+// the subscription is retained by the server across restarts.
+type Subscription struct {
+	es   *EventStore
+	name string
+	opts subscriptionOpts
+	sub  *nats.Subscription
+	asm  *assembler
+}
+
+// delivery adds the message and returns the delivery once the event is
+// complete. An event which cannot be unpacked is rejected and nil is
+// returned, so it does not stop the subscription.
+func (s *Subscription) delivery(ctx context.Context, msg *nats.Msg) (*Delivery, error) {
+	parts, amsg, err := s.asm.add(msg)
+	if err != nil {
+		return nil, msg.Term()
+	}
+	if amsg == nil {
+		return nil, nil
+	}
+
+	event, err := s.es.unpackEvent(amsg, false)
+	if err != nil {
+		return nil, s.es.reject(ctx, parts, err, s.opts.retryDelay)
+	}
+
+	return &Delivery{
+		Event: event,
+		msgs:  parts,
+	}, nil
+}
+
+// reject handles an event which failed to unpack. An event which failed to
+// decode is copied to the quarantine stream, see Quarantined, and terminated
+// since it will never decode. Other failures, such as of the claimed data
+// not being available, and failures to quarantine are retried after the
+// retry delay.
+func (s *EventStore) reject(ctx context.Context, parts []*nats.Msg, err error, retryDelay time.Duration) error {
+	dl := &Delivery{msgs: parts}
+
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		return dl.Nak(retryDelay)
+	}
+
+	// The assembled message of a chunked event has no metadata.
+	if de.Sequence == 0 {
+		if md, merr := parts[len(parts)-1].Metadata(); merr == nil {
+			de.Sequence = md.Sequence.Stream
+		}
+	}
+
+	if err := s.quarantineDecodeError(ctx, de); err != nil {
+		return dl.Nak(retryDelay)
+	}
+	return dl.Term()
+}
+
+// Fetch fetches up to n events. If the context has no deadline, a default
+// wait of five seconds is used. Chunked events which are incomplete are
+// returned by a subsequent fetch. Events which fail to decode are
+// quarantined rather than returned.
+func (s *Subscription) Fetch(ctx context.Context, n int) ([]*Delivery, error) {
+	if err := s.es.authorize(ctx, OpWatch, s.opts.subject, nil); err != nil {
+		return nil, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultFetchWait)
+		defer cancel()
+	}
+
+	msgs, err := s.sub.Fetch(n, nats.Context(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var ds []*Delivery
+	for _, msg := range msgs {
+		d, err := s.delivery(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			ds = append(ds, d)
+		}
+	}
+
+	return ds, nil
+}
+
+// Run passes each event to the handler until the context is done. If the
+// handler returns nil, the event is acked, otherwise it is redelivered
+// after the retry delay. Events are acked with AckSync if the subscription
+// is configured with SubscriptionAckSync. Events which fail to decode are
+// quarantined and skipped.
+func (s *Subscription) Run(ctx context.Context, handle func(ctx context.Context, event *Event) error) error {
+	if err := s.es.authorize(ctx, OpWatch, s.opts.subject, nil); err != nil {
+		return err
+	}
+
+	return fetchLoop(ctx, s.sub, s.opts.batch, func(msg *nats.Msg) error {
+		d, err := s.delivery(ctx, msg)
+		if err != nil || d == nil {
+			return err
+		}
+
+		if err := handle(ctx, d.Event); err != nil {
+			return d.Nak(s.opts.retryDelay)
+		}
+		if s.opts.ackSync {
+			return d.AckSync()
+		}
+		return d.Ack()
+	})
+}
+
+// Lag returns the number of events that have not yet been processed, which
+// includes events that have not been delivered and those pending an ack.
+func (s *Subscription) Lag() (uint64, error) {
+	info, err := s.sub.ConsumerInfo()
+	if err != nil {
+		return 0, err
+	}
+	return info.NumPending + uint64(info.NumAckPending), nil
+}
+
+// Close closes the subscription. The durable consumer is retained.
+func (s *Subscription) Close() error {
+	return s.sub.Unsubscribe()
+}
+
+// Delete closes the subscription and deletes the durable consumer.
+func (s *Subscription) Delete() error {
+	_ = s.sub.Unsubscribe()
+	return s.es.rt.js.DeleteConsumer(s.es.stream, s.es.durable(s.name))
+}
+
+// bindDurable ensures the durable pull consumer exists and binds a
+// subscription to it. Since the consumer is not created by the subscription,
+// it is not deleted when the subscription is closed.
+func (s *EventStore) bindDurable(config *nats.ConsumerConfig) (*nats.Subscription, error) {
+	config.Durable = s.durable(config.Durable)
+	config.FilterSubject = s.subject(config.FilterSubject)
+
+	_, err := s.rt.js.ConsumerInfo(s.stream, config.Durable)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		_, err = s.rt.js.AddConsumer(s.stream, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.rt.js.PullSubscribe(config.FilterSubject, config.Durable, nats.Bind(s.stream, config.Durable))
+}
+
+// Subscription returns a durable subscription with the name, creating the
+// underlying consumer if it does not exist. Options only apply when the
+// consumer is created.
+func (s *EventStore) Subscription(name string, opts ...SubscriptionOption) (*Subscription, error) {
+	o := subscriptionOpts{
+		subject:    s.filterSubject(),
+		batch:      100,
+		retryDelay: time.Second,
+	}
+
+	for _, opt := range opts {
+		if err := opt.subscriptionOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	config := &nats.ConsumerConfig{
+		Durable:       name,
+		FilterSubject: o.subject,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       o.ackWait,
+		MaxAckPending: o.maxAckPending,
+		MaxDeliver:    o.maxDeliver,
+		DeliverPolicy: nats.DeliverAllPolicy,
+	}
+
+	if o.startSeq > 0 {
+		config.DeliverPolicy = nats.DeliverByStartSequencePolicy
+		config.OptStartSeq = o.startSeq
+	}
+
+	sub, err := s.bindDurable(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subscription{
+		es:   s,
+		name: name,
+		opts: o,
+		sub:  sub,
+		asm:  newAssembler(),
+	}, nil
+}
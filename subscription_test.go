@@ -0,0 +1,144 @@
+package rita
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestSubscription(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	err = es.Create(&EventStoreConfig{
+		Storage: nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	sub, err := es.Subscription("audit", SubscriptionAckWait(time.Minute))
+	is.NoErr(err)
+
+	lag, err := sub.Lag()
+	is.NoErr(err)
+	is.Equal(lag, uint64(3))
+
+	ds, err := sub.Fetch(ctx, 2)
+	is.NoErr(err)
+	is.Equal(len(ds), 2)
+	is.Equal(ds[0].Type, "order-placed")
+	is.Equal(ds[0].NumDelivered(), uint64(1))
+
+	is.NoErr(ds[0].Ack())
+	is.NoErr(ds[1].AckSync())
+
+	// Position is retained after closing.
+	is.NoErr(sub.Close())
+
+	sub, err = es.Subscription("audit")
+	is.NoErr(err)
+	defer sub.Delete() //nolint
+
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var events []*Event
+	err = sub.Run(rctx, func(ctx context.Context, event *Event) error {
+		events = append(events, event)
+		cancel()
+		return nil
+	})
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Subject, "orders.2")
+}
+
+func TestSubscriptionDecodeError(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+	js, _ := nc.JetStream()
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	// Event of an unknown type between two events.
+	msg := nats.NewMsg("orders.1")
+	msg.Header.Set(nats.MsgIdHdr, "cancelled")
+	msg.Header.Set(eventTypeHdr, "order-cancelled")
+	msg.Header.Set(eventCodecHdr, "json")
+	msg.Header.Set(eventTimeHdr, "2022-05-01T00:00:00Z")
+	msg.Data = []byte(`{"ID":"1"}`)
+	_, err = js.PublishMsg(msg)
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	// The event is quarantined and the rest of the batch is returned.
+	sub, err := es.Subscription("fetch")
+	is.NoErr(err)
+	defer sub.Delete() //nolint
+
+	ds, err := sub.Fetch(ctx, 3)
+	is.NoErr(err)
+	is.Equal(len(ds), 2)
+	is.Equal(ds[1].Sequence, uint64(3))
+
+	qs, err := es.Quarantined(ctx)
+	is.NoErr(err)
+	is.Equal(len(qs), 1)
+	is.Equal(qs[0].Sequence, uint64(2))
+
+	// Run does not stop at the event.
+	sub, err = es.Subscription("run")
+	is.NoErr(err)
+	defer sub.Delete() //nolint
+
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var seqs []uint64
+	is.NoErr(sub.Run(rctx, func(ctx context.Context, event *Event) error {
+		seqs = append(seqs, event.Sequence)
+		if len(seqs) == 2 {
+			cancel()
+		}
+		return nil
+	}))
+	is.Equal(seqs, []uint64{1, 3})
+}
@@ -0,0 +1,206 @@
+package rita
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bruth/rita/storage"
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	ErrInvalidResumeToken = errors.New("rita: invalid resume token")
+)
+
+// encodeResumeToken encodes the position in the store as an opaque token.
+func encodeResumeToken(store string, seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", store, seq)))
+}
+
+// decodeResumeToken decodes the sequence of a token for the store.
+func decodeResumeToken(store string, token string) (uint64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidResumeToken
+	}
+
+	name, seq, ok := strings.Cut(string(b), ":")
+	if !ok || name != store {
+		return 0, ErrInvalidResumeToken
+	}
+
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidResumeToken
+	}
+
+	return n, nil
+}
+
+// Tail is a live stream of every event in a store across all subjects.
+type Tail struct {
+	es  *EventStore
+	sub *nats.Subscription
+	asm *assembler
+	seq uint64
+
+	// w is set if the store has a backend.
+	w storage.Watcher
+
+	lenient bool
+	decode  decodeOpts
+}
+
+// Next blocks until the next event is received or the context is done.
+func (t *Tail) Next(ctx context.Context) (*Event, error) {
+	if t.w != nil {
+		m, err := t.w.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		event, err := t.es.backendUnpack(m, t.lenient)
+		if err != nil {
+			t.seq = m.Sequence
+			if event, err = t.decode.handle(ctx, t.es, err); event == nil {
+				if err != nil {
+					return nil, err
+				}
+				return t.Next(ctx)
+			}
+		}
+		t.seq = event.Sequence
+		return event, nil
+	}
+
+	var msg *nats.Msg
+	for msg == nil {
+		m, err := t.sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		_, msg, err = t.asm.add(m)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	event, err := t.es.unpackEvent(msg, t.lenient)
+	if err != nil {
+		var de *DecodeError
+		if errors.As(err, &de) && de.Sequence > 0 {
+			t.seq = de.Sequence
+		}
+		if event, err = t.decode.handle(ctx, t.es, err); event == nil {
+			if err != nil {
+				return nil, err
+			}
+			return t.Next(ctx)
+		}
+	}
+
+	t.seq = event.Sequence
+
+	return event, nil
+}
+
+// Token returns an opaque resume token positioned after the last event
+// returned by Next. Passing the token to Tail resumes from the next event.
+func (t *Tail) Token() string {
+	return encodeResumeToken(t.es.name, t.seq)
+}
+
+// Stop stops the tail.
+func (t *Tail) Stop() error {
+	if t.w != nil {
+		return t.w.Stop()
+	}
+	return t.sub.Unsubscribe()
+}
+
+// Tail streams every event in the store, starting after the position of the
+// resume token. If the token is empty, all events from the beginning of the
+// store are streamed. The tail is stopped when the context is done. Only the
+// consumer options of LoadOption, Lenient, and OnDecodeError apply.
+func (s *EventStore) Tail(ctx context.Context, token string, opts ...LoadOption) (*Tail, error) {
+	var o loadOpts
+	for _, opt := range opts {
+		if err := opt.loadOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.authorize(ctx, OpWatch, s.filterSubject(), nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	var seq uint64
+	if token != "" {
+		var err error
+		seq, err = decodeResumeToken(s.name, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.backend != nil {
+		w, err := s.backend.Watch(ctx, s.subject(s.filterSubject()), seq)
+		if err != nil {
+			return nil, err
+		}
+
+		go func() {
+			<-ctx.Done()
+			_ = w.Stop()
+		}()
+
+		return &Tail{
+			es:      s,
+			seq:     seq,
+			w:       w,
+			lenient: o.lenient,
+			decode:  o.decode,
+		}, nil
+	}
+
+	sopts := append(o.subOpts(), nats.BindStream(s.stream))
+	if seq > 0 {
+		sopts = append(sopts, nats.StartSequence(seq+1))
+	} else {
+		sopts = append(sopts, nats.DeliverAll())
+	}
+
+	// An empty subject with a bound stream consumes all subjects. A shared
+	// stream is limited to the subjects of the store.
+	var subject string
+	if s.shared {
+		subject = s.subject(s.filterSubject())
+	}
+
+	sub, err := s.rt.js.SubscribeSync(subject, sopts...)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = sub.Unsubscribe()
+	}()
+
+	return &Tail{
+		es:      s,
+		sub:     sub,
+		asm:     newAssembler(),
+		seq:     seq,
+		lenient: o.lenient,
+		decode:  o.decode,
+	}, nil
+}
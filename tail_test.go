@@ -0,0 +1,72 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestTail(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	err = es.Create(&EventStoreConfig{
+		Storage: nats.MemoryStorage,
+	})
+	is.NoErr(err)
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	tail, err := es.Tail(ctx, "")
+	is.NoErr(err)
+
+	e1, err := tail.Next(ctx)
+	is.NoErr(err)
+	is.Equal(e1.Subject, "orders.1")
+
+	e2, err := tail.Next(ctx)
+	is.NoErr(err)
+	is.Equal(e2.Subject, "orders.2")
+	is.Equal(*e2.Data.(*OrderPlaced), OrderPlaced{ID: "2"})
+
+	token := tail.Token()
+	is.NoErr(tail.Stop())
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	tail, err = es.Tail(ctx, token)
+	is.NoErr(err)
+	defer tail.Stop() //nolint
+
+	e3, err := tail.Next(ctx)
+	is.NoErr(err)
+	is.Equal(e3.Sequence, uint64(3))
+	is.Equal(e3.Type, "order-shipped")
+
+	_, err = es.Tail(ctx, "bogus")
+	is.Err(err, ErrInvalidResumeToken)
+
+	pes, err := r.EventStore("payments")
+	is.NoErr(err)
+
+	_, err = pes.Tail(ctx, token)
+	is.Err(err, ErrInvalidResumeToken)
+}
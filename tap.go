@@ -0,0 +1,166 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+type tapOpts struct {
+	fn      func(e *Event)
+	subject string
+}
+
+type tapOptFn func(o *tapOpts) error
+
+func (f tapOptFn) tapOpt(o *tapOpts) error {
+	return f(o)
+}
+
+// TapOption is an option for Tap.
+type TapOption interface {
+	tapOpt(o *tapOpts) error
+}
+
+// TapFunc sets the function the sampled events are passed to. It is called
+// from a single goroutine, so a slow function delays the tap rather than
+// the store.
+func TapFunc(fn func(e *Event)) TapOption {
+	return tapOptFn(func(o *tapOpts) error {
+		if fn == nil {
+			return fmt.Errorf("tap: function required")
+		}
+		o.fn = fn
+		return nil
+	})
+}
+
+// TapSubject sets the subject the sampled events are published to as JSON,
+// e.g. to be inspected with the NATS CLI.
+func TapSubject(subject string) TapOption {
+	return tapOptFn(func(o *tapOpts) error {
+		if err := validateSubject(subject, false); err != nil {
+			return err
+		}
+		o.subject = subject
+		return nil
+	})
+}
+
+// Tap is a sampled stream of the live events of a store.
+type Tap struct {
+	sub  *nats.Subscription
+	once sync.Once
+}
+
+// Stop stops the tap.
+func (t *Tap) Stop() error {
+	var err error
+	t.once.Do(func() {
+		err = t.sub.Unsubscribe()
+	})
+	return err
+}
+
+// sampled returns true if the event with the ID is in the sample. Events are
+// sampled by ID, so taps with the same rate sample the same events.
+func sampled(id string, rate float64) bool {
+	return rate >= 1 || float64(hash32(id)) < rate*math.MaxUint32
+}
+
+// Tap mirrors the sample rate, between 0 and 1, of the live events matching
+// the subject pattern to the function set by TapFunc and the subject set by
+// TapSubject, for inspecting the events of a production store. The events
+// are consumed by an ephemeral ordered consumer starting at the next event,
+// so no durable consumer state is created and the tap has no effect on
+// other consumers. Events are decoded leniently and events which fail to
+// decode are skipped. The tap is stopped when the context is done. Stores
+// with a storage backend are not supported.
+func (s *EventStore) Tap(ctx context.Context, subject string, rate float64, opts ...TapOption) (*Tap, error) {
+	var o tapOpts
+	for _, opt := range opts {
+		if err := opt.tapOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.fn == nil && o.subject == "" {
+		return nil, errors.New("rita: tap: function or subject required")
+	}
+	if rate <= 0 || rate > 1 {
+		return nil, fmt.Errorf("rita: tap: sample rate %v not in (0, 1]", rate)
+	}
+	if err := validateSubject(subject, true); err != nil {
+		return nil, err
+	}
+	if s.backend != nil {
+		return nil, ErrBackendUnsupported
+	}
+
+	if err := s.authorize(ctx, OpWatch, subject, nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.ready(ctx); err != nil {
+		return nil, err
+	}
+
+	asm := newAssembler()
+
+	handle := func(m *nats.Msg) {
+		_, msg, err := asm.add(m)
+		if err != nil || msg == nil {
+			return
+		}
+		if !sampled(msg.Header.Get(nats.MsgIdHdr), rate) {
+			return
+		}
+
+		event, err := s.unpackEvent(msg, true)
+		if err != nil {
+			return
+		}
+
+		if o.fn != nil {
+			o.fn(event)
+		}
+		if o.subject != "" {
+			b, err := json.Marshal(&archivedEvent{
+				ID:           event.ID,
+				Type:         event.Type,
+				Subject:      event.Subject,
+				Sequence:     event.Sequence,
+				Time:         event.Time,
+				RecordedTime: event.RecordedTime,
+				Meta:         event.Meta,
+				Data:         event.Data,
+			})
+			if err == nil {
+				_ = s.rt.nc.Publish(o.subject, b)
+			}
+		}
+	}
+
+	sub, err := s.rt.js.Subscribe(s.subject(subject), handle,
+		nats.BindStream(s.stream),
+		nats.OrderedConsumer(),
+		nats.DeliverNew(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tap{sub: sub}
+
+	go func() {
+		<-ctx.Done()
+		_ = t.Stop()
+	}()
+
+	return t, nil
+}
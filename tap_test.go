@@ -0,0 +1,102 @@
+package rita
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestSampled(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	var n int
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("event-%d", i)
+		is.True(sampled(id, 1))
+		if sampled(id, 0.5) {
+			n++
+		}
+	}
+	is.True(n > 400 && n < 600)
+}
+
+func TestEventStoreTap(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Events before the tap are not mirrored.
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	_, err = es.Tap(ctx, "orders.*", 1)
+	is.Err(err, nil)
+	_, err = es.Tap(ctx, "orders.*", 0, TapSubject("debug.orders"))
+	is.Err(err, nil)
+
+	events := make(chan *Event, 10)
+
+	debug, err := nc.SubscribeSync("debug.orders")
+	is.NoErr(err)
+
+	tap, err := es.Tap(ctx, "orders.1", 1,
+		TapFunc(func(e *Event) { events <- e }),
+		TapSubject("debug.orders"),
+	)
+	is.NoErr(err)
+	defer tap.Stop() //nolint
+
+	_, err = es.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	select {
+	case e := <-events:
+		is.Equal(e.Subject, "orders.1")
+		is.Equal(e.Sequence, uint64(3))
+		is.Equal(*e.Data.(*OrderShipped), OrderShipped{ID: "1"})
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for tapped event")
+	}
+
+	msg, err := debug.NextMsg(5 * time.Second)
+	is.NoErr(err)
+
+	var v map[string]any
+	is.NoErr(json.Unmarshal(msg.Data, &v))
+	is.Equal(v["type"], "order-shipped")
+	is.Equal(v["subject"], "orders.1")
+
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %s", e.Subject)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The consumer of the tap is ephemeral.
+	ci, err := tap.sub.ConsumerInfo()
+	is.NoErr(err)
+	is.Equal(ci.Config.Durable, "")
+
+	is.NoErr(tap.Stop())
+	is.NoErr(tap.Stop())
+}
@@ -0,0 +1,105 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// TenantConfig describes the topology provisioned for a tenant in its own
+// NATS account.
+type TenantConfig struct {
+	// URL of the servers.
+	URL string
+
+	// Credentials is the path to a credentials file of a user in the
+	// account of the tenant.
+	Credentials string
+
+	// ConnOptions are additional options for connecting to the account.
+	ConnOptions []nats.Option
+
+	// EventStores are the event stores to ensure by name.
+	EventStores map[string]*EventStoreConfig
+
+	// StateStores are the state stores to create, if they do not exist, by
+	// name.
+	StateStores map[string][]StateStoreOption
+
+	// SchemaRegistry creates the schema registry bucket.
+	SchemaRegistry bool
+}
+
+// ProvisionTenant connects to the account of a tenant, provisions the event
+// stores and buckets of the topology, and returns a Rita instance for the
+// tenant. Provisioning is idempotent so it can be run on every onboarding or
+// deployment. The connection is owned by the instance and closed by Close.
+func ProvisionTenant(ctx context.Context, config *TenantConfig, opts ...RitaOption) (*Rita, error) {
+	if config == nil || config.URL == "" {
+		return nil, errors.New("rita: tenant url required")
+	}
+
+	copts := config.ConnOptions
+	if config.Credentials != "" {
+		copts = append([]nats.Option{nats.UserCredentials(config.Credentials)}, copts...)
+	}
+
+	nc, err := nats.Connect(config.URL, copts...)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := New(nc, opts...)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	r.ownsConn = true
+
+	if err := r.provision(ctx, config); err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Rita) provision(ctx context.Context, config *TenantConfig) error {
+	for name, sc := range config.EventStores {
+		es, err := r.EventStore(name)
+		if err != nil {
+			return err
+		}
+		if err := es.Ensure(ctx, sc); err != nil {
+			return fmt.Errorf("rita: provision event store %q: %w", name, err)
+		}
+	}
+
+	for name, sopts := range config.StateStores {
+		if _, err := r.StateStore(name, sopts...); err != nil {
+			return fmt.Errorf("rita: provision state store %q: %w", name, err)
+		}
+	}
+
+	if config.SchemaRegistry {
+		if _, err := r.SchemaRegistry(); err != nil {
+			return fmt.Errorf("rita: provision schema registry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the connections of the instance if they were opened by it,
+// such as by ProvisionTenant. Otherwise, it is a no-op.
+func (r *Rita) Close() {
+	if !r.ownsConn {
+		return
+	}
+	if r.anc != r.nc {
+		r.anc.Close()
+	}
+	r.nc.Close()
+}
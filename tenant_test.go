@@ -0,0 +1,65 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestProvisionTenant(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServerWithAccounts("acme", "globex")
+	defer testutil.ShutdownNatsServer(srv)
+
+	ctx := context.Background()
+
+	_, err := ProvisionTenant(ctx, nil)
+	is.True(err != nil)
+
+	config := &TenantConfig{
+		URL:         srv.ClientURL(),
+		ConnOptions: []nats.Option{nats.UserInfo("acme", "acme")},
+		EventStores: map[string]*EventStoreConfig{
+			"orders": {Storage: nats.MemoryStorage},
+		},
+		StateStores: map[string][]StateStoreOption{
+			"carts": {StateStorage(nats.MemoryStorage)},
+		},
+		SchemaRegistry: true,
+	}
+
+	r, err := ProvisionTenant(ctx, config, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+	defer r.Close()
+
+	// Provisioning again is a no-op.
+	r2, err := ProvisionTenant(ctx, config)
+	is.NoErr(err)
+	r2.Close()
+
+	infos, err := r.ListEventStores(ctx)
+	is.NoErr(err)
+	is.Equal(len(infos), 1)
+	is.Equal(infos[0].Name, "orders")
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &OrderPlaced{ID: "1"}}})
+	is.NoErr(err)
+
+	// Other tenants are isolated by account.
+	other, err := ProvisionTenant(ctx, &TenantConfig{
+		URL:         srv.ClientURL(),
+		ConnOptions: []nats.Option{nats.UserInfo("globex", "globex")},
+	})
+	is.NoErr(err)
+	defer other.Close()
+
+	infos, err = other.ListEventStores(ctx)
+	is.NoErr(err)
+	is.Equal(len(infos), 0)
+}
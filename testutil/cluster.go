@@ -0,0 +1,111 @@
+package testutil
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natsserver "github.com/nats-io/nats-server/v2/test"
+)
+
+// Cluster is a JetStream-enabled cluster of NATS servers for testing
+// behavior under failures, such as server restarts.
+type Cluster struct {
+	Servers []*server.Server
+
+	opts []*server.Options
+}
+
+func freePort() int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// NewNatsCluster starts a cluster of n servers and waits for JetStream to
+// be ready. Ports are fixed so servers can be restarted.
+func NewNatsCluster(n int) *Cluster {
+	routes := make([]*url.URL, n)
+	opts := make([]*server.Options, n)
+
+	for i := 0; i < n; i++ {
+		o := natsserver.DefaultTestOptions
+		o.ServerName = fmt.Sprintf("S-%d", i+1)
+		o.Port = freePort()
+		o.JetStream = true
+		o.Cluster.Name = "rita"
+		o.Cluster.Host = "127.0.0.1"
+		o.Cluster.Port = freePort()
+
+		dir, err := os.MkdirTemp("", "rita-cluster-")
+		if err != nil {
+			panic(err)
+		}
+		o.StoreDir = dir
+
+		routes[i] = &url.URL{Scheme: "nats", Host: fmt.Sprintf("127.0.0.1:%d", o.Cluster.Port)}
+		opts[i] = &o
+	}
+
+	c := &Cluster{
+		Servers: make([]*server.Server, n),
+		opts:    opts,
+	}
+
+	for i, o := range opts {
+		o.Routes = routes
+		c.Servers[i] = natsserver.RunServer(o)
+	}
+
+	c.WaitReady(10 * time.Second)
+
+	return c
+}
+
+// ClientURL returns the comma-separated client URLs of the servers.
+func (c *Cluster) ClientURL() string {
+	urls := make([]string, len(c.opts))
+	for i, o := range c.opts {
+		urls[i] = fmt.Sprintf("nats://127.0.0.1:%d", o.Port)
+	}
+	return strings.Join(urls, ",")
+}
+
+// WaitReady waits until the cluster has a JetStream meta leader.
+func (c *Cluster) WaitReady(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		for _, s := range c.Servers {
+			if s.Running() && s.JetStreamIsLeader() {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	panic("cluster not ready")
+}
+
+// Restart shuts down the server at the index and starts it again with the
+// same options and storage.
+func (c *Cluster) Restart(i int) {
+	s := c.Servers[i]
+	s.Shutdown()
+	s.WaitForShutdown()
+	c.Servers[i] = natsserver.RunServer(c.opts[i])
+}
+
+// Shutdown shuts down all servers and removes their storage.
+func (c *Cluster) Shutdown() {
+	for i, s := range c.Servers {
+		s.Shutdown()
+		s.WaitForShutdown()
+		os.RemoveAll(c.opts[i].StoreDir)
+	}
+}
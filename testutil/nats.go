@@ -1,7 +1,9 @@
 package testutil
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/nats-io/nats-server/v2/server"
 	natsserver "github.com/nats-io/nats-server/v2/test"
@@ -14,6 +16,31 @@ func NewNatsServer(port int) *server.Server {
 	return natsserver.RunServer(&opts)
 }
 
+// NewNatsServerWithAccounts starts a server with a JetStream-enabled account
+// per name. Each account has a user with the name as the username and
+// password.
+func NewNatsServerWithAccounts(names ...string) *server.Server {
+	dir, err := os.MkdirTemp("", "rita-accounts-")
+	if err != nil {
+		panic(err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "listen: 127.0.0.1:-1\njetstream: {store_dir: %q}\naccounts: {\n", dir)
+	for _, n := range names {
+		fmt.Fprintf(&b, "  %s: {jetstream: enabled, users: [{user: %q, password: %q}]}\n", n, n, n)
+	}
+	b.WriteString("}\n")
+
+	conf := dir + "/server.conf"
+	if err := os.WriteFile(conf, []byte(b.String()), 0o600); err != nil {
+		panic(err)
+	}
+
+	s, _ := natsserver.RunServerWithConfig(conf)
+	return s
+}
+
 func ShutdownNatsServer(s *server.Server) {
 	var sd string
 	if config := s.JetStreamConfig(); config != nil {
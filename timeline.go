@@ -0,0 +1,207 @@
+package rita
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	TimelineCommand = "command"
+	TimelineEvent   = "event"
+)
+
+// TimelineEntry is a command or event in a causal timeline.
+type TimelineEntry struct {
+	// Kind of the entry, TimelineCommand or TimelineEvent.
+	Kind string `json:"kind"`
+
+	// ID of the command or event.
+	ID string `json:"id"`
+
+	// CausedBy is the ID of the entry which caused this entry, if any. An
+	// event is caused by the command which decided it and a command is
+	// caused by the event a reactor dispatched it in reaction to.
+	CausedBy string `json:"caused_by,omitempty"`
+
+	// Store, Subject, Sequence, and Type of an event. Commands are not
+	// stored, so only the ID is known.
+	Store    string `json:"store,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Sequence uint64 `json:"seq,omitempty"`
+	Type     string `json:"type,omitempty"`
+
+	// Time and RecordedTime of an event.
+	Time         time.Time `json:"time,omitempty"`
+	RecordedTime time.Time `json:"recorded_time,omitempty"`
+}
+
+// Timeline is the causal timeline of a correlation, ordered by the time the
+// events were recorded. Each command precedes the first event it decided.
+type Timeline struct {
+	// Roots are the correlation IDs of the timeline.
+	Roots []string `json:"roots"`
+
+	Entries []*TimelineEntry `json:"entries"`
+}
+
+// timelineParent returns the ID an ID was derived from, if any. Events
+// decided by a command have IDs "{command}.{n}" and commands dispatched by a
+// reactor have IDs "{event}.{n}".
+func timelineParent(id string) string {
+	i := strings.LastIndexByte(id, '.')
+	if i < 0 {
+		return ""
+	}
+	return id[:i]
+}
+
+// timelineRoot returns the correlation ID of an ID, which is the ID of the
+// first command or event in the chain it was derived from.
+func timelineRoot(id string) string {
+	root, _, _ := strings.Cut(id, ".")
+	return root
+}
+
+// Timeline returns the causal timeline of the commands and events correlated
+// by the ID, which is the ID of the command or event which started the chain.
+// Correlation relies on the IDs derived by Execute, actors, and reactors, so
+// commands and events with explicit IDs are not correlated. Since only event
+// IDs are indexed by the server, all events of the stores are scanned, so
+// this is intended for debugging and tooling.
+func (r *Rita) Timeline(ctx context.Context, id string, stores ...*EventStore) (*Timeline, error) {
+	if id == "" {
+		return nil, fmt.Errorf("rita: timeline: id required")
+	}
+	return r.timeline(ctx, []string{id}, stores)
+}
+
+// SubjectTimeline returns the causal timeline of the correlations of the
+// events of the subject. The subject must follow the "{store}.{entity}"
+// convention and its store must be one of the stores.
+func (r *Rita) SubjectTimeline(ctx context.Context, subject string, stores ...*EventStore) (*Timeline, error) {
+	p, err := ParseSubject(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var es *EventStore
+	for _, s := range stores {
+		if s.name == p.Store {
+			es = s
+			break
+		}
+	}
+	if es == nil {
+		return nil, fmt.Errorf("rita: timeline: store %q not provided", p.Store)
+	}
+
+	var roots []string
+	seen := make(map[string]bool)
+
+	lo := loadOpts{headersOnly: true}
+	_, err = es.readMsgs(ctx, subject, &lo, func(seq uint64, msg *nats.Msg) error {
+		root := timelineRoot(msg.Header.Get(nats.MsgIdHdr))
+		if root != "" && !seen[root] {
+			seen[root] = true
+			roots = append(roots, root)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.timeline(ctx, roots, stores)
+}
+
+func (r *Rita) timeline(ctx context.Context, roots []string, stores []*EventStore) (*Timeline, error) {
+	correlated := func(id string) bool {
+		for _, root := range roots {
+			if id == root || strings.HasPrefix(id, root+".") {
+				return true
+			}
+		}
+		return false
+	}
+
+	var events []*TimelineEntry
+	for _, s := range stores {
+		lo := loadOpts{headersOnly: true}
+		_, err := s.readMsgs(ctx, s.filterSubject(), &lo, func(seq uint64, msg *nats.Msg) error {
+			id := msg.Header.Get(nats.MsgIdHdr)
+			if !correlated(id) {
+				return nil
+			}
+
+			e := &TimelineEntry{
+				Kind:     TimelineEvent,
+				ID:       id,
+				Store:    s.name,
+				Subject:  contextUnsubject(s.context, msg.Subject),
+				Sequence: seq,
+				Type:     msg.Header.Get(eventTypeHdr),
+			}
+			e.Time, _ = time.Parse(eventTimeFormat, msg.Header.Get(eventTimeHdr))
+			if md, err := msg.Metadata(); err == nil {
+				e.RecordedTime = md.Timestamp
+			}
+
+			events = append(events, e)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		ti, tj := events[i].RecordedTime, events[j].RecordedTime
+		if ti.IsZero() || tj.IsZero() {
+			ti, tj = events[i].Time, events[j].Time
+		}
+		return ti.Before(tj)
+	})
+
+	isEvent := make(map[string]bool, len(events))
+	for _, e := range events {
+		isEvent[e.ID] = true
+	}
+
+	// Commands are inferred from the IDs of the events they decided.
+	tl := &Timeline{
+		Roots: roots,
+	}
+	commands := make(map[string]bool)
+
+	for _, e := range events {
+		parent := timelineParent(e.ID)
+		if parent == "" || isEvent[parent] {
+			e.CausedBy = parent
+			tl.Entries = append(tl.Entries, e)
+			continue
+		}
+
+		// The parent is the command which decided the event.
+		e.CausedBy = parent
+
+		if !commands[parent] {
+			commands[parent] = true
+			c := &TimelineEntry{
+				Kind: TimelineCommand,
+				ID:   parent,
+			}
+			if p := timelineParent(parent); isEvent[p] {
+				c.CausedBy = p
+			}
+			tl.Entries = append(tl.Entries, c)
+		}
+		tl.Entries = append(tl.Entries, e)
+	}
+
+	return tl, nil
+}
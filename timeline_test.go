@@ -0,0 +1,74 @@
+package rita
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestTimeline(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	orders, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(orders.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	shipping, err := r.EventStore("shipping")
+	is.NoErr(err)
+	is.NoErr(shipping.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	// The command "c1" placed the order, and a reactor dispatched a command
+	// in reaction which shipped it.
+	_, _, err = orders.Execute(ctx, "orders.1", &Order{}, &Command{ID: "c1", Data: &PlaceOrder{ID: "1"}})
+	is.NoErr(err)
+
+	_, err = shipping.Append(ctx, "shipping.1", []*Event{{ID: "c1.0.0.0", Data: &OrderShipped{ID: "1"}}})
+	is.NoErr(err)
+
+	_, err = orders.Append(ctx, "orders.2", []*Event{{ID: "c2.0", Data: &OrderPlaced{ID: "2"}}})
+	is.NoErr(err)
+
+	_, err = r.SubjectTimeline(ctx, "orders.1", shipping)
+	is.True(err != nil)
+
+	for _, tl := range []func() (*Timeline, error){
+		func() (*Timeline, error) { return r.Timeline(ctx, "c1", orders, shipping) },
+		func() (*Timeline, error) { return r.SubjectTimeline(ctx, "orders.1", orders, shipping) },
+	} {
+		tl, err := tl()
+		is.NoErr(err)
+		is.Equal(tl.Roots, []string{"c1"})
+		is.Equal(len(tl.Entries), 4)
+
+		is.Equal(*tl.Entries[0], TimelineEntry{Kind: TimelineCommand, ID: "c1"})
+
+		placed := tl.Entries[1]
+		is.Equal(placed.Kind, TimelineEvent)
+		is.Equal(placed.ID, "c1.0")
+		is.Equal(placed.CausedBy, "c1")
+		is.Equal(placed.Store, "orders")
+		is.Equal(placed.Subject, "orders.1")
+		is.Equal(placed.Sequence, uint64(1))
+		is.Equal(placed.Type, "order-placed")
+		is.True(!placed.RecordedTime.IsZero())
+
+		is.Equal(*tl.Entries[2], TimelineEntry{Kind: TimelineCommand, ID: "c1.0.0", CausedBy: "c1.0"})
+
+		shipped := tl.Entries[3]
+		is.Equal(shipped.ID, "c1.0.0.0")
+		is.Equal(shipped.CausedBy, "c1.0.0")
+		is.Equal(shipped.Store, "shipping")
+	}
+}
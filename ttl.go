@@ -0,0 +1,205 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	eventExpiresHdr = "rita-expires"
+)
+
+// TTL sets the time to live of the appended events which do not have an
+// expiry time set. The NATS server does not support per-message TTLs, so the
+// expiry time is recorded on the event and expired events are skipped by
+// loads until they are deleted by Expire.
+func TTL(d time.Duration) AppendOption {
+	return appendOptFn(func(o *appendOpts) error {
+		if d <= 0 {
+			return errors.New("rita: ttl must be positive")
+		}
+		o.ttl = d
+		return nil
+	})
+}
+
+// EventStoreTypeTTL sets the time to live of the events of the type, such as
+// heartbeats or progress markers co-located with other events, unless an
+// expiry time or TTL is set on append.
+func EventStoreTypeTTL(eventType string, d time.Duration) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		if eventType == "" {
+			return errors.New("rita: ttl event type required")
+		}
+		if d <= 0 {
+			return errors.New("rita: ttl must be positive")
+		}
+		if o.typeTTL == nil {
+			o.typeTTL = make(map[string]time.Duration)
+		}
+		o.typeTTL[eventType] = d
+		return nil
+	})
+}
+
+// setExpires sets the expiry time of the event given the TTL of the append,
+// if any, or the type.
+func (s *EventStore) setExpires(e *Event, ttl time.Duration) error {
+	if e.Expires.IsZero() {
+		if ttl == 0 {
+			ttl = s.typeTTL[e.Type]
+		}
+		if ttl > 0 {
+			e.Expires = s.rt.clock.Now().Add(ttl)
+		}
+	}
+
+	// Deleting expired events would break the chain.
+	if !e.Expires.IsZero() && s.hashChain {
+		return errors.New("rita: events of a hash chained store cannot expire")
+	}
+	return nil
+}
+
+// expired returns true if the event expired at the time.
+func (e *Event) expired(t time.Time) bool {
+	return !e.Expires.IsZero() && !t.Before(e.Expires)
+}
+
+// Expire deletes the expired events of the store and returns the number of
+// events deleted. Only the headers of events are read. If the store is
+// causal, the last event of a subject is retained, though skipped by loads,
+// until another event is appended, so its clock is not reused. Checkpoints
+// of models which may have applied the deleted events are dropped. See
+// RunExpiry to delete expired events periodically.
+func (s *EventStore) Expire(ctx context.Context) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	if s.backend != nil {
+		return 0, ErrBackendUnsupported
+	}
+
+	if err := s.checkDeletes(ctx); err != nil {
+		return 0, err
+	}
+
+	now := s.rt.clock.Now()
+
+	var (
+		expired  []uint64
+		last     = make(map[string]uint64)
+		subjects = make(map[uint64]string)
+	)
+
+	lo := loadOpts{headersOnly: true}
+	_, err := s.readMsgs(ctx, s.filterSubject(), &lo, func(seq uint64, msg *nats.Msg) error {
+		last[msg.Subject] = seq
+
+		v := msg.Header.Get(eventExpiresHdr)
+		if v == "" {
+			return nil
+		}
+		t, err := time.Parse(eventTimeFormat, v)
+		if err != nil {
+			return fmt.Errorf("rita: sequence %d: invalid expires: %s", seq, err)
+		}
+		if !now.Before(t) {
+			expired = append(expired, seq)
+			subjects[seq] = contextUnsubject(s.context, msg.Subject)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The clock of a causal store is read from the last message of the
+	// subject, so the last event is retained until another is appended.
+	if s.causal {
+		tails := make(map[uint64]bool, len(last))
+		for _, seq := range last {
+			tails[seq] = true
+		}
+		n := 0
+		for _, seq := range expired {
+			if !tails[seq] {
+				expired[n] = seq
+				n++
+			}
+		}
+		expired = expired[:n]
+	}
+
+	// Checkpoints which may have applied the deleted events are dropped,
+	// including if deleting fails part way.
+	var (
+		dropped = make([]string, 0, len(expired))
+		seen    = make(map[string]bool)
+	)
+	defer func() {
+		_ = s.dropCheckpoints(dropped...)
+	}()
+
+	for i, seq := range expired {
+		if subject := subjects[seq]; !seen[subject] {
+			seen[subject] = true
+			dropped = append(dropped, subject)
+		}
+		if err := s.deleteEvent(ctx, seq); err != nil {
+			return i, err
+		}
+	}
+
+	return len(expired), nil
+}
+
+// deleteEvent deletes the messages of the event at the sequence.
+func (s *EventStore) deleteEvent(ctx context.Context, seq uint64) error {
+	msg, err := s.rt.js.GetMsg(s.stream, seq, nats.Context(ctx))
+	if err != nil {
+		return err
+	}
+
+	parts, err := s.chunkParts(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range parts {
+		if err := s.rt.js.DeleteMsg(s.stream, p.Sequence, nats.Context(ctx)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunExpiry calls Expire at the interval until the context is done or an
+// error occurs. It is typically run in a goroutine by one instance.
+func (s *EventStore) RunExpiry(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("rita: expiry interval must be positive")
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if _, err := s.Expire(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package rita
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+type testClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestEventTTL(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	clk := &testClock{t: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)}
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), Clock(clk))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreTypeTTL("order-shipped", time.Hour))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+	}, TTL(2*time.Hour))
+	is.NoErr(err)
+
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 3)
+	is.True(events[0].Expires.IsZero())
+	is.Equal(events[1].Expires, clk.Now().Add(time.Hour))
+
+	// Expired events are skipped before they are deleted.
+	clk.Add(time.Hour)
+	events, _, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+
+	clk.Add(time.Hour)
+	n, err := es.Expire(ctx)
+	is.NoErr(err)
+	is.Equal(n, 2)
+
+	events, _, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(events[0].Sequence, uint64(1))
+
+	n, err = es.Expire(ctx)
+	is.NoErr(err)
+	is.Equal(n, 0)
+
+	hc, err := r.EventStore("orders", EventStoreHashChain())
+	is.NoErr(err)
+	_, err = hc.Append(ctx, "orders.2", []*Event{{Data: &OrderPlaced{ID: "2"}}}, TTL(time.Hour), ExpectSequence(0))
+	is.Err(err, nil)
+}
+
+func TestEventTTLCausal(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	clk := &testClock{t: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)}
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), Clock(clk))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreCausal())
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	seq, err := es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	}, ExpectSequence(0), TTL(time.Hour))
+	is.NoErr(err)
+
+	// The last event is retained so its clock is not reused.
+	clk.Add(time.Hour)
+	n, err := es.Expire(ctx)
+	is.NoErr(err)
+	is.Equal(n, 1)
+
+	events, last, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 0)
+	is.Equal(last, seq)
+
+	e := &Event{Data: &OrderPlaced{ID: "1"}}
+	_, err = es.Append(ctx, "orders.1", []*Event{e}, ExpectSequence(seq))
+	is.NoErr(err)
+	is.Equal(e.Clock, VectorClock{"orders": 3})
+
+	n, err = es.Expire(ctx)
+	is.NoErr(err)
+	is.Equal(n, 1)
+}
+
+func TestEventTTLExecute(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	clk := &testClock{t: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)}
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)), Clock(clk))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders", EventStoreTypeTTL("order-shipped", time.Hour))
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	// The expired tail event is not applied, but its sequence is expected
+	// by the next append.
+	clk.Add(time.Hour)
+
+	var o Order
+	seq, err := es.Evolve(ctx, "orders.1", &o)
+	is.NoErr(err)
+	is.Equal(seq, uint64(2))
+	is.True(!o.Shipped)
+
+	events, seq, err := es.Execute(ctx, "orders.1", &Order{}, &Command{Data: &ShipOrder{ID: "1"}})
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+	is.Equal(seq, uint64(3))
+}
@@ -0,0 +1,268 @@
+package types
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	jsonSchemaFormat  = "jsonschema"
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Schemas returns a JSON Schema document for each registered type keyed by
+// the type name, derived from the Go type returned by Init. The schema
+// describes the value as encoded by encoding/json, so the json struct tags
+// determine the property names, fields tagged with omitempty are optional,
+// and all other fields are required. Nested named struct types are defined
+// under "$defs". Types implementing json.Marshaler are described as any value
+// since their encoding cannot be inferred. The catalog metadata of a type is
+// included as the description, examples, and the "x-owner" and "x-pii"
+// extension keywords.
+func (r *Registry) Schemas() (map[string]*Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schemas := make(map[string]*Schema, len(r.types))
+
+	for n, t := range r.types {
+		rt := reflect.TypeOf(t.Init())
+
+		g := &jsonSchemaGen{
+			root: rt.Elem(),
+			defs: make(map[string]any),
+			refs: make(map[reflect.Type]string),
+		}
+
+		doc := g.schema(rt)
+		doc["$schema"] = jsonSchemaDialect
+		doc["title"] = n
+		if len(g.defs) > 0 {
+			doc["$defs"] = g.defs
+		}
+		if err := catalogSchema(doc, t.Catalog); err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrMarshal, n, err)
+		}
+
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrMarshal, n, err)
+		}
+
+		schemas[n] = &Schema{
+			Format: jsonSchemaFormat,
+			Data:   b,
+		}
+	}
+
+	return schemas, nil
+}
+
+// catalogSchema adds the catalog metadata to the schema document.
+func catalogSchema(doc map[string]any, c *Catalog) error {
+	if c == nil {
+		return nil
+	}
+	if c.Description != "" {
+		doc["description"] = c.Description
+	}
+	if c.Owner != "" {
+		doc["x-owner"] = c.Owner
+	}
+	if c.PII != "" {
+		doc["x-pii"] = c.PII
+	}
+	if len(c.Examples) > 0 {
+		examples := make([]json.RawMessage, len(c.Examples))
+		for i, x := range c.Examples {
+			b, err := json.Marshal(x)
+			if err != nil {
+				return err
+			}
+			examples[i] = b
+		}
+		doc["examples"] = examples
+	}
+	return nil
+}
+
+// jsonSchemaGen generates the JSON Schema of a root type.
+type jsonSchemaGen struct {
+	root reflect.Type
+	defs map[string]any
+	refs map[reflect.Type]string
+}
+
+func (g *jsonSchemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return map[string]any{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+		// Byte slices are encoded as base64 strings.
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		s := map[string]any{
+			"type":  "array",
+			"items": g.schema(t.Elem()),
+		}
+		if t.Kind() == reflect.Array {
+			s["minItems"] = t.Len()
+			s["maxItems"] = t.Len()
+		}
+		return s
+
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": g.schema(t.Elem()),
+		}
+
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+
+	// Interfaces and other kinds accept any value.
+	return map[string]any{}
+}
+
+// structRef returns a reference to the definition of a named struct type,
+// adding the definition if needed. The root type is referenced as "#" and
+// anonymous structs are inlined.
+func (g *jsonSchemaGen) structRef(t reflect.Type) map[string]any {
+	if t == g.root {
+		if _, ok := g.refs[t]; !ok {
+			g.refs[t] = "#"
+			return g.object(t)
+		}
+		return map[string]any{"$ref": "#"}
+	}
+
+	if t.Name() == "" {
+		return g.object(t)
+	}
+
+	if ref, ok := g.refs[t]; ok {
+		return map[string]any{"$ref": ref}
+	}
+
+	// Disambiguate types with the same name in different packages.
+	name := t.Name()
+	for i := 2; g.defs[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", t.Name(), i)
+	}
+
+	ref := "#/$defs/" + name
+	g.refs[t] = ref
+	// Reserve the name before recursing for self-referencing types.
+	g.defs[name] = map[string]any{}
+	g.defs[name] = g.object(t)
+
+	return map[string]any{"$ref": ref}
+}
+
+// object returns the schema of the fields of a struct.
+func (g *jsonSchemaGen) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	g.fields(t, props, &required)
+
+	s := map[string]any{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *jsonSchemaGen) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// Fields of embedded structs without a name are promoted.
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, props, required)
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		var s map[string]any
+		if hasTagOpt(opts, "string") {
+			s = map[string]any{"type": "string"}
+		} else {
+			s = g.schema(f.Type)
+		}
+		props[name] = s
+
+		if !hasTagOpt(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func hasTagOpt(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
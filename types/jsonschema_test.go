@@ -0,0 +1,136 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+)
+
+type schemaAddress struct {
+	Street string `json:"street"`
+	Zip    string `json:"zip,omitempty"`
+}
+
+type schemaNode struct {
+	Name     string        `json:"name"`
+	Children []*schemaNode `json:"children,omitempty"`
+}
+
+type schemaBase struct {
+	ID string `json:"id"`
+}
+
+type schemaOrder struct {
+	schemaBase
+
+	Customer string            `json:"customer"`
+	Total    float64           `json:"total"`
+	Count    int64             `json:"count,string"`
+	Paid     bool              `json:"paid,omitempty"`
+	Placed   time.Time         `json:"placed"`
+	Raw      []byte            `json:"raw,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Shipping *schemaAddress    `json:"shipping,omitempty"`
+	Billing  schemaAddress     `json:"billing"`
+	Tree     schemaNode        `json:"tree"`
+	Any      any               `json:"any,omitempty"`
+	Ignored  string            `json:"-"`
+	NoTag    string
+	internal string
+}
+
+func TestRegistrySchemas(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	r, err := NewRegistry(map[string]*Type{
+		"order": {
+			Init: func() any { return &schemaOrder{} },
+		},
+	})
+	is.NoErr(err)
+
+	schemas, err := r.Schemas()
+	is.NoErr(err)
+	is.Equal(len(schemas), 1)
+
+	s := schemas["order"]
+	is.Equal(s.Format, "jsonschema")
+
+	var doc map[string]any
+	is.NoErr(json.Unmarshal(s.Data, &doc))
+
+	var expected map[string]any
+	is.NoErr(json.Unmarshal([]byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "order",
+		"type": "object",
+		"properties": {
+			"id": {"type": "string"},
+			"customer": {"type": "string"},
+			"total": {"type": "number"},
+			"count": {"type": "string"},
+			"paid": {"type": "boolean"},
+			"placed": {"type": "string", "format": "date-time"},
+			"raw": {"type": "string", "contentEncoding": "base64"},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"shipping": {"$ref": "#/$defs/schemaAddress"},
+			"billing": {"$ref": "#/$defs/schemaAddress"},
+			"tree": {"$ref": "#/$defs/schemaNode"},
+			"any": {},
+			"NoTag": {"type": "string"}
+		},
+		"required": ["id", "customer", "total", "count", "placed", "billing", "tree", "NoTag"],
+		"$defs": {
+			"schemaAddress": {
+				"type": "object",
+				"properties": {
+					"street": {"type": "string"},
+					"zip": {"type": "string"}
+				},
+				"required": ["street"]
+			},
+			"schemaNode": {
+				"type": "object",
+				"properties": {
+					"name": {"type": "string"},
+					"children": {"type": "array", "items": {"$ref": "#/$defs/schemaNode"}}
+				},
+				"required": ["name"]
+			}
+		}
+	}`), &expected))
+
+	is.Equal(doc, expected)
+}
+
+func TestRegistrySchemasCatalog(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	r, err := NewRegistry(map[string]*Type{
+		"address": {
+			Init: func() any { return &schemaAddress{} },
+			Catalog: &Catalog{
+				Description: "Postal address.",
+				Owner:       "shipping",
+				PII:         PIIPersonal,
+				Examples:    []any{&schemaAddress{Street: "1 Main St"}},
+			},
+		},
+	})
+	is.NoErr(err)
+
+	schemas, err := r.Schemas()
+	is.NoErr(err)
+
+	var doc map[string]any
+	is.NoErr(json.Unmarshal(schemas["address"].Data, &doc))
+
+	is.Equal(doc["description"], "Postal address.")
+	is.Equal(doc["x-owner"], "shipping")
+	is.Equal(doc["x-pii"], "personal")
+	is.Equal(doc["examples"], []any{map[string]any{"street": "1 Main St"}})
+}
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// NamespaceSep separates the namespace of a type name from the name within
+// the namespace, e.g. "orders/order-placed".
+const NamespaceSep = "/"
+
+// Namespace returns the types with names prefixed by the namespace, e.g.
+// the types of an aggregate, so they can be passed to NewRegistry along with
+// the types of other namespaces.
+func Namespace(namespace string, types map[string]*Type) map[string]*Type {
+	m := make(map[string]*Type, len(types))
+	for n, t := range types {
+		m[namespace+NamespaceSep+n] = t
+	}
+	return m
+}
+
+// SplitName splits a type name into its namespace and the name within the
+// namespace. The namespace is empty if the name is not namespaced.
+func SplitName(name string) (string, string) {
+	ns, n, ok := strings.Cut(name, NamespaceSep)
+	if !ok {
+		return "", name
+	}
+	return ns, n
+}
+
+// Scope returns a view of the registry with only the types of the namespace,
+// for example to ensure a decider only produces events of its aggregate.
+// Types are looked up by their full names, so data is encoded the same as
+// by the registry. Types registered after the view is created are not
+// included.
+func (r *Registry) Scope(namespace string) (*Registry, error) {
+	if err := validateTypeName(namespace); err != nil {
+		return nil, err
+	}
+	if strings.Contains(namespace, NamespaceSep) || strings.Contains(namespace, ".") {
+		return nil, fmt.Errorf("%w: namespace %q has invalid characters", ErrTypeNotValid, namespace)
+	}
+
+	v := &Registry{
+		codec:     r.codec,
+		types:     make(map[string]*Type),
+		rtypes:    make(map[reflect.Type]string),
+		rversions: make(map[reflect.Type]int),
+		naming:    r.naming,
+		strategy:  r.strategy,
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for n, t := range r.types {
+		if ns, _ := SplitName(n); ns == namespace {
+			v.types[n] = t
+		}
+	}
+	for rt, n := range r.rtypes {
+		if _, ok := v.types[n]; ok {
+			v.rtypes[rt] = n
+			if ver, ok := r.rversions[rt]; ok {
+				v.rversions[rt] = ver
+			}
+		}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+)
+
+func TestRegistryScope(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	type OrderPlaced struct{}
+	type CartCreated struct{}
+	type Tick struct{}
+
+	ty := Namespace("orders", map[string]*Type{
+		"order-placed": {Init: func() any { return &OrderPlaced{} }},
+	})
+	ty["carts/cart-created"] = &Type{Init: func() any { return &CartCreated{} }}
+	ty["tick"] = &Type{Init: func() any { return &Tick{} }}
+
+	r, err := NewRegistry(ty, Naming(NamingPolicy{Style: KebabStyle}))
+	is.NoErr(err)
+	is.Equal(r.Names(), []string{"carts/cart-created", "orders/order-placed", "tick"})
+
+	ns, n := SplitName("orders/order-placed")
+	is.Equal(ns, "orders")
+	is.Equal(n, "order-placed")
+
+	ns, n = SplitName("tick")
+	is.Equal(ns, "")
+	is.Equal(n, "tick")
+
+	v, err := r.Scope("orders")
+	is.NoErr(err)
+	is.Equal(v.Names(), []string{"orders/order-placed"})
+
+	n, err = v.Lookup(&OrderPlaced{})
+	is.NoErr(err)
+	is.Equal(n, "orders/order-placed")
+
+	_, err = v.Lookup(&CartCreated{})
+	is.Err(err, ErrNoTypeForStruct)
+
+	_, err = v.Marshal(&Tick{})
+	is.Err(err, ErrNoTypeForStruct)
+
+	_, err = r.Scope("orders/x")
+	is.Err(err, ErrTypeNotValid)
+
+	// Nested namespaces are not supported.
+	_, err = NewRegistry(map[string]*Type{
+		"a/b/c": {Init: func() any { return &Tick{} }},
+	})
+	is.Err(err, ErrTypeNotValid)
+}
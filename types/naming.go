@@ -0,0 +1,182 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// NameStyle is the style of the type names enforced by a naming policy.
+type NameStyle int
+
+const (
+	// AnyStyle allows any name with valid characters.
+	AnyStyle NameStyle = iota
+
+	// KebabStyle requires lower case words delimited by dashes, e.g.
+	// "order-placed".
+	KebabStyle
+
+	// DotStyle requires lower case words delimited by dots, e.g.
+	// "order.placed".
+	DotStyle
+)
+
+var (
+	kebabNameRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	dotNameRegex   = regexp.MustCompile(`^[a-z0-9]+(\.[a-z0-9]+)*$`)
+)
+
+// NamingPolicy defines the rules type names must follow in addition to
+// having valid characters, so conventions are enforced when types are
+// registered.
+type NamingPolicy struct {
+	// Prefix required of all names, e.g. "acme.". Names derived using
+	// DeriveNames are prefixed automatically.
+	Prefix string
+
+	// Style of the names, excluding the prefix. The prefix and style apply
+	// to the name within the namespace of namespaced names.
+	Style NameStyle
+
+	// MaxLength of the names, including the prefix. Zero means no limit.
+	MaxLength int
+}
+
+func (p *NamingPolicy) validate(name string) error {
+	if p.MaxLength > 0 && len(name) > p.MaxLength {
+		return fmt.Errorf("%w: name %q is longer than %d characters", ErrTypeNotValid, name, p.MaxLength)
+	}
+
+	// The rules apply to the name within the namespace, if any.
+	_, n := SplitName(name)
+
+	if !strings.HasPrefix(n, p.Prefix) {
+		return fmt.Errorf("%w: name %q must have prefix %q", ErrTypeNotValid, name, p.Prefix)
+	}
+	n = strings.TrimPrefix(n, p.Prefix)
+
+	switch p.Style {
+	case KebabStyle:
+		if !kebabNameRegex.MatchString(n) {
+			return fmt.Errorf("%w: name %q must be kebab-case", ErrTypeNotValid, name)
+		}
+	case DotStyle:
+		if !dotNameRegex.MatchString(n) {
+			return fmt.Errorf("%w: name %q must be dot-delimited", ErrTypeNotValid, name)
+		}
+	}
+
+	return nil
+}
+
+// Naming is a registry option to enforce a naming policy.
+func Naming(p NamingPolicy) RegistryOption {
+	return registryOption(func(o *Registry) error {
+		switch p.Style {
+		case AnyStyle, KebabStyle, DotStyle:
+		default:
+			return fmt.Errorf("rita: invalid name style %d", p.Style)
+		}
+		if p.MaxLength < 0 {
+			return fmt.Errorf("rita: max name length must be positive")
+		}
+		o.naming = &p
+		return nil
+	})
+}
+
+// NameStrategy derives a type name from a Go struct type.
+type NameStrategy func(t reflect.Type) string
+
+// KebabCase derives names from the words of the Go type name delimited by
+// dashes, e.g. OrderPlaced is named "order-placed".
+func KebabCase(t reflect.Type) string {
+	return strings.Join(nameWords(t.Name()), "-")
+}
+
+// DotCase derives names from the words of the Go type name delimited by
+// dots, e.g. OrderPlaced is named "order.placed".
+func DotCase(t reflect.Type) string {
+	return strings.Join(nameWords(t.Name()), ".")
+}
+
+// nameWords splits a Go identifier into lower case words, keeping acronyms
+// together, e.g. "HTTPRequestSent" is split into "http", "request", "sent".
+func nameWords(s string) []string {
+	var (
+		words []string
+		word  []rune
+	)
+
+	rs := []rune(s)
+	for i, r := range rs {
+		if r == '_' {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		}
+
+		if i > 0 && len(word) > 0 && unicode.IsUpper(r) {
+			prev := rs[i-1]
+			nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+
+		word = append(word, unicode.ToLower(r))
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+
+	return words
+}
+
+// DeriveNames is a registry option to derive the names of types added with
+// Register from their Go types using the strategy.
+func DeriveNames(s NameStrategy) RegistryOption {
+	return registryOption(func(o *Registry) error {
+		if s == nil {
+			return fmt.Errorf("rita: name strategy required")
+		}
+		o.strategy = s
+		return nil
+	})
+}
+
+// Register adds the type to the registry with the name derived by the
+// strategy set with DeriveNames and returns the name. Like Add, it is safe to
+// call concurrently with other methods of the registry.
+func (r *Registry) Register(typ *Type) (string, error) {
+	if r.strategy == nil {
+		return "", fmt.Errorf("%w: no name strategy", ErrTypeNotValid)
+	}
+	if typ.Init == nil {
+		return "", fmt.Errorf("%w: init func is nil", ErrTypeNotValid)
+	}
+
+	rt := reflect.TypeOf(typ.Init())
+	if rt == nil {
+		return "", fmt.Errorf("%w: init func returns nil", ErrTypeNotValid)
+	}
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+
+	name := r.strategy(rt)
+	if r.naming != nil {
+		name = r.naming.Prefix + name
+	}
+
+	if err := r.add(name, typ); err != nil {
+		return "", err
+	}
+	return name, nil
+}
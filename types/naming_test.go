@@ -0,0 +1,134 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bruth/rita/testutil"
+)
+
+type HTTPRequestSent struct{}
+
+type OrderPlaced struct{}
+
+func TestNameStrategies(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	tests := map[string][2]string{
+		"OrderPlaced":     {"order-placed", "order.placed"},
+		"HTTPRequestSent": {"http-request-sent", "http.request.sent"},
+		"Order_V2":        {"order-v2", "order.v2"},
+		"A":               {"a", "a"},
+	}
+
+	for n, x := range tests {
+		is.Equal(strings.Join(nameWords(n), "-"), x[0])
+		is.Equal(strings.Join(nameWords(n), "."), x[1])
+	}
+
+	is.Equal(KebabCase(reflect.TypeOf(OrderPlaced{})), "order-placed")
+	is.Equal(DotCase(reflect.TypeOf(HTTPRequestSent{})), "http.request.sent")
+}
+
+func TestNamingPolicy(t *testing.T) {
+	init := func() any { return &OrderPlaced{} }
+
+	tests := map[string]struct {
+		Policy NamingPolicy
+		Name   string
+		Err    bool
+	}{
+		"any": {
+			NamingPolicy{},
+			"Order_Placed",
+			false,
+		},
+		"prefix": {
+			NamingPolicy{Prefix: "acme."},
+			"acme.order-placed",
+			false,
+		},
+		"missing-prefix": {
+			NamingPolicy{Prefix: "acme."},
+			"order-placed",
+			true,
+		},
+		"kebab": {
+			NamingPolicy{Prefix: "acme.", Style: KebabStyle},
+			"acme.order-placed",
+			false,
+		},
+		"not-kebab": {
+			NamingPolicy{Style: KebabStyle},
+			"order.placed",
+			true,
+		},
+		"dot": {
+			NamingPolicy{Style: DotStyle},
+			"order.placed",
+			false,
+		},
+		"not-dot": {
+			NamingPolicy{Style: DotStyle},
+			"Order.Placed",
+			true,
+		},
+		"max-length": {
+			NamingPolicy{MaxLength: 5},
+			"order-placed",
+			true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewRegistry(map[string]*Type{
+				test.Name: {Init: init},
+			}, Naming(test.Policy))
+			if err != nil && !test.Err {
+				t.Errorf("unexpected error: %s", err)
+			} else if err == nil && test.Err {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	r, err := NewRegistry(nil)
+	is.NoErr(err)
+
+	_, err = r.Register(&Type{Init: func() any { return &OrderPlaced{} }})
+	is.Err(err, ErrTypeNotValid)
+
+	r, err = NewRegistry(nil,
+		Naming(NamingPolicy{Prefix: "acme.", Style: KebabStyle}),
+		DeriveNames(KebabCase),
+	)
+	is.NoErr(err)
+
+	n, err := r.Register(&Type{Init: func() any { return &OrderPlaced{} }})
+	is.NoErr(err)
+	is.Equal(n, "acme.order-placed")
+
+	n, err = r.Lookup(&OrderPlaced{})
+	is.NoErr(err)
+	is.Equal(n, "acme.order-placed")
+
+	// Already registered.
+	_, err = r.Register(&Type{Init: func() any { return &OrderPlaced{} }})
+	is.Err(err, ErrTypeNotValid)
+
+	// Derived names must follow the policy.
+	r, err = NewRegistry(nil,
+		Naming(NamingPolicy{Style: KebabStyle}),
+		DeriveNames(DotCase),
+	)
+	is.NoErr(err)
+
+	_, err = r.Register(&Type{Init: func() any { return &OrderPlaced{} }})
+	is.Err(err, ErrTypeNotValid)
+}
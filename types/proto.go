@@ -0,0 +1,70 @@
+package types
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// FromProtoFiles returns a registry of all messages defined in the files of
+// a compiled descriptor set, e.g. produced by `protoc --descriptor_set_out`,
+// using the protobuf codec. Messages are registered by their full proto name,
+// e.g. "shop.v1.OrderPlaced", and the schema of each type is the encoded file
+// descriptor defining it. The Go types of the messages must be linked into
+// the binary, i.e. the generated packages must be imported, since values are
+// initialized from the global protobuf type registry.
+func FromProtoFiles(set *descriptorpb.FileDescriptorSet, opts ...RegistryOption) (*Registry, error) {
+	types := make(map[string]*Type)
+
+	for _, fd := range set.GetFile() {
+		b, err := proto.Marshal(fd)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrTypeNotValid, fd.GetName(), err)
+		}
+		schema := &Schema{
+			Format: "protobuf",
+			Data:   b,
+		}
+
+		var add func(prefix string, msgs []*descriptorpb.DescriptorProto) error
+		add = func(prefix string, msgs []*descriptorpb.DescriptorProto) error {
+			for _, md := range msgs {
+				// Map entries are synthesized and have no Go type.
+				if md.GetOptions().GetMapEntry() {
+					continue
+				}
+
+				name := md.GetName()
+				if prefix != "" {
+					name = prefix + "." + name
+				}
+
+				mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name))
+				if err != nil {
+					return fmt.Errorf("%w: %s: message type not linked: %s", ErrTypeNotValid, name, err)
+				}
+
+				types[name] = &Type{
+					Init: func() any {
+						return mt.New().Interface()
+					},
+					Schema: schema,
+				}
+
+				if err := add(name, md.GetNestedType()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if err := add(fd.GetPackage(), fd.GetMessageType()); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewRegistry(types, append([]RegistryOption{Codec("protobuf")}, opts...)...)
+}
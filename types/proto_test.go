@@ -0,0 +1,53 @@
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bruth/rita/internal/pb"
+	"github.com/bruth/rita/testutil"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestFromProtoFiles(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(pb.File_types_proto),
+		},
+	}
+
+	r, err := FromProtoFiles(set)
+	is.NoErr(err)
+	is.Equal(r.Codec().Name(), "protobuf")
+	is.Equal(r.Names(), []string{"rita.A"})
+
+	n, err := r.Lookup(&pb.A{})
+	is.NoErr(err)
+	is.Equal(n, "rita.A")
+
+	b, err := r.Marshal(&pb.A{S: "foo"})
+	is.NoErr(err)
+
+	v, err := r.UnmarshalType(b, "rita.A")
+	is.NoErr(err)
+	is.Equal(v.(*pb.A).S, "foo")
+
+	s, err := r.Schema("rita.A")
+	is.NoErr(err)
+	is.Equal(s.Format, "protobuf")
+
+	// Messages without a linked Go type cannot be registered.
+	set.File = append(set.File, &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("other.proto"),
+		Package: proto.String("other"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Missing")},
+		},
+	})
+	_, err = FromProtoFiles(set)
+	is.True(errors.Is(err, ErrTypeNotValid))
+}
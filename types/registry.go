@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
+	"sync"
 
 	"github.com/bruth/rita/codec"
 )
@@ -16,7 +18,9 @@ var (
 	ErrMarshal           = errors.New("rita: marshal error")
 	ErrUnmarshal         = errors.New("rita: unmarshal error")
 
-	nameRegex = regexp.MustCompile(`^[\w-]+(\.[\w-]+)*$`)
+	ErrVersionNotRegistered = errors.New("rita: type version not registered")
+
+	nameRegex = regexp.MustCompile(`^([\w-]+/)?[\w-]+(\.[\w-]+)*$`)
 )
 
 func validateTypeName(n string) error {
@@ -26,11 +30,66 @@ func validateTypeName(n string) error {
 	return nil
 }
 
+// Schema describes the shape of a type in a format such as JSON Schema,
+// so the shape can be shared independently of the Go type.
+type Schema struct {
+	// Format of the schema data, e.g. "jsonschema" or "protobuf".
+	Format string `json:"format"`
+
+	// Data is the encoded schema.
+	Data []byte `json:"data"`
+}
+
+// PII is the classification of personally identifiable information in a
+// type. The constants are conventional values, but any classification
+// scheme can be used.
+type PII string
+
+const (
+	PIINone      PII = "none"
+	PIIPersonal  PII = "personal"
+	PIISensitive PII = "sensitive"
+)
+
+// Catalog describes a type for governance, such as in an event catalog or
+// generated documentation.
+type Catalog struct {
+	// Description of the type.
+	Description string
+
+	// Owner of the type, e.g. the owning team.
+	Owner string
+
+	// PII is the classification of personal data in the type.
+	PII PII
+
+	// Examples of values of the type, which must be of the type returned
+	// by Init.
+	Examples []any
+}
+
 type Type struct {
 	Init func() any
 
-	// TODO: support schema?
-	// Schema
+	// Schema of the type, if any.
+	Schema *Schema
+
+	// Catalog metadata of the type, if any.
+	Catalog *Catalog
+
+	// Version of the type returned by Init. Zero means the type is not
+	// versioned. Data without a recorded version is assumed to be the
+	// current version, so versioning should start at one before the
+	// shape of the type changes.
+	Version int
+
+	// Versions maps prior versions of the type to init functions, so data
+	// of every version remains addressable.
+	Versions map[int]func() any
+
+	// Migrate migrates a value of a prior version to the current version.
+	// It is required if prior versions are defined.
+	Migrate func(from int, v any) (any, error)
 }
 
 type registryOption func(o *Registry) error
@@ -63,11 +122,23 @@ type Registry struct {
 	// Codec for marshaling and unmarshaling a values.
 	codec codec.Codec
 
+	// mu guards the indexes of types, which can be added after construction.
+	mu sync.RWMutex
+
 	// Index of types.
 	types map[string]*Type
 
 	// Reflection type to the type name.
 	rtypes map[reflect.Type]string
+
+	// Reflection type to the version for prior versions of types.
+	rversions map[reflect.Type]int
+
+	// Naming policy of type names, if any.
+	naming *NamingPolicy
+
+	// Strategy to derive type names in Register, if any.
+	strategy NameStrategy
 }
 
 func (r *Registry) Codec() codec.Codec {
@@ -83,12 +154,50 @@ func (r *Registry) validate(name string, typ *Type) error {
 		return err
 	}
 
-	if typ.Init == nil {
+	if r.naming != nil {
+		if err := r.naming.validate(name); err != nil {
+			return err
+		}
+	}
+
+	if err := r.validateInit(name, typ.Init); err != nil {
+		return err
+	}
+
+	if typ.Catalog != nil {
+		rt := reflect.TypeOf(typ.Init())
+		for i, x := range typ.Catalog.Examples {
+			if reflect.TypeOf(x) != rt {
+				return fmt.Errorf("%w: %s: example %d has type %T, expected %s", ErrTypeNotValid, name, i, x, rt)
+			}
+		}
+	}
+
+	if len(typ.Versions) > 0 {
+		if typ.Migrate == nil {
+			return fmt.Errorf("%w: %s: migrate func is nil", ErrTypeNotValid, name)
+		}
+
+		for v, init := range typ.Versions {
+			if v < 1 || v >= typ.Version {
+				return fmt.Errorf("%w: %s: version %d must be between 1 and %d", ErrTypeNotValid, name, v, typ.Version)
+			}
+			if err := r.validateInit(fmt.Sprintf("%s@%d", name, v), init); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Registry) validateInit(name string, init func() any) error {
+	if init == nil {
 		return fmt.Errorf("%w: %s: init func is nil", ErrTypeNotValid, name)
 	}
 
 	// Ensure the initialize value is not nil.
-	v := typ.Init()
+	v := init()
 	if v == nil {
 		return fmt.Errorf("%w: %s: init func returns nil", ErrTypeNotValid, name)
 	}
@@ -120,6 +229,51 @@ func (r *Registry) validate(name string, typ *Type) error {
 	return nil
 }
 
+// Add validates and adds a type after the registry is constructed, for
+// example when a plugin registers its types at load time. The name and the
+// Go types of the type must not be registered already. It is safe to call
+// concurrently with other methods of the registry.
+func (r *Registry) Add(name string, typ *Type) error {
+	return r.add(name, typ)
+}
+
+// add validates and adds a type which must not be registered already.
+func (r *Registry) add(name string, typ *Type) error {
+	if err := r.validate(name, typ); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.types[name]; ok {
+		return fmt.Errorf("%w: %s: already registered", ErrTypeNotValid, name)
+	}
+
+	inits := []func() any{typ.Init}
+	for _, init := range typ.Versions {
+		inits = append(inits, init)
+	}
+	for _, init := range inits {
+		rt := reflect.TypeOf(init())
+		if n, ok := r.rtypes[rt]; ok {
+			return fmt.Errorf("%w: %s: Go type %s is registered as %s", ErrTypeNotValid, name, rt, n)
+		}
+	}
+
+	r.addType(name, typ)
+	return nil
+}
+
+// lookupType returns the registered type by name.
+func (r *Registry) lookupType(t string) (*Type, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	x, ok := r.types[t]
+	return x, ok
+}
+
 func (r *Registry) addType(name string, typ *Type) {
 	r.types[name] = typ
 
@@ -129,11 +283,122 @@ func (r *Registry) addType(name string, typ *Type) {
 
 	r.rtypes[rt] = name
 	r.rtypes[rt.Elem()] = name
+
+	for ver, init := range typ.Versions {
+		rt := reflect.TypeOf(init())
+		r.rtypes[rt] = name
+		r.rtypes[rt.Elem()] = name
+		r.rversions[rt] = ver
+		r.rversions[rt.Elem()] = ver
+	}
+}
+
+// Version returns the version of the type of the value, which may be a
+// prior version of a registered type.
+func (r *Registry) Version(v any) (int, error) {
+	rt := reflect.TypeOf(v)
+
+	r.mu.RLock()
+	ver, ok := r.rversions[rt]
+	r.mu.RUnlock()
+
+	if ok {
+		return ver, nil
+	}
+
+	t, err := r.Lookup(v)
+	if err != nil {
+		return 0, err
+	}
+	x, _ := r.lookupType(t)
+	return x.Version, nil
+}
+
+// InitVersion initializes a value of the version of the registered type.
+// A version of zero initializes the current version.
+func (r *Registry) InitVersion(t string, version int) (any, error) {
+	x, ok := r.lookupType(t)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTypeNotRegistered, t)
+	}
+
+	if version == 0 || version == x.Version {
+		return x.Init(), nil
+	}
+
+	init, ok := x.Versions[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s@%d", ErrVersionNotRegistered, t, version)
+	}
+	return init(), nil
+}
+
+// Migrate migrates a value of the version of the registered type to the
+// current version. A version of zero is the current version.
+func (r *Registry) Migrate(t string, version int, v any) (any, error) {
+	x, ok := r.lookupType(t)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTypeNotRegistered, t)
+	}
+
+	if version == 0 || version == x.Version {
+		return v, nil
+	}
+
+	if _, ok := x.Versions[version]; !ok {
+		return nil, fmt.Errorf("%w: %s@%d", ErrVersionNotRegistered, t, version)
+	}
+
+	return x.Migrate(version, v)
+}
+
+// Names returns the sorted names of the registered types.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.types))
+	for n := range r.types {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Schema returns the schema of the registered type, if any.
+func (r *Registry) Schema(t string) (*Schema, error) {
+	x, ok := r.lookupType(t)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTypeNotRegistered, t)
+	}
+	return x.Schema, nil
+}
+
+// SetSchema sets the schema of the registered type.
+func (r *Registry) SetSchema(t string, s *Schema) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	x, ok := r.types[t]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTypeNotRegistered, t)
+	}
+	x.Schema = s
+	return nil
+}
+
+// Catalog returns the catalog metadata of the registered type, if any.
+func (r *Registry) Catalog(t string) (*Catalog, error) {
+	x, ok := r.lookupType(t)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTypeNotRegistered, t)
+	}
+	return x.Catalog, nil
 }
 
 // Initialize a value given the registered name of the type.
 func (r *Registry) Init(t string) (any, error) {
-	x, ok := r.types[t]
+	x, ok := r.lookupType(t)
 	if !ok {
 		return nil, fmt.Errorf("%w: %s", ErrTypeNotRegistered, t)
 	}
@@ -145,7 +410,11 @@ func (r *Registry) Init(t string) (any, error) {
 // Lookup returns the registered name of the type given a value.
 func (r *Registry) Lookup(v any) (string, error) {
 	rt := reflect.TypeOf(v)
+
+	r.mu.RLock()
 	t, ok := r.rtypes[rt]
+	r.mu.RUnlock()
+
 	if !ok {
 		return "", fmt.Errorf("%w: %s", ErrNoTypeForStruct, rt)
 	}
@@ -199,9 +468,10 @@ func (r *Registry) UnmarshalType(b []byte, t string) (any, error) {
 
 func NewRegistry(types map[string]*Type, opts ...RegistryOption) (*Registry, error) {
 	r := &Registry{
-		codec:  codec.Default,
-		types:  make(map[string]*Type),
-		rtypes: make(map[reflect.Type]string),
+		codec:     codec.Default,
+		types:     make(map[string]*Type),
+		rtypes:    make(map[reflect.Type]string),
+		rversions: make(map[reflect.Type]int),
 	}
 
 	for _, f := range opts {
@@ -140,3 +140,177 @@ func BenchmarkLookup(b *testing.B) {
 		_, _ = r.Lookup(v)
 	}
 }
+
+func TestRegistrySchema(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	type A struct{}
+	type B struct{}
+
+	r, err := NewRegistry(map[string]*Type{
+		"b": {Init: func() any { return &B{} }},
+		"a": {Init: func() any { return &A{} }},
+	})
+	is.NoErr(err)
+
+	is.Equal(r.Names(), []string{"a", "b"})
+
+	s, err := r.Schema("a")
+	is.NoErr(err)
+	is.True(s == nil)
+
+	err = r.SetSchema("a", &Schema{Format: "jsonschema", Data: []byte(`{}`)})
+	is.NoErr(err)
+
+	s, err = r.Schema("a")
+	is.NoErr(err)
+	is.Equal(s.Format, "jsonschema")
+
+	err = r.SetSchema("c", &Schema{})
+	is.Err(err, ErrTypeNotRegistered)
+}
+
+func TestRegistryVersions(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	type V1 struct{ Name string }
+	type V2 struct{ First, Last string }
+
+	_, err := NewRegistry(map[string]*Type{
+		"a": {
+			Init:     func() any { return &V2{} },
+			Version:  2,
+			Versions: map[int]func() any{1: func() any { return &V1{} }},
+		},
+	})
+	is.Err(err, ErrTypeNotValid)
+
+	r, err := NewRegistry(map[string]*Type{
+		"a": {
+			Init:     func() any { return &V2{} },
+			Version:  2,
+			Versions: map[int]func() any{1: func() any { return &V1{} }},
+			Migrate: func(from int, v any) (any, error) {
+				return &V2{First: v.(*V1).Name}, nil
+			},
+		},
+	})
+	is.NoErr(err)
+
+	name, err := r.Lookup(&V1{})
+	is.NoErr(err)
+	is.Equal(name, "a")
+
+	ver, err := r.Version(&V1{})
+	is.NoErr(err)
+	is.Equal(ver, 1)
+
+	ver, err = r.Version(&V2{})
+	is.NoErr(err)
+	is.Equal(ver, 2)
+
+	v, err := r.InitVersion("a", 1)
+	is.NoErr(err)
+	v.(*V1).Name = "joe"
+
+	m, err := r.Migrate("a", 1, v)
+	is.NoErr(err)
+	is.Equal(m, &V2{First: "joe"})
+
+	_, err = r.InitVersion("a", 3)
+	is.Err(err, ErrVersionNotRegistered)
+}
+
+func TestRegistryCatalog(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	type A struct {
+		S string
+	}
+
+	c := &Catalog{
+		Description: "A value.",
+		Owner:       "core",
+		PII:         PIINone,
+		Examples:    []any{&A{S: "foo"}},
+	}
+
+	r, err := NewRegistry(map[string]*Type{
+		"a": {
+			Init:    func() any { return &A{} },
+			Catalog: c,
+		},
+		"b": {
+			Init: func() any { return &A{} },
+		},
+	})
+	is.NoErr(err)
+
+	x, err := r.Catalog("a")
+	is.NoErr(err)
+	is.Equal(x, c)
+
+	x, err = r.Catalog("b")
+	is.NoErr(err)
+	is.True(x == nil)
+
+	_, err = r.Catalog("c")
+	is.Err(err, ErrTypeNotRegistered)
+
+	// Examples must be of the type.
+	_, err = NewRegistry(map[string]*Type{
+		"a": {
+			Init: func() any { return &A{} },
+			Catalog: &Catalog{
+				Examples: []any{A{}},
+			},
+		},
+	})
+	is.Err(err, ErrTypeNotValid)
+}
+
+func TestRegistryAdd(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	type A struct{}
+	type B struct{}
+	type C struct {
+		C chan int
+	}
+
+	r, err := NewRegistry(map[string]*Type{
+		"a": {Init: func() any { return &A{} }},
+	})
+	is.NoErr(err)
+
+	// Concurrent lookups while adding.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, _ = r.Lookup(&B{})
+			_ = r.Names()
+		}
+	}()
+
+	is.NoErr(r.Add("b", &Type{Init: func() any { return &B{} }}))
+	<-done
+
+	n, err := r.Lookup(&B{})
+	is.NoErr(err)
+	is.Equal(n, "b")
+
+	// Name already registered.
+	err = r.Add("b", &Type{Init: func() any { return &A{} }})
+	is.Err(err, ErrTypeNotValid)
+
+	// Go type already registered.
+	err = r.Add("b2", &Type{Init: func() any { return &B{} }})
+	is.Err(err, ErrTypeNotValid)
+
+	// Types are validated.
+	err = r.Add("c", &Type{Init: func() any { return &C{} }})
+	is.Err(err, ErrTypeNotValid)
+
+	is.Equal(r.Names(), []string{"a", "b"})
+}
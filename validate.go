@@ -0,0 +1,92 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidEvent = errors.New("rita: invalid event")
+)
+
+// ValidationPolicy determines how invalid events are handled when loaded.
+type ValidationPolicy int
+
+const (
+	// ValidationFail fails the load with ErrInvalidEvent.
+	ValidationFail ValidationPolicy = iota + 1
+
+	// ValidationSkip skips invalid events.
+	ValidationSkip
+
+	// ValidationSideline moves invalid events out of the store into the
+	// KV bucket named "{stream}_sidelined", as ScanDuplicates does, and
+	// skips them. Checkpoints which may have applied the events are
+	// dropped. Invalid events of a read-only store are skipped.
+	ValidationSideline
+)
+
+// EventStoreValidateOnLoad validates the data of events implementing the
+// Validate or ValidateContext method when they are loaded, not only when they are appended, so
+// corrupted or hand-published events are surfaced when read. The policy
+// determines how invalid events are handled. Quarantine is not supported for
+// stores with a backend, a hash chain, or a disk cache, since the events
+// are deleted from the stream. Default is no validation on load.
+func EventStoreValidateOnLoad(policy ValidationPolicy) EventStoreOption {
+	return eventStoreOptFn(func(o *eventStoreOpts) error {
+		switch policy {
+		case ValidationFail, ValidationSkip, ValidationSideline:
+		default:
+			return fmt.Errorf("rita: invalid validation policy %d", policy)
+		}
+		o.loadValidation = policy
+		return nil
+	})
+}
+
+// validateData validates the data if it implements ValidateContext or
+// Validate, preferring ValidateContext.
+func validateData(ctx context.Context, data any) error {
+	switch v := data.(type) {
+	case contextValidator:
+		return v.ValidateContext(ctx)
+	case validator:
+		return v.Validate()
+	}
+	return nil
+}
+
+// validateLoaded validates a loaded event according to the policy of the
+// store and returns false if the event is skipped.
+func (s *EventStore) validateLoaded(ctx context.Context, e *Event) (bool, error) {
+	if s.loadValidation == 0 {
+		return true, nil
+	}
+
+	verr := validateData(ctx, e.Data)
+	if verr == nil {
+		return true, nil
+	}
+
+	switch s.loadValidation {
+	case ValidationSkip:
+		return false, nil
+
+	case ValidationSideline:
+		if s.readOnly {
+			return false, nil
+		}
+
+		kv, err := s.sidelineKV()
+		if err != nil {
+			return false, err
+		}
+		if err := s.sideline(ctx, kv, e.Sequence); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	return false, fmt.Errorf("%w: %s: sequence %d: %s", ErrInvalidEvent, e.Subject, e.Sequence, verr)
+}
@@ -0,0 +1,153 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/bruth/rita/types"
+	"github.com/nats-io/nats.go"
+)
+
+type CheckedOrder struct {
+	ID string
+}
+
+func (o *CheckedOrder) Validate() error {
+	if o.ID == "" {
+		return errors.New("id required")
+	}
+	return nil
+}
+
+func TestEventStoreValidateOnLoad(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+	js, _ := nc.JetStream()
+
+	tr, err := types.NewRegistry(map[string]*types.Type{
+		"checked-order": {
+			Init: func() any { return &CheckedOrder{} },
+		},
+	})
+	is.NoErr(err)
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	_, err = r.EventStore("orders", EventStoreValidateOnLoad(0))
+	is.Err(err, nil)
+
+	_, err = r.EventStore("orders", EventStoreValidateOnLoad(ValidationSideline), EventStoreHashChain())
+	is.Err(err, nil)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.Background()
+
+	// Invalid events cannot be appended.
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &CheckedOrder{}}})
+	is.Err(err, nil)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &CheckedOrder{ID: "1"}}})
+	is.NoErr(err)
+
+	// Hand-published invalid event.
+	msg := nats.NewMsg("orders.1")
+	msg.Header.Set(eventTypeHdr, "checked-order")
+	msg.Header.Set(eventCodecHdr, "json")
+	msg.Header.Set(eventTimeHdr, time.Now().Format(eventTimeFormat))
+	msg.Data = []byte(`{"ID":""}`)
+	_, err = js.PublishMsg(msg)
+	is.NoErr(err)
+
+	// Not validated by default.
+	events, _, err := es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 2)
+
+	fail, err := r.EventStore("orders", EventStoreValidateOnLoad(ValidationFail))
+	is.NoErr(err)
+	_, _, err = fail.Load(ctx, "orders.1")
+	is.Err(err, ErrInvalidEvent)
+
+	skip, err := r.EventStore("orders", EventStoreValidateOnLoad(ValidationSkip))
+	is.NoErr(err)
+	events, _, err = skip.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	sideline, err := r.EventStore("orders", EventStoreValidateOnLoad(ValidationSideline))
+	is.NoErr(err)
+	events, _, err = sideline.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	// The invalid event was moved out of the store.
+	events, _, err = es.Load(ctx, "orders.1")
+	is.NoErr(err)
+	is.Equal(len(events), 1)
+
+	kv, err := js.KeyValue("orders_sidelined")
+	is.NoErr(err)
+	_, err = kv.Get("2")
+	is.NoErr(err)
+}
+
+type tenantKey struct{}
+
+type TenantOrder struct {
+	Tenant string
+}
+
+func (o *TenantOrder) ValidateContext(ctx context.Context) error {
+	if t, _ := ctx.Value(tenantKey{}).(string); t != o.Tenant {
+		return errors.New("wrong tenant")
+	}
+	return nil
+}
+
+func TestValidateContext(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	tr, err := types.NewRegistry(map[string]*types.Type{
+		"tenant-order": {
+			Init: func() any { return &TenantOrder{} },
+		},
+	})
+	is.NoErr(err)
+
+	r, err := New(nc, TypeRegistry(tr))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &TenantOrder{Tenant: "acme"}}})
+	is.NoErr(err)
+
+	_, err = es.Append(ctx, "orders.1", []*Event{{Data: &TenantOrder{Tenant: "other"}}})
+	is.Err(err, nil)
+
+	_, err = r.packCommand(ctx, "orders.1", &Command{Data: &TenantOrder{Tenant: "other"}})
+	is.Err(err, nil)
+
+	_, err = r.packCommand(ctx, "orders.1", &Command{Data: &TenantOrder{Tenant: "acme"}})
+	is.NoErr(err)
+}
@@ -0,0 +1,484 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	workersBucket         = "rita-workers"
+	defaultWorkerTTL      = 10 * time.Second
+	defaultPartitions     = 16
+	defaultWorkerReplicas = 64
+)
+
+type workerOpts struct {
+	subject    string
+	partitions int
+	ttl        time.Duration
+	batch      int
+	retryDelay time.Duration
+}
+
+type workerOptFn func(o *workerOpts) error
+
+func (f workerOptFn) workerOpt(o *workerOpts) error {
+	return f(o)
+}
+
+// WorkerOption is an option for a projection worker.
+type WorkerOption interface {
+	workerOpt(o *workerOpts) error
+}
+
+// WorkerSubject sets the subject filter of the events projected. Default is
+// all subjects of the store.
+func WorkerSubject(subject string) WorkerOption {
+	return workerOptFn(func(o *workerOpts) error {
+		o.subject = subject
+		return nil
+	})
+}
+
+// WorkerPartitions sets the number of partitions subjects are sharded into,
+// which bounds the number of workers processing events concurrently. All
+// workers of a projection must use the same number of partitions. Default
+// is 16.
+func WorkerPartitions(n int) WorkerOption {
+	return workerOptFn(func(o *workerOpts) error {
+		if n < 1 {
+			return fmt.Errorf("worker: partitions must be positive")
+		}
+		o.partitions = n
+		return nil
+	})
+}
+
+// WorkerTTL sets the TTL of the membership of a worker and its partition
+// leases. A worker renews its membership at a third of the TTL, so the
+// partitions of a failed worker are reassigned within roughly one TTL. This
+// only applies when the workers bucket is first created, after which the TTL
+// of the bucket is used. Default is 10 seconds.
+func WorkerTTL(d time.Duration) WorkerOption {
+	return workerOptFn(func(o *workerOpts) error {
+		if d <= 0 {
+			return fmt.Errorf("worker: ttl must be positive")
+		}
+		o.ttl = d
+		return nil
+	})
+}
+
+// WorkerBatch sets the max number of events fetched at a time per partition.
+// Default is 100.
+func WorkerBatch(n int) WorkerOption {
+	return workerOptFn(func(o *workerOpts) error {
+		if n < 1 {
+			return fmt.Errorf("worker: batch must be positive")
+		}
+		o.batch = n
+		return nil
+	})
+}
+
+// WorkerRetryDelay sets the delay before an event is retried when the
+// handler returns an error. Default is 1 second.
+func WorkerRetryDelay(d time.Duration) WorkerOption {
+	return workerOptFn(func(o *workerOpts) error {
+		o.retryDelay = d
+		return nil
+	})
+}
+
+// hashRing is a consistent hash ring of workers, each placed at a number of
+// points so partitions are spread evenly and only the partitions of a worker
+// joining or leaving are moved.
+type hashRing struct {
+	points  []uint32
+	members map[uint32]string
+}
+
+func hash32(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
+
+func newHashRing(members []string, replicas int) *hashRing {
+	r := &hashRing{
+		members: make(map[uint32]string),
+	}
+	for _, m := range members {
+		for i := 0; i < replicas; i++ {
+			p := hash32(fmt.Sprintf("%s#%d", m, i))
+			// Resolve collisions deterministically.
+			if o, ok := r.members[p]; ok {
+				if m < o {
+					r.members[p] = m
+				}
+				continue
+			}
+			r.points = append(r.points, p)
+			r.members[p] = m
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i] < r.points[j]
+	})
+	return r
+}
+
+// owner returns the member owning the key or an empty string if the ring
+// has no members.
+func (r *hashRing) owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash32(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.members[r.points[i]]
+}
+
+// workerPartition is a partition processed by this worker.
+type workerPartition struct {
+	rev    uint64
+	sub    *Subscription
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// ProjectionWorker is one of a set of instances which process the events of
+// a store in parallel, such as to project wildcard or partitioned stores
+// into a read model. Subjects are hashed into a fixed number of partitions,
+// and the partitions are assigned to the live workers using consistent
+// hashing over a membership KV bucket. As workers join or leave, the
+// partitions are rebalanced. A partition is only processed by the worker
+// holding its lease, so the events of a subject are processed in order by
+// one worker at a time.
+//
+// Each partition is a durable consumer of all events matching the subject
+// filter which skips the events of other partitions, so the events are read
+// once per partition. Events fetched but not handled when a partition moves
+// are redelivered to the new owner after the ack wait.
+type ProjectionWorker struct {
+	es   *EventStore
+	name string
+	key  string
+	id   string
+	opts workerOpts
+
+	mu         sync.Mutex
+	partitions map[int]*workerPartition
+}
+
+// ProjectionWorker returns a worker of the projection with the name. The
+// name is used as the prefix of the durable consumer names of the
+// partitions and must be unique per store.
+func (s *EventStore) ProjectionWorker(name string, opts ...WorkerOption) (*ProjectionWorker, error) {
+	o := workerOpts{
+		subject:    s.filterSubject(),
+		partitions: defaultPartitions,
+		ttl:        defaultWorkerTTL,
+		batch:      100,
+		retryDelay: time.Second,
+	}
+
+	for _, opt := range opts {
+		if err := opt.workerOpt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if name == "" || strings.ContainsAny(name, ".*> ") {
+		return nil, fmt.Errorf("worker: invalid name %q", name)
+	}
+
+	return &ProjectionWorker{
+		es:         s,
+		name:       name,
+		key:        fmt.Sprintf("%s.%s", s.stream, s.durable(name)),
+		id:         s.rt.id.New(),
+		opts:       o,
+		partitions: make(map[int]*workerPartition),
+	}, nil
+}
+
+// ID returns the unique ID of this worker.
+func (w *ProjectionWorker) ID() string {
+	return w.id
+}
+
+// Partition returns the partition the subject deterministically maps to.
+func (w *ProjectionWorker) Partition(subject string) int {
+	return int(hash32(subject) % uint32(w.opts.partitions))
+}
+
+// Partitions returns the partitions currently processed by this worker.
+func (w *ProjectionWorker) Partitions() []int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ps := make([]int, 0, len(w.partitions))
+	for p := range w.partitions {
+		ps = append(ps, p)
+	}
+	sort.Ints(ps)
+	return ps
+}
+
+func (w *ProjectionWorker) memberKey(id string) string {
+	return fmt.Sprintf("%s.members.%s", w.key, id)
+}
+
+func (w *ProjectionWorker) partitionKey(p int) string {
+	return fmt.Sprintf("%s.partitions.%d", w.key, p)
+}
+
+func (r *Rita) workers(ttl time.Duration) (nats.KeyValue, error) {
+	bucket := r.resourceName(workersBucket)
+
+	kv, err := r.js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = r.js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:  bucket,
+			History: 1,
+			TTL:     ttl,
+		})
+	}
+	return kv, err
+}
+
+// members returns the IDs of the live workers.
+func (w *ProjectionWorker) members(kv nats.KeyValue) ([]string, error) {
+	watcher, err := kv.Watch(w.memberKey("*"), nats.IgnoreDeletes(), nats.MetaOnly())
+	if err != nil {
+		return nil, err
+	}
+	defer watcher.Stop() //nolint
+
+	prefix := w.memberKey("")
+
+	var ids []string
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+		ids = append(ids, strings.TrimPrefix(entry.Key(), prefix))
+	}
+	return ids, nil
+}
+
+// assigned returns the partitions assigned to this worker given the members.
+func (w *ProjectionWorker) assigned(members []string) map[int]bool {
+	ring := newHashRing(members, defaultWorkerReplicas)
+
+	ps := make(map[int]bool)
+	for p := 0; p < w.opts.partitions; p++ {
+		if ring.owner(fmt.Sprintf("partition-%d", p)) == w.id {
+			ps[p] = true
+		}
+	}
+	return ps
+}
+
+// start starts processing the partition, reporting a failure on the error
+// channel.
+func (w *ProjectionWorker) start(ctx context.Context, p int, rev uint64, handle func(ctx context.Context, event *Event) error, errc chan<- error) error {
+	sub, err := w.es.Subscription(
+		fmt.Sprintf("%s-%d", w.name, p),
+		SubscriptionSubject(w.opts.subject),
+		SubscriptionBatch(w.opts.batch),
+		SubscriptionRetryDelay(w.opts.retryDelay),
+	)
+	if err != nil {
+		return err
+	}
+
+	pctx, cancel := context.WithCancel(ctx)
+	wp := &workerPartition{
+		rev:    rev,
+		sub:    sub,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	w.mu.Lock()
+	w.partitions[p] = wp
+	w.mu.Unlock()
+
+	go func() {
+		defer close(wp.done)
+
+		err := fetchLoop(pctx, sub.sub, sub.opts.batch, func(msg *nats.Msg) error {
+			d, err := sub.delivery(pctx, msg)
+			if err != nil || d == nil {
+				return err
+			}
+
+			if w.Partition(d.Event.Subject) != p {
+				return d.Ack()
+			}
+
+			// A failed event is retried in place rather than redelivered,
+			// since events after it would be delivered in the meantime.
+			for handle(pctx, d.Event) != nil {
+				if err := d.InProgress(); err != nil {
+					return err
+				}
+				select {
+				case <-pctx.Done():
+					return nil
+				case <-time.After(sub.opts.retryDelay):
+				}
+			}
+			return d.Ack()
+		})
+		if err != nil {
+			select {
+			case errc <- fmt.Errorf("worker: partition %d: %w", p, err):
+			default:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// stop stops processing the partition and releases its lease if it is
+// still held.
+func (w *ProjectionWorker) stop(kv nats.KeyValue, p int, release bool) {
+	w.mu.Lock()
+	wp := w.partitions[p]
+	delete(w.partitions, p)
+	w.mu.Unlock()
+
+	wp.cancel()
+	<-wp.done
+	_ = wp.sub.Close()
+
+	if release {
+		_ = kv.Delete(w.partitionKey(p), nats.LastRevision(wp.rev))
+	}
+}
+
+// rebalance renews the membership of the worker and acquires or releases
+// partitions given the live members.
+func (w *ProjectionWorker) rebalance(ctx context.Context, kv nats.KeyValue, handle func(ctx context.Context, event *Event) error, errc chan<- error) error {
+	if _, err := kv.Put(w.memberKey(w.id), []byte(w.id)); err != nil {
+		return err
+	}
+
+	members, err := w.members(kv)
+	if err != nil {
+		return err
+	}
+
+	assigned := w.assigned(members)
+
+	w.mu.Lock()
+	owned := make(map[int]*workerPartition, len(w.partitions))
+	for p, wp := range w.partitions {
+		owned[p] = wp
+	}
+	w.mu.Unlock()
+
+	for p, wp := range owned {
+		if !assigned[p] {
+			w.stop(kv, p, true)
+			continue
+		}
+
+		rev, err := kv.Update(w.partitionKey(p), []byte(w.id), wp.rev)
+		if err != nil {
+			// The lease was lost, so stop processing and re-acquire it
+			// on the next rebalance.
+			w.stop(kv, p, false)
+			continue
+		}
+		wp.rev = rev
+	}
+
+	for p := range assigned {
+		if _, ok := owned[p]; ok {
+			continue
+		}
+
+		// The lease is held by the previous owner until it is released
+		// or expires.
+		rev, err := kv.Create(w.partitionKey(p), []byte(w.id))
+		if err != nil {
+			continue
+		}
+
+		if err := w.start(ctx, p, rev, handle, errc); err != nil {
+			_ = kv.Delete(w.partitionKey(p), nats.LastRevision(rev))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run joins the set of workers and passes each event of the partitions
+// assigned to this worker to the handler until the context is done. If the
+// handler returns nil, the event is acked, otherwise it is retried after the
+// retry delay. A failed event blocks its partition until it succeeds, so the
+// events of a subject are never processed out of order. When Run returns, the partitions are released and the
+// worker leaves the set so the partitions are reassigned immediately.
+func (w *ProjectionWorker) Run(ctx context.Context, handle func(ctx context.Context, event *Event) error) error {
+	if err := w.es.authorize(ctx, OpWatch, w.opts.subject, nil); err != nil {
+		return err
+	}
+
+	kv, err := w.es.rt.workers(w.opts.ttl)
+	if err != nil {
+		return err
+	}
+
+	interval, err := leaseInterval(kv)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, 1)
+
+	defer func() {
+		for _, p := range w.Partitions() {
+			w.stop(kv, p, true)
+		}
+		_ = kv.Delete(w.memberKey(w.id))
+	}()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := w.rebalance(ctx, kv, handle, errc); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errc:
+			return err
+		case <-t.C:
+		}
+	}
+}
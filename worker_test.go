@@ -0,0 +1,230 @@
+package rita
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bruth/rita/testutil"
+	"github.com/nats-io/nats.go"
+)
+
+func TestHashRing(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	is.Equal(newHashRing(nil, 8).owner("partition-0"), "")
+
+	owners := func(r *hashRing) map[string]string {
+		m := make(map[string]string)
+		for p := 0; p < 64; p++ {
+			k := fmt.Sprintf("partition-%d", p)
+			m[k] = r.owner(k)
+		}
+		return m
+	}
+
+	before := owners(newHashRing([]string{"a", "b", "c"}, defaultWorkerReplicas))
+	after := owners(newHashRing([]string{"a", "b", "c", "d"}, defaultWorkerReplicas))
+
+	// Only partitions moved to the new member change owners.
+	for k, o := range after {
+		if o != "d" {
+			is.Equal(o, before[k])
+		}
+	}
+}
+
+func TestProjectionWorker(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	_, err = es.ProjectionWorker("orders.view")
+	is.Err(err, nil)
+	_, err = es.ProjectionWorker("view", WorkerPartitions(0))
+	is.Err(err, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		handled = make(map[string]map[string]int)
+	)
+
+	handler := func(id string) func(ctx context.Context, event *Event) error {
+		return func(ctx context.Context, event *Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if handled[event.Subject] == nil {
+				handled[event.Subject] = make(map[string]int)
+			}
+			handled[event.Subject][id]++
+			return nil
+		}
+	}
+
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		n := 0
+		for _, ws := range handled {
+			for _, c := range ws {
+				n += c
+			}
+		}
+		return n
+	}
+
+	opts := []WorkerOption{WorkerPartitions(8), WorkerTTL(time.Second)}
+
+	w1, err := es.ProjectionWorker("view", opts...)
+	is.NoErr(err)
+	w2, err := es.ProjectionWorker("view", opts...)
+	is.NoErr(err)
+
+	go w1.Run(ctx, handler(w1.ID())) //nolint
+
+	ctx2, cancel2 := context.WithCancel(ctx)
+	done2 := make(chan struct{})
+	go func() {
+		defer close(done2)
+		w2.Run(ctx2, handler(w2.ID())) //nolint
+	}()
+
+	// Wait until the partitions are split between the workers.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if len(w1.Partitions())+len(w2.Partitions()) == 8 && len(w2.Partitions()) > 0 && len(w1.Partitions()) > 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	is.Equal(len(w1.Partitions())+len(w2.Partitions()), 8)
+
+	for i := 0; i < 20; i++ {
+		_, err := es.Append(ctx, fmt.Sprintf("orders.%d", i), []*Event{
+			{Data: &OrderPlaced{ID: fmt.Sprint(i)}},
+			{Data: &OrderShipped{ID: fmt.Sprint(i)}},
+		})
+		is.NoErr(err)
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for count() < 40 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	is.Equal(count(), 40)
+
+	// Each subject is processed by one worker.
+	mu.Lock()
+	for s, ws := range handled {
+		if len(ws) != 1 {
+			t.Errorf("subject %s processed by %d workers", s, len(ws))
+		}
+	}
+	mu.Unlock()
+
+	// The partitions of a worker leaving are reassigned.
+	cancel2()
+	<-done2
+
+	deadline = time.Now().Add(5 * time.Second)
+	for len(w1.Partitions()) < 8 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	is.Equal(len(w1.Partitions()), 8)
+
+	for i := 0; i < 20; i++ {
+		_, err := es.Append(ctx, fmt.Sprintf("orders.%d", i), []*Event{
+			{Data: &OrderShipped{ID: fmt.Sprint(i)}},
+		})
+		is.NoErr(err)
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for count() < 60 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	is.Equal(count(), 60)
+
+	mu.Lock()
+	for _, ws := range handled {
+		is.True(ws[w1.ID()] > 0)
+	}
+	mu.Unlock()
+}
+
+func TestProjectionWorkerRetryOrder(t *testing.T) {
+	is := testutil.NewIs(t)
+
+	srv := testutil.NewNatsServer(-1)
+	defer testutil.ShutdownNatsServer(srv)
+
+	nc, _ := nats.Connect(srv.ClientURL())
+
+	r, err := New(nc, TypeRegistry(newOrderTypes(t)))
+	is.NoErr(err)
+
+	es, err := r.EventStore("orders")
+	is.NoErr(err)
+	is.NoErr(es.Create(&EventStoreConfig{Storage: nats.MemoryStorage}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = es.Append(ctx, "orders.1", []*Event{
+		{Data: &OrderPlaced{ID: "1"}},
+		{Data: &OrderShipped{ID: "1"}},
+	})
+	is.NoErr(err)
+
+	w, err := es.ProjectionWorker("view", WorkerPartitions(1), WorkerTTL(time.Second), WorkerRetryDelay(100*time.Millisecond))
+	is.NoErr(err)
+
+	var (
+		mu     sync.Mutex
+		failed bool
+		seqs   []uint64
+	)
+
+	go w.Run(ctx, func(ctx context.Context, event *Event) error { //nolint
+		mu.Lock()
+		defer mu.Unlock()
+		if !failed {
+			failed = true
+			return errors.New("unavailable")
+		}
+		seqs = append(seqs, event.Sequence)
+		return nil
+	})
+
+	// The failed event is retried before the next event is handled.
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(seqs)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	is.Equal(seqs, []uint64{1, 2})
+}